@@ -51,8 +51,7 @@ var brokersListCmd = &cobra.Command{
                         })
                 }
 
-                formatter.OutputTable(headers, rows)
-                return nil
+                return formatter.OutputTable(headers, rows)
         },
 }
 
@@ -90,8 +89,7 @@ var brokersDescribeCmd = &cobra.Command{
                                 {"Host", broker.Host},
                                 {"Port", strconv.Itoa(int(broker.Port))},
                         }
-                        formatter.OutputTable(headers, rows)
-                        return nil
+                        return formatter.OutputTable(headers, rows)
                 }
 
                 return formatter.Output(broker)
@@ -176,7 +174,9 @@ func fetchAndDisplayMetrics(brokerHost string, metricsPort int, analyze bool, pr
         }
 
         metrics := parsePrometheusMetrics(resp)
-        displayMetrics(metrics)
+        if err := displayMetrics(metrics); err != nil {
+                return err
+        }
 
         // Perform AI analysis if requested
         if analyze {
@@ -350,10 +350,10 @@ func performAIAnalysis(metrics []ai.Metric, provider, model string) error {
 }
 
 // displayMetrics formats and displays the parsed metrics
-func displayMetrics(metrics []ai.Metric) {
+func displayMetrics(metrics []ai.Metric) error {
         if len(metrics) == 0 {
                 fmt.Println("No Kafka metrics found")
-                return
+                return nil
         }
 
         formatter := getFormatter()
@@ -374,7 +374,7 @@ func displayMetrics(metrics []ai.Metric) {
                 rows = append(rows, []string{metric.Name, labels, metric.Value})
         }
 
-        formatter.OutputTable(headers, rows)
+        return formatter.OutputTable(headers, rows)
 }
 
 // Broker config commands
@@ -413,8 +413,7 @@ var brokersConfigsListCmd = &cobra.Command{
                         }
                 }
 
-                formatter.OutputTable(headers, rows)
-                return nil
+                return formatter.OutputTable(headers, rows)
         },
 }
 
@@ -456,8 +455,7 @@ var brokersConfigsGetCmd = &cobra.Command{
                         rows = append(rows, []string{key, configs[key]})
                 }
 
-                formatter.OutputTable(headers, rows)
-                return nil
+                return formatter.OutputTable(headers, rows)
         },
 }
 
@@ -501,6 +499,7 @@ func init() {
         brokersCmd.AddCommand(brokersDescribeCmd)
         brokersCmd.AddCommand(brokersMetricsCmd)
         brokersCmd.AddCommand(brokersConfigsCmd)
+        brokersCmd.AddCommand(brokersBalanceCmd)
 
         // Add AI analysis flags to metrics command
         brokersMetricsCmd.Flags().BoolVarP(&metricsAnalyzeFlag, "analyze", "a", false, "Enable AI-powered analysis and recommendations")
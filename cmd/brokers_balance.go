@@ -0,0 +1,558 @@
+package cmd
+
+import (
+        "fmt"
+        "math"
+        "os"
+        "regexp"
+        "sort"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        kafkaClient "kafy/internal/kafka"
+        "kafy/internal/reassign"
+)
+
+// minSkewBytes keeps small topics out of the data skew analysis, like minSkewRecords when sizes are unknown
+const minSkewBytes = 1 << 20
+
+var brokersBalanceCmd = &cobra.Command{
+        Use:   "balance",
+        Short: "Analyse leader, replica and data balance across brokers and racks",
+        Long: `Report how evenly leaders, replicas and bytes (read with DescribeLogDirs) are spread over brokers
+and racks, with a score from 0 to 100 where 100 is perfectly even.
+
+Topics are flagged when a broker leads or hosts more of their partitions than an even spread
+allows, or when their largest partition holds --skew-threshold times the average (by bytes, or by
+records when log directory sizes are unavailable). The largest partition of each data-skewed topic
+is sampled (--sample records) to find the keys that make it hot.
+
+A proposed reassignment that evens out replicas and preferred leaders with as few replica moves as
+possible, keeping replicas on distinct racks, is included. Write it with --proposal-file; the
+file uses the kafka-reassign-partitions.sh format.
+
+Examples:
+  kafy brokers balance
+  kafy brokers balance --topics 'orders.*' --proposal-file rebalance.json
+  kafy brokers balance --sample 5000 --skew-threshold 1.5 --output json`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicsPattern, _ := cmd.Flags().GetString("topics")
+                sample, _ := cmd.Flags().GetInt("sample")
+                threshold, _ := cmd.Flags().GetFloat64("skew-threshold")
+                proposalFile, _ := cmd.Flags().GetString("proposal-file")
+                if threshold <= 1 {
+                        return fmt.Errorf("--skew-threshold must be greater than 1")
+                }
+
+                var topicFilter *regexp.Regexp
+                if topicsPattern != "" {
+                        var err error
+                        if topicFilter, err = anchoredRegexp(topicsPattern); err != nil {
+                                return fmt.Errorf("invalid --topics pattern: %w", err)
+                        }
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                brokers, err := client.ListBrokers()
+                if err != nil {
+                        return fmt.Errorf("failed to list brokers: %w", err)
+                }
+                all, err := client.ListTopicPartitions()
+                if err != nil {
+                        return fmt.Errorf("failed to list topics: %w", err)
+                }
+                var topics []kafkaClient.TopicInfo
+                for _, topic := range all {
+                        if topicFilter == nil || topicFilter.MatchString(topic.Name) {
+                                topics = append(topics, topic)
+                        }
+                }
+                sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+
+                racks, err := client.BrokerRacks()
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Warning: could not read broker racks: %v\n", err)
+                }
+
+                replicaSizes, failed := client.DescribeLogDirs(brokers)
+                for id, err := range failed {
+                        fmt.Fprintf(os.Stderr, "Warning: could not read the log directories of broker %d, sizes are incomplete: %v\n", id, err)
+                }
+                sizesKnown := len(failed) == 0
+
+                report := analyseBalance(brokers, racks, topics, replicaSizes, sizesKnown)
+                report.SkewedTopics = append(report.SkewedTopics, dataSkew(client, topics, report.partitionSizes, sizesKnown, threshold)...)
+                if sample > 0 {
+                        for _, skew := range report.SkewedTopics {
+                                if skew.Issue != "data" {
+                                        continue
+                                }
+                                keys, err := sampleHotKeys(client, skew.Topic, skew.partition, sample)
+                                if err != nil {
+                                        fmt.Fprintf(os.Stderr, "Warning: could not sample %s/%d: %v\n", skew.Topic, skew.partition, err)
+                                        continue
+                                }
+                                report.HotKeys = append(report.HotKeys, keys...)
+                        }
+                }
+
+                var current []reassign.Partition
+                var ids []int32
+                for _, topic := range topics {
+                        for _, p := range topic.PartitionDetails {
+                                current = append(current, reassign.Partition{Topic: topic.Name, Partition: p.ID, Replicas: p.Replicas})
+                        }
+                }
+                for _, broker := range brokers {
+                        ids = append(ids, broker.ID)
+                }
+                // The analysis is still useful when no balanced assignment exists
+                proposed, err := reassign.Balance(current, reassign.Options{Brokers: ids, Racks: racks, Sizes: report.partitionSizes})
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Warning: no reassignment proposed: %v\n", err)
+                } else {
+                        report.Proposal = reassign.NewPlan(reassign.Changed(current, proposed))
+                        report.ProposalMoves, report.ProposalBytes = reassign.Movement(current, proposed, report.partitionSizes)
+                }
+
+                if err := outputBalanceReport(report, proposalFile); err != nil {
+                        return err
+                }
+                if proposalFile != "" && report.Proposal != nil {
+                        if err := report.Proposal.WriteFile(proposalFile); err != nil {
+                                return err
+                        }
+                }
+                return nil
+        },
+}
+
+type balanceReport struct {
+        Score         float64            `json:"score"`
+        Dimensions    []balanceDimension `json:"dimensions"`
+        Brokers       []brokerBalance    `json:"brokers"`
+        Racks         []brokerBalance    `json:"racks,omitempty"`
+        SkewedTopics  []topicSkew        `json:"skewed_topics"`
+        HotKeys       []hotKey           `json:"hot_keys,omitempty"`
+        Proposal      *reassign.Plan     `json:"proposal"` // Nil when no reassignment could be proposed
+        ProposalMoves int                `json:"proposal_replica_moves"`
+        ProposalBytes int64              `json:"proposal_bytes_moved"`
+
+        partitionSizes map[reassign.Key]int64
+}
+
+// balanceDimension measures how evenly one quantity is spread over brokers
+type balanceDimension struct {
+        Name      string  `json:"name"`
+        Min       float64 `json:"min"`
+        Max       float64 `json:"max"`
+        Mean      float64 `json:"mean"`
+        Imbalance float64 `json:"imbalance_pct"` // How far the busiest broker is above the mean
+        Score     float64 `json:"score"`
+}
+
+// brokerBalance is the load of a broker, or of a rack when Rack is set and Broker is -1
+type brokerBalance struct {
+        Broker   int32  `json:"broker"`
+        Rack     string `json:"rack,omitempty"`
+        Brokers  int    `json:"brokers,omitempty"`
+        Leaders  int    `json:"leaders"`
+        Replicas int    `json:"replicas"`
+        Bytes    int64  `json:"bytes"`
+}
+
+type topicSkew struct {
+        Topic  string `json:"topic"`
+        Issue  string `json:"issue"` // leaders, replicas or data
+        Detail string `json:"detail"`
+
+        partition int32 // Largest partition, for data skew
+}
+
+type hotKey struct {
+        Topic     string  `json:"topic"`
+        Partition int32   `json:"partition"`
+        Key       string  `json:"key"`
+        Share     float64 `json:"share_pct"`
+        Sampled   int     `json:"sampled"`
+}
+
+func analyseBalance(brokers []kafkaClient.BrokerInfo, racks map[int32]string, topics []kafkaClient.TopicInfo, replicaSizes []kafkaClient.ReplicaLogDir, sizesKnown bool) *balanceReport {
+        report := &balanceReport{partitionSizes: make(map[reassign.Key]int64)}
+
+        load := make(map[int32]*brokerBalance)
+        for _, broker := range brokers {
+                load[broker.ID] = &brokerBalance{Broker: broker.ID, Rack: racks[broker.ID]}
+        }
+        analysed := make(map[string]bool)
+        for _, topic := range topics {
+                analysed[topic.Name] = true
+        }
+        for _, r := range replicaSizes {
+                // Log directories list every topic; count only those matching --topics
+                if r.Future || !analysed[r.Topic] {
+                        continue
+                }
+                if b, ok := load[r.Broker]; ok {
+                        b.Bytes += r.Size
+                }
+                key := reassign.Key{Topic: r.Topic, Partition: r.Partition}
+                if r.Size > report.partitionSizes[key] {
+                        report.partitionSizes[key] = r.Size
+                }
+        }
+
+        for _, topic := range topics {
+                leaders := make(map[int32]int)
+                replicas := make(map[int32]int)
+                total := 0
+                for _, p := range topic.PartitionDetails {
+                        if b, ok := load[p.Leader]; ok {
+                                b.Leaders++
+                                leaders[p.Leader]++
+                        }
+                        for _, r := range p.Replicas {
+                                if b, ok := load[r]; ok {
+                                        b.Replicas++
+                                }
+                                replicas[r]++
+                                total++
+                        }
+                }
+
+                partitions := len(topic.PartitionDetails)
+                if partitions < 2 || len(brokers) < 2 {
+                        continue
+                }
+                evenLeaders := (partitions + len(brokers) - 1) / len(brokers)
+                if broker, count := busiest(leaders); count > evenLeaders {
+                        report.SkewedTopics = append(report.SkewedTopics, topicSkew{
+                                Topic:  topic.Name,
+                                Issue:  "leaders",
+                                Detail: fmt.Sprintf("broker %d leads %d of %d partitions (even spread: at most %d)", broker, count, partitions, evenLeaders),
+                        })
+                }
+                evenReplicas := (total + len(brokers) - 1) / len(brokers)
+                if broker, count := busiest(replicas); count > evenReplicas {
+                        report.SkewedTopics = append(report.SkewedTopics, topicSkew{
+                                Topic:  topic.Name,
+                                Issue:  "replicas",
+                                Detail: fmt.Sprintf("broker %d hosts %d of %d replicas (even spread: at most %d)", broker, count, total, evenReplicas),
+                        })
+                }
+        }
+
+        for _, b := range load {
+                report.Brokers = append(report.Brokers, *b)
+        }
+        sort.Slice(report.Brokers, func(i, j int) bool { return report.Brokers[i].Broker < report.Brokers[j].Broker })
+
+        if len(racks) > 0 {
+                byRack := make(map[string]*brokerBalance)
+                for _, b := range report.Brokers {
+                        rack := b.Rack
+                        if rack == "" {
+                                rack = "(none)"
+                        }
+                        if byRack[rack] == nil {
+                                byRack[rack] = &brokerBalance{Broker: -1, Rack: rack}
+                        }
+                        r := byRack[rack]
+                        r.Brokers++
+                        r.Leaders += b.Leaders
+                        r.Replicas += b.Replicas
+                        r.Bytes += b.Bytes
+                }
+                for _, r := range byRack {
+                        report.Racks = append(report.Racks, *r)
+                }
+                sort.Slice(report.Racks, func(i, j int) bool { return report.Racks[i].Rack < report.Racks[j].Rack })
+        }
+
+        values := func(get func(brokerBalance) float64) []float64 {
+                var v []float64
+                for _, b := range report.Brokers {
+                        v = append(v, get(b))
+                }
+                return v
+        }
+        report.Dimensions = append(report.Dimensions,
+                newBalanceDimension("leaders", values(func(b brokerBalance) float64 { return float64(b.Leaders) })),
+                newBalanceDimension("replicas", values(func(b brokerBalance) float64 { return float64(b.Replicas) })),
+        )
+        if sizesKnown {
+                report.Dimensions = append(report.Dimensions, newBalanceDimension("bytes", values(func(b brokerBalance) float64 { return float64(b.Bytes) })))
+        }
+        var total float64
+        for _, d := range report.Dimensions {
+                total += d.Score
+        }
+        report.Score = math.Round(total / float64(len(report.Dimensions)))
+        return report
+}
+
+// newBalanceDimension scores a spread: 100 when every broker carries the mean,
+// falling to 0 as the busiest broker reaches twice the mean
+func newBalanceDimension(name string, values []float64) balanceDimension {
+        d := balanceDimension{Name: name, Score: 100}
+        if len(values) == 0 {
+                return d
+        }
+        d.Min, d.Max = values[0], values[0]
+        var sum float64
+        for _, v := range values {
+                sum += v
+                d.Min = math.Min(d.Min, v)
+                d.Max = math.Max(d.Max, v)
+        }
+        d.Mean = sum / float64(len(values))
+        if d.Mean > 0 {
+                excess := (d.Max - d.Mean) / d.Mean
+                d.Imbalance = math.Round(excess*1000) / 10
+                d.Score = math.Round(100 * math.Max(0, 1-excess))
+        }
+        d.Mean = math.Round(d.Mean*10) / 10
+        return d
+}
+
+func busiest(counts map[int32]int) (int32, int) {
+        broker, most := int32(-1), 0
+        for id, count := range counts {
+                if count > most || (count == most && id < broker) {
+                        broker, most = id, count
+                }
+        }
+        return broker, most
+}
+
+// dataSkew flags topics whose largest partition holds threshold times the
+// average, by bytes when sizes are known and by records otherwise
+func dataSkew(client *kafkaClient.Client, topics []kafkaClient.TopicInfo, sizes map[reassign.Key]int64, sizesKnown bool, threshold float64) []topicSkew {
+        var consumer *kafka.Consumer
+        if !sizesKnown {
+                var err error
+                consumer, err = client.CreateConsumerWithOptions(fmt.Sprintf("kafy-balance-%d", time.Now().Unix()), kafkaClient.ConsumerOptions{
+                        OffsetReset:       "earliest",
+                        DisableAutoCommit: true,
+                })
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Warning: failed to create consumer, skipping data skew: %v\n", err)
+                        return nil
+                }
+                defer consumer.Close()
+        }
+
+        var skewed []topicSkew
+        for _, topic := range topics {
+                if len(topic.PartitionDetails) < 2 {
+                        continue
+                }
+                var total, largest int64
+                largestPartition := int32(-1)
+                complete := true
+                for _, p := range topic.PartitionDetails {
+                        var amount int64
+                        if sizesKnown {
+                                amount = sizes[reassign.Key{Topic: topic.Name, Partition: p.ID}]
+                        } else {
+                                low, high, err := consumer.QueryWatermarkOffsets(topic.Name, p.ID, 5000)
+                                if err != nil {
+                                        complete = false
+                                        break
+                                }
+                                amount = high - low
+                        }
+                        total += amount
+                        if amount > largest {
+                                largest, largestPartition = amount, p.ID
+                        }
+                }
+                if !complete || (sizesKnown && total < minSkewBytes) || (!sizesKnown && total < minSkewRecords) {
+                        continue
+                }
+
+                average := float64(total) / float64(len(topic.PartitionDetails))
+                skew := float64(largest) / average
+                if skew < threshold {
+                        continue
+                }
+                detail := fmt.Sprintf("partition %d holds %.1fx the average: %d of %d records", largestPartition, skew, largest, total)
+                if sizesKnown {
+                        detail = fmt.Sprintf("partition %d holds %.1fx the average: %s of %s", largestPartition, skew, formatBytes(largest), formatBytes(total))
+                }
+                skewed = append(skewed, topicSkew{Topic: topic.Name, Issue: "data", Detail: detail, partition: largestPartition})
+        }
+        return skewed
+}
+
+// sampleHotKeys reads the latest records of a partition and returns the keys
+// that make up at least 5% of them, most frequent first
+func sampleHotKeys(client *kafkaClient.Client, topic string, partition int32, sample int) ([]hotKey, error) {
+        consumer, err := client.CreateConsumerWithOptions(fmt.Sprintf("kafy-balance-sample-%d", time.Now().UnixNano()), kafkaClient.ConsumerOptions{
+                OffsetReset:       "earliest",
+                DisableAutoCommit: true,
+        })
+        if err != nil {
+                return nil, err
+        }
+        defer consumer.Close()
+
+        low, high, err := consumer.QueryWatermarkOffsets(topic, partition, 5000)
+        if err != nil {
+                return nil, err
+        }
+        start := high - int64(sample)
+        if start < low {
+                start = low
+        }
+        if err := consumer.Assign([]kafka.TopicPartition{{Topic: &topic, Partition: partition, Offset: kafka.Offset(start)}}); err != nil {
+                return nil, err
+        }
+
+        counts := make(map[string]int)
+        read := 0
+        deadline := time.Now().Add(30 * time.Second)
+        for read < sample && time.Now().Before(deadline) {
+                msg, err := consumer.ReadMessage(time.Second)
+                if err != nil {
+                        if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+                                continue
+                        }
+                        return nil, err
+                }
+                key := "(null)"
+                if msg.Key != nil {
+                        key = strconv.Quote(string(msg.Key))
+                        if len(key) > 64 {
+                                key = key[:61] + "..."
+                        }
+                }
+                counts[key]++
+                read++
+                if int64(msg.TopicPartition.Offset) >= high-1 {
+                        break
+                }
+        }
+        if read == 0 {
+                return nil, nil
+        }
+
+        var keys []hotKey
+        for key, count := range counts {
+                share := float64(count) * 100 / float64(read)
+                if share >= 5 {
+                        keys = append(keys, hotKey{Topic: topic, Partition: partition, Key: key, Share: math.Round(share*10) / 10, Sampled: read})
+                }
+        }
+        sort.Slice(keys, func(i, j int) bool { return keys[i].Share > keys[j].Share })
+        return keys, nil
+}
+
+func outputBalanceReport(report *balanceReport, proposalFile string) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                return formatter.Output(report)
+        }
+
+        fmt.Printf("Balance score: %.0f/100\n\n", report.Score)
+        var rows [][]string
+        for _, d := range report.Dimensions {
+                format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+                if d.Name == "bytes" {
+                        format = func(v float64) string { return formatBytes(int64(v)) }
+                }
+                rows = append(rows, []string{d.Name, format(d.Min), format(d.Max), format(d.Mean), fmt.Sprintf("%.1f%%", d.Imbalance), fmt.Sprintf("%.0f", d.Score)})
+        }
+        if err := formatter.OutputTable([]string{"Dimension", "Min", "Max", "Mean", "Imbalance", "Score"}, rows); err != nil {
+                return err
+        }
+
+        fmt.Println()
+        rows = nil
+        for _, b := range report.Brokers {
+                rows = append(rows, []string{fmt.Sprintf("%d", b.Broker), dashIfEmpty(b.Rack), fmt.Sprintf("%d", b.Leaders), fmt.Sprintf("%d", b.Replicas), formatBytes(b.Bytes)})
+        }
+        if err := formatter.OutputTable([]string{"Broker", "Rack", "Leaders", "Replicas", "Size"}, rows); err != nil {
+                return err
+        }
+
+        if len(report.Racks) > 0 {
+                fmt.Println()
+                rows = nil
+                for _, r := range report.Racks {
+                        rows = append(rows, []string{r.Rack, fmt.Sprintf("%d", r.Brokers), fmt.Sprintf("%d", r.Leaders), fmt.Sprintf("%d", r.Replicas), formatBytes(r.Bytes)})
+                }
+                if err := formatter.OutputTable([]string{"Rack", "Brokers", "Leaders", "Replicas", "Size"}, rows); err != nil {
+                        return err
+                }
+        }
+
+        fmt.Println()
+        if len(report.SkewedTopics) == 0 {
+                fmt.Println("No skewed topics found")
+        } else {
+                rows = nil
+                for _, s := range report.SkewedTopics {
+                        rows = append(rows, []string{s.Topic, s.Issue, s.Detail})
+                }
+                if err := formatter.OutputTable([]string{"Topic", "Issue", "Detail"}, rows); err != nil {
+                        return err
+                }
+        }
+
+        if len(report.HotKeys) > 0 {
+                fmt.Println()
+                rows = nil
+                for _, k := range report.HotKeys {
+                        rows = append(rows, []string{k.Topic, fmt.Sprintf("%d", k.Partition), k.Key, fmt.Sprintf("%.1f%%", k.Share), fmt.Sprintf("%d", k.Sampled)})
+                }
+                if err := formatter.OutputTable([]string{"Topic", "Partition", "Hot Key", "Share", "Sampled"}, rows); err != nil {
+                        return err
+                }
+        }
+
+        if report.Proposal == nil {
+                return nil
+        }
+        fmt.Println()
+        if len(report.Proposal.Partitions) == 0 {
+                fmt.Println("Replicas and preferred leaders are already balanced; no reassignment proposed")
+                return nil
+        }
+        fmt.Printf("Proposed reassignment: %d partitions, %d replica moves", len(report.Proposal.Partitions), report.ProposalMoves)
+        if report.ProposalBytes > 0 {
+                fmt.Printf(" (%s to copy)", formatBytes(report.ProposalBytes))
+        }
+        if proposalFile != "" {
+                fmt.Printf(", written to %s\n", proposalFile)
+        } else {
+                fmt.Println(", use --proposal-file to save it")
+        }
+        return nil
+}
+
+func dashIfEmpty(s string) string {
+        if strings.TrimSpace(s) == "" {
+                return "-"
+        }
+        return s
+}
+
+func init() {
+        brokersBalanceCmd.Flags().String("topics", "", "Only analyse topics matching this regular expression")
+        brokersBalanceCmd.Flags().Int("sample", 1000, "Records to sample from each data-skewed topic to find hot keys (0 to disable)")
+        brokersBalanceCmd.Flags().Float64("skew-threshold", 2, "Flag topics whose largest partition holds this many times the average")
+        brokersBalanceCmd.Flags().String("proposal-file", "", "Write the proposed reassignment to this file (kafka-reassign-partitions.sh format)")
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
         "fmt"
+        "kafy/internal/decode"
         kafkaClient "kafy/internal/kafka"
+        "kafy/internal/mirror"
+        "kafy/internal/output"
         
         "github.com/spf13/cobra"
 )
@@ -114,5 +117,23 @@ func completeBrokerIDs(cmd *cobra.Command, args []string, toComplete string) ([]
 
 // completeOutputFormats provides completion for output formats
 func completeOutputFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-        return []string{"table", "json", "yaml"}, cobra.ShellCompDirectiveNoFileComp
-}
\ No newline at end of file
+        return output.FormatNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeDecoders provides completion for --key-decoder and --value-decoder
+func completeDecoders(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+        return decode.Names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeMirrors provides completion for mirror names
+func completeMirrors(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+        states, err := mirror.List()
+        if err != nil {
+                return nil, cobra.ShellCompDirectiveNoFileComp
+        }
+        var names []string
+        for _, state := range states {
+                names = append(names, state.Name)
+        }
+        return names, cobra.ShellCompDirectiveNoFileComp
+}
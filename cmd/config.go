@@ -56,8 +56,7 @@ var configListCmd = &cobra.Command{
                         rows = append(rows, []string{name, cluster.Bootstrap, zookeeper, metricsPort, current})
                 }
 
-                formatter.OutputTable(headers, rows)
-                return nil
+                return formatter.OutputTable(headers, rows)
         },
 }
 
@@ -102,8 +101,7 @@ var configCurrentCmd = &cobra.Command{
                                 rows = append(rows, []string{"Metrics Port", "-"})
                         }
                         
-                        formatter.OutputTable(headers, rows)
-                        return nil
+                        return formatter.OutputTable(headers, rows)
                 } else {
                         // For JSON/YAML, use structured output
                         currentConfig := struct {
@@ -375,7 +373,13 @@ var configExportCmd = &cobra.Command{
                 if format == "table" {
                         format = "yaml"
                 }
-                formatter := output.NewFormatter(format)
+                if format != string(output.FormatJSON) && format != string(output.FormatYAML) {
+                        return fmt.Errorf("config export only supports json or yaml output, got '%s'", format)
+                }
+                formatter, err := output.NewFormatter(format)
+                if err != nil {
+                        return err
+                }
                 return formatter.Output(cfg)
         },
 }
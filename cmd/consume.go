@@ -1,16 +1,20 @@
 package cmd
 
 import (
+        "encoding/hex"
         "encoding/json"
         "fmt"
         "os"
         "os/signal"
+        "strconv"
         "strings"
         "syscall"
         "time"
 
         "github.com/confluentinc/confluent-kafka-go/v2/kafka"
         "github.com/spf13/cobra"
+        "gopkg.in/yaml.v3"
+        "kafy/internal/decode"
         kafkaClient "kafy/internal/kafka"
 )
 
@@ -25,9 +29,13 @@ var consumeCmd = &cobra.Command{
                 fromBeginning, _ := cmd.Flags().GetBool("from-beginning")
                 fromLatest, _ := cmd.Flags().GetBool("from-latest")
                 limit, _ := cmd.Flags().GetInt("limit")
-                output, _ := cmd.Flags().GetString("output")
                 keyFilter, _ := cmd.Flags().GetString("key-filter")
-                noValue, _ := cmd.Flags().GetBool("no-value")
+                isolation, _ := cmd.Flags().GetString("isolation")
+
+                printOpts, err := newMessagePrintOptions(cmd)
+                if err != nil {
+                        return err
+                }
 
                 // Validate conflicting flags
                 if fromBeginning && fromLatest {
@@ -59,12 +67,24 @@ var consumeCmd = &cobra.Command{
                         offsetReset = "earliest" // default
                 }
                 
-                consumer, err := client.CreateConsumerWithOffset(group, offsetReset)
+                consumer, err := client.CreateConsumerWithOptions(group, kafkaClient.ConsumerOptions{
+                        OffsetReset:    offsetReset,
+                        IsolationLevel: isolation,
+                })
                 if err != nil {
                         return err
                 }
                 defer consumer.Close()
 
+                if isolation == kafkaClient.ReadUncommitted {
+                        classifier, err := newTransactionClassifier(client)
+                        if err != nil {
+                                return err
+                        }
+                        defer classifier.Close()
+                        printOpts.Transactions = classifier
+                }
+
                 // Subscribe to topics
                 err = consumer.SubscribeTopics(topicNames, nil)
                 if err != nil {
@@ -77,15 +97,15 @@ var consumeCmd = &cobra.Command{
 
                 messageCount := 0
                 if len(topicNames) == 1 {
-                        fmt.Printf("Consuming from topic '%s' (group: %s). Press Ctrl+C to exit.\n", topicNames[0], group)
+                        fmt.Fprintf(os.Stderr, "Consuming from topic '%s' (group: %s). Press Ctrl+C to exit.\n", topicNames[0], group)
                 } else {
-                        fmt.Printf("Consuming from %d topics: %s (group: %s). Press Ctrl+C to exit.\n", len(topicNames), strings.Join(topicNames, ", "), group)
+                        fmt.Fprintf(os.Stderr, "Consuming from %d topics: %s (group: %s). Press Ctrl+C to exit.\n", len(topicNames), strings.Join(topicNames, ", "), group)
                 }
 
                 for {
                         select {
                         case sig := <-sigChan:
-                                fmt.Printf("\nCaught signal %v: cleaning up and terminating\n", sig)
+                                fmt.Fprintf(os.Stderr, "\nCaught signal %v: cleaning up and terminating\n", sig)
                                 // Close consumer first to leave the group
                                 consumer.Close()
                                 // Wait a moment for the group to become empty
@@ -94,7 +114,7 @@ var consumeCmd = &cobra.Command{
                                 originalGroup, _ := cmd.Flags().GetString("group")
                                 if originalGroup == "" { // Only delete if group was auto-generated
                                         if err := client.DeleteConsumerGroup(group); err != nil {
-                                                fmt.Printf("Warning: Failed to delete consumer group '%s': %v\n", group, err)
+                                                fmt.Fprintf(os.Stderr, "Warning: Failed to delete consumer group '%s': %v\n", group, err)
                                         }
                                 }
                                 return nil
@@ -110,22 +130,45 @@ var consumeCmd = &cobra.Command{
                                         return fmt.Errorf("consumer error: %w", err)
                                 }
 
-                                if msg != nil {
-                                        // Apply key filtering if specified
-                                        if keyFilter != "" {
-                                                messageKey := string(msg.Key)
-                                                if !matchesKeyFilter(messageKey, keyFilter) {
-                                                        continue // Skip this message
+                                batch := []*kafka.Message{msg}
+                                if printOpts.Transactions != nil {
+                                        // Classify what is already fetched together, so the shadow consumer catches up once per batch
+                                        size := transactionBatchSize
+                                        if limit > 0 && limit-messageCount < size {
+                                                size = limit - messageCount
+                                        }
+                                        if batch, err = readBuffered(consumer, batch, size); err != nil {
+                                                return fmt.Errorf("consumer error: %w", err)
+                                        }
+                                }
+
+                                // Apply key filtering if specified
+                                if keyFilter != "" {
+                                        matched := batch[:0]
+                                        for _, msg := range batch {
+                                                if matchesKeyFilter(messageKeyText(msg, printOpts), keyFilter) {
+                                                        matched = append(matched, msg)
                                                 }
                                         }
-                                        
-                                        if err := printMessage(msg, output, noValue); err != nil {
+                                        batch = matched
+                                }
+                                var txnStates []string
+                                if printOpts.Transactions != nil {
+                                        txnStates = printOpts.Transactions.Classify(batch)
+                                }
+
+                                for i, msg := range batch {
+                                        txnState := ""
+                                        if txnStates != nil {
+                                                txnState = txnStates[i]
+                                        }
+                                        if err := printMessage(msg, txnState, printOpts); err != nil {
                                                 fmt.Printf("Error formatting message: %v\n", err)
                                         }
-                                        
+
                                         messageCount++
                                         if limit > 0 && messageCount >= limit {
-                                                fmt.Printf("\nReached limit of %d messages\n", limit)
+                                                fmt.Fprintf(os.Stderr, "\nReached limit of %d messages\n", limit)
                                                 return nil
                                         }
                                 }
@@ -134,8 +177,91 @@ var consumeCmd = &cobra.Command{
         },
 }
 
-func printMessage(msg *kafka.Message, outputFormat string, hideValue bool) error {
-        switch outputFormat {
+// messagePrintOptions controls how consumed messages are rendered
+type messagePrintOptions struct {
+        Format       string
+        HideValue    bool
+        KeyDecoder   decode.Decoder
+        ValueDecoder decode.Decoder
+        Transactions *transactionClassifier // Set when reading uncommitted records, to mark aborted/open transactions
+}
+
+// newMessagePrintOptions reads the output and decoder flags shared by consume and tail
+func newMessagePrintOptions(cmd *cobra.Command) (*messagePrintOptions, error) {
+        format, _ := cmd.Flags().GetString("output")
+        noValue, _ := cmd.Flags().GetBool("no-value")
+        keyDecoderSpec, _ := cmd.Flags().GetString("key-decoder")
+        valueDecoderSpec, _ := cmd.Flags().GetString("value-decoder")
+
+        keyDecoder, err := decode.Parse(keyDecoderSpec)
+        if err != nil {
+                return nil, fmt.Errorf("invalid --key-decoder: %w", err)
+        }
+        valueDecoder, err := decode.Parse(valueDecoderSpec)
+        if err != nil {
+                return nil, fmt.Errorf("invalid --value-decoder: %w", err)
+        }
+
+        return &messagePrintOptions{
+                Format:       format,
+                HideValue:    noValue,
+                KeyDecoder:   keyDecoder,
+                ValueDecoder: valueDecoder,
+        }, nil
+}
+
+// decodedField is a message key or value after running it through a decoder
+type decodedField struct {
+        Null  bool        // The record carried no key/value at all (null key or tombstone)
+        Value interface{} // Decoded value, or the hex bytes if decoding failed
+        Err   error
+}
+
+func decodeField(decoder decode.Decoder, data []byte) decodedField {
+        if data == nil {
+                return decodedField{Null: true}
+        }
+        value, err := decoder.Decode(data)
+        if err != nil {
+                return decodedField{Value: hex.EncodeToString(data), Err: err}
+        }
+        return decodedField{Value: value}
+}
+
+// Text renders the field for the line-oriented formats, using nullLabel for absent data
+func (f decodedField) Text(pretty bool, nullLabel string) string {
+        if f.Null {
+                return nullLabel
+        }
+        if f.Err != nil {
+                return fmt.Sprintf("<decode error: %v> hex:%s", f.Err, f.Value)
+        }
+        return decode.Format(f.Value, pretty)
+}
+
+// JSON returns the field for structured output; absent data becomes null
+func (f decodedField) JSON() interface{} {
+        if f.Null {
+                return nil
+        }
+        return f.Value
+}
+
+// messageKeyText returns the decoded key used for --key-filter matching
+func messageKeyText(msg *kafka.Message, opts *messagePrintOptions) string {
+        if msg.Key == nil {
+                return ""
+        }
+        return decodeField(opts.KeyDecoder, msg.Key).Text(false, "")
+}
+
+// printMessage renders a record; txnState is its transaction state when reading uncommitted records
+func printMessage(msg *kafka.Message, txnState string, opts *messagePrintOptions) error {
+        key := decodeField(opts.KeyDecoder, msg.Key)
+        value := decodeField(opts.ValueDecoder, msg.Value)
+        tombstone := msg.Value == nil
+
+        switch opts.Format {
         case "json":
                 // Convert headers to map
                 headers := make(map[string]string)
@@ -147,12 +273,22 @@ func printMessage(msg *kafka.Message, outputFormat string, hideValue bool) error
                         "topic":     *msg.TopicPartition.Topic,
                         "partition": msg.TopicPartition.Partition,
                         "offset":    msg.TopicPartition.Offset,
-                        "key":       string(msg.Key),
+                        "key":       key.JSON(),
                         "headers":   headers,
                         "timestamp": msg.Timestamp,
+                        "tombstone": tombstone,
+                }
+                if key.Err != nil {
+                        msgData["key_decode_error"] = key.Err.Error()
                 }
-                if !hideValue {
-                        msgData["value"] = string(msg.Value)
+                if txnState != "" {
+                        msgData["transaction"] = txnState
+                }
+                if !opts.HideValue {
+                        msgData["value"] = value.JSON()
+                        if value.Err != nil {
+                                msgData["value_decode_error"] = value.Err.Error()
+                        }
                 }
 
                 encoder := json.NewEncoder(os.Stdout)
@@ -164,15 +300,19 @@ func printMessage(msg *kafka.Message, outputFormat string, hideValue bool) error
                 fmt.Printf("topic: %s\n", *msg.TopicPartition.Topic)
                 fmt.Printf("partition: %d\n", msg.TopicPartition.Partition)
                 fmt.Printf("offset: %d\n", msg.TopicPartition.Offset)
-                fmt.Printf("key: %s\n", string(msg.Key))
+                fmt.Printf("key: %s\n", yamlScalar(key))
                 if len(msg.Headers) > 0 {
                         fmt.Printf("headers:\n")
                         for _, header := range msg.Headers {
                                 fmt.Printf("  %s: %s\n", header.Key, string(header.Value))
                         }
                 }
-                if !hideValue {
-                        fmt.Printf("value: %s\n", string(msg.Value))
+                if !opts.HideValue {
+                        fmt.Printf("value: %s\n", yamlScalar(value))
+                }
+                fmt.Printf("tombstone: %t\n", tombstone)
+                if txnState != "" {
+                        fmt.Printf("transaction: %s\n", txnState)
                 }
                 fmt.Printf("timestamp: %s\n", msg.Timestamp.Format(time.RFC3339))
                 
@@ -183,12 +323,17 @@ func printMessage(msg *kafka.Message, outputFormat string, hideValue bool) error
                         *msg.TopicPartition.Topic,
                         msg.TopicPartition.Partition,
                         msg.TopicPartition.Offset)
+                if txnState != "" {
+                        fmt.Printf("Transaction: %s\n", txnState)
+                }
                 
-                if len(msg.Key) > 0 {
+                if msg.Key == nil {
+                        fmt.Printf("Key: <null>\n")
+                } else if len(msg.Key) == 0 {
+                        fmt.Printf("Key: <empty>\n")
+                } else {
                         fmt.Printf("Key (hex):\n")
                         printHexDump(msg.Key)
-                } else {
-                        fmt.Printf("Key: <null>\n")
                 }
 
                 if len(msg.Headers) > 0 {
@@ -198,12 +343,14 @@ func printMessage(msg *kafka.Message, outputFormat string, hideValue bool) error
                         }
                 }
 
-                if !hideValue {
+                if tombstone {
+                        fmt.Printf("Value: <tombstone>\n")
+                } else if !opts.HideValue {
                         if len(msg.Value) > 0 {
                                 fmt.Printf("Value (hex):\n")
                                 printHexDump(msg.Value)
                         } else {
-                                fmt.Printf("Value: <null>\n")
+                                fmt.Printf("Value: <empty>\n")
                         }
                 }
                 fmt.Printf("\n")
@@ -217,30 +364,59 @@ func printMessage(msg *kafka.Message, outputFormat string, hideValue bool) error
                         }
                         headerStr = fmt.Sprintf(", Headers: {%s}", strings.Join(headerPairs, ", "))
                 }
+                txnStr := ""
+                if txnState != "" {
+                        txnStr = fmt.Sprintf(" [%s]", txnState)
+                }
 
-                if hideValue {
-                        fmt.Printf("[%s] Topic: %s, Partition: %d, Offset: %d, Key: %s%s\n",
+                if opts.HideValue {
+                        tombstoneStr := ""
+                        if tombstone {
+                                tombstoneStr = " <tombstone>"
+                        }
+                        fmt.Printf("[%s] Topic: %s, Partition: %d, Offset: %d%s, Key: %s%s%s\n",
                                 msg.Timestamp.Format("15:04:05"),
                                 *msg.TopicPartition.Topic,
                                 msg.TopicPartition.Partition,
                                 msg.TopicPartition.Offset,
-                                string(msg.Key),
-                                headerStr)
+                                txnStr,
+                                key.Text(false, "<null>"),
+                                headerStr,
+                                tombstoneStr)
                 } else {
-                        fmt.Printf("[%s] Topic: %s, Partition: %d, Offset: %d, Key: %s%s, Value: %s\n",
+                        fmt.Printf("[%s] Topic: %s, Partition: %d, Offset: %d%s, Key: %s%s, Value: %s\n",
                                 msg.Timestamp.Format("15:04:05"),
                                 *msg.TopicPartition.Topic,
                                 msg.TopicPartition.Partition,
                                 msg.TopicPartition.Offset,
-                                string(msg.Key),
+                                txnStr,
+                                key.Text(false, "<null>"),
                                 headerStr,
-                                string(msg.Value))
+                                value.Text(true, "<tombstone>"))
                 }
         }
         
         return nil
 }
 
+// yamlScalar renders a decoded field as a YAML value: null stays null, strings
+// are quoted when needed and structured values use JSON flow style
+func yamlScalar(field decodedField) string {
+        if field.Null {
+                return "null"
+        }
+        if field.Err != nil {
+                return strconv.Quote(field.Text(false, ""))
+        }
+        if text, ok := field.Value.(string); ok {
+                raw, err := yaml.Marshal(text)
+                if err == nil {
+                        return strings.TrimSuffix(string(raw), "\n")
+                }
+        }
+        return decode.Format(field.Value, false)
+}
+
 // printHexDump prints data in hex dump format similar to hexdump -C
 func printHexDump(data []byte) {
         const bytesPerLine = 16
@@ -319,4 +495,11 @@ func init() {
         consumeCmd.Flags().String("output", "table", "Output format (table, json, yaml, hex)")
         consumeCmd.Flags().String("key-filter", "", "Filter messages by key (supports wildcards: *, prefix*, *suffix, *contains*)")
         consumeCmd.Flags().Bool("no-value", false, "Hide message values from output")
+        consumeCmd.Flags().String("isolation", kafkaClient.ReadCommitted, "Isolation level: read_committed or read_uncommitted (marks records from aborted and open transactions)")
+        consumeCmd.Flags().String("key-decoder", "string", "Decode message keys as: "+decode.Usage)
+        consumeCmd.Flags().String("value-decoder", "string", "Decode message values as: "+decode.Usage)
+
+        consumeCmd.RegisterFlagCompletionFunc("isolation", cobra.FixedCompletions([]string{kafkaClient.ReadCommitted, kafkaClient.ReadUncommitted}, cobra.ShellCompDirectiveNoFileComp))
+        consumeCmd.RegisterFlagCompletionFunc("key-decoder", completeDecoders)
+        consumeCmd.RegisterFlagCompletionFunc("value-decoder", completeDecoders)
 }
\ No newline at end of file
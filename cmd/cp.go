@@ -4,6 +4,9 @@ import (
         "fmt"
         "os"
         "os/signal"
+        "strings"
+        "sync"
+        "sync/atomic"
         "syscall"
         "time"
 
@@ -12,19 +15,42 @@ import (
         kafkaClient "kafy/internal/kafka"
 )
 
+// maxProgressRows is the most partitions shown one per line in the progress display
+const maxProgressRows = 16
+
 var cpCmd = &cobra.Command{
         Use:   "cp <source-topic> <destination-topic>",
         Short: "Copy messages from one topic to another",
         Long: `Copy messages from a source topic to a destination topic.
 This command will consume messages from the source topic and produce them to the destination topic,
-preserving keys, values, and headers.
+preserving keys, values, and headers. With --transform, records can be masked, filtered, re-keyed or
+routed to other topics on the way; records a step fails on go to --dead-letter-file.
+
+Source partitions are split across --workers, each with its own consumer. Every source partition is
+copied by one worker in offset order and the producer is idempotent, so records with the same key
+keep their order. At most --max-in-flight records wait for delivery at a time. Failed sends are
+retried by the producer itself, up to --retries times within --delivery-timeout, which keeps the
+order of each partition; records that still fail stop the copy, or go to --dead-letter-file when it
+is set. With --idempotent=false, retries can reorder records.
+
+--replay-timing copies with one worker that merges the source partitions by record timestamp, so
+the gaps between records are kept across partitions.
 
 Examples:
   kafy cp orders orders-backup
   kafy cp --from-beginning user-events user-events-copy
   kafy cp --limit 1000 transactions transactions-test
   kafy cp orders backup --begin-offset 100 --end-offset 500
-  kafy cp events archive --begin-offset 1000`,
+  kafy cp events archive --begin-offset 1000
+  kafy cp big-topic big-topic-copy --from-beginning --workers 12 --max-in-flight 50000
+  kafy cp incident-capture staging-orders --from-beginning --replay-timing --speed 10x --timestamps shift
+  kafy cp orders orders-load --from-beginning --rate 500/s
+  kafy cp orders orders-dev --from-beginning --transform mask:.card.number:4 --transform hash:.customer.email:file:hash.key
+  kafy cp events events-by-region --transform 'drop:.type == heartbeat' --transform 'route:events-{.region}'
+  kafy cp orders orders-rekeyed --transform key-from:customer.id --transform rename-header:trace=traceparent
+  kafy cp orders orders-clean --transform 'exec:python3 scrub.py' --dead-letter-file scrub-errors.ndjson
+
+` + transformHelp(),
         Args:              cobra.ExactArgs(2),
         ValidArgsFunction: completeTopics,
         RunE: func(cmd *cobra.Command, args []string) error {
@@ -34,7 +60,64 @@ Examples:
                 limit, _ := cmd.Flags().GetInt("limit")
                 beginOffset, _ := cmd.Flags().GetInt64("begin-offset")
                 endOffset, _ := cmd.Flags().GetInt64("end-offset")
-                
+                workers, _ := cmd.Flags().GetInt("workers")
+                maxInFlight, _ := cmd.Flags().GetInt("max-in-flight")
+                retries, _ := cmd.Flags().GetInt("retries")
+                deliveryTimeout, _ := cmd.Flags().GetDuration("delivery-timeout")
+                idempotent, _ := cmd.Flags().GetBool("idempotent")
+                deadLetterFile, _ := cmd.Flags().GetString("dead-letter-file")
+
+                if workers < 1 {
+                        return fmt.Errorf("--workers must be at least 1")
+                }
+                if maxInFlight < 1 || maxInFlight > 100000 {
+                        return fmt.Errorf("--max-in-flight must be between 1 and 100000")
+                }
+                if retries < 0 {
+                        return fmt.Errorf("--retries cannot be negative")
+                }
+                if retries == 0 && idempotent {
+                        return fmt.Errorf("the idempotent producer needs --retries of at least 1")
+                }
+                if deliveryTimeout <= 0 {
+                        return fmt.Errorf("--delivery-timeout must be positive")
+                }
+                if !cmd.Flags().Changed("begin-offset") {
+                        beginOffset = -1
+                }
+                if !cmd.Flags().Changed("end-offset") {
+                        endOffset = -1
+                }
+
+                replay, err := newReplayer(cmd)
+                if err != nil {
+                        return err
+                }
+                if replay.timing {
+                        // Replaying the original timing needs the records in one stream,
+                        // merged across partitions by timestamp
+                        if cmd.Flags().Changed("workers") && workers > 1 {
+                                return fmt.Errorf("--replay-timing copies with a single worker")
+                        }
+                        workers = 1
+                }
+
+                deadLetter, err := openDeadLetterFile(deadLetterFile)
+                if err != nil {
+                        return err
+                }
+                if deadLetter != nil {
+                        defer deadLetter.Close()
+                }
+
+                transformer, err := newRecordTransformer(cmd, false, deadLetter)
+                if err != nil {
+                        return err
+                }
+                if transformer != nil {
+                        defer transformer.Close()
+                }
+
                 cfg, err := LoadConfigWithClusterOverride()
                 if err != nil {
                         return err
@@ -45,153 +128,595 @@ Examples:
                         return err
                 }
 
-                // Generate unique group ID for this copy operation
-                group := fmt.Sprintf("kafy-cp-%d", time.Now().Unix())
-
-                // Create consumer
-                var offsetReset string
-                if fromBeginning {
-                        offsetReset = "earliest"
-                } else {
-                        offsetReset = "latest"
+                c := &copyRun{
+                        client:      client,
+                        sourceTopic: sourceTopic,
+                        destTopic:   destTopic,
+                        endOffset:   endOffset,
+                        limit:       int64(limit),
+                        replay:      replay,
+                        transformer: transformer,
+                        deadLetter:  deadLetter,
+                        inFlight:    make(chan struct{}, maxInFlight),
+                        stop:        make(chan struct{}),
+                }
+                if err := c.plan(workers, fromBeginning, beginOffset); err != nil {
+                        return err
                 }
+                defer c.closeConsumers()
 
-                consumer, err := client.CreateConsumerWithOffset(group, offsetReset)
+                // Idempotence keeps records in order per partition when the producer retries
+                opts := kafkaClient.ProducerOptions{Retries: &retries, MessageTimeout: deliveryTimeout}
+                if idempotent {
+                        opts.Acks = "all"
+                        opts.Idempotent = true
+                }
+                c.producer, err = client.CreateProducerWithOptions(opts)
                 if err != nil {
                         return err
                 }
-                defer consumer.Close()
+                defer c.producer.Close()
+
+                start := "the latest offset"
+                switch {
+                case beginOffset >= 0:
+                        start = fmt.Sprintf("offset %d", beginOffset)
+                case fromBeginning:
+                        start = "the beginning"
+                }
+                fmt.Printf("Starting to copy messages from '%s' to '%s' (%d partitions from %s, %d workers)...\n",
+                        sourceTopic, destTopic, len(c.partitions), start, len(c.consumers))
+                if endOffset >= 0 {
+                        fmt.Printf("Stopping at offset %d on every partition\n", endOffset)
+                }
+                if limit > 0 {
+                        fmt.Printf("Limiting to %d messages\n", limit)
+                }
+                if description := replay.Describe(); description != "" {
+                        fmt.Printf("Pacing messages: %s\n", description)
+                }
+
+                return c.run()
+        },
+}
+
+// copyRun is one cp operation: a consumer per worker, one shared producer,
+// and a bounded window of records waiting for delivery
+type copyRun struct {
+        client      *kafkaClient.Client
+        sourceTopic string
+        destTopic   string
+        endOffset   int64 // -1 keeps copying until stopped
+        limit       int64
+        replay      *replayer
+        replayMu    sync.Mutex
+        transformer *recordTransformer
+        deadLetter  *deadLetterWriter
+
+        partitions []*copyPartition
+        consumers  []*kafka.Consumer
+        assigned   [][]*copyPartition // Partitions per worker
+        producer   *kafka.Producer
+        inFlight   chan struct{} // One slot per record waiting for its delivery report
+
+        claimed   atomic.Int64 // Records counted against --limit
+        delivered atomic.Int64
+        failed    atomic.Int64
+        remaining atomic.Int64 // Partitions still short of --end-offset
+
+        stop     chan struct{}
+        stopOnce sync.Once
+        errMu    sync.Mutex
+        err      error
+        workers  sync.WaitGroup
+
+        startedAt     time.Time
+        progressLines int
+}
+
+// copyPartition tracks one source partition
+type copyPartition struct {
+        id       int32
+        start    int64
+        consumer *kafka.Consumer
+        position atomic.Int64 // Next offset to read
+        high     atomic.Int64 // Latest known high watermark
+        done     atomic.Bool  // Copied up to --end-offset
+}
+
+// copyDelivery travels with a produced record so a failed delivery can be set aside
+type copyDelivery struct {
+        source *kafka.Message
+}
 
-                // Create producer
-                producer, err := client.CreateProducer()
+// plan resolves the start offset of every source partition and spreads the partitions over the workers
+func (c *copyRun) plan(workers int, fromBeginning bool, beginOffset int64) error {
+        topicInfo, err := c.client.DescribeTopic(c.sourceTopic)
+        if err != nil {
+                return err
+        }
+        if len(topicInfo.PartitionDetails) < workers {
+                workers = len(topicInfo.PartitionDetails)
+        }
+
+        group := fmt.Sprintf("kafy-cp-%d", time.Now().Unix())
+        for i := 0; i < workers; i++ {
+                // Workers are positioned by Assign; nothing should be committed for the throwaway group
+                consumer, err := c.client.CreateConsumerWithOptions(group, kafkaClient.ConsumerOptions{
+                        OffsetReset:       "earliest",
+                        DisableAutoCommit: true,
+                })
                 if err != nil {
+                        c.closeConsumers()
                         return err
                 }
-                defer producer.Close()
+                c.consumers = append(c.consumers, consumer)
+        }
+        c.assigned = make([][]*copyPartition, workers)
 
-                // Check if offset-based copying is requested
-                useOffsetBasedCopy := cmd.Flags().Changed("begin-offset")
-                
-                if useOffsetBasedCopy {
-                        // Get topic metadata to determine partitions
-                        adminClient, err := client.CreateAdminClient()
-                        if err != nil {
-                                return fmt.Errorf("failed to create admin client: %w", err)
-                        }
-                        defer adminClient.Close()
+        for i, partition := range topicInfo.PartitionDetails {
+                low, high, err := c.consumers[0].QueryWatermarkOffsets(c.sourceTopic, partition.ID, 10000)
+                if err != nil {
+                        c.closeConsumers()
+                        return fmt.Errorf("failed to query watermarks for partition %d: %w", partition.ID, err)
+                }
 
-                        metadata, err := adminClient.GetMetadata(&sourceTopic, false, 5*1000)
-                        if err != nil {
-                                return fmt.Errorf("failed to get topic metadata: %w", err)
+                start := high
+                switch {
+                case beginOffset >= 0:
+                        start = beginOffset
+                        if start < low {
+                                start = low
                         }
-
-                        topic, exists := metadata.Topics[sourceTopic]
-                        if !exists {
-                                return fmt.Errorf("topic '%s' not found", sourceTopic)
+                        if start > high {
+                                start = high
                         }
+                case fromBeginning:
+                        start = low
+                }
 
-                        // Assign specific partitions with begin offsets
-                        var topicPartitions []kafka.TopicPartition
-                        for _, partition := range topic.Partitions {
-                                topicPartitions = append(topicPartitions, kafka.TopicPartition{
-                                        Topic:     &sourceTopic,
-                                        Partition: partition.ID,
-                                        Offset:    kafka.Offset(beginOffset),
-                                })
-                        }
+                worker := i % workers
+                p := &copyPartition{id: partition.ID, start: start, consumer: c.consumers[worker]}
+                p.position.Store(start)
+                p.high.Store(high)
+                c.partitions = append(c.partitions, p)
+                c.assigned[worker] = append(c.assigned[worker], p)
+        }
 
-                        err = consumer.Assign(topicPartitions)
-                        if err != nil {
-                                return fmt.Errorf("failed to assign partitions: %w", err)
+        if c.endOffset >= 0 {
+                c.remaining.Store(int64(len(c.partitions)))
+                for _, p := range c.partitions {
+                        if p.start >= c.endOffset {
+                                c.finish(p)
                         }
+                }
+        }
+        return nil
+}
+
+func (c *copyRun) closeConsumers() {
+        for _, consumer := range c.consumers {
+                consumer.Close()
+        }
+}
+
+func (c *copyRun) run() error {
+        c.startedAt = time.Now()
+        go c.handleDeliveries()
+
+        sigChan := make(chan os.Signal, 1)
+        signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+        defer signal.Stop(sigChan)
+        // A long --replay-timing wait is cut short through its own channel
+        c.replay.interrupt = make(chan os.Signal, 1)
+        go func() {
+                select {
+                case sig := <-sigChan:
+                        fmt.Printf("\nReceived interrupt signal, stopping copy operation...\n")
+                        c.halt()
+                        c.replay.interrupt <- sig
+                case <-c.stop:
+                }
+        }()
+
+        progressDone := make(chan struct{})
+        var progressWG sync.WaitGroup
+        progressWG.Add(1)
+        go c.showProgress(progressDone, &progressWG)
+
+        for i, consumer := range c.consumers {
+                c.workers.Add(1)
+                go c.work(consumer, c.assigned[i])
+        }
+        c.workers.Wait()
+
+        // Wait for outstanding delivery reports
+        deadline := time.Now().Add(30 * time.Second)
+        for (c.producer.Len() > 0 || len(c.inFlight) > 0) && time.Now().Before(deadline) {
+                c.producer.Flush(500)
+        }
+        undelivered := len(c.inFlight)
 
-                        fmt.Printf("Starting to copy messages from '%s' to '%s' (offset-based)...\n", sourceTopic, destTopic)
-                        fmt.Printf("Begin offset: %d", beginOffset)
-                        if cmd.Flags().Changed("end-offset") {
-                                fmt.Printf(", End offset: %d", endOffset)
+        close(progressDone)
+        progressWG.Wait()
+
+        if c.endOffset >= 0 && c.remaining.Load() == 0 {
+                fmt.Printf("Reached end offset %d on every partition\n", c.endOffset)
+        }
+        fmt.Printf("\nCopy operation completed. Total messages copied: %d\n", c.delivered.Load())
+        if failed := c.failed.Load(); failed > 0 {
+                fmt.Printf("Failed to deliver %d messages", failed)
+                if c.deadLetter != nil {
+                        fmt.Printf(" (written to %s)", c.deadLetter.Name())
+                }
+                fmt.Printf("\n")
+        }
+        if c.transformer != nil {
+                fmt.Printf("%s\n", c.transformer.Summary())
+        }
+
+        c.errMu.Lock()
+        defer c.errMu.Unlock()
+        if c.err != nil {
+                return c.err
+        }
+        if undelivered > 0 {
+                return fmt.Errorf("%d messages were still waiting for delivery after 30s", undelivered)
+        }
+        return nil
+}
+
+// halt tells every worker to stop
+func (c *copyRun) halt() {
+        c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// fail stops the copy, keeping the first error
+func (c *copyRun) fail(err error) {
+        c.errMu.Lock()
+        if c.err == nil {
+                c.err = err
+        }
+        c.errMu.Unlock()
+        c.halt()
+}
+
+func (c *copyRun) stopped() bool {
+        select {
+        case <-c.stop:
+                return true
+        default:
+                return false
+        }
+}
+
+// finish marks a partition as copied up to --end-offset; the copy stops once all are
+func (c *copyRun) finish(p *copyPartition) {
+        if p.done.CompareAndSwap(false, true) && c.remaining.Add(-1) == 0 {
+                c.halt()
+        }
+}
+
+// work copies the partitions of one worker, each in offset order
+func (c *copyRun) work(consumer *kafka.Consumer, partitions []*copyPartition) {
+        defer c.workers.Done()
+
+        byID := make(map[int32]*copyPartition)
+        var assignments []kafka.TopicPartition
+        for _, p := range partitions {
+                if p.done.Load() {
+                        continue
+                }
+                byID[p.id] = p
+                assignments = append(assignments, kafka.TopicPartition{Topic: &c.sourceTopic, Partition: p.id, Offset: kafka.Offset(p.start)})
+        }
+        if len(assignments) == 0 {
+                return
+        }
+        if err := consumer.Assign(assignments); err != nil {
+                c.fail(fmt.Errorf("failed to assign partitions: %w", err))
+                return
+        }
+
+        // --replay-timing merges the partitions by timestamp before copying
+        var merge *timestampMerge
+        if c.replay.timing {
+                merge = newTimestampMerge(c, consumer, byID)
+        }
+        idle := 0
+        for !c.stopped() {
+                if merge != nil && !merge.release(idle >= mergeIdleReads) {
+                        return
+                }
+                msg, err := consumer.ReadMessage(200 * time.Millisecond)
+                if err != nil {
+                        if transientReadError(err) {
+                                idle++
+                                if (merge == nil || merge.empty()) && c.checkFinished(consumer, byID) {
+                                        return
+                                }
+                                continue
                         }
-                        fmt.Printf("\n")
-                } else {
-                        // Subscribe to source topic (existing behavior)
-                        err = consumer.SubscribeTopics([]string{sourceTopic}, nil)
-                        if err != nil {
-                                return fmt.Errorf("failed to subscribe to source topic: %w", err)
+                        c.fail(fmt.Errorf("failed to read message: %w", err))
+                        return
+                }
+                idle = 0
+
+                p, ok := byID[msg.TopicPartition.Partition]
+                if !ok || p.done.Load() {
+                        continue
+                }
+                offset := int64(msg.TopicPartition.Offset)
+                if c.endOffset >= 0 && offset >= c.endOffset {
+                        if merge != nil {
+                                merge.end(p)
+                                continue
                         }
+                        c.finish(p)
+                        consumer.Pause([]kafka.TopicPartition{{Topic: &c.sourceTopic, Partition: p.id}})
+                        continue
+                }
+                p.position.Store(offset + 1)
 
-                        fmt.Printf("Starting to copy messages from '%s' to '%s'...\n", sourceTopic, destTopic)
+                if merge != nil {
+                        merge.add(p, msg)
+                        continue
                 }
-                
-                if limit > 0 {
-                        fmt.Printf("Limiting to %d messages\n", limit)
+                if !c.copyFrom(consumer, p, msg) {
+                        return
                 }
+        }
+}
 
-                // Setup signal handling for graceful shutdown
-                sigChan := make(chan os.Signal, 1)
-                signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-                messageCount := 0
-                
-        copyLoop:
-                for {
-                        select {
-                        case <-sigChan:
-                                fmt.Printf("\nReceived interrupt signal, stopping copy operation...\n")
-                                break copyLoop
-                        default:
-                                msg, err := consumer.ReadMessage(100 * time.Millisecond)
-                                if err != nil {
-                                        // Check if it's just a timeout
-                                        if kafkaErr, ok := err.(kafka.Error); ok {
-                                                if kafkaErr.Code() == kafka.ErrTimedOut {
-                                                        continue
-                                                }
-                                        }
-                                        return fmt.Errorf("failed to read message: %w", err)
-                                }
+// copyFrom copies a record of p, finishing p once it reached --end-offset.
+// It returns false when the copy should stop.
+func (c *copyRun) copyFrom(consumer *kafka.Consumer, p *copyPartition, msg *kafka.Message) bool {
+        if !c.copy(msg) {
+                return false
+        }
+        if c.endOffset >= 0 && int64(msg.TopicPartition.Offset)+1 >= c.endOffset {
+                c.finish(p)
+                consumer.Pause([]kafka.TopicPartition{{Topic: &c.sourceTopic, Partition: p.id}})
+        }
+        return true
+}
 
-                                // Check if we've reached the end offset (for offset-based copying)
-                                if useOffsetBasedCopy && cmd.Flags().Changed("end-offset") {
-                                        if msg.TopicPartition.Offset >= kafka.Offset(endOffset) {
-                                                fmt.Printf("Reached end offset %d, stopping copy operation...\n", endOffset)
-                                                break copyLoop
-                                        }
-                                }
+// checkFinished finishes partitions whose position passed --end-offset without
+// a record at the end (transaction markers and compacted gaps are never read).
+// It reports whether all of the worker's partitions are finished.
+func (c *copyRun) checkFinished(consumer *kafka.Consumer, byID map[int32]*copyPartition) bool {
+        if c.endOffset < 0 {
+                return false
+        }
+        var open []kafka.TopicPartition
+        for _, p := range byID {
+                if !p.done.Load() {
+                        open = append(open, kafka.TopicPartition{Topic: &c.sourceTopic, Partition: p.id})
+                }
+        }
+        if len(open) == 0 {
+                return true
+        }
+        positions, err := consumer.Position(open)
+        if err != nil {
+                return false
+        }
+        finished := 0
+        for _, position := range positions {
+                if position.Offset >= 0 && int64(position.Offset) >= c.endOffset {
+                        c.finish(byID[position.Partition])
+                        finished++
+                }
+        }
+        return finished == len(open)
+}
 
-                                // Create message for destination topic
-                                destMessage := &kafka.Message{
-                                        TopicPartition: kafka.TopicPartition{
-                                                Topic:     &destTopic,
-                                                Partition: kafka.PartitionAny,
-                                        },
-                                        Key:     msg.Key,
-                                        Value:   msg.Value,
-                                        Headers: msg.Headers,
-                                }
+// copy transforms, paces and produces one record. It returns false when the copy should stop.
+func (c *copyRun) copy(msg *kafka.Message) bool {
+        dest := &kafka.Message{
+                TopicPartition: kafka.TopicPartition{
+                        Topic:     &c.destTopic,
+                        Partition: kafka.PartitionAny,
+                },
+                Key:       msg.Key,
+                Value:     msg.Value,
+                Headers:   msg.Headers,
+                Timestamp: msg.Timestamp,
+        }
+        if c.transformer != nil {
+                keep, err := c.transformer.Apply(msg, dest)
+                if err != nil {
+                        c.fail(err)
+                        return false
+                }
+                if !keep {
+                        return true
+                }
+        }
 
-                                // Produce to destination topic
-                                err = producer.Produce(destMessage, nil)
-                                if err != nil {
-                                        return fmt.Errorf("failed to produce message to destination: %w", err)
-                                }
+        claimed := c.claimed.Add(1)
+        if c.limit > 0 && claimed > c.limit {
+                c.halt()
+                return false
+        }
 
-                                messageCount++
-                                if messageCount%100 == 0 {
-                                        fmt.Printf("Copied %d messages...\n", messageCount)
-                                }
+        c.replayMu.Lock()
+        dest.Timestamp = c.replay.Wait(dest.Timestamp)
+        c.replayMu.Unlock()
+        if c.stopped() {
+                return false
+        }
 
-                                // Check if we've reached the limit
-                                if limit > 0 && messageCount >= limit {
-                                        break copyLoop
-                                }
+        select {
+        case c.inFlight <- struct{}{}:
+        case <-c.stop:
+                return false
+        }
+        dest.Opaque = &copyDelivery{source: msg}
+        if err := c.produce(dest); err != nil {
+                <-c.inFlight
+                c.deliveryFailed(msg, err)
+        }
+
+        if c.limit > 0 && claimed == c.limit {
+                c.halt()
+        }
+        return true
+}
+
+// produce queues a record, waiting for room when the local queue is full
+func (c *copyRun) produce(msg *kafka.Message) error {
+        for {
+                err := c.producer.Produce(msg, nil)
+                if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrQueueFull {
+                        time.Sleep(10 * time.Millisecond)
+                        continue
+                }
+                return err
+        }
+}
+
+func (c *copyRun) handleDeliveries() {
+        for e := range c.producer.Events() {
+                switch ev := e.(type) {
+                case *kafka.Message:
+                        d, ok := ev.Opaque.(*copyDelivery)
+                        if !ok {
+                                continue
+                        }
+                        <-c.inFlight
+                        // The producer has already retried; resending here would put the
+                        // record after later ones of its partition
+                        if err := ev.TopicPartition.Error; err != nil {
+                                c.deliveryFailed(d.source, err)
+                                continue
+                        }
+                        c.delivered.Add(1)
+                case kafka.Error:
+                        fmt.Fprintf(os.Stderr, "\nProducer error: %v\n", ev)
+                        if ev.IsFatal() {
+                                c.fail(fmt.Errorf("producer error: %w", ev))
                         }
                 }
+        }
+}
 
-                // Wait for all messages to be delivered
-                producer.Flush(5000)
+// deliveryFailed sets a record aside in the dead letter file, or stops the copy without one
+func (c *copyRun) deliveryFailed(source *kafka.Message, err error) {
+        c.failed.Add(1)
+        if c.deadLetter == nil {
+                c.fail(fmt.Errorf("failed to deliver the record from %s: %w", source.TopicPartition, err))
+                return
+        }
+        if writeErr := c.deadLetter.Write(source, err); writeErr != nil {
+                c.fail(writeErr)
+        }
+}
 
-                fmt.Printf("\nCopy operation completed. Total messages copied: %d\n", messageCount)
-                return nil
-        },
+// showProgress redraws per-partition progress on a terminal every second, or
+// prints a status line every 10 seconds when stderr is redirected
+func (c *copyRun) showProgress(done chan struct{}, wg *sync.WaitGroup) {
+        defer wg.Done()
+        terminal := isTerminal(os.Stderr)
+        interval := 10 * time.Second
+        if terminal {
+                interval = time.Second
+        }
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        for {
+                select {
+                case <-done:
+                        if terminal {
+                                c.printProgress(true)
+                        }
+                        return
+                case <-ticker.C:
+                        c.printProgress(terminal)
+                }
+        }
+}
+
+// printProgress shows how far each partition is toward its high watermark (or --end-offset)
+func (c *copyRun) printProgress(terminal bool) {
+        type row struct {
+                id       int32
+                fraction float64
+                copied   int64
+                target   int64
+        }
+        var rows []row
+        var copiedTotal, targetTotal int64
+        for _, p := range c.partitions {
+                if _, high, err := p.consumer.GetWatermarkOffsets(c.sourceTopic, p.id); err == nil && high >= 0 {
+                        p.high.Store(high)
+                }
+                end := p.high.Load()
+                if c.endOffset >= 0 {
+                        end = c.endOffset
+                }
+                copied := p.position.Load() - p.start
+                target := end - p.start
+                if target < copied {
+                        target = copied
+                }
+                fraction := 1.0
+                if target > 0 {
+                        fraction = float64(copied) / float64(target)
+                }
+                copiedTotal += copied
+                targetTotal += target
+                rows = append(rows, row{id: p.id, fraction: fraction, copied: copied, target: target})
+        }
+
+        overall := 1.0
+        if targetTotal > 0 {
+                overall = float64(copiedTotal) / float64(targetTotal)
+        }
+        rate := float64(c.delivered.Load()) / time.Since(c.startedAt).Seconds()
+        status := fmt.Sprintf("Copied %d messages (%.0f msg/s), in flight %d, failed %d",
+                c.delivered.Load(), rate, len(c.inFlight), c.failed.Load())
+
+        if !terminal {
+                fmt.Fprintf(os.Stderr, "%s, %.0f%% read\n", status, overall*100)
+                return
+        }
+
+        lines := []string{status}
+        if len(rows) <= maxProgressRows {
+                for _, r := range rows {
+                        lines = append(lines, fmt.Sprintf("  p%-4d %s %3.0f%%  %d/%d", r.id, progressBar(r.fraction, 30), r.fraction*100, r.copied, r.target))
+                }
+        } else {
+                caughtUp := 0
+                for _, r := range rows {
+                        if r.fraction >= 1 {
+                                caughtUp++
+                        }
+                }
+                lines = append(lines, fmt.Sprintf("  all   %s %3.0f%%  %d/%d (%d of %d partitions caught up)",
+                        progressBar(overall, 30), overall*100, copiedTotal, targetTotal, caughtUp, len(rows)))
+        }
+
+        // Redraw over the previous frame
+        var b strings.Builder
+        if c.progressLines > 0 {
+                fmt.Fprintf(&b, "\033[%dA", c.progressLines)
+        }
+        for _, line := range lines {
+                b.WriteString("\r\033[K" + line + "\n")
+        }
+        c.progressLines = len(lines)
+        fmt.Fprint(os.Stderr, b.String())
+}
+
+func progressBar(fraction float64, width int) string {
+        filled := int(fraction * float64(width))
+        if filled > width {
+                filled = width
+        }
+        return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
 }
 
 func init() {
@@ -199,4 +724,12 @@ func init() {
         cpCmd.Flags().Int("limit", 0, "Maximum number of messages to copy (0 = unlimited)")
         cpCmd.Flags().Int64("begin-offset", -1, "Begin copying from this offset (applies to all partitions)")
         cpCmd.Flags().Int64("end-offset", -1, "Stop copying at this offset (optional, applies to all partitions)")
-}
\ No newline at end of file
+        cpCmd.Flags().Int("workers", 4, "Number of workers, each consuming a share of the source partitions")
+        cpCmd.Flags().Int("max-in-flight", 10000, "Maximum number of records waiting for delivery")
+        cpCmd.Flags().Int("retries", 10, "Times the producer retries a failed send before the record counts as failed")
+        cpCmd.Flags().Duration("delivery-timeout", 2*time.Minute, "Give up on a record not delivered within this time, retries included")
+        cpCmd.Flags().Bool("idempotent", true, "Use an idempotent producer (acks=all, no duplicates or reordering on internal retries)")
+        cpCmd.Flags().String("dead-letter-file", "", "Append records that fail a transform step or cannot be delivered to this file as NDJSON (replay with produce --input-format ndjson --base64 key,value,headers)")
+        addReplayFlags(cpCmd, timestampsNow)
+        addTransformFlags(cpCmd)
+}
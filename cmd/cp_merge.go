@@ -0,0 +1,143 @@
+package cmd
+
+import (
+        "container/heap"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+        mergeBuffer    = 64 // Records buffered per partition before it is paused
+        mergeIdleReads = 5  // Empty reads after which partitions that are behind no longer hold back the others
+)
+
+// timestampMerge puts the records of several partitions into timestamp order
+// for --replay-timing. Every partition buffers a few records; the oldest head
+// is released once each partition that could still hold an older record has
+// one buffered, or has been read up to its high watermark.
+type timestampMerge struct {
+        run      *copyRun
+        consumer *kafka.Consumer
+        byID     map[int32]*copyPartition
+        buffers  map[int32][]*kafka.Message
+        ended    map[int32]bool // Read past --end-offset; finished once the buffer drains
+        heads    mergeHeap
+}
+
+func newTimestampMerge(run *copyRun, consumer *kafka.Consumer, byID map[int32]*copyPartition) *timestampMerge {
+        return &timestampMerge{
+                run:      run,
+                consumer: consumer,
+                byID:     byID,
+                buffers:  make(map[int32][]*kafka.Message),
+                ended:    make(map[int32]bool),
+        }
+}
+
+// add buffers a record, pausing its partition once the buffer is full
+func (m *timestampMerge) add(p *copyPartition, msg *kafka.Message) {
+        m.buffers[p.id] = append(m.buffers[p.id], msg)
+        if len(m.buffers[p.id]) == 1 {
+                heap.Push(&m.heads, mergeHead{partition: p.id, timestamp: msg.Timestamp})
+        }
+        if len(m.buffers[p.id]) == mergeBuffer {
+                m.consumer.Pause([]kafka.TopicPartition{{Topic: &m.run.sourceTopic, Partition: p.id}})
+        }
+}
+
+// end notes that a partition was read past --end-offset
+func (m *timestampMerge) end(p *copyPartition) {
+        m.consumer.Pause([]kafka.TopicPartition{{Topic: &m.run.sourceTopic, Partition: p.id}})
+        if len(m.buffers[p.id]) == 0 {
+                m.run.finish(p)
+                return
+        }
+        m.ended[p.id] = true
+}
+
+// empty reports whether no records are buffered
+func (m *timestampMerge) empty() bool {
+        return m.heads.Len() == 0
+}
+
+// release copies buffered records in timestamp order for as long as no
+// partition may still deliver an older one; idle releases them regardless.
+// It returns false when the copy should stop.
+func (m *timestampMerge) release(idle bool) bool {
+        for m.heads.Len() > 0 {
+                if !idle && m.waiting() {
+                        return true
+                }
+                id := m.heads[0].partition
+                buffer := m.buffers[id]
+                msg := buffer[0]
+                m.buffers[id] = buffer[1:]
+                if len(m.buffers[id]) > 0 {
+                        m.heads[0].timestamp = m.buffers[id][0].Timestamp
+                        heap.Fix(&m.heads, 0)
+                } else {
+                        heap.Pop(&m.heads)
+                }
+                if len(buffer) == mergeBuffer && !m.ended[id] {
+                        m.consumer.Resume([]kafka.TopicPartition{{Topic: &m.run.sourceTopic, Partition: id}})
+                }
+
+                p := m.byID[id]
+                if !m.run.copyFrom(m.consumer, p, msg) {
+                        return false
+                }
+                if m.ended[id] && len(m.buffers[id]) == 0 {
+                        m.run.finish(p)
+                }
+        }
+        return true
+}
+
+// waiting reports whether a partition without buffered records has not been
+// read up to its high watermark, so its next record may be older than the heads
+func (m *timestampMerge) waiting() bool {
+        for id, p := range m.byID {
+                if len(m.buffers[id]) > 0 || m.ended[id] || p.done.Load() {
+                        continue
+                }
+                position := p.start
+                positions, err := m.consumer.Position([]kafka.TopicPartition{{Topic: &m.run.sourceTopic, Partition: id}})
+                if err == nil && len(positions) == 1 && positions[0].Offset >= 0 {
+                        position = int64(positions[0].Offset)
+                }
+                _, high, err := m.consumer.GetWatermarkOffsets(m.run.sourceTopic, id)
+                if err != nil || high < 0 {
+                        high = p.high.Load()
+                }
+                if position < high {
+                        return true
+                }
+        }
+        return false
+}
+
+// mergeHead is the oldest buffered record of a partition
+type mergeHead struct {
+        partition int32
+        timestamp time.Time
+}
+
+// mergeHeap orders partitions by the timestamp of their oldest buffered record
+type mergeHeap []mergeHead
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+        if !h[i].timestamp.Equal(h[j].timestamp) {
+                return h[i].timestamp.Before(h[j].timestamp)
+        }
+        return h[i].partition < h[j].partition
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeHead)) }
+func (h *mergeHeap) Pop() interface{} {
+        old := *h
+        head := old[len(old)-1]
+        *h = old[:len(old)-1]
+        return head
+}
@@ -0,0 +1,556 @@
+package cmd
+
+import (
+        "fmt"
+        "io"
+        "os"
+        "os/signal"
+        "sort"
+        "strconv"
+        "strings"
+        "sync/atomic"
+        "syscall"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        "kafy/internal/archive"
+        kafkaClient "kafy/internal/kafka"
+)
+
+var topicsDumpCmd = &cobra.Command{
+        Use:   "dump <topic>",
+        Short: "Dump a topic to a lossless archive",
+        Long: `Dump every message currently in a topic to a lossless archive.
+The archive is newline-delimited JSON with a header describing the topic and its partitions,
+one line per record (binary-safe base64 keys, values and headers, timestamps with their type,
+original partition and offset) and a footer per partition used to verify restores. The header
+also keeps the topic's config overrides, such as retention and compaction, for 'restore --create'.
+
+The dump reads up to the high watermark of each partition as of when it starts.
+Progress is written to stderr so the archive can be redirected from stdout.
+
+Examples:
+  kafy topics dump orders > orders.kafy
+  kafy topics dump orders --file orders.kafy.gz --gzip
+  kafy topics dump orders --file orders.kafy --split-size 512MB`,
+        Args:              cobra.ExactArgs(1),
+        ValidArgsFunction: completeTopics,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicName := args[0]
+                file, _ := cmd.Flags().GetString("file")
+                useGzip, _ := cmd.Flags().GetBool("gzip")
+                splitSizeStr, _ := cmd.Flags().GetString("split-size")
+
+                var splitSize int64
+                if splitSizeStr != "" {
+                        var err error
+                        splitSize, err = parseByteSize(splitSizeStr)
+                        if err != nil {
+                                return fmt.Errorf("invalid --split-size: %w", err)
+                        }
+                        if file == "" {
+                                return fmt.Errorf("--split-size requires --file")
+                        }
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                topicInfo, err := client.DescribeTopic(topicName)
+                if err != nil {
+                        return err
+                }
+
+                // Overrides only, so a restore recreates the topic's own settings and not another cluster's defaults
+                configs, err := client.GetTopicConfigOverrides(topicName)
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Warning: could not read topic configs: %v\n", err)
+                }
+
+                groupID := fmt.Sprintf("kafy-dump-%d", time.Now().Unix())
+                consumer, err := client.CreateConsumerWithOptions(groupID, kafkaClient.ConsumerOptions{OffsetReset: "earliest", DisableAutoCommit: true})
+                if err != nil {
+                        return fmt.Errorf("failed to create consumer: %w", err)
+                }
+                defer consumer.Close()
+
+                // Snapshot the watermarks so the dump has a well-defined end
+                header := archive.Header{
+                        Topic:     topicName,
+                        CreatedAt: time.Now().UTC(),
+                        Configs:   configs,
+                }
+                var assignments []kafka.TopicPartition
+                highWatermarks := make(map[int32]int64)
+                for _, partition := range topicInfo.PartitionDetails {
+                        low, high, err := consumer.QueryWatermarkOffsets(topicName, partition.ID, 10000)
+                        if err != nil {
+                                return fmt.Errorf("failed to query watermarks for partition %d: %w", partition.ID, err)
+                        }
+                        header.Partitions = append(header.Partitions, archive.PartitionMeta{
+                                Partition:     partition.ID,
+                                LowWatermark:  low,
+                                HighWatermark: high,
+                                Leader:        partition.Leader,
+                                Replicas:      partition.Replicas,
+                        })
+                        if len(partition.Replicas) > header.Replication {
+                                header.Replication = len(partition.Replicas)
+                        }
+                        if high > low {
+                                highWatermarks[partition.ID] = high
+                                assignments = append(assignments, kafka.TopicPartition{
+                                        Topic:     &topicName,
+                                        Partition: partition.ID,
+                                        Offset:    kafka.Offset(low),
+                                })
+                        }
+                }
+                sort.Slice(header.Partitions, func(i, j int) bool {
+                        return header.Partitions[i].Partition < header.Partitions[j].Partition
+                })
+
+                writer, err := archive.NewWriter(archive.WriterOptions{
+                        Path:      file,
+                        Gzip:      useGzip,
+                        SplitSize: splitSize,
+                }, header)
+                if err != nil {
+                        return err
+                }
+
+                footers := make(map[int32]*archive.Footer)
+                for _, partition := range header.Partitions {
+                        footers[partition.Partition] = &archive.Footer{Partition: partition.Partition, FirstOffset: -1, LastOffset: -1}
+                }
+
+                if len(assignments) > 0 {
+                        if err := consumer.Assign(assignments); err != nil {
+                                writer.Close()
+                                return fmt.Errorf("failed to assign partitions: %w", err)
+                        }
+                }
+
+                fmt.Fprintf(os.Stderr, "Dumping topic '%s' (%d partitions)...\n", topicName, len(header.Partitions))
+
+                sigChan := make(chan os.Signal, 1)
+                signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+                defer signal.Stop(sigChan)
+
+                var total int64
+                for len(highWatermarks) > 0 {
+                        select {
+                        case <-sigChan:
+                                writer.Close()
+                                return fmt.Errorf("dump interrupted after %d messages; the archive is incomplete", total)
+                        default:
+                        }
+
+                        msg, err := consumer.ReadMessage(500 * time.Millisecond)
+                        if err != nil {
+                                if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+                                        // Transaction markers and compacted gaps never show up as
+                                        // messages, so check the consumer position instead
+                                        if err := dropFinishedPartitions(consumer, topicName, highWatermarks); err != nil {
+                                                writer.Close()
+                                                return err
+                                        }
+                                        continue
+                                }
+                                writer.Close()
+                                return fmt.Errorf("failed to read message: %w", err)
+                        }
+
+                        partition := msg.TopicPartition.Partition
+                        offset := int64(msg.TopicPartition.Offset)
+                        high, active := highWatermarks[partition]
+                        if !active || offset >= high {
+                                continue
+                        }
+
+                        if err := writer.WriteRecord(recordFromMessage(msg)); err != nil {
+                                writer.Close()
+                                return fmt.Errorf("failed to write archive: %w", err)
+                        }
+
+                        footer := footers[partition]
+                        if footer.FirstOffset < 0 {
+                                footer.FirstOffset = offset
+                        }
+                        footer.LastOffset = offset
+                        footer.Records++
+                        total++
+                        if total%10000 == 0 {
+                                fmt.Fprintf(os.Stderr, "Dumped %d messages...\n", total)
+                        }
+
+                        if offset >= high-1 {
+                                delete(highWatermarks, partition)
+                        }
+                }
+
+                for _, partition := range header.Partitions {
+                        if err := writer.WriteFooter(*footers[partition.Partition]); err != nil {
+                                writer.Close()
+                                return fmt.Errorf("failed to write archive: %w", err)
+                        }
+                }
+                if err := writer.Close(); err != nil {
+                        return fmt.Errorf("failed to write archive: %w", err)
+                }
+
+                fmt.Fprintf(os.Stderr, "Dumped %d messages from topic '%s'\n", total, topicName)
+                if len(writer.Files) > 1 {
+                        fmt.Fprintf(os.Stderr, "Archive split into %d files: %s\n", len(writer.Files), strings.Join(writer.Files, ", "))
+                }
+                return nil
+        },
+}
+
+var topicsRestoreCmd = &cobra.Command{
+        Use:   "restore <topic>",
+        Short: "Restore a topic from an archive created by 'topics dump'",
+        Long: `Restore messages from an archive created by 'kafy topics dump' into a topic.
+Reads the archive from stdin unless --file is given. Split archives are restored by passing
+every part in order. Gzip-compressed archives are detected automatically.
+
+By default records are repartitioned by key and get new timestamps. Use --keep-partitions
+to write each record to its original partition and --keep-timestamps to keep the original
+timestamps (topics using LogAppendTime will still overwrite them). --create creates a missing
+topic with the archived partition count, replication factor and config overrides.
+
+After restoring, the record counts are checked against the footers the dump wrote for each
+partition. A mismatch or missing footer (a truncated archive) fails the restore unless
+--allow-incomplete is given.
+
+Examples:
+  kafy topics restore orders-copy < orders.kafy
+  kafy topics restore orders --file orders.kafy.gz --keep-partitions --keep-timestamps
+  kafy topics restore orders --file orders.kafy.0001,orders.kafy.0002 --create`,
+        Args:              cobra.ExactArgs(1),
+        ValidArgsFunction: completeTopics,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicName := args[0]
+                files, _ := cmd.Flags().GetStringSlice("file")
+                keepPartitions, _ := cmd.Flags().GetBool("keep-partitions")
+                keepTimestamps, _ := cmd.Flags().GetBool("keep-timestamps")
+                create, _ := cmd.Flags().GetBool("create")
+                allowIncomplete, _ := cmd.Flags().GetBool("allow-incomplete")
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                producer, err := client.CreateProducer()
+                if err != nil {
+                        return err
+                }
+                defer producer.Close()
+
+                var failed int64
+                var firstFailure atomic.Value
+                go func() {
+                        for e := range producer.Events() {
+                                if m, ok := e.(*kafka.Message); ok && m.TopicPartition.Error != nil {
+                                        if atomic.AddInt64(&failed, 1) == 1 {
+                                                firstFailure.Store(m.TopicPartition.Error.Error())
+                                        }
+                                }
+                        }
+                }()
+
+                restore := &archiveRestore{
+                        client:         client,
+                        producer:       producer,
+                        topic:          topicName,
+                        keepPartitions: keepPartitions,
+                        keepTimestamps: keepTimestamps,
+                        create:         create,
+                        counts:         make(map[int32]int64),
+                        footers:        make(map[int32]archive.Footer),
+                }
+
+                if len(files) == 0 {
+                        if err := restore.read(os.Stdin); err != nil {
+                                return err
+                        }
+                } else {
+                        for _, path := range files {
+                                f, err := os.Open(path)
+                                if err != nil {
+                                        return fmt.Errorf("failed to open archive: %w", err)
+                                }
+                                err = restore.read(f)
+                                f.Close()
+                                if err != nil {
+                                        return fmt.Errorf("%s: %w", path, err)
+                                }
+                        }
+                }
+                if restore.header == nil {
+                        return fmt.Errorf("archive is empty")
+                }
+
+                remaining := producer.Flush(60 * 1000)
+                if remaining > 0 {
+                        return fmt.Errorf("%d messages were still undelivered after flushing", remaining)
+                }
+                if n := atomic.LoadInt64(&failed); n > 0 {
+                        return fmt.Errorf("%d of %d messages failed to deliver (first error: %v)", n, restore.total, firstFailure.Load())
+                }
+
+                problems := restore.verify()
+                for _, problem := range problems {
+                        fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
+                }
+                fmt.Fprintf(os.Stderr, "Restored %d messages from '%s' into topic '%s'\n", restore.total, restore.header.Topic, topicName)
+                if len(problems) > 0 && !allowIncomplete {
+                        return fmt.Errorf("the restore does not match the archive footers; pass --allow-incomplete to accept a partial archive")
+                }
+                return nil
+        },
+}
+
+// archiveRestore carries restore state across the parts of a split archive
+type archiveRestore struct {
+        client         *kafkaClient.Client
+        producer       *kafka.Producer
+        topic          string
+        keepPartitions bool
+        keepTimestamps bool
+        create         bool
+
+        header  *archive.Header
+        part    int
+        total   int64
+        counts  map[int32]int64
+        footers map[int32]archive.Footer
+}
+
+func (r *archiveRestore) read(in io.Reader) error {
+        reader, err := archive.NewReader(in)
+        if err != nil {
+                return err
+        }
+
+        for {
+                entry, err := reader.Next()
+                if err == io.EOF {
+                        return nil
+                }
+                if err != nil {
+                        return err
+                }
+
+                switch {
+                case entry.Header != nil:
+                        if err := r.startPart(entry.Header); err != nil {
+                                return err
+                        }
+                case entry.Record != nil:
+                        if r.header == nil {
+                                return fmt.Errorf("archive is missing its header line")
+                        }
+                        if err := r.produce(entry.Record); err != nil {
+                                return err
+                        }
+                case entry.Footer != nil:
+                        r.footers[entry.Footer.Partition] = *entry.Footer
+                }
+        }
+}
+
+func (r *archiveRestore) startPart(header *archive.Header) error {
+        if r.header == nil {
+                if header.Part > 1 {
+                        return fmt.Errorf("archive starts at part %d; pass every part in order starting with part 1", header.Part)
+                }
+                r.header = header
+                r.part = header.Part
+                return r.prepareTopic()
+        }
+
+        if header.Topic != r.header.Topic || !header.CreatedAt.Equal(r.header.CreatedAt) {
+                return fmt.Errorf("archive part belongs to a different dump (topic '%s' at %s)", header.Topic, header.CreatedAt.Format(time.RFC3339))
+        }
+        if header.Part != r.part+1 {
+                return fmt.Errorf("expected archive part %d but found part %d", r.part+1, header.Part)
+        }
+        r.part = header.Part
+        return nil
+}
+
+// prepareTopic creates the destination topic if asked and checks it can hold the original partitions
+func (r *archiveRestore) prepareTopic() error {
+        topicInfo, err := r.client.DescribeTopic(r.topic)
+        if err != nil {
+                if !r.create {
+                        return fmt.Errorf("%w (use --create to create it from the archive metadata)", err)
+                }
+                replication := r.header.Replication
+                if replication < 1 {
+                        replication = 1
+                }
+                if err := r.client.CreateTopicWithConfigs(r.topic, len(r.header.Partitions), replication, r.header.Configs); err != nil {
+                        return err
+                }
+                fmt.Printf("Created topic '%s' with %d partitions, replication factor %d and %d config overrides\n", r.topic, len(r.header.Partitions), replication, len(r.header.Configs))
+                return nil
+        }
+
+        if r.keepPartitions {
+                for _, partition := range r.header.Partitions {
+                        if int(partition.Partition) >= topicInfo.Partitions {
+                                return fmt.Errorf("--keep-partitions needs partition %d but topic '%s' only has %d partitions", partition.Partition, r.topic, topicInfo.Partitions)
+                        }
+                }
+        }
+        return nil
+}
+
+func (r *archiveRestore) produce(record *archive.Record) error {
+        msg := &kafka.Message{
+                TopicPartition: kafka.TopicPartition{Topic: &r.topic, Partition: kafka.PartitionAny},
+                Key:            record.Key,
+                Value:          record.Value,
+        }
+        if r.keepPartitions {
+                msg.TopicPartition.Partition = record.Partition
+        }
+        if r.keepTimestamps && record.TimestampType != archive.TimestampNone {
+                msg.Timestamp = time.UnixMilli(record.Timestamp)
+        }
+        for _, header := range record.Headers {
+                msg.Headers = append(msg.Headers, kafka.Header{Key: header.Key, Value: header.Value})
+        }
+
+        for {
+                err := r.producer.Produce(msg, nil)
+                if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrQueueFull {
+                        // Let the local queue drain before retrying
+                        r.producer.Flush(100)
+                        continue
+                }
+                if err != nil {
+                        return fmt.Errorf("failed to produce message from offset %d of partition %d: %w", record.Offset, record.Partition, err)
+                }
+                break
+        }
+
+        r.counts[record.Partition]++
+        r.total++
+        if r.total%10000 == 0 {
+                fmt.Fprintf(os.Stderr, "Restored %d messages...\n", r.total)
+        }
+        return nil
+}
+
+// verify compares restored counts against the archive footers and returns the mismatches
+func (r *archiveRestore) verify() []string {
+        if len(r.footers) == 0 {
+                return []string{"archive has no footers; it may be truncated or from an interrupted dump"}
+        }
+        var problems []string
+        for _, partition := range r.header.Partitions {
+                footer, ok := r.footers[partition.Partition]
+                if !ok {
+                        problems = append(problems, fmt.Sprintf("no footer for partition %d", partition.Partition))
+                        continue
+                }
+                if footer.Records != r.counts[partition.Partition] {
+                        problems = append(problems, fmt.Sprintf("partition %d archive footer lists %d records but %d were restored",
+                                partition.Partition, footer.Records, r.counts[partition.Partition]))
+                }
+        }
+        return problems
+}
+
+// recordFromMessage converts a consumed message into an archive record
+func recordFromMessage(msg *kafka.Message) archive.Record {
+        record := archive.Record{
+                Partition:     msg.TopicPartition.Partition,
+                Offset:        int64(msg.TopicPartition.Offset),
+                Timestamp:     msg.Timestamp.UnixMilli(),
+                TimestampType: archive.TimestampNone,
+                Key:           msg.Key,
+                Value:         msg.Value,
+        }
+        switch msg.TimestampType {
+        case kafka.TimestampCreateTime:
+                record.TimestampType = archive.TimestampCreateTime
+        case kafka.TimestampLogAppendTime:
+                record.TimestampType = archive.TimestampLogAppendTime
+        }
+        for _, header := range msg.Headers {
+                record.Headers = append(record.Headers, archive.RecordHeader{Key: header.Key, Value: header.Value})
+        }
+        return record
+}
+
+// dropFinishedPartitions removes partitions whose consumer position has passed the dump's end offset
+func dropFinishedPartitions(consumer *kafka.Consumer, topic string, highWatermarks map[int32]int64) error {
+        var partitions []kafka.TopicPartition
+        for partition := range highWatermarks {
+                partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: partition})
+        }
+        positions, err := consumer.Position(partitions)
+        if err != nil {
+                return fmt.Errorf("failed to get consumer position: %w", err)
+        }
+        for _, position := range positions {
+                if position.Offset >= 0 && int64(position.Offset) >= highWatermarks[position.Partition] {
+                        delete(highWatermarks, position.Partition)
+                }
+        }
+        return nil
+}
+
+// parseByteSize parses sizes such as "512MB", "1.5GB" or "1048576"
+func parseByteSize(input string) (int64, error) {
+        s := strings.ToUpper(strings.TrimSpace(input))
+        units := []struct {
+                suffix     string
+                multiplier float64
+        }{
+                {"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+        }
+        multiplier := 1.0
+        for _, unit := range units {
+                if strings.HasSuffix(s, unit.suffix) {
+                        multiplier = unit.multiplier
+                        s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+                        break
+                }
+        }
+        value, err := strconv.ParseFloat(s, 64)
+        if err != nil || value <= 0 {
+                return 0, fmt.Errorf("'%s' is not a valid size (e.g. 512MB, 2GB)", input)
+        }
+        return int64(value * multiplier), nil
+}
+
+func init() {
+        topicsDumpCmd.Flags().String("file", "", "Write the archive to this file instead of stdout")
+        topicsDumpCmd.Flags().Bool("gzip", false, "Compress the archive with gzip")
+        topicsDumpCmd.Flags().String("split-size", "", "Split the archive into parts of about this size (e.g. 512MB, 2GB); requires --file")
+
+        topicsRestoreCmd.Flags().StringSlice("file", []string{}, "Archive file(s) to restore, in part order (default: stdin)")
+        topicsRestoreCmd.Flags().Bool("keep-partitions", false, "Write each record to its original partition")
+        topicsRestoreCmd.Flags().Bool("keep-timestamps", false, "Keep the original record timestamps")
+        topicsRestoreCmd.Flags().Bool("create", false, "Create the topic from the archive metadata if it does not exist")
+        topicsRestoreCmd.Flags().Bool("allow-incomplete", false, "Succeed even if the restored counts do not match the archive footers, or footers are missing")
+}
@@ -0,0 +1,426 @@
+package cmd
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "math"
+        "net/http"
+        "os"
+        "os/signal"
+        "regexp"
+        "sort"
+        "strconv"
+        "syscall"
+        "time"
+
+        "github.com/spf13/cobra"
+        "kafy/internal/exporter"
+        "kafy/internal/health"
+        kafkaClient "kafy/internal/kafka"
+)
+
+var exporterCmd = &cobra.Command{
+        Use:   "exporter",
+        Short: "Serve consumer lag and cluster health as Prometheus metrics",
+        Long: `Run a long-lived HTTP server exposing consumer group lag and partition health on /metrics
+in the Prometheus text format, as a lightweight alternative to a dedicated lag exporter.
+
+Metrics (all labelled with the cluster context):
+  kafy_brokers                           Live brokers
+  kafy_topic_partitions                  Partitions per topic
+  kafy_partition_leader                  Leader broker id, -1 when offline
+  kafy_partition_replicas                Replica count per partition
+  kafy_partition_in_sync_replicas        In-sync replica count per partition
+  kafy_partition_start_offset            Log start offset per partition
+  kafy_partition_end_offset              Log end offset (high watermark) per partition
+  kafy_offline_partitions                Partitions without a leader
+  kafy_under_replicated_partitions       Partitions with fewer in-sync replicas than replicas
+  kafy_under_min_isr_partitions          Partitions below min.insync.replicas
+  kafy_health_check_status               Partition health checks: 0 pass, 1 warn, 2 fail
+  kafy_consumergroup_members             Members per group
+  kafy_consumergroup_state               1 for the current state of each group
+  kafy_consumergroup_committed_offset    Committed offset per group and partition
+  kafy_consumergroup_lag                 Lag per group and partition
+  kafy_consumergroup_topic_lag           Total lag per group and topic
+  kafy_up, kafy_collect_duration_seconds Whether and how fast each cluster was collected
+
+With --canary, each collection also runs a round of 'kafy health canary' per cluster:
+  kafy_canary_up                         1 when the partition's probe was produced and consumed
+  kafy_canary_produce_latency_seconds    Produce latency of the last probe per partition
+  kafy_canary_end_to_end_latency_seconds End-to-end latency of the last probe per partition
+  kafy_canary_probes_total               Probes sent per broker
+  kafy_canary_probes_failed_total        Probes whose produce failed per broker
+  kafy_canary_probes_lost_total          Probes acknowledged but never consumed per broker
+
+Collections are cached for --cache-ttl, so several Prometheus servers or a short scrape interval
+do not add load on the clusters. Use --contexts to export several clusters from one process.
+--topics/--exclude-topics and --groups/--exclude-groups are regular expressions matched against
+the whole name.
+
+Examples:
+  kafy exporter
+  kafy exporter --listen :9787 --cache-ttl 1m
+  kafy exporter --contexts prod-eu,prod-us --exclude-topics '__.*'
+  kafy exporter --contexts all --groups 'billing-.*'
+  kafy exporter --canary --cache-ttl 15s`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                listen, _ := cmd.Flags().GetString("listen")
+                cacheTTL, _ := cmd.Flags().GetDuration("cache-ttl")
+                contexts, _ := cmd.Flags().GetStringSlice("contexts")
+                concurrency, _ := cmd.Flags().GetInt("concurrency")
+                withCanary, _ := cmd.Flags().GetBool("canary")
+                canaryTopic, _ := cmd.Flags().GetString("canary-topic")
+                canaryTimeout, _ := cmd.Flags().GetDuration("canary-timeout")
+                if concurrency < 1 {
+                        return fmt.Errorf("--concurrency must be at least 1")
+                }
+
+                var filter exporterFilter
+                for _, f := range []struct {
+                        flag string
+                        re   **regexp.Regexp
+                }{
+                        {"topics", &filter.topics},
+                        {"exclude-topics", &filter.excludeTopics},
+                        {"groups", &filter.groups},
+                        {"exclude-groups", &filter.excludeGroups},
+                } {
+                        pattern, _ := cmd.Flags().GetString(f.flag)
+                        if pattern == "" {
+                                continue
+                        }
+                        re, err := anchoredRegexp(pattern)
+                        if err != nil {
+                                return fmt.Errorf("invalid --%s pattern: %w", f.flag, err)
+                        }
+                        *f.re = re
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                if len(contexts) == 0 {
+                        contexts = []string{cfg.CurrentContext}
+                } else if len(contexts) == 1 && contexts[0] == "all" {
+                        contexts = nil
+                        for name := range cfg.Clusters {
+                                contexts = append(contexts, name)
+                        }
+                        sort.Strings(contexts)
+                }
+
+                var collectors []exporter.Collector
+                for _, name := range contexts {
+                        client, err := clientForContext(cfg, name)
+                        if err != nil {
+                                return err
+                        }
+                        collectors = append(collectors, &clusterCollector{name: name, client: client, filter: filter, concurrency: concurrency})
+                        if withCanary {
+                                collectors = append(collectors, &canaryCollector{name: name, client: client, topic: canaryTopic, timeout: canaryTimeout})
+                        }
+                }
+
+                metrics := exporter.New(cacheTTL, collectors...)
+                mux := http.NewServeMux()
+                mux.Handle("/metrics", metrics)
+                mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+                        if r.URL.Path != "/" {
+                                http.NotFound(w, r)
+                                return
+                        }
+                        fmt.Fprintln(w, "kafy exporter: metrics are served on /metrics")
+                })
+                server := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+                sigChan := make(chan os.Signal, 1)
+                signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+                defer signal.Stop(sigChan)
+                go func() {
+                        <-sigChan
+                        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+                        defer cancel()
+                        server.Shutdown(ctx)
+                }()
+
+                fmt.Fprintf(os.Stderr, "Serving metrics for %v on http://%s/metrics (cache TTL %s)\n", contexts, listen, cacheTTL)
+                if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+                        return err
+                }
+                return nil
+        },
+}
+
+// exporterFilter selects the topics and groups that are exported
+type exporterFilter struct {
+        topics, excludeTopics *regexp.Regexp
+        groups, excludeGroups *regexp.Regexp
+}
+
+func (f exporterFilter) topic(name string) bool {
+        return (f.topics == nil || f.topics.MatchString(name)) && (f.excludeTopics == nil || !f.excludeTopics.MatchString(name))
+}
+
+func (f exporterFilter) group(name string) bool {
+        return (f.groups == nil || f.groups.MatchString(name)) && (f.excludeGroups == nil || !f.excludeGroups.MatchString(name))
+}
+
+// clusterCollector collects the broker, partition and consumer group metrics of one cluster
+type clusterCollector struct {
+        name        string
+        client      *kafkaClient.Client
+        filter      exporterFilter
+        concurrency int
+}
+
+func (c *clusterCollector) Name() string { return c.name }
+
+func (c *clusterCollector) Collect() ([]*exporter.Family, error) {
+        cluster := c.name
+
+        brokers, err := c.client.ListBrokers()
+        if err != nil {
+                return nil, fmt.Errorf("failed to list brokers: %w", err)
+        }
+        brokerCount := exporter.NewGauge("kafy_brokers", "Number of live brokers")
+        brokerCount.Add(float64(len(brokers)), "cluster", cluster)
+
+        all, err := c.client.ListTopicPartitions()
+        if err != nil {
+                return []*exporter.Family{brokerCount}, fmt.Errorf("failed to list topics: %w", err)
+        }
+        var topics []kafkaClient.TopicInfo
+        for _, topic := range all {
+                if c.filter.topic(topic.Name) {
+                        topics = append(topics, topic)
+                }
+        }
+        sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+
+        families := append([]*exporter.Family{brokerCount}, c.partitionMetrics(topics)...)
+        families = append(families, c.healthMetrics(topics)...)
+
+        ends := make(map[string]map[int32]int64)
+        offsets, err := c.offsetMetrics(topics, ends)
+        families = append(families, offsets...)
+        if err != nil {
+                return families, err
+        }
+
+        groups, err := c.groupMetrics(ends)
+        families = append(families, groups...)
+        return families, err
+}
+
+func (c *clusterCollector) partitionMetrics(topics []kafkaClient.TopicInfo) []*exporter.Family {
+        count := exporter.NewGauge("kafy_topic_partitions", "Number of partitions of the topic")
+        leader := exporter.NewGauge("kafy_partition_leader", "Broker id of the partition leader, -1 when the partition is offline")
+        replicas := exporter.NewGauge("kafy_partition_replicas", "Number of replicas of the partition")
+        isr := exporter.NewGauge("kafy_partition_in_sync_replicas", "Number of in-sync replicas of the partition")
+
+        for _, topic := range topics {
+                count.Add(float64(len(topic.PartitionDetails)), "cluster", c.name, "topic", topic.Name)
+                for _, p := range topic.PartitionDetails {
+                        labels := []string{"cluster", c.name, "topic", topic.Name, "partition", strconv.Itoa(int(p.ID))}
+                        leader.Add(float64(p.Leader), labels...)
+                        replicas.Add(float64(len(p.Replicas)), labels...)
+                        isr.Add(float64(len(p.Isr)), labels...)
+                }
+        }
+        return []*exporter.Family{count, leader, replicas, isr}
+}
+
+// healthMetrics exports the partition health checks and the number of partitions each one flags
+func (c *clusterCollector) healthMetrics(topics []kafkaClient.TopicInfo) []*exporter.Family {
+        status := exporter.NewGauge("kafy_health_check_status", "Status of the health check: 0 pass, 1 warn, 2 fail")
+        findings := exporter.NewGauge("kafy_health_check_findings", "Number of findings of the health check")
+        counts := map[string]*exporter.Family{
+                "offline-partitions": exporter.NewGauge("kafy_offline_partitions", "Number of partitions without a leader"),
+                "under-replicated":   exporter.NewGauge("kafy_under_replicated_partitions", "Number of partitions with fewer in-sync replicas than replicas"),
+                "under-min-isr":      exporter.NewGauge("kafy_under_min_isr_partitions", "Number of partitions with fewer in-sync replicas than min.insync.replicas"),
+        }
+
+        families := []*exporter.Family{status, findings}
+        for _, check := range partitionChecks(c.client, topics) {
+                var value float64
+                switch check.Status {
+                case health.Warn:
+                        value = 1
+                case health.Fail:
+                        value = 2
+                }
+                status.Add(value, "cluster", c.name, "check", check.Name)
+                findings.Add(float64(len(check.Findings)), "cluster", c.name, "check", check.Name)
+                if family, ok := counts[check.Name]; ok {
+                        family.Add(float64(len(check.Findings)), "cluster", c.name)
+                        families = append(families, family)
+                }
+        }
+        return families
+}
+
+// offsetMetrics exports the log start and end offsets and records the end offsets in ends
+func (c *clusterCollector) offsetMetrics(topics []kafkaClient.TopicInfo, ends map[string]map[int32]int64) ([]*exporter.Family, error) {
+        start := exporter.NewGauge("kafy_partition_start_offset", "Log start offset of the partition")
+        end := exporter.NewGauge("kafy_partition_end_offset", "Log end offset (high watermark) of the partition")
+        families := []*exporter.Family{start, end}
+
+        consumer, err := c.client.CreateConsumer(fmt.Sprintf("kafy-exporter-%d", time.Now().UnixNano()))
+        if err != nil {
+                return families, fmt.Errorf("failed to create consumer: %w", err)
+        }
+        defer consumer.Close()
+
+        for _, topic := range topics {
+                ends[topic.Name] = make(map[int32]int64)
+                for _, p := range topic.PartitionDetails {
+                        low, high, err := consumer.QueryWatermarkOffsets(topic.Name, p.ID, 5000)
+                        if err != nil {
+                                continue
+                        }
+                        labels := []string{"cluster", c.name, "topic", topic.Name, "partition", strconv.Itoa(int(p.ID))}
+                        start.Add(float64(low), labels...)
+                        end.Add(float64(high), labels...)
+                        ends[topic.Name][p.ID] = high
+                }
+        }
+        return families, nil
+}
+
+// groupMetrics exports the state, committed offsets and lag of the groups on the exported topics
+func (c *clusterCollector) groupMetrics(ends map[string]map[int32]int64) ([]*exporter.Family, error) {
+        members := exporter.NewGauge("kafy_consumergroup_members", "Number of members of the consumer group")
+        state := exporter.NewGauge("kafy_consumergroup_state", "1 for the current state of the consumer group")
+        committed := exporter.NewGauge("kafy_consumergroup_committed_offset", "Committed offset of the consumer group on the partition")
+        lag := exporter.NewGauge("kafy_consumergroup_lag", "Lag of the consumer group on the partition")
+        topicLag := exporter.NewGauge("kafy_consumergroup_topic_lag", "Total lag of the consumer group on the topic")
+        families := []*exporter.Family{members, state, committed, lag, topicLag}
+
+        summaries, err := c.client.ListConsumerGroups()
+        if err != nil {
+                return families, fmt.Errorf("failed to list consumer groups: %w", err)
+        }
+        var groups []string
+        for _, summary := range summaries {
+                if !c.filter.group(summary.GroupID) {
+                        continue
+                }
+                groups = append(groups, summary.GroupID)
+                members.Add(float64(summary.MemberCount), "cluster", c.name, "group", summary.GroupID)
+                state.Add(1, "cluster", c.name, "group", summary.GroupID, "state", summary.State)
+        }
+        sort.Strings(groups)
+
+        index, failed, err := c.client.BuildGroupOffsetIndex(groups, c.concurrency)
+        if err != nil {
+                return families, err
+        }
+        if len(failed) > 0 {
+                fmt.Fprintf(os.Stderr, "Warning: could not read the offsets of %d groups on %s\n", len(failed), c.name)
+        }
+
+        for _, group := range groups {
+                var topics []string
+                for topic, byGroup := range index {
+                        if _, ok := byGroup[group]; ok && ends[topic] != nil {
+                                topics = append(topics, topic)
+                        }
+                }
+                sort.Strings(topics)
+
+                for _, topic := range topics {
+                        offsets := index[topic][group]
+                        partitions := make([]int32, 0, len(offsets))
+                        for partition := range offsets {
+                                partitions = append(partitions, partition)
+                        }
+                        sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+                        var total int64
+                        for _, partition := range partitions {
+                                labels := []string{"cluster", c.name, "group", group, "topic", topic, "partition", strconv.Itoa(int(partition))}
+                                committed.Add(float64(offsets[partition]), labels...)
+                                high, ok := ends[topic][partition]
+                                if !ok {
+                                        continue
+                                }
+                                var partitionLag int64
+                                if high > offsets[partition] {
+                                        partitionLag = high - offsets[partition]
+                                }
+                                total += partitionLag
+                                lag.Add(float64(partitionLag), labels...)
+                        }
+                        topicLag.Add(float64(total), "cluster", c.name, "group", group, "topic", topic)
+                }
+        }
+        return families, nil
+}
+
+// canaryCollector runs a canary round per collection. The canary is created
+// at the first collection and kept, so its probes continue where they left off.
+type canaryCollector struct {
+        name    string
+        client  *kafkaClient.Client
+        topic   string
+        timeout time.Duration
+        canary  *canary
+}
+
+func (c *canaryCollector) Name() string { return c.name + " canary" }
+
+func (c *canaryCollector) Collect() ([]*exporter.Family, error) {
+        if c.canary == nil {
+                probe, err := newCanary(c.client, c.topic, c.timeout)
+                if err != nil {
+                        return nil, err
+                }
+                c.canary = probe
+        }
+        round, err := c.canary.Probe()
+        if err != nil {
+                return nil, err
+        }
+
+        up := exporter.NewGauge("kafy_canary_up", "1 when the canary probe to the partition was produced and consumed")
+        produce := exporter.NewGauge("kafy_canary_produce_latency_seconds", "Produce latency of the last canary probe to the partition")
+        endToEnd := exporter.NewGauge("kafy_canary_end_to_end_latency_seconds", "End-to-end latency of the last canary probe to the partition")
+        for _, p := range round.Probes {
+                labels := []string{"cluster", c.name, "broker", strconv.Itoa(int(p.Broker)), "partition", strconv.Itoa(int(p.Partition))}
+                if p.Status != "ok" {
+                        up.Add(0, labels...)
+                        continue
+                }
+                up.Add(1, labels...)
+                produce.Add(math.Round(p.ProduceMs*1000)/1e6, labels...)
+                endToEnd.Add(math.Round(p.EndToEnd*1000)/1e6, labels...)
+        }
+
+        sent := exporter.NewCounter("kafy_canary_probes_total", "Canary probes sent to partitions led by the broker")
+        failed := exporter.NewCounter("kafy_canary_probes_failed_total", "Canary probes to partitions led by the broker whose produce failed")
+        lost := exporter.NewCounter("kafy_canary_probes_lost_total", "Canary probes to partitions led by the broker that were never consumed")
+        for _, s := range c.canary.Stats() {
+                labels := []string{"cluster", c.name, "broker", strconv.Itoa(int(s.Broker))}
+                sent.Add(float64(s.Sent), labels...)
+                failed.Add(float64(s.Failed), labels...)
+                lost.Add(float64(s.Lost), labels...)
+        }
+        return []*exporter.Family{up, produce, endToEnd, sent, failed, lost}, nil
+}
+
+func init() {
+        exporterCmd.Flags().String("listen", ":9787", "Address to serve /metrics on")
+        exporterCmd.Flags().Duration("cache-ttl", 30*time.Second, "Reuse collected metrics for this long across scrapes")
+        exporterCmd.Flags().StringSlice("contexts", []string{}, "Cluster contexts to export, comma-separated, or 'all' (default: current context)")
+        exporterCmd.Flags().String("topics", "", "Only export topics matching this regular expression")
+        exporterCmd.Flags().String("exclude-topics", "", "Skip topics matching this regular expression")
+        exporterCmd.Flags().String("groups", "", "Only export consumer groups matching this regular expression")
+        exporterCmd.Flags().String("exclude-groups", "", "Skip consumer groups matching this regular expression")
+        exporterCmd.Flags().Int("concurrency", 16, "Consumer groups whose offsets are read in parallel")
+        exporterCmd.Flags().Bool("canary", false, "Also run a canary round at every collection (see 'kafy health canary')")
+        exporterCmd.Flags().String("canary-topic", defaultCanaryTopic, "Canary topic, created with one partition per broker when missing")
+        exporterCmd.Flags().Duration("canary-timeout", 10*time.Second, "Time to wait for canary probes to be acknowledged and consumed")
+}
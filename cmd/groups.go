@@ -46,8 +46,7 @@ var groupsListCmd = &cobra.Command{
                         })
                 }
 
-                formatter.OutputTable(headers, rows)
-                return nil
+                return formatter.OutputTable(headers, rows)
         },
 }
 
@@ -91,88 +90,13 @@ var groupsDescribeCmd = &cobra.Command{
                                 }
                         }
                         
-                        formatter.OutputTable(headers, rows)
-                        return nil
+                        return formatter.OutputTable(headers, rows)
                 }
                 
                 return formatter.Output(groupInfo)
         },
 }
 
-var groupsLagCmd = &cobra.Command{
-        Use:   "lag <group>",
-        Short: "Show lag metrics per partition",
-        Args:  cobra.ExactArgs(1),
-        RunE: func(cmd *cobra.Command, args []string) error {
-                groupID := args[0]
-                
-                cfg, err := LoadConfigWithClusterOverride()
-                if err != nil {
-                        return err
-                }
-
-                client, err := kafkaClient.NewClient(cfg)
-                if err != nil {
-                        return err
-                }
-
-                lag, err := client.GetConsumerGroupLag(groupID)
-                if err != nil {
-                        return err
-                }
-
-                formatter := getFormatter()
-                if len(lag) == 0 {
-                        fmt.Printf("No lag data available for group '%s'\n", groupID)
-                        return nil
-                }
-                
-                if formatter.Format == "table" {
-                        headers := []string{"Topic", "Partition", "Lag"}
-                        var rows [][]string
-                        totalLagPerTopic := make(map[string]int64)
-                        var totalLag int64
-                        
-                        for topic, partitions := range lag {
-                                for partition, lagValue := range partitions {
-                                        rows = append(rows, []string{
-                                                topic,
-                                                strconv.Itoa(int(partition)),
-                                                strconv.Itoa(int(lagValue)),
-                                        })
-                                        totalLagPerTopic[topic] += lagValue
-                                        totalLag += lagValue
-                                }
-                        }
-                        
-                        formatter.OutputTable(headers, rows)
-                        
-                        // Display summary with total lag per topic and overall total
-                        fmt.Println("\nLag Summary:")
-                        summaryHeaders := []string{"Topic", "Total Lag"}
-                        var summaryRows [][]string
-                        
-                        for topic, topicLag := range totalLagPerTopic {
-                                summaryRows = append(summaryRows, []string{
-                                        topic,
-                                        strconv.Itoa(int(topicLag)),
-                                })
-                        }
-                        
-                        // Add overall total
-                        summaryRows = append(summaryRows, []string{
-                                "TOTAL",
-                                strconv.Itoa(int(totalLag)),
-                        })
-                        
-                        formatter.OutputTable(summaryHeaders, summaryRows)
-                        return nil
-                }
-                
-                return formatter.Output(lag)
-        },
-}
-
 var groupsResetCmd = &cobra.Command{
         Use:   "reset <group>",
         Short: "Reset consumer group offsets",
@@ -0,0 +1,502 @@
+package cmd
+
+import (
+        "fmt"
+        "os"
+        "os/signal"
+        "regexp"
+        "sort"
+        "strconv"
+        "syscall"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        kafkaClient "kafy/internal/kafka"
+)
+
+var groupsLagCmd = &cobra.Command{
+        Use:   "lag [group]",
+        Short: "Show lag metrics per partition",
+        Long: `Show the lag of a consumer group per partition: committed and end offsets and offset lag. With
+--age, the record at the committed offset of every lagging partition is read to show the age of
+the oldest unconsumed record; that costs a fetch per lagging partition on every refresh.
+
+With --watch the view refreshes every --interval and adds the consume and produce rates, the lag
+trend since the previous refresh and the estimated time until the lag reaches zero. Use
+--group-pattern to watch every group matching a regular expression, --worst to list the worst
+partitions (oldest unconsumed record with --age, then largest lag) first and --top to limit the rows.
+
+Examples:
+  kafy groups lag billing
+  kafy groups lag billing --watch --interval 5s --age
+  kafy groups lag --group-pattern 'billing-.*' --watch --worst --top 20
+  kafy groups lag billing --output json`,
+        Args: cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+                pattern, _ := cmd.Flags().GetString("group-pattern")
+                watch, _ := cmd.Flags().GetBool("watch")
+                interval, _ := cmd.Flags().GetDuration("interval")
+                worst, _ := cmd.Flags().GetBool("worst")
+                top, _ := cmd.Flags().GetInt("top")
+                withAge, _ := cmd.Flags().GetBool("age")
+
+                if (len(args) == 0) == (pattern == "") {
+                        return fmt.Errorf("specify either a group or --group-pattern")
+                }
+                if interval < time.Second {
+                        return fmt.Errorf("--interval must be at least 1s")
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                watcher := &lagWatcher{client: client, withAge: withAge}
+                if pattern != "" {
+                        if watcher.pattern, err = anchoredRegexp(pattern); err != nil {
+                                return fmt.Errorf("invalid --group-pattern: %w", err)
+                        }
+                        watcher.patternText = pattern
+                } else {
+                        watcher.groups = []string{args[0]}
+                }
+
+                // The watcher only reads at committed offsets of other groups; committing would leave its own group behind
+                watcher.consumer, err = client.CreateConsumerWithOptions(fmt.Sprintf("kafy-lag-watch-%d", time.Now().Unix()), kafkaClient.ConsumerOptions{
+                        OffsetReset:       "earliest",
+                        DisableAutoCommit: true,
+                })
+                if err != nil {
+                        return fmt.Errorf("failed to create consumer: %w", err)
+                }
+                defer watcher.consumer.Close()
+
+                if !watch {
+                        snapshot, err := watcher.take()
+                        if err != nil {
+                                return err
+                        }
+                        if len(snapshot.partitions) == 0 {
+                                fmt.Printf("No committed offsets found for %s\n", watcher.describe())
+                                return nil
+                        }
+                        return outputLag(snapshot.rows(worst, top), false)
+                }
+
+                sigChan := make(chan os.Signal, 1)
+                signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+                defer signal.Stop(sigChan)
+
+                ticker := time.NewTicker(interval)
+                defer ticker.Stop()
+                clear := isTerminal(os.Stdout) && getFormatter().Format == "table"
+                for {
+                        snapshot, err := watcher.take()
+                        if err != nil {
+                                return err
+                        }
+                        if clear {
+                                fmt.Print("\033[H\033[2J")
+                        }
+                        if getFormatter().Format == "table" {
+                                fmt.Printf("Every %s: lag of %s at %s (Ctrl-C to stop)\n\n", interval, watcher.describe(), snapshot.at.Format("15:04:05"))
+                        }
+                        if err := outputLag(snapshot.rows(worst, top), snapshot.rated); err != nil {
+                                return err
+                        }
+
+                        select {
+                        case <-sigChan:
+                                return nil
+                        case <-ticker.C:
+                        }
+                }
+        },
+}
+
+// lagKey identifies a partition of a group
+type lagKey struct {
+        group     string
+        topic     string
+        partition int32
+}
+
+// lagPartition is the lag of one partition of a group. Rates, trend and time
+// to zero compare with the previous snapshot and are nil in the first one.
+type lagPartition struct {
+        Group       string   `json:"group" yaml:"group"`
+        Topic       string   `json:"topic" yaml:"topic"`
+        Partition   int32    `json:"partition" yaml:"partition"`
+        Committed   int64    `json:"committed_offset" yaml:"committed_offset"`
+        End         int64    `json:"end_offset" yaml:"end_offset"`
+        Lag         int64    `json:"lag" yaml:"lag"`
+        LagChange   *int64   `json:"lag_change,omitempty" yaml:"lag_change,omitempty"`
+        ConsumeRate *float64 `json:"consume_rate,omitempty" yaml:"consume_rate,omitempty"` // Records per second
+        ProduceRate *float64 `json:"produce_rate,omitempty" yaml:"produce_rate,omitempty"`
+        OldestAge   *float64 `json:"oldest_unconsumed_age_seconds,omitempty" yaml:"oldest_unconsumed_age_seconds,omitempty"`
+        TimeToZero  *float64 `json:"time_to_zero_seconds,omitempty" yaml:"time_to_zero_seconds,omitempty"` // Nil when the lag is not shrinking
+}
+
+type lagSnapshot struct {
+        at         time.Time
+        partitions map[lagKey]*lagPartition
+        rated      bool // Rates were computed against a previous snapshot
+}
+
+// lagWatcher takes lag snapshots of a fixed group or of every group matching a pattern
+type lagWatcher struct {
+        client      *kafkaClient.Client
+        consumer    *kafka.Consumer
+        groups      []string
+        pattern     *regexp.Regexp
+        patternText string
+        withAge     bool
+        last        *lagSnapshot
+}
+
+func (w *lagWatcher) describe() string {
+        if w.pattern != nil {
+                return fmt.Sprintf("groups matching '%s'", w.patternText)
+        }
+        return fmt.Sprintf("group '%s'", w.groups[0])
+}
+
+// take reads the committed and end offsets of every watched partition
+func (w *lagWatcher) take() (*lagSnapshot, error) {
+        groups := w.groups
+        if w.pattern != nil {
+                // Groups come and go, so the pattern is matched on every refresh
+                summaries, err := w.client.ListConsumerGroups()
+                if err != nil {
+                        return nil, fmt.Errorf("failed to list consumer groups: %w", err)
+                }
+                groups = nil
+                for _, summary := range summaries {
+                        if w.pattern.MatchString(summary.GroupID) {
+                                groups = append(groups, summary.GroupID)
+                        }
+                }
+        }
+
+        snapshot := &lagSnapshot{at: time.Now(), partitions: make(map[lagKey]*lagPartition), rated: w.last != nil}
+        for _, group := range groups {
+                committed, err := w.client.GetConsumerGroupOffsets(group)
+                if err != nil {
+                        return nil, err
+                }
+                for topic, partitions := range committed {
+                        for partition, offset := range partitions {
+                                _, high, err := w.consumer.QueryWatermarkOffsets(topic, partition, 5000)
+                                if err != nil {
+                                        continue
+                                }
+                                p := &lagPartition{Group: group, Topic: topic, Partition: partition, Committed: offset, End: high}
+                                if high > offset {
+                                        p.Lag = high - offset
+                                }
+                                snapshot.partitions[lagKey{group, topic, partition}] = p
+                        }
+                }
+        }
+
+        if w.withAge {
+                w.readOldestUnconsumed(snapshot)
+        }
+        if w.last != nil {
+                elapsed := snapshot.at.Sub(w.last.at).Seconds()
+                for key, p := range snapshot.partitions {
+                        before, ok := w.last.partitions[key]
+                        if !ok || elapsed <= 0 {
+                                continue
+                        }
+                        change := p.Lag - before.Lag
+                        consumeRate := float64(p.Committed-before.Committed) / elapsed
+                        produceRate := float64(p.End-before.End) / elapsed
+                        p.LagChange, p.ConsumeRate, p.ProduceRate = &change, &consumeRate, &produceRate
+                        if drain := consumeRate - produceRate; p.Lag == 0 || drain > 0 {
+                                seconds := 0.0
+                                if p.Lag > 0 {
+                                        seconds = float64(p.Lag) / drain
+                                }
+                                p.TimeToZero = &seconds
+                        }
+                }
+        }
+        w.last = snapshot
+        return snapshot, nil
+}
+
+// readOldestUnconsumed reads the record at the committed offset of every
+// lagging partition; its timestamp is the age of the oldest unconsumed record
+func (w *lagWatcher) readOldestUnconsumed(snapshot *lagSnapshot) {
+        // Groups on one partition can be at different offsets, but a consumer has one
+        // position per partition, so each round reads the next offset of every partition
+        offsets := make(map[kafkaClient.TopicPartition][]int64)
+        for _, p := range snapshot.partitions {
+                if p.Lag == 0 {
+                        continue
+                }
+                tp := kafkaClient.TopicPartition{Topic: p.Topic, Partition: p.Partition}
+                known := false
+                for _, offset := range offsets[tp] {
+                        known = known || offset == p.Committed
+                }
+                if !known {
+                        offsets[tp] = append(offsets[tp], p.Committed)
+                }
+        }
+
+        found := make(map[oldestRecordKey]time.Time)
+        deadline := time.Now().Add(5 * time.Second)
+        for round := 0; time.Now().Before(deadline); round++ {
+                positions := make(map[kafkaClient.TopicPartition]int64)
+                for tp, list := range offsets {
+                        if round < len(list) {
+                                positions[tp] = list[round]
+                        }
+                }
+                if len(positions) == 0 {
+                        break
+                }
+                w.readRecordsAt(positions, found, deadline)
+        }
+
+        for _, p := range snapshot.partitions {
+                if p.Lag == 0 {
+                        continue
+                }
+                key := oldestRecordKey{kafkaClient.TopicPartition{Topic: p.Topic, Partition: p.Partition}, p.Committed}
+                if timestamp, ok := found[key]; ok && !timestamp.IsZero() {
+                        age := snapshot.at.Sub(timestamp).Seconds()
+                        if age < 0 {
+                                age = 0
+                        }
+                        p.OldestAge = &age
+                }
+        }
+}
+
+// oldestRecordKey is a partition and the committed offset its record was read at
+type oldestRecordKey struct {
+        tp     kafkaClient.TopicPartition
+        offset int64
+}
+
+// readRecordsAt reads the first record at or after each position into found
+func (w *lagWatcher) readRecordsAt(positions map[kafkaClient.TopicPartition]int64, found map[oldestRecordKey]time.Time, deadline time.Time) {
+        var assignment []kafka.TopicPartition
+        for tp, offset := range positions {
+                topic := tp.Topic
+                assignment = append(assignment, kafka.TopicPartition{Topic: &topic, Partition: tp.Partition, Offset: kafka.Offset(offset)})
+        }
+        if err := w.consumer.Assign(assignment); err != nil {
+                return
+        }
+        defer func() {
+                // Paused partitions would stay paused when assigned again
+                w.consumer.Resume(assignment)
+                w.consumer.Unassign()
+        }()
+
+        read := 0
+        for read < len(positions) && time.Now().Before(deadline) {
+                msg, err := w.consumer.ReadMessage(500 * time.Millisecond)
+                if err != nil {
+                        continue
+                }
+                tp := kafkaClient.TopicPartition{Topic: *msg.TopicPartition.Topic, Partition: msg.TopicPartition.Partition}
+                offset, ok := positions[tp]
+                if !ok {
+                        continue
+                }
+                key := oldestRecordKey{tp, offset}
+                if _, done := found[key]; done {
+                        continue
+                }
+                found[key] = msg.Timestamp
+                read++
+                w.consumer.Pause([]kafka.TopicPartition{msg.TopicPartition})
+        }
+}
+
+// rows orders the partitions by group, topic and partition, or worst first
+func (s *lagSnapshot) rows(worst bool, top int) []*lagPartition {
+        rows := make([]*lagPartition, 0, len(s.partitions))
+        for _, p := range s.partitions {
+                rows = append(rows, p)
+        }
+        sort.Slice(rows, func(i, j int) bool {
+                a, b := rows[i], rows[j]
+                if worst {
+                        ageA, ageB := optionalFloat(a.OldestAge), optionalFloat(b.OldestAge)
+                        if ageA != ageB {
+                                return ageA > ageB
+                        }
+                        if a.Lag != b.Lag {
+                                return a.Lag > b.Lag
+                        }
+                }
+                if a.Group != b.Group {
+                        return a.Group < b.Group
+                }
+                if a.Topic != b.Topic {
+                        return a.Topic < b.Topic
+                }
+                return a.Partition < b.Partition
+        })
+        if top > 0 && len(rows) > top {
+                rows = rows[:top]
+        }
+        return rows
+}
+
+func optionalFloat(v *float64) float64 {
+        if v == nil {
+                return -1
+        }
+        return *v
+}
+
+// outputLag prints the partitions and, in table format, a per-group summary
+func outputLag(rows []*lagPartition, withRates bool) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                return formatter.Output(rows)
+        }
+
+        headers := []string{"Group", "Topic", "Partition", "Committed", "End", "Lag", "Oldest Unconsumed"}
+        if withRates {
+                headers = append(headers, "Trend", "Consume/s", "Produce/s", "Time To Zero")
+        }
+        var tableRows [][]string
+        for _, p := range rows {
+                row := []string{
+                        p.Group,
+                        p.Topic,
+                        strconv.Itoa(int(p.Partition)),
+                        strconv.FormatInt(p.Committed, 10),
+                        strconv.FormatInt(p.End, 10),
+                        strconv.FormatInt(p.Lag, 10),
+                        formatAge(p.OldestAge),
+                }
+                if withRates {
+                        row = append(row, formatLagChange(p.LagChange), formatRecordRate(p.ConsumeRate), formatRecordRate(p.ProduceRate), formatTimeToZero(p))
+                }
+                tableRows = append(tableRows, row)
+        }
+        if err := formatter.OutputTable(headers, tableRows); err != nil {
+                return err
+        }
+
+        // Summary per group
+        type groupTotal struct {
+                partitions   int
+                lag          int64
+                consume      float64
+                produce      float64
+                oldest       *float64
+                missingRates bool
+        }
+        totals := make(map[string]*groupTotal)
+        var groups []string
+        for _, p := range rows {
+                t, ok := totals[p.Group]
+                if !ok {
+                        t = &groupTotal{}
+                        totals[p.Group] = t
+                        groups = append(groups, p.Group)
+                }
+                t.partitions++
+                t.lag += p.Lag
+                if p.ConsumeRate != nil {
+                        t.consume += *p.ConsumeRate
+                        t.produce += *p.ProduceRate
+                } else {
+                        t.missingRates = true
+                }
+                if p.OldestAge != nil && (t.oldest == nil || *p.OldestAge > *t.oldest) {
+                        t.oldest = p.OldestAge
+                }
+        }
+        sort.Strings(groups)
+
+        fmt.Println("\nLag Summary:")
+        headers = []string{"Group", "Partitions", "Total Lag", "Oldest Unconsumed"}
+        if withRates {
+                headers = append(headers, "Consume/s", "Produce/s", "Time To Zero")
+        }
+        tableRows = nil
+        for _, group := range groups {
+                t := totals[group]
+                row := []string{group, strconv.Itoa(t.partitions), strconv.FormatInt(t.lag, 10), formatAge(t.oldest)}
+                if withRates {
+                        if t.missingRates {
+                                row = append(row, "-", "-", "-")
+                        } else {
+                                summary := &lagPartition{Lag: t.lag}
+                                if drain := t.consume - t.produce; t.lag == 0 || drain > 0 {
+                                        seconds := 0.0
+                                        if t.lag > 0 {
+                                                seconds = float64(t.lag) / drain
+                                        }
+                                        summary.TimeToZero = &seconds
+                                }
+                                row = append(row, formatRecordRate(&t.consume), formatRecordRate(&t.produce), formatTimeToZero(summary))
+                        }
+                }
+                tableRows = append(tableRows, row)
+        }
+        return formatter.OutputTable(headers, tableRows)
+}
+
+func formatAge(seconds *float64) string {
+        if seconds == nil {
+                return "-"
+        }
+        return (time.Duration(*seconds * float64(time.Second))).Round(time.Second).String()
+}
+
+func formatLagChange(change *int64) string {
+        switch {
+        case change == nil:
+                return "-"
+        case *change > 0:
+                return fmt.Sprintf("↑ +%d", *change)
+        case *change < 0:
+                return fmt.Sprintf("↓ %d", *change)
+        }
+        return "= 0"
+}
+
+func formatRecordRate(rate *float64) string {
+        if rate == nil {
+                return "-"
+        }
+        return strconv.FormatFloat(*rate, 'f', 1, 64)
+}
+
+func formatTimeToZero(p *lagPartition) string {
+        switch {
+        case p.Lag == 0:
+                return "caught up"
+        case p.TimeToZero == nil:
+                return "never"
+        }
+        return formatAge(p.TimeToZero)
+}
+
+func init() {
+        groupsLagCmd.Flags().Bool("watch", false, "Refresh the view every --interval")
+        groupsLagCmd.Flags().Duration("interval", 5*time.Second, "Refresh interval for --watch")
+        groupsLagCmd.Flags().String("group-pattern", "", "Regular expression of groups to show, e.g. 'billing-.*'")
+        groupsLagCmd.Flags().Bool("worst", false, "List the worst partitions first (oldest unconsumed record, then largest lag)")
+        groupsLagCmd.Flags().Int("top", 0, "Show only this many partitions (0 = all)")
+        groupsLagCmd.Flags().Bool("age", false, "Read the oldest unconsumed record of each lagging partition to show its age (one fetch per lagging partition)")
+}
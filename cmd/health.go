@@ -2,9 +2,14 @@ package cmd
 
 import (
         "fmt"
+        "os"
+        "strconv"
         "strings"
+        "time"
 
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
         "github.com/spf13/cobra"
+        "kafy/internal/health"
         kafkaClient "kafy/internal/kafka"
 )
 
@@ -14,43 +19,6 @@ var healthCmd = &cobra.Command{
         Long:  "Commands for checking Kafka cluster health",
 }
 
-var healthCheckCmd = &cobra.Command{
-        Use:   "check",
-        Short: "Run all health checks",
-        RunE: func(cmd *cobra.Command, args []string) error {
-                fmt.Println("Running comprehensive health checks...")
-                fmt.Println()
-                
-                // Check brokers
-                fmt.Println("🔍 Checking brokers...")
-                if err := checkBrokers(); err != nil {
-                        fmt.Printf("❌ Brokers: %v\n", err)
-                } else {
-                        fmt.Println("✅ Brokers: OK")
-                }
-                fmt.Println()
-
-                // Check topics
-                fmt.Println("🔍 Checking topics...")
-                if err := checkTopics(); err != nil {
-                        fmt.Printf("❌ Topics: %v\n", err)
-                } else {
-                        fmt.Println("✅ Topics: OK")
-                }
-                fmt.Println()
-
-                // Check groups
-                fmt.Println("🔍 Checking consumer groups...")
-                if err := checkGroups(); err != nil {
-                        fmt.Printf("❌ Consumer Groups: %v\n", err)
-                } else {
-                        fmt.Println("✅ Consumer Groups: OK")
-                }
-
-                return nil
-        },
-}
-
 var healthBrokersCmd = &cobra.Command{
         Use:   "brokers",
         Short: "Check broker connectivity",
@@ -181,13 +149,21 @@ func getExpectedBrokerIDsFromTopics(client *kafkaClient.Client) (map[int32]bool,
 
 var healthTopicsCmd = &cobra.Command{
         Use:   "topics",
-        Short: "Check topic accessibility and health",
+        Short: "Check partition health across all topics",
+        Long: `Scan every partition of every topic and report:
+  offline-partitions  partitions without a leader (fail)
+  under-min-isr       fewer in-sync replicas than the topic's min.insync.replicas (fail)
+  under-replicated    fewer in-sync replicas than replicas (warn)
+  preferred-leader    partitions not led by their preferred replica (informational)
+
+Findings are listed most severe first. Use --output json for a machine-readable report.
+Exit codes are 0 (pass), 2 (warn) and 3 (fail).`,
         RunE: func(cmd *cobra.Command, args []string) error {
-                return checkTopics()
+                return checkTopics(cmd)
         },
 }
 
-func checkTopics() error {
+func checkTopics(cmd *cobra.Command) error {
         cfg, err := LoadConfigWithClusterOverride()
         if err != nil {
                 return err
@@ -198,92 +174,240 @@ func checkTopics() error {
                 return err
         }
 
-        topics, err := client.ListTopics()
+        topics, err := client.ListTopicPartitions()
         if err != nil {
                 return fmt.Errorf("failed to list topics: %w", err)
         }
 
-        fmt.Printf("✓ Successfully accessed %d topics\n", len(topics))
-        
-        // Check a few topics for detailed health
-        healthy := 0
-        checkCount := min(5, len(topics))
-        for i := 0; i < checkCount; i++ {
-                topic := topics[i]
-                
-                // Try to get topic details to verify accessibility
-                _, err := client.DescribeTopic(topic.Name)
-                if err != nil {
-                        fmt.Printf("  ⚠ Topic '%s': %v\n", topic.Name, err)
-                } else {
-                        healthy++
-                        fmt.Printf("  ✓ Topic '%s': accessible\n", topic.Name)
+        report := &health.Report{}
+        report.Add(partitionChecks(client, topics)...)
+
+        if err := outputHealthReport(report); err != nil {
+                return err
+        }
+        return healthExitError(cmd, report)
+}
+
+// partitionChecks scans every partition of every topic
+func partitionChecks(client *kafkaClient.Client, topics []kafkaClient.TopicInfo) []health.Check {
+        var names []string
+        var partitions []health.PartitionState
+        for _, topic := range topics {
+                names = append(names, topic.Name)
+                for _, p := range topic.PartitionDetails {
+                        partitions = append(partitions, health.PartitionState{
+                                Topic:     topic.Name,
+                                Partition: p.ID,
+                                Leader:    p.Leader,
+                                Replicas:  p.Replicas,
+                                Isr:       p.Isr,
+                        })
                 }
         }
-        
-        if len(topics) > 5 {
-                fmt.Printf("  ... and %d more topics\n", len(topics)-5)
+
+        minISR := make(map[string]int)
+        values, err := client.GetTopicsConfigValue(names, "min.insync.replicas")
+        if err != nil {
+                fmt.Fprintf(os.Stderr, "Warning: could not read min.insync.replicas, skipping that check: %v\n", err)
         }
-        
-        fmt.Printf("✓ %d/%d sampled topics are healthy\n", healthy, checkCount)
-        return nil
+        for topic, value := range values {
+                if n, err := strconv.Atoi(value); err == nil {
+                        minISR[topic] = n
+                }
+        }
+
+        return health.CheckPartitions(partitions, minISR)
+}
+
+// outputHealthReport prints the checks with their status, then every finding, most severe first
+func outputHealthReport(report *health.Report) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                return formatter.Output(report)
+        }
+
+        headers := []string{"Check", "Status", "Checked", "Findings", "Description"}
+        var rows [][]string
+        for _, c := range report.Checks {
+                rows = append(rows, []string{
+                        c.Name,
+                        strings.ToUpper(c.Status.String()),
+                        strconv.Itoa(c.Checked),
+                        strconv.Itoa(len(c.Findings)),
+                        c.Description,
+                })
+        }
+        if err := formatter.OutputTable(headers, rows); err != nil {
+                return err
+        }
+
+        ranked := report.Ranked()
+        if len(ranked) == 0 {
+                return nil
+        }
+        fmt.Println()
+        headers = []string{"Severity", "Check", "Subject", "Detail"}
+        rows = nil
+        for _, f := range ranked {
+                subject := f.Subject()
+                if subject == "" {
+                        subject = "-"
+                }
+                rows = append(rows, []string{strings.ToUpper(f.Status.String()), f.Check, subject, f.Message})
+        }
+        return formatter.OutputTable(headers, rows)
 }
 
 var healthGroupsCmd = &cobra.Command{
         Use:   "groups",
         Short: "Check consumer group health and status",
+        Long: `Assess consumer groups and report:
+  group-sampling     groups whose state or offsets could not be read, e.g. deleted meanwhile (warn)
+  group-rebalancing  groups in PreparingRebalance or CompletingRebalance in every sample (fail)
+  group-offset-loss  committed offsets below the log start offset, i.e. lost records (fail)
+  group-inactive     Empty groups with records waiting on the topics they committed to (warn)
+  group-unassigned   partitions of subscribed topics assigned to no member of a stable group (warn)
+  group-lag-growth   groups whose lag grew in every sample (warn)
+
+Stuck rebalances and growing lag need several samples: --samples 3 --interval 10s takes three
+samples ten seconds apart. kafy's own temporary groups (kafy-*) are skipped. Exit codes are
+0 (pass), 2 (warn) and 3 (fail).
+
+Examples:
+  kafy health groups
+  kafy health groups --group billing --group shipping --samples 5 --interval 30s
+  kafy health groups --samples 3 --output json`,
         RunE: func(cmd *cobra.Command, args []string) error {
-                return checkGroups()
+                groups, _ := cmd.Flags().GetStringArray("group")
+                samples, _ := cmd.Flags().GetInt("samples")
+                interval, _ := cmd.Flags().GetDuration("interval")
+                if samples < 1 {
+                        return fmt.Errorf("--samples must be at least 1")
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                topics, err := client.ListTopicPartitions()
+                if err != nil {
+                        return fmt.Errorf("failed to list topics: %w", err)
+                }
+
+                checks, err := groupChecks(client, topics, groups, samples, interval)
+                if err != nil {
+                        return err
+                }
+                report := &health.Report{}
+                report.Add(checks...)
+
+                if err := outputHealthReport(report); err != nil {
+                        return err
+                }
+                return healthExitError(cmd, report)
         },
 }
 
-func checkGroups() error {
-        cfg, err := LoadConfigWithClusterOverride()
-        if err != nil {
-                return err
+// groupChecks samples the groups (all groups when none are given) and assesses them
+func groupChecks(client *kafkaClient.Client, topics []kafkaClient.TopicInfo, groups []string, samples int, interval time.Duration) ([]health.Check, error) {
+        if len(groups) == 0 {
+                summaries, err := client.ListConsumerGroups()
+                if err != nil {
+                        return nil, fmt.Errorf("failed to list consumer groups: %w", err)
+                }
+                for _, summary := range summaries {
+                        if !strings.HasPrefix(summary.GroupID, health.ToolGroupPrefix) {
+                                groups = append(groups, summary.GroupID)
+                        }
+                }
         }
 
-        client, err := kafkaClient.NewClient(cfg)
-        if err != nil {
-                return err
+        partitions := make(map[string]int)
+        for _, topic := range topics {
+                partitions[topic.Name] = topic.Partitions
         }
 
-        groups, err := client.ListConsumerGroups()
+        consumer, err := client.CreateConsumer(fmt.Sprintf("kafy-health-groups-%d", time.Now().Unix()))
         if err != nil {
-                return fmt.Errorf("failed to list consumer groups: %w", err)
+                return nil, fmt.Errorf("failed to create consumer: %w", err)
         }
+        defer consumer.Close()
 
-        fmt.Printf("✓ Found %d consumer groups\n", len(groups))
-        
-        // Check a few groups for detailed health
-        healthy := 0
-        checkCount := min(5, len(groups))
-        for i := 0; i < checkCount; i++ {
-                group := groups[i]
-                
-                // Try to describe group to verify accessibility
-                _, err := client.DescribeConsumerGroup(group.GroupID)
-                if err != nil {
-                        fmt.Printf("  ⚠ Group '%s': %v\n", group.GroupID, err)
-                } else {
-                        healthy++
-                        fmt.Printf("  ✓ Group '%s': accessible\n", group.GroupID)
-                }
+        observed := make([]health.GroupSamples, len(groups))
+        for i, group := range groups {
+                observed[i].Group = group
         }
-        
-        if len(groups) > 5 {
-                fmt.Printf("  ... and %d more groups\n", len(groups)-5)
+        for n := 0; n < samples; n++ {
+                if n > 0 {
+                        fmt.Fprintf(os.Stderr, "Taking sample %d/%d in %s...\n", n+1, samples, interval)
+                        time.Sleep(interval)
+                }
+                for i, group := range groups {
+                        if observed[i].Error != "" {
+                                continue
+                        }
+                        // A group can be deleted between listing and sampling; that is no reason to drop the others
+                        sample, err := sampleGroup(client, consumer, group)
+                        if err != nil {
+                                observed[i].Error = err.Error()
+                                continue
+                        }
+                        observed[i].Samples = append(observed[i].Samples, sample)
+                }
         }
-        
-        fmt.Printf("✓ %d/%d sampled groups are healthy\n", healthy, checkCount)
-        return nil
+
+        return health.CheckGroups(observed, partitions), nil
 }
 
-func min(a, b int) int {
-        if a < b {
-                return a
+// sampleGroup records a group's state, assignment, committed offsets and the watermarks of its partitions
+func sampleGroup(client *kafkaClient.Client, consumer *kafka.Consumer, group string) (health.GroupSample, error) {
+        sample := health.GroupSample{
+                Time:     time.Now(),
+                Assigned: make(map[string]map[int32]bool),
+                LogStart: make(map[string]map[int32]int64),
+                LogEnd:   make(map[string]map[int32]int64),
+        }
+
+        info, err := client.DescribeConsumerGroup(group)
+        if err != nil {
+                return sample, fmt.Errorf("failed to describe group '%s': %w", group, err)
+        }
+        sample.State = info.State
+        sample.Members = len(info.Members)
+        for _, member := range info.Members {
+                for _, tp := range member.Assignment {
+                        if sample.Assigned[tp.Topic] == nil {
+                                sample.Assigned[tp.Topic] = make(map[int32]bool)
+                        }
+                        sample.Assigned[tp.Topic][tp.Partition] = true
+                }
+        }
+
+        sample.Committed, err = client.GetConsumerGroupOffsets(group)
+        if err != nil {
+                return sample, fmt.Errorf("failed to read offsets of group '%s': %w", group, err)
+        }
+        for topic, partitions := range sample.Committed {
+                for partition := range partitions {
+                        low, high, err := consumer.QueryWatermarkOffsets(topic, partition, 5000)
+                        if err != nil {
+                                continue
+                        }
+                        if sample.LogStart[topic] == nil {
+                                sample.LogStart[topic] = make(map[int32]int64)
+                                sample.LogEnd[topic] = make(map[int32]int64)
+                        }
+                        sample.LogStart[topic][partition] = low
+                        sample.LogEnd[topic][partition] = high
+                }
         }
-        return b
+        return sample, nil
 }
 
 func init() {
@@ -291,4 +415,10 @@ func init() {
         healthCmd.AddCommand(healthBrokersCmd)
         healthCmd.AddCommand(healthTopicsCmd)
         healthCmd.AddCommand(healthGroupsCmd)
+        healthCmd.AddCommand(healthCanaryCmd)
+
+        healthGroupsCmd.Flags().StringArray("group", nil, "Group to check (repeatable, default: all groups)")
+        healthGroupsCmd.Flags().Int("samples", 1, "Number of samples to take")
+        healthGroupsCmd.Flags().Duration("interval", 10*time.Second, "Time between samples")
+        healthGroupsCmd.RegisterFlagCompletionFunc("group", completeGroups)
 }
\ No newline at end of file
@@ -0,0 +1,606 @@
+package cmd
+
+import (
+        "fmt"
+        "math"
+        "os"
+        "os/signal"
+        "sort"
+        "strconv"
+        "syscall"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        "kafy/internal/health"
+        kafkaClient "kafy/internal/kafka"
+        "kafy/internal/perf"
+)
+
+const defaultCanaryTopic = "__kafy_canary"
+
+var healthCanaryCmd = &cobra.Command{
+        Use:   "canary",
+        Short: "Produce and consume probes through every broker",
+        Long: `Check that the cluster really moves data: produce a probe to every partition of a canary topic
+and consume it back, measuring produce latency (until acknowledged by all in-sync replicas),
+end-to-end latency (until consumed) and lost probes, per partition leader.
+
+The canary topic (default ` + defaultCanaryTopic + `) is created on demand with one partition per broker,
+each assigned to lead on its own broker, and grown when brokers are added. Brokers that lead no
+canary partition are reported.
+
+A single round runs by default and exits 0 when every probe arrived, 2 when a probe was slower than
+--max-latency and 3 when a probe failed or was lost. --continuous probes every --interval and shows
+cumulative loss and latency percentiles per broker until stopped. 'kafy exporter --canary' runs a
+round at every scrape and exposes the results as metrics.
+
+Examples:
+  kafy health canary
+  kafy health canary --continuous --interval 5s
+  kafy health canary --topic ops.canary --timeout 5s --max-latency 500ms --output json`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topic, _ := cmd.Flags().GetString("topic")
+                timeout, _ := cmd.Flags().GetDuration("timeout")
+                maxLatency, _ := cmd.Flags().GetDuration("max-latency")
+                continuous, _ := cmd.Flags().GetBool("continuous")
+                interval, _ := cmd.Flags().GetDuration("interval")
+                if timeout <= 0 || interval <= 0 {
+                        return fmt.Errorf("--timeout and --interval must be positive")
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                c, err := newCanary(client, topic, timeout)
+                if err != nil {
+                        return err
+                }
+                defer c.Close()
+
+                if !continuous {
+                        round, err := c.Probe()
+                        if err != nil {
+                                return err
+                        }
+                        if err := outputCanaryRound(round); err != nil {
+                                return err
+                        }
+                        report := &health.Report{}
+                        report.Add(round.Check(maxLatency))
+                        return healthExitError(cmd, report)
+                }
+
+                sigChan := make(chan os.Signal, 1)
+                signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+                defer signal.Stop(sigChan)
+
+                ticker := time.NewTicker(interval)
+                defer ticker.Stop()
+                clear := isTerminal(os.Stdout) && getFormatter().Format == "table"
+                for {
+                        round, err := c.Probe()
+                        if err != nil {
+                                fmt.Fprintf(os.Stderr, "%s %v\n", time.Now().Format("15:04:05"), err)
+                        } else {
+                                if clear {
+                                        fmt.Print("\033[H\033[2J")
+                                }
+                                if getFormatter().Format == "table" {
+                                        fmt.Printf("Canary on %s every %s, %d rounds since %s (Ctrl-C to stop)\n\n",
+                                                topic, interval, c.rounds, c.started.Format("15:04:05"))
+                                        if len(round.Uncovered) > 0 {
+                                                fmt.Printf("Brokers leading no canary partition: %v\n\n", round.Uncovered)
+                                        }
+                                }
+                                if err := outputCanaryStats(c.Stats()); err != nil {
+                                        return err
+                                }
+                        }
+
+                        select {
+                        case <-sigChan:
+                                return nil
+                        case <-ticker.C:
+                        }
+                }
+        },
+}
+
+// canary probes every partition of the canary topic and keeps cumulative statistics per broker
+type canary struct {
+        client   *kafkaClient.Client
+        topic    string
+        timeout  time.Duration
+        producer *kafka.Producer
+        consumer *kafka.Consumer
+        assigned map[int32]bool
+        run      string
+        seq      int64
+        rounds   int
+        started  time.Time
+        stats    map[int32]*canaryBrokerStats
+}
+
+// canaryProbe is the outcome of one probe
+type canaryProbe struct {
+        Partition int32   `json:"partition"`
+        Broker    int32   `json:"broker"`
+        ProduceMs float64 `json:"produce_ms"`
+        EndToEnd  float64 `json:"end_to_end_ms"`
+        Status    string  `json:"status"` // ok, lost or failed
+        Error     string  `json:"error,omitempty"`
+}
+
+// canaryRound is the outcome of one probe per partition
+type canaryRound struct {
+        Topic     string        `json:"topic"`
+        Time      time.Time     `json:"time"`
+        Probes    []canaryProbe `json:"probes"`
+        Uncovered []int32       `json:"uncovered_brokers,omitempty"` // Brokers leading no canary partition
+}
+
+// canaryBrokerStats accumulates the probes of partitions led by one broker
+type canaryBrokerStats struct {
+        Broker   int32
+        Sent     int64
+        Failed   int64
+        Lost     int64
+        Produce  *perf.Histogram
+        EndToEnd *perf.Histogram
+        Last     string
+}
+
+func newCanary(client *kafkaClient.Client, topic string, timeout time.Duration) (*canary, error) {
+        linger := 0
+        producer, err := client.CreateProducerWithOptions(kafkaClient.ProducerOptions{Acks: "all", LingerMs: &linger, MessageTimeout: timeout})
+        if err != nil {
+                return nil, fmt.Errorf("failed to create producer: %w", err)
+        }
+        run := strconv.FormatInt(time.Now().UnixNano(), 36)
+        // The consumer only uses Assign, so there is nothing worth committing
+        consumer, err := client.CreateConsumerWithOptions("kafy-canary-"+run, kafkaClient.ConsumerOptions{
+                OffsetReset:       "earliest",
+                DisableAutoCommit: true,
+        })
+        if err != nil {
+                producer.Close()
+                return nil, fmt.Errorf("failed to create consumer: %w", err)
+        }
+        return &canary{
+                client:   client,
+                topic:    topic,
+                timeout:  timeout,
+                producer: producer,
+                consumer: consumer,
+                assigned: make(map[int32]bool),
+                run:      run,
+                started:  time.Now(),
+                stats:    make(map[int32]*canaryBrokerStats),
+        }, nil
+}
+
+func (c *canary) Close() {
+        c.producer.Close()
+        c.consumer.Close()
+}
+
+// ensureTopic creates the canary topic, or grows it, so it has a partition per broker.
+// It returns the partitions and the brokers that lead none of them.
+func (c *canary) ensureTopic() ([]kafkaClient.PartitionInfo, []int32, error) {
+        brokers, err := c.client.ListBrokers()
+        if err != nil {
+                return nil, nil, fmt.Errorf("failed to list brokers: %w", err)
+        }
+
+        info, err := c.describeTopic()
+        if err != nil {
+                return nil, nil, err
+        }
+        if info == nil {
+                fmt.Fprintf(os.Stderr, "Creating canary topic '%s' with %d partitions\n", c.topic, len(brokers))
+                if err := c.client.CreateTopicWithAssignment(c.topic, canaryAssignment(brokers), map[string]string{"retention.ms": "3600000"}); err != nil {
+                        return nil, nil, fmt.Errorf("failed to create canary topic: %w", err)
+                }
+                info, err = c.waitForTopic(len(brokers))
+                if err != nil {
+                        return nil, nil, err
+                }
+        } else if len(info.PartitionDetails) < len(brokers) {
+                fmt.Fprintf(os.Stderr, "Growing canary topic '%s' to %d partitions\n", c.topic, len(brokers))
+                assignment := canaryGrowth(brokers, info.PartitionDetails)
+                if err := c.client.AddTopicPartitionsWithAssignment(c.topic, len(info.PartitionDetails), assignment); err != nil {
+                        return nil, nil, fmt.Errorf("failed to add canary partitions: %w", err)
+                }
+                if info, err = c.waitForTopic(len(brokers)); err != nil {
+                        return nil, nil, err
+                }
+        }
+
+        leaders := make(map[int32]bool)
+        for _, p := range info.PartitionDetails {
+                leaders[p.Leader] = true
+        }
+        var uncovered []int32
+        for _, broker := range brokers {
+                if !leaders[broker.ID] {
+                        uncovered = append(uncovered, broker.ID)
+                }
+        }
+        sort.Slice(uncovered, func(i, j int) bool { return uncovered[i] < uncovered[j] })
+        return info.PartitionDetails, uncovered, nil
+}
+
+// canaryGrowth places the partitions added to reach one per broker, each led
+// by a broker that is not the preferred leader of an existing partition, with
+// the existing replication factor on the brokers that follow it
+func canaryGrowth(brokers []kafkaClient.BrokerInfo, existing []kafkaClient.PartitionInfo) [][]int32 {
+        ids := make([]int32, len(brokers))
+        for i, broker := range brokers {
+                ids[i] = broker.ID
+        }
+        sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+        led := make(map[int32]bool)
+        replication := 1
+        for _, p := range existing {
+                if len(p.Replicas) > 0 {
+                        led[p.Replicas[0]] = true
+                }
+                if len(p.Replicas) > replication {
+                        replication = len(p.Replicas)
+                }
+        }
+        if replication > len(ids) {
+                replication = len(ids)
+        }
+
+        var assignment [][]int32
+        for i, id := range ids {
+                if led[id] || len(existing)+len(assignment) >= len(ids) {
+                        continue
+                }
+                var replicas []int32
+                for r := 0; r < replication; r++ {
+                        replicas = append(replicas, ids[(i+r)%len(ids)])
+                }
+                assignment = append(assignment, replicas)
+        }
+        return assignment
+}
+
+// canaryAssignment places one partition per broker, led by that broker, with
+// up to two more replicas on the brokers that follow it
+func canaryAssignment(brokers []kafkaClient.BrokerInfo) [][]int32 {
+        ids := make([]int32, len(brokers))
+        for i, broker := range brokers {
+                ids[i] = broker.ID
+        }
+        sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+        replication := len(ids)
+        if replication > 3 {
+                replication = 3
+        }
+        assignment := make([][]int32, len(ids))
+        for i := range ids {
+                for r := 0; r < replication; r++ {
+                        assignment[i] = append(assignment[i], ids[(i+r)%len(ids)])
+                }
+        }
+        return assignment
+}
+
+// describeTopic returns the canary topic, or nil when it does not exist. Cluster
+// metadata is used rather than asking for the topic, which could auto-create it.
+func (c *canary) describeTopic() (*kafkaClient.TopicInfo, error) {
+        topics, err := c.client.ListTopicPartitions()
+        if err != nil {
+                return nil, fmt.Errorf("failed to list topics: %w", err)
+        }
+        for i := range topics {
+                if topics[i].Name == c.topic {
+                        return &topics[i], nil
+                }
+        }
+        return nil, nil
+}
+
+// waitForTopic waits until the topic metadata shows the partitions with leaders
+func (c *canary) waitForTopic(partitions int) (*kafkaClient.TopicInfo, error) {
+        deadline := time.Now().Add(c.timeout)
+        for {
+                info, err := c.describeTopic()
+                if err == nil && info != nil && len(info.PartitionDetails) >= partitions {
+                        ready := true
+                        for _, p := range info.PartitionDetails {
+                                if p.Leader < 0 {
+                                        ready = false
+                                }
+                        }
+                        if ready {
+                                return info, nil
+                        }
+                }
+                if time.Now().After(deadline) {
+                        return nil, fmt.Errorf("canary topic '%s' has no leaders after %s", c.topic, c.timeout)
+                }
+                time.Sleep(200 * time.Millisecond)
+        }
+}
+
+// assign adds new partitions to the consumer, starting at their current end
+func (c *canary) assign(partitions []kafkaClient.PartitionInfo) error {
+        var added []kafka.TopicPartition
+        for _, p := range partitions {
+                if c.assigned[p.ID] {
+                        continue
+                }
+                _, high, err := c.consumer.QueryWatermarkOffsets(c.topic, p.ID, int(c.timeout/time.Millisecond))
+                if err != nil {
+                        return fmt.Errorf("failed to read canary offsets: %w", err)
+                }
+                added = append(added, kafka.TopicPartition{Topic: &c.topic, Partition: p.ID, Offset: kafka.Offset(high)})
+        }
+        if len(added) == 0 {
+                return nil
+        }
+        if err := c.consumer.IncrementalAssign(added); err != nil {
+                return fmt.Errorf("failed to assign canary partitions: %w", err)
+        }
+        for _, tp := range added {
+                c.assigned[tp.Partition] = true
+        }
+        return nil
+}
+
+// Probe sends one probe to every partition and waits for them to be
+// acknowledged and consumed, or for the timeout
+func (c *canary) Probe() (*canaryRound, error) {
+        partitions, uncovered, err := c.ensureTopic()
+        if err != nil {
+                return nil, err
+        }
+        if err := c.assign(partitions); err != nil {
+                return nil, err
+        }
+
+        type pending struct {
+                probe    *canaryProbe
+                sent     time.Time
+                acked    bool
+                received bool
+        }
+        round := &canaryRound{Topic: c.topic, Time: time.Now(), Uncovered: uncovered}
+        round.Probes = make([]canaryProbe, len(partitions))
+        byKey := make(map[string]*pending)
+        deliveries := make(chan kafka.Event, len(partitions))
+        outstanding := 0
+
+        for i, p := range partitions {
+                c.seq++
+                key := fmt.Sprintf("%s-%d", c.run, c.seq)
+                round.Probes[i] = canaryProbe{Partition: p.ID, Broker: p.Leader, Status: "lost"}
+                entry := &pending{probe: &round.Probes[i], sent: time.Now()}
+                err := c.producer.Produce(&kafka.Message{
+                        TopicPartition: kafka.TopicPartition{Topic: &c.topic, Partition: p.ID},
+                        Key:            []byte(key),
+                        Value:          []byte(entry.sent.UTC().Format(time.RFC3339Nano)),
+                        Opaque:         key,
+                }, deliveries)
+                if err != nil {
+                        entry.probe.Status, entry.probe.Error = "failed", err.Error()
+                        continue
+                }
+                byKey[key] = entry
+                outstanding += 2 // acknowledgement and consumption
+        }
+
+        // Note acknowledgement times as they arrive, not when the loop below gets to them
+        type ack struct {
+                msg *kafka.Message
+                at  time.Time
+        }
+        acks := make(chan ack, len(partitions))
+        go func(produced int) {
+                for i := 0; i < produced; i++ {
+                        if msg, ok := (<-deliveries).(*kafka.Message); ok {
+                                acks <- ack{msg, time.Now()}
+                        }
+                }
+        }(len(byKey))
+
+        deadline := time.Now().Add(c.timeout)
+        for outstanding > 0 && time.Now().Before(deadline) {
+                select {
+                case a := <-acks:
+                        entry := byKey[fmt.Sprint(a.msg.Opaque)]
+                        if entry == nil || entry.acked {
+                                continue
+                        }
+                        entry.acked = true
+                        outstanding--
+                        if a.msg.TopicPartition.Error != nil {
+                                entry.probe.Status, entry.probe.Error = "failed", a.msg.TopicPartition.Error.Error()
+                                if !entry.received {
+                                        outstanding--
+                                }
+                                continue
+                        }
+                        entry.probe.ProduceMs = canaryMillis(a.at.Sub(entry.sent))
+                        continue
+                default:
+                }
+
+                msg, err := c.consumer.ReadMessage(20 * time.Millisecond)
+                if err != nil {
+                        continue
+                }
+                entry := byKey[string(msg.Key)]
+                if entry == nil || entry.received {
+                        continue
+                }
+                entry.received = true
+                outstanding--
+                entry.probe.EndToEnd = canaryMillis(time.Since(entry.sent))
+                if entry.probe.Status == "lost" {
+                        entry.probe.Status = "ok"
+                }
+        }
+        for _, entry := range byKey {
+                if !entry.acked && entry.probe.Status == "lost" {
+                        entry.probe.Error = fmt.Sprintf("not acknowledged within %s", c.timeout)
+                } else if !entry.received && entry.probe.Status == "lost" {
+                        entry.probe.Error = fmt.Sprintf("not consumed within %s", c.timeout)
+                }
+        }
+
+        c.record(round)
+        return round, nil
+}
+
+func (c *canary) record(round *canaryRound) {
+        c.rounds++
+        for _, p := range round.Probes {
+                stats, ok := c.stats[p.Broker]
+                if !ok {
+                        stats = &canaryBrokerStats{Broker: p.Broker, Produce: perf.NewHistogram(), EndToEnd: perf.NewHistogram()}
+                        c.stats[p.Broker] = stats
+                }
+                stats.Sent++
+                stats.Last = p.Status
+                switch p.Status {
+                case "failed":
+                        stats.Failed++
+                case "lost":
+                        stats.Lost++
+                default:
+                        stats.Produce.Record(time.Duration(p.ProduceMs * float64(time.Millisecond)))
+                        stats.EndToEnd.Record(time.Duration(p.EndToEnd * float64(time.Millisecond)))
+                }
+        }
+}
+
+// Stats returns the cumulative statistics, by broker
+func (c *canary) Stats() []*canaryBrokerStats {
+        stats := make([]*canaryBrokerStats, 0, len(c.stats))
+        for _, s := range c.stats {
+                stats = append(stats, s)
+        }
+        sort.Slice(stats, func(i, j int) bool { return stats[i].Broker < stats[j].Broker })
+        return stats
+}
+
+// Check grades the round: failed and lost probes fail, slow probes warn
+func (r *canaryRound) Check(maxLatency time.Duration) health.Check {
+        check := health.Check{
+                Name:        "canary",
+                Description: fmt.Sprintf("Probes produced to and consumed from every partition of %s", r.Topic),
+                Checked:     len(r.Probes),
+        }
+        if maxLatency > 0 {
+                check.Description += fmt.Sprintf(" (max latency: %s)", maxLatency)
+        }
+        for _, p := range r.Probes {
+                broker, partition := p.Broker, p.Partition
+                switch {
+                case p.Status != "ok":
+                        check.Add(health.Finding{Status: health.Fail, Topic: r.Topic, Partition: &partition, Broker: &broker, Message: p.Status + ": " + p.Error})
+                case maxLatency > 0 && p.EndToEnd > canaryMillis(maxLatency):
+                        check.Add(health.Finding{Status: health.Warn, Topic: r.Topic, Partition: &partition, Broker: &broker,
+                                Message: fmt.Sprintf("end-to-end latency %.1fms", p.EndToEnd)})
+                }
+        }
+        for _, id := range r.Uncovered {
+                id := id
+                check.Add(health.Finding{Status: health.Warn, Broker: &id, Message: "leads no canary partition and is not probed"})
+        }
+        return check
+}
+
+func canaryMillis(d time.Duration) float64 {
+        return math.Round(float64(d)/float64(time.Millisecond)*100) / 100
+}
+
+func outputCanaryRound(round *canaryRound) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                return formatter.Output(round)
+        }
+
+        headers := []string{"Broker", "Partition", "Produce (ms)", "End-to-End (ms)", "Status"}
+        var rows [][]string
+        for _, p := range round.Probes {
+                status := p.Status
+                if p.Error != "" {
+                        status += ": " + p.Error
+                }
+                produce, endToEnd := "-", "-"
+                if p.ProduceMs > 0 {
+                        produce = fmt.Sprintf("%.2f", p.ProduceMs)
+                }
+                if p.Status == "ok" {
+                        endToEnd = fmt.Sprintf("%.2f", p.EndToEnd)
+                }
+                rows = append(rows, []string{fmt.Sprintf("%d", p.Broker), fmt.Sprintf("%d", p.Partition), produce, endToEnd, status})
+        }
+        if err := formatter.OutputTable(headers, rows); err != nil {
+                return err
+        }
+        if len(round.Uncovered) > 0 {
+                fmt.Printf("\nBrokers leading no canary partition: %v\n", round.Uncovered)
+        }
+        return nil
+}
+
+func outputCanaryStats(stats []*canaryBrokerStats) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                type brokerStats struct {
+                        Broker   int32              `json:"broker" yaml:"broker"`
+                        Sent     int64              `json:"sent" yaml:"sent"`
+                        Failed   int64              `json:"failed" yaml:"failed"`
+                        Lost     int64              `json:"lost" yaml:"lost"`
+                        Produce  perf.LatencySummary `json:"produce" yaml:"produce"`
+                        EndToEnd perf.LatencySummary `json:"end_to_end" yaml:"end_to_end"`
+                        Last     string             `json:"last" yaml:"last"`
+                }
+                var out []brokerStats
+                for _, s := range stats {
+                        out = append(out, brokerStats{s.Broker, s.Sent, s.Failed, s.Lost, s.Produce.Summary(), s.EndToEnd.Summary(), s.Last})
+                }
+                return formatter.Output(out)
+        }
+
+        headers := []string{"Broker", "Sent", "Failed", "Lost", "Loss %", "Produce p50/p99 (ms)", "End-to-End p50/p99 (ms)", "Last"}
+        var rows [][]string
+        for _, s := range stats {
+                produce, endToEnd := s.Produce.Summary(), s.EndToEnd.Summary()
+                rows = append(rows, []string{
+                        fmt.Sprintf("%d", s.Broker),
+                        fmt.Sprintf("%d", s.Sent),
+                        fmt.Sprintf("%d", s.Failed),
+                        fmt.Sprintf("%d", s.Lost),
+                        fmt.Sprintf("%.2f", float64(s.Failed+s.Lost)*100/float64(s.Sent)),
+                        fmt.Sprintf("%.2f / %.2f", produce.P50, produce.P99),
+                        fmt.Sprintf("%.2f / %.2f", endToEnd.P50, endToEnd.P99),
+                        s.Last,
+                })
+        }
+        return formatter.OutputTable(headers, rows)
+}
+
+func init() {
+        healthCanaryCmd.Flags().String("topic", defaultCanaryTopic, "Canary topic, created with one partition per broker when missing")
+        healthCanaryCmd.Flags().Duration("timeout", 10*time.Second, "Time to wait for probes to be acknowledged and consumed")
+        healthCanaryCmd.Flags().Duration("max-latency", time.Second, "Warn when a probe's end-to-end latency exceeds this (0 to disable)")
+        healthCanaryCmd.Flags().Bool("continuous", false, "Probe every --interval until stopped")
+        healthCanaryCmd.Flags().Duration("interval", 10*time.Second, "Time between probe rounds with --continuous")
+}
@@ -0,0 +1,353 @@
+package cmd
+
+import (
+        "fmt"
+        "os"
+        "sort"
+        "strings"
+        "time"
+
+        "github.com/spf13/cobra"
+        "kafy/internal/health"
+        kafkaClient "kafy/internal/kafka"
+)
+
+// Exit codes of health check; 1 remains the code for errors
+const (
+        exitHealthWarn = 2
+        exitHealthFail = 3
+)
+
+// minSkewRecords keeps small topics, where a few records make a big difference, out of the skew check
+const minSkewRecords = 1000
+
+var healthCheckCmd = &cobra.Command{
+        Use:   "check",
+        Short: "Run all health checks",
+        Long: `Run the broker, partition, topic and consumer group checks and report each as pass, warn or fail.
+
+Exit codes: 0 when every check passes, 2 when a check warns, 3 when a check fails and 1 on errors,
+so a pipeline can gate a rollout on the result. Use --output json for a machine-readable report and
+--junit-file to also write a JUnit XML report.
+
+A policy file (--policy) sets thresholds. Each threshold is a number (the fail limit) or
+{warn: N, fail: N}:
+
+  min-live-brokers: 3
+  max-offline-partitions: 0
+  max-under-replicated: {warn: 0, fail: 10}
+  max-under-min-isr: 0
+  max-preferred-leader-imbalance: {warn: 10}   # partitions away from their preferred leader
+  max-group-lag: {warn: 10000, fail: 100000}
+  groups: [billing, shipping]        # groups the group checks apply to (default: all)
+  group-samples: 3                   # sample groups 3 times to detect stuck rebalances and growing lag
+  group-sample-interval: 10s
+  max-partition-skew: {warn: 1.5, fail: 3}   # largest partition / average, topics with 1000+ records
+  required-topics: [orders, payments]
+
+Without a policy, offline and under-min-ISR partitions fail, under-replicated partitions warn,
+partitions away from their preferred leader are listed for information only, and brokers hosting
+replicas must be live.
+Consumer groups are assessed as in 'kafy health groups'.
+
+Examples:
+  kafy health check
+  kafy health check --policy health.yaml --junit-file health-report.xml
+  kafy health check --policy health.yaml --output json`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                policyPath, _ := cmd.Flags().GetString("policy")
+                junitFile, _ := cmd.Flags().GetString("junit-file")
+
+                policy := &health.Policy{}
+                if policyPath != "" {
+                        var err error
+                        if policy, err = health.LoadPolicy(policyPath); err != nil {
+                                return err
+                        }
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                started := time.Now()
+                report, err := runHealthChecks(client, policy)
+                if err != nil {
+                        return err
+                }
+
+                if err := outputHealthReport(report); err != nil {
+                        return err
+                }
+
+                if junitFile != "" {
+                        file, err := os.Create(junitFile)
+                        if err != nil {
+                                return fmt.Errorf("failed to create JUnit report: %w", err)
+                        }
+                        err = health.WriteJUnit(file, "kafy health check", report, started, time.Since(started))
+                        if closeErr := file.Close(); err == nil {
+                                err = closeErr
+                        }
+                        if err != nil {
+                                return fmt.Errorf("failed to write JUnit report: %w", err)
+                        }
+                }
+
+                return healthExitError(cmd, report)
+        },
+}
+
+// runHealthChecks runs every check of health check against the policy
+func runHealthChecks(client *kafkaClient.Client, policy *health.Policy) (*health.Report, error) {
+        report := &health.Report{}
+
+        topics, err := client.ListTopicPartitions()
+        if err != nil {
+                return nil, fmt.Errorf("failed to list topics: %w", err)
+        }
+
+        report.Add(brokerHealthCheck(client, topics, policy))
+
+        checks := partitionChecks(client, topics)
+        for i := range checks {
+                switch checks[i].Name {
+                case "offline-partitions":
+                        health.ApplyCount(&checks[i], policy.MaxOfflinePartitions)
+                case "under-min-isr":
+                        health.ApplyCount(&checks[i], policy.MaxUnderMinISR)
+                case "under-replicated":
+                        health.ApplyCount(&checks[i], policy.MaxUnderReplicated)
+                case "preferred-leader":
+                        health.ApplyCount(&checks[i], policy.MaxPreferredLeader)
+                }
+        }
+        report.Add(checks...)
+
+        if len(policy.RequiredTopics) > 0 {
+                report.Add(requiredTopicsCheck(topics, policy.RequiredTopics))
+        }
+        if policy.MaxPartitionSkew != nil {
+                report.Add(partitionSkewCheck(client, topics, policy.MaxPartitionSkew))
+        }
+        report.Add(groupLagCheck(client, policy))
+
+        samples, interval := 1, 10*time.Second
+        if policy.GroupSamples > 0 {
+                samples = policy.GroupSamples
+        }
+        if policy.GroupSampleInterval > 0 {
+                interval = policy.GroupSampleInterval
+        }
+        groupHealth, err := groupChecks(client, topics, policy.Groups, samples, interval)
+        if err != nil {
+                groupHealth = []health.Check{{Name: "groups", Description: "Consumer group assessment"}}
+                groupHealth[0].Add(health.Finding{Status: health.Fail, Message: err.Error()})
+        }
+        report.Add(groupHealth...)
+        return report, nil
+}
+
+// healthExitError reports the overall status on stderr and maps it to the exit code
+func healthExitError(cmd *cobra.Command, report *health.Report) error {
+        var failed, warned []string
+        for _, c := range report.Checks {
+                switch c.Status {
+                case health.Fail:
+                        failed = append(failed, c.Name)
+                case health.Warn:
+                        warned = append(warned, c.Name)
+                }
+        }
+
+        switch report.Status {
+        case health.Fail:
+                fmt.Fprintf(os.Stderr, "Health: FAIL (%s)\n", strings.Join(failed, ", "))
+                cmd.SilenceErrors = true
+                return &ExitError{Code: exitHealthFail, Err: fmt.Errorf("health checks failed: %s", strings.Join(failed, ", "))}
+        case health.Warn:
+                fmt.Fprintf(os.Stderr, "Health: WARN (%s)\n", strings.Join(warned, ", "))
+                cmd.SilenceErrors = true
+                return &ExitError{Code: exitHealthWarn, Err: fmt.Errorf("health checks warned: %s", strings.Join(warned, ", "))}
+        }
+        fmt.Fprintf(os.Stderr, "Health: PASS (%d checks)\n", len(report.Checks))
+        return nil
+}
+
+// brokerHealthCheck fails for brokers that host replicas but are not live
+func brokerHealthCheck(client *kafkaClient.Client, topics []kafkaClient.TopicInfo, policy *health.Policy) health.Check {
+        check := health.Check{Name: "brokers", Description: "Brokers hosting replicas must be live"}
+
+        brokers, err := client.ListBrokers()
+        if err != nil {
+                check.Add(health.Finding{Status: health.Fail, Message: fmt.Sprintf("failed to list brokers: %v", err)})
+                return check
+        }
+        live := make(map[int32]bool)
+        for _, broker := range brokers {
+                live[broker.ID] = true
+        }
+
+        expected := make(map[int32]bool)
+        for _, topic := range topics {
+                for _, partition := range topic.PartitionDetails {
+                        for _, replica := range partition.Replicas {
+                                expected[replica] = true
+                        }
+                }
+        }
+        var missing []int32
+        for id := range expected {
+                if !live[id] {
+                        missing = append(missing, id)
+                }
+        }
+        sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+        for _, id := range missing {
+                id := id
+                check.Add(health.Finding{Status: health.Fail, Broker: &id, Message: "hosts replicas but is not live"})
+        }
+        check.Checked = len(live) + len(missing)
+
+        if policy.MinLiveBrokers != nil {
+                check.Description += fmt.Sprintf(" (minimum live brokers: %s)", policy.MinLiveBrokers)
+                if status := policy.MinLiveBrokers.AtLeast(float64(len(live))); status != health.Pass {
+                        check.Add(health.Finding{Status: status, Message: fmt.Sprintf("%d live brokers", len(live))})
+                }
+        }
+        return check
+}
+
+func requiredTopicsCheck(topics []kafkaClient.TopicInfo, required []string) health.Check {
+        check := health.Check{Name: "required-topics", Description: "Topics the policy requires must exist", Checked: len(required)}
+        existing := make(map[string]bool)
+        for _, topic := range topics {
+                existing[topic.Name] = true
+        }
+        for _, name := range required {
+                if !existing[name] {
+                        check.Add(health.Finding{Status: health.Fail, Topic: name, Message: "topic does not exist"})
+                }
+        }
+        return check
+}
+
+// partitionSkewCheck compares each topic's largest partition, by record count, with its average partition
+func partitionSkewCheck(client *kafkaClient.Client, topics []kafkaClient.TopicInfo, threshold *health.Threshold) health.Check {
+        check := health.Check{
+                Name:        "partition-skew",
+                Description: fmt.Sprintf("Largest partition relative to the average partition of each topic (threshold: %s)", threshold),
+        }
+
+        consumer, err := client.CreateConsumer(fmt.Sprintf("kafy-health-skew-%d", time.Now().Unix()))
+        if err != nil {
+                check.Add(health.Finding{Status: health.Warn, Message: fmt.Sprintf("failed to create consumer: %v", err)})
+                return check
+        }
+        defer consumer.Close()
+
+        for _, topic := range topics {
+                if strings.HasPrefix(topic.Name, "__") || len(topic.PartitionDetails) < 2 {
+                        continue
+                }
+                var total, largest int64
+                largestPartition := int32(-1)
+                complete := true
+                for _, partition := range topic.PartitionDetails {
+                        low, high, err := consumer.QueryWatermarkOffsets(topic.Name, partition.ID, 5000)
+                        if err != nil {
+                                complete = false
+                                break
+                        }
+                        records := high - low
+                        total += records
+                        if records > largest {
+                                largest, largestPartition = records, partition.ID
+                        }
+                }
+                if !complete {
+                        check.Add(health.Finding{Status: health.Warn, Topic: topic.Name, Message: "could not read partition offsets"})
+                        continue
+                }
+                if total < minSkewRecords {
+                        continue
+                }
+
+                check.Checked++
+                average := float64(total) / float64(len(topic.PartitionDetails))
+                skew := float64(largest) / average
+                if status := threshold.AtMost(skew); status != health.Pass {
+                        partition := largestPartition
+                        check.Add(health.Finding{
+                                Status:    status,
+                                Topic:     topic.Name,
+                                Partition: &partition,
+                                Message:   fmt.Sprintf("holds %d records, %.2fx the average of %.0f", largest, skew, average),
+                        })
+                }
+        }
+        return check
+}
+
+// groupLagCheck grades the total lag of each group against the policy
+func groupLagCheck(client *kafkaClient.Client, policy *health.Policy) health.Check {
+        check := health.Check{Name: "group-lag", Description: "Total lag of each consumer group"}
+        if policy.MaxGroupLag != nil {
+                check.Description += fmt.Sprintf(" (threshold: %s)", policy.MaxGroupLag)
+        } else {
+                check.Description += " (no threshold set)"
+        }
+
+        summaries, err := client.ListConsumerGroups()
+        if err != nil {
+                check.Add(health.Finding{Status: health.Fail, Message: fmt.Sprintf("failed to list consumer groups: %v", err)})
+                return check
+        }
+        exists := make(map[string]bool)
+        var groups []string
+        for _, summary := range summaries {
+                exists[summary.GroupID] = true
+                groups = append(groups, summary.GroupID)
+        }
+        if len(policy.Groups) > 0 {
+                groups = policy.Groups
+        }
+        sort.Strings(groups)
+
+        for _, group := range groups {
+                check.Checked++
+                if !exists[group] {
+                        check.Add(health.Finding{Status: health.Fail, Group: group, Message: "group not found"})
+                        continue
+                }
+                if policy.MaxGroupLag == nil {
+                        continue
+                }
+                lag, err := client.GetConsumerGroupLag(group)
+                if err != nil {
+                        check.Add(health.Finding{Status: health.Warn, Group: group, Message: fmt.Sprintf("could not read lag: %v", err)})
+                        continue
+                }
+                var total int64
+                for _, partitions := range lag {
+                        for _, partitionLag := range partitions {
+                                total += partitionLag
+                        }
+                }
+                if status := policy.MaxGroupLag.AtMost(float64(total)); status != health.Pass {
+                        check.Add(health.Finding{Status: status, Group: group, Message: fmt.Sprintf("total lag %d", total)})
+                }
+        }
+        return check
+}
+
+func init() {
+        healthCheckCmd.Flags().String("policy", "", "YAML file with health thresholds (see --help)")
+        healthCheckCmd.Flags().String("junit-file", "", "Also write the report as JUnit XML to this file")
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+        "fmt"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        kafkaClient "kafy/internal/kafka"
+)
+
+// Transaction states reported for records read with read_uncommitted
+const (
+        txnStateAborted     = "aborted"
+        txnStateUncommitted = "uncommitted"
+        txnStateUnknown     = "unknown" // The shadow consumer could not tell in time
+)
+
+// lsoRefreshInterval bounds how often the LSO of a partition is queried. Records
+// at or beyond an LSO read less than this long ago are reported as uncommitted.
+const lsoRefreshInterval = time.Second
+
+// transactionBatchSize is the most records classified together
+const transactionBatchSize = 500
+
+// transactionClassifier works out whether a record read with read_uncommitted
+// belongs to an aborted or still-open transaction. Kafka clients don't expose
+// control records, so it compares against a shadow read_committed consumer:
+// records below the last stable offset (LSO) that the shadow never returns were
+// aborted, and records at or beyond the LSO are not committed yet.
+type transactionClassifier struct {
+        shadow     *kafka.Consumer
+        assigned   map[string]bool
+        lso        map[string]int64
+        lsoChecked map[string]time.Time
+        pending    map[string][]int64 // Committed offsets read by the shadow but not yet matched
+}
+
+func newTransactionClassifier(client *kafkaClient.Client) (*transactionClassifier, error) {
+        groupID := fmt.Sprintf("kafy-isolation-%d", time.Now().UnixNano())
+        shadow, err := client.CreateConsumerWithOptions(groupID, kafkaClient.ConsumerOptions{
+                OffsetReset:       "earliest",
+                IsolationLevel:    kafkaClient.ReadCommitted,
+                DisableAutoCommit: true,
+        })
+        if err != nil {
+                return nil, fmt.Errorf("failed to create read_committed consumer: %w", err)
+        }
+        return &transactionClassifier{
+                shadow:     shadow,
+                assigned:   make(map[string]bool),
+                lso:        make(map[string]int64),
+                lsoChecked: make(map[string]time.Time),
+                pending:    make(map[string][]int64),
+        }, nil
+}
+
+func (c *transactionClassifier) Close() {
+        c.shadow.Close()
+}
+
+func partitionKey(topic string, partition int32) string {
+        return fmt.Sprintf("%s/%d", topic, partition)
+}
+
+// Classify returns for each record txnStateAborted, txnStateUncommitted, ""
+// for committed and non-transactional records, or txnStateUnknown when the
+// shadow consumer could not read up to the record. The shadow catches up with
+// the whole batch at once. Records must be passed in offset order per
+// partition, across calls too.
+func (c *transactionClassifier) Classify(msgs []*kafka.Message) []string {
+        states := make([]string, len(msgs))
+        var waiting []int
+        targets := make(map[string]kafka.TopicPartition) // Highest offset to reach per partition
+        for i, msg := range msgs {
+                topic := *msg.TopicPartition.Topic
+                partition := msg.TopicPartition.Partition
+                offset := int64(msg.TopicPartition.Offset)
+                key := partitionKey(topic, partition)
+
+                if offset >= c.lso[key] && time.Since(c.lsoChecked[key]) >= lsoRefreshInterval {
+                        // A read_committed watermark query returns the LSO as the high watermark
+                        _, lso, err := c.shadow.QueryWatermarkOffsets(topic, partition, 5000)
+                        if err != nil {
+                                states[i] = txnStateUnknown
+                                continue
+                        }
+                        c.lso[key], c.lsoChecked[key] = lso, time.Now()
+                }
+                if offset >= c.lso[key] {
+                        states[i] = txnStateUncommitted
+                        continue
+                }
+
+                if !c.assigned[key] {
+                        err := c.shadow.IncrementalAssign([]kafka.TopicPartition{{
+                                Topic:     &topic,
+                                Partition: partition,
+                                Offset:    kafka.Offset(offset),
+                        }})
+                        if err != nil {
+                                states[i] = txnStateUnknown
+                                continue
+                        }
+                        c.assigned[key] = true
+                }
+                waiting = append(waiting, i)
+                targets[key] = kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)}
+        }
+        if len(waiting) == 0 {
+                return states
+        }
+
+        c.catchUp(targets)
+        for _, i := range waiting {
+                states[i] = c.match(msgs[i])
+        }
+        return states
+}
+
+// catchUp reads committed records until the shadow has reached every target offset
+func (c *transactionClassifier) catchUp(targets map[string]kafka.TopicPartition) {
+        deadline := time.Now().Add(10 * time.Second)
+        for time.Now().Before(deadline) {
+                reached := true
+                for key, tp := range targets {
+                        offsets := c.pending[key]
+                        if len(offsets) > 0 && offsets[len(offsets)-1] >= int64(tp.Offset) {
+                                continue
+                        }
+                        if !c.shadowPassed(*tp.Topic, tp.Partition, int64(tp.Offset)) {
+                                reached = false
+                                break
+                        }
+                }
+                if reached {
+                        return
+                }
+
+                shadowMsg, err := c.shadow.ReadMessage(200 * time.Millisecond)
+                if err != nil {
+                        continue
+                }
+                shadowKey := partitionKey(*shadowMsg.TopicPartition.Topic, shadowMsg.TopicPartition.Partition)
+                c.pending[shadowKey] = append(c.pending[shadowKey], int64(shadowMsg.TopicPartition.Offset))
+        }
+}
+
+// match classifies a record below the LSO by what the shadow has read
+func (c *transactionClassifier) match(msg *kafka.Message) string {
+        topic := *msg.TopicPartition.Topic
+        partition := msg.TopicPartition.Partition
+        offset := int64(msg.TopicPartition.Offset)
+        key := partitionKey(topic, partition)
+
+        offsets := c.pending[key]
+        for len(offsets) > 0 && offsets[0] < offset {
+                offsets = offsets[1:]
+        }
+        c.pending[key] = offsets
+        if len(offsets) > 0 {
+                if offsets[0] == offset {
+                        c.pending[key] = offsets[1:]
+                        return ""
+                }
+                return txnStateAborted
+        }
+        if c.shadowPassed(topic, partition, offset) {
+                return txnStateAborted
+        }
+        return txnStateUnknown
+}
+
+// readBuffered adds to batch the records the consumer has already fetched, up
+// to limit records, without waiting for more
+func readBuffered(consumer *kafka.Consumer, batch []*kafka.Message, limit int) ([]*kafka.Message, error) {
+        for len(batch) < limit {
+                msg, err := consumer.ReadMessage(0)
+                if err != nil {
+                        if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+                                break
+                        }
+                        return batch, err
+                }
+                batch = append(batch, msg)
+        }
+        return batch, nil
+}
+
+// shadowPassed reports whether the shadow consumer has moved beyond offset
+// without returning it (control records and aborted data are skipped silently)
+func (c *transactionClassifier) shadowPassed(topic string, partition int32, offset int64) bool {
+        positions, err := c.shadow.Position([]kafka.TopicPartition{{Topic: &topic, Partition: partition}})
+        if err != nil || len(positions) == 0 {
+                return false
+        }
+        return positions[0].Offset >= 0 && int64(positions[0].Offset) > offset
+}
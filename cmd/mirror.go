@@ -0,0 +1,673 @@
+package cmd
+
+import (
+        "errors"
+        "fmt"
+        "os"
+        "os/signal"
+        "regexp"
+        "sort"
+        "strings"
+        "syscall"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        "kafy/config"
+        kafkaClient "kafy/internal/kafka"
+        "kafy/internal/mirror"
+)
+
+var mirrorCmd = &cobra.Command{
+        Use:   "mirror",
+        Short: "Continuously mirror topics and consumer group offsets to another cluster",
+        Long: `Mirror topics from one configured cluster to another until stopped.
+
+Missing destination topics are created with the same partition count and topic-level configs,
+and records keep their partition, key, headers and timestamp. The mirror remembers how source
+offsets map to destination offsets, so consumer group offsets can be translated and committed
+on the destination, letting groups fail over without reprocessing or skipping records.
+
+Records can be masked, filtered or rewritten on the way with --transform; route steps are not
+available since records keep their topic and partition. Dropped records leave gaps in the
+destination, which offset translation accounts for.
+
+Progress is saved under ~/.kafy/mirror/<name>.json; restarting a mirror with the same name resumes
+where it stopped. Group offsets are only synced for groups with no active members on the destination,
+and destination offsets are never moved backwards.
+
+Examples:
+  kafy mirror --from prod --to dr --topics 'orders.*'
+  kafy mirror --from prod --to dr --topics '.*' --exclude '^_' --groups 'payments-.*'
+  kafy mirror --from prod --to staging --topics 'orders.*' --transform hash:.customer.email --transform drop:tombstone
+  kafy mirror status
+
+` + transformHelp(),
+        Args: cobra.NoArgs,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                from, _ := cmd.Flags().GetString("from")
+                to, _ := cmd.Flags().GetString("to")
+                topicsPattern, _ := cmd.Flags().GetString("topics")
+                excludePattern, _ := cmd.Flags().GetString("exclude")
+                groupsPattern, _ := cmd.Flags().GetString("groups")
+                name, _ := cmd.Flags().GetString("name")
+                syncInterval, _ := cmd.Flags().GetDuration("sync-interval")
+                start, _ := cmd.Flags().GetString("start")
+                replication, _ := cmd.Flags().GetInt("replication-factor")
+                force, _ := cmd.Flags().GetBool("force")
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+                if from == "" {
+                        from = cfg.CurrentContext
+                }
+                if from == "" || to == "" {
+                        return fmt.Errorf("--to is required, and --from unless a current context is set")
+                }
+                if from == to {
+                        return fmt.Errorf("--from and --to must be different clusters")
+                }
+                if topicsPattern == "" {
+                        return fmt.Errorf("--topics is required (a regular expression, e.g. 'orders.*')")
+                }
+                if start != "earliest" && start != "latest" {
+                        return fmt.Errorf("--start must be earliest or latest")
+                }
+                if syncInterval < time.Second {
+                        return fmt.Errorf("--sync-interval must be at least 1s")
+                }
+                if name == "" {
+                        name = from + "-to-" + to
+                }
+
+                m := &mirrorRun{name: name, start: start, replication: replication, syncInterval: syncInterval}
+                deadLetterFile, _ := cmd.Flags().GetString("dead-letter-file")
+                if deadLetterFile != "" && !cmd.Flags().Changed("transform") {
+                        return fmt.Errorf("--dead-letter-file requires --transform")
+                }
+                deadLetter, err := openDeadLetterFile(deadLetterFile)
+                if err != nil {
+                        return err
+                }
+                if deadLetter != nil {
+                        defer deadLetter.Close()
+                }
+                if m.transformer, err = newRecordTransformer(cmd, true, deadLetter); err != nil {
+                        return err
+                }
+                if m.transformer != nil {
+                        defer m.transformer.Close()
+                }
+                if m.topics, err = anchoredRegexp(topicsPattern); err != nil {
+                        return fmt.Errorf("invalid --topics pattern: %w", err)
+                }
+                if excludePattern != "" {
+                        if m.exclude, err = anchoredRegexp(excludePattern); err != nil {
+                                return fmt.Errorf("invalid --exclude pattern: %w", err)
+                        }
+                }
+                if groupsPattern != "" {
+                        if m.groups, err = anchoredRegexp(groupsPattern); err != nil {
+                                return fmt.Errorf("invalid --groups pattern: %w", err)
+                        }
+                }
+
+                if m.source, err = clientForContext(cfg, from); err != nil {
+                        return err
+                }
+                if m.dest, err = clientForContext(cfg, to); err != nil {
+                        return err
+                }
+
+                state, err := mirror.Load(name)
+                switch {
+                case errors.Is(err, os.ErrNotExist):
+                        state = mirror.New(name, from, to)
+                case err != nil:
+                        return err
+                case state.From != from || state.To != to:
+                        return fmt.Errorf("mirror '%s' was created for %s -> %s; use another --name", name, state.From, state.To)
+                case state.Status() == "running" && !force:
+                        return fmt.Errorf("mirror '%s' appears to be running already (last update %s); use --force if it is not", name, state.UpdatedAt.Local().Format(time.RFC3339))
+                }
+                state.Topics, state.Exclude, state.Groups = topicsPattern, excludePattern, groupsPattern
+                state.SyncInterval = syncInterval
+                state.Running = true
+                state.StartedAt = time.Now().UTC()
+                state.LastError = ""
+                m.state = state
+
+                return m.run()
+        },
+}
+
+// mirrorRun is one running mirror process
+type mirrorRun struct {
+        name         string
+        start        string
+        replication  int
+        syncInterval time.Duration
+        topics       *regexp.Regexp
+        exclude      *regexp.Regexp
+        groups       *regexp.Regexp
+        transformer  *recordTransformer
+
+        source   *kafkaClient.Client
+        dest     *kafkaClient.Client
+        state    *mirror.State
+        consumer *kafka.Consumer
+        producer *kafka.Producer
+
+        assigned     map[string]bool
+        destTopics   map[string]int // Destination partition counts
+        failures     chan error
+        lastMirrored int64
+}
+
+// mirrorDelivery identifies the source record behind a produced message
+type mirrorDelivery struct {
+        topic     string
+        partition int32
+        offset    int64
+}
+
+func (m *mirrorRun) run() error {
+        var err error
+        // Positions come from the state file; committed offsets would run ahead of what landed
+        m.consumer, err = m.source.CreateConsumerWithOptions("kafy-mirror-"+m.name, kafkaClient.ConsumerOptions{
+                OffsetReset:       m.start,
+                IsolationLevel:    kafkaClient.ReadCommitted,
+                DisableAutoCommit: true,
+        })
+        if err != nil {
+                return fmt.Errorf("failed to create source consumer: %w", err)
+        }
+        defer m.consumer.Close()
+
+        // Idempotence keeps records in order per partition, which the offset mapping relies on
+        m.producer, err = m.dest.CreateProducerWithOptions(kafkaClient.ProducerOptions{Acks: "all", Idempotent: true})
+        if err != nil {
+                return fmt.Errorf("failed to create destination producer: %w", err)
+        }
+        defer m.producer.Close()
+
+        m.assigned = make(map[string]bool)
+        m.destTopics = make(map[string]int)
+        m.failures = make(chan error, 1)
+        go m.handleDeliveries()
+
+        fmt.Fprintf(os.Stderr, "Mirroring %s -> %s (topics: %s, state: %s)\n", m.state.From, m.state.To, m.state.Topics, mirror.Dir())
+        if err := m.discover(); err != nil {
+                return err
+        }
+        if err := m.state.Save(); err != nil {
+                return err
+        }
+
+        sigChan := make(chan os.Signal, 1)
+        signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+        defer signal.Stop(sigChan)
+        ticker := time.NewTicker(m.syncInterval)
+        defer ticker.Stop()
+
+        var runErr error
+mirrorLoop:
+        for {
+                select {
+                case <-sigChan:
+                        fmt.Fprintf(os.Stderr, "\nStopping mirror...\n")
+                        break mirrorLoop
+                case runErr = <-m.failures:
+                        break mirrorLoop
+                case <-ticker.C:
+                        m.sync()
+                        continue
+                default:
+                }
+
+                msg, err := m.consumer.ReadMessage(200 * time.Millisecond)
+                if err != nil {
+                        if transientReadError(err) {
+                                continue
+                        }
+                        runErr = fmt.Errorf("source consumer error: %w", err)
+                        break
+                }
+                if err := m.mirrorMessage(msg); err != nil {
+                        runErr = err
+                        break
+                }
+        }
+
+        // Let in-flight records land so the saved position and offset mapping are complete
+        deadline := time.Now().Add(30 * time.Second)
+        for m.producer.Len() > 0 && time.Now().Before(deadline) {
+                m.producer.Flush(500)
+        }
+        if runErr == nil {
+                select {
+                case runErr = <-m.failures:
+                default:
+                }
+        }
+
+        m.state.Stop(runErr)
+        if err := m.state.Save(); err != nil && runErr == nil {
+                runErr = err
+        }
+        fmt.Fprintf(os.Stderr, "Mirror state saved; restart with --name %s to resume\n", m.name)
+        return runErr
+}
+
+func (m *mirrorRun) mirrorMessage(msg *kafka.Message) error {
+        topic := *msg.TopicPartition.Topic
+        partition := msg.TopicPartition.Partition
+        if int(partition) >= m.destTopics[topic] {
+                // The source gained partitions since the last discovery
+                if err := m.discover(); err != nil {
+                        return err
+                }
+        }
+
+        out := &kafka.Message{
+                TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition},
+                Key:            msg.Key,
+                Value:          msg.Value,
+                Headers:        msg.Headers,
+                Timestamp:      msg.Timestamp,
+                Opaque:         mirrorDelivery{topic: topic, partition: partition, offset: int64(msg.TopicPartition.Offset)},
+        }
+        if m.transformer != nil {
+                // Records that are dropped or set aside are not mapped; groups committed past
+                // them translate to the next mirrored record
+                keep, err := m.transformer.Apply(msg, out)
+                if err != nil || !keep {
+                        return err
+                }
+        }
+        for {
+                err := m.producer.Produce(out, nil)
+                if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrQueueFull {
+                        m.producer.Flush(100)
+                        continue
+                }
+                if err != nil {
+                        return fmt.Errorf("failed to produce to destination: %w", err)
+                }
+                return nil
+        }
+}
+
+func (m *mirrorRun) handleDeliveries() {
+        for e := range m.producer.Events() {
+                msg, ok := e.(*kafka.Message)
+                if !ok {
+                        continue
+                }
+                source, ok := msg.Opaque.(mirrorDelivery)
+                if !ok {
+                        continue
+                }
+                if msg.TopicPartition.Error != nil {
+                        m.state.RecordFailure(source.topic, source.partition, source.offset, msg.TopicPartition.Error)
+                        select {
+                        case m.failures <- fmt.Errorf("failed to mirror %s/%d offset %d: %w", source.topic, source.partition, source.offset, msg.TopicPartition.Error):
+                        default:
+                        }
+                        continue
+                }
+                m.state.RecordDelivery(source.topic, source.partition, source.offset, int64(msg.TopicPartition.Offset))
+        }
+}
+
+// discover finds matching source topics, prepares their destination topics and
+// starts consuming any partitions that are not assigned yet
+func (m *mirrorRun) discover() error {
+        topics, err := m.source.ListTopics()
+        if err != nil {
+                return fmt.Errorf("failed to list source topics: %w", err)
+        }
+
+        var assignments []kafka.TopicPartition
+        for _, topic := range topics {
+                if strings.HasPrefix(topic.Name, "__") || !m.topics.MatchString(topic.Name) {
+                        continue
+                }
+                if m.exclude != nil && m.exclude.MatchString(topic.Name) {
+                        continue
+                }
+                if err := m.prepareDestTopic(topic.Name); err != nil {
+                        return err
+                }
+
+                name := topic.Name
+                for partition := int32(0); partition < int32(topic.Partitions); partition++ {
+                        key := fmt.Sprintf("%s/%d", name, partition)
+                        if m.assigned[key] {
+                                continue
+                        }
+                        offset := kafka.OffsetBeginning
+                        if m.start == "latest" {
+                                offset = kafka.OffsetEnd
+                        }
+                        if next, ok := m.state.NextOffset(name, partition); ok {
+                                offset = kafka.Offset(next)
+                        } else if offset == kafka.OffsetBeginning {
+                                m.state.Track(name, partition, 0)
+                        }
+                        assignments = append(assignments, kafka.TopicPartition{Topic: &name, Partition: partition, Offset: offset})
+                        m.assigned[key] = true
+                }
+        }
+
+        if len(assignments) == 0 {
+                return nil
+        }
+        if err := m.consumer.IncrementalAssign(assignments); err != nil {
+                return fmt.Errorf("failed to assign source partitions: %w", err)
+        }
+        fmt.Fprintf(os.Stderr, "Mirroring %d new partition(s)\n", len(assignments))
+        return nil
+}
+
+// prepareDestTopic creates the destination topic, or adds partitions so every source partition has a counterpart
+func (m *mirrorRun) prepareDestTopic(topic string) error {
+        source, err := m.source.DescribeTopic(topic)
+        if err != nil {
+                return fmt.Errorf("failed to describe source topic '%s': %w", topic, err)
+        }
+        if m.destTopics[topic] >= source.Partitions {
+                return nil
+        }
+
+        dest, err := m.dest.DescribeTopic(topic)
+        if err != nil {
+                configs, err := m.source.GetTopicConfigOverrides(topic)
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Warning: could not read configs of '%s', creating it with defaults: %v\n", topic, err)
+                }
+                replication := m.replication
+                if replication <= 0 {
+                        replication = source.Replicas
+                }
+                if err := m.dest.CreateTopicWithConfigs(topic, source.Partitions, replication, configs); err != nil {
+                        return fmt.Errorf("failed to create destination topic '%s': %w", topic, err)
+                }
+                fmt.Fprintf(os.Stderr, "Created destination topic '%s' (%d partitions, replication %d, %d config overrides)\n",
+                        topic, source.Partitions, replication, len(configs))
+                m.destTopics[topic] = source.Partitions
+                return nil
+        }
+
+        if dest.Partitions < source.Partitions {
+                if err := m.dest.AlterTopicPartitions(topic, source.Partitions); err != nil {
+                        return fmt.Errorf("failed to add partitions to destination topic '%s': %w", topic, err)
+                }
+                fmt.Fprintf(os.Stderr, "Increased destination topic '%s' to %d partitions\n", topic, source.Partitions)
+                dest.Partitions = source.Partitions
+        }
+        m.destTopics[topic] = dest.Partitions
+        return nil
+}
+
+// sync runs the periodic work: topic discovery, lag snapshot, group offset sync and saving state
+func (m *mirrorRun) sync() {
+        if err := m.discover(); err != nil {
+                fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+        }
+
+        for _, p := range m.state.Snapshot() {
+                if _, high, err := m.consumer.GetWatermarkOffsets(p.Topic, p.Partition); err == nil && high >= 0 {
+                        m.state.SetSourceEnd(p.Topic, p.Partition, high)
+                }
+        }
+        mirrored, lag := mirrorTotals(m.state)
+
+        synced := 0
+        if m.groups != nil {
+                synced = m.syncGroups()
+        }
+        if err := m.state.Save(); err != nil {
+                fmt.Fprintf(os.Stderr, "Warning: failed to save mirror state: %v\n", err)
+        }
+        line := fmt.Sprintf("[%s] %d records mirrored (+%d), lag %d, %d group(s) synced",
+                time.Now().Format("15:04:05"), mirrored, mirrored-m.lastMirrored, lag, synced)
+        if m.transformer != nil {
+                line += "; " + m.transformer.Summary()
+        }
+        fmt.Fprintln(os.Stderr, line)
+        m.lastMirrored = mirrored
+}
+
+// syncGroups translates committed source offsets of matching groups and commits them on the destination
+func (m *mirrorRun) syncGroups() int {
+        groups, err := m.source.ListConsumerGroups()
+        if err != nil {
+                fmt.Fprintf(os.Stderr, "Warning: failed to list source groups: %v\n", err)
+                return 0
+        }
+
+        synced := 0
+        for _, group := range groups {
+                // Skip kafy's own temporary groups, including this mirror's consumer
+                if strings.HasPrefix(group.GroupID, "kafy-") || !m.groups.MatchString(group.GroupID) {
+                        continue
+                }
+                result := m.syncGroup(group.GroupID)
+                m.state.SetGroupSync(group.GroupID, result)
+                if result != nil && result.Error == "" && len(result.Offsets) > 0 {
+                        synced++
+                }
+        }
+        return synced
+}
+
+func (m *mirrorRun) syncGroup(group string) *mirror.GroupSync {
+        result := &mirror.GroupSync{SyncedAt: time.Now().UTC(), Offsets: make(map[string]map[int32]int64)}
+
+        sourceOffsets, err := m.source.GetConsumerGroupOffsets(group)
+        if err != nil {
+                result.Error = err.Error()
+                return result
+        }
+
+        translated := make(map[string]map[int32]int64)
+        for topic, partitions := range sourceOffsets {
+                for partition, offset := range partitions {
+                        if destOffset, exact, ok := m.state.Translate(topic, partition, offset); ok {
+                                if !exact {
+                                        result.Rewound++
+                                }
+                                if translated[topic] == nil {
+                                        translated[topic] = make(map[int32]int64)
+                                }
+                                translated[topic][partition] = destOffset
+                        }
+                }
+        }
+        if len(translated) == 0 {
+                return nil
+        }
+
+        // Only commit where the destination group is behind, so a group that already failed over keeps its progress
+        destOffsets, err := m.dest.GetConsumerGroupOffsets(group)
+        if err != nil {
+                result.Error = err.Error()
+                return result
+        }
+        for topic, partitions := range translated {
+                for partition, offset := range partitions {
+                        if current, ok := destOffsets[topic][partition]; ok && current >= offset {
+                                result.Skipped++
+                                continue
+                        }
+                        if result.Offsets[topic] == nil {
+                                result.Offsets[topic] = make(map[int32]int64)
+                        }
+                        result.Offsets[topic][partition] = offset
+                }
+        }
+        if len(result.Offsets) == 0 {
+                return result
+        }
+
+        if info, err := m.dest.DescribeConsumerGroup(group); err == nil && len(info.Members) > 0 {
+                result.Error = fmt.Sprintf("group has %d active member(s) on %s", len(info.Members), m.state.To)
+                result.Offsets = nil
+                return result
+        }
+        if err := m.dest.AlterConsumerGroupOffsets(group, result.Offsets); err != nil {
+                result.Error = err.Error()
+                result.Offsets = nil
+        }
+        return result
+}
+
+var mirrorStatusCmd = &cobra.Command{
+        Use:   "status [name]",
+        Short: "Show mirror progress, lag and group offset syncs",
+        Long: `Show the saved state of mirrors. Without a name all mirrors are listed;
+with a name, per-partition progress and consumer group syncs are shown as well.
+Lag is the number of source records not yet mirrored at the mirror's last update.`,
+        Args:              cobra.MaximumNArgs(1),
+        ValidArgsFunction: completeMirrors,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                formatter := getFormatter()
+
+                if len(args) == 0 {
+                        states, err := mirror.List()
+                        if err != nil {
+                                return err
+                        }
+                        if formatter.Format != "table" {
+                                return formatter.Output(states)
+                        }
+                        if len(states) == 0 {
+                                fmt.Println("No mirrors found. Start one with 'kafy mirror --from <cluster> --to <cluster> --topics <pattern>'.")
+                                return nil
+                        }
+                        headers := []string{"Name", "From", "To", "Topics", "Status", "Partitions", "Mirrored", "Lag", "Groups", "Updated"}
+                        var rows [][]string
+                        for _, state := range states {
+                                mirrored, lag := mirrorTotals(state)
+                                rows = append(rows, []string{
+                                        state.Name, state.From, state.To, state.Topics, state.Status(),
+                                        fmt.Sprintf("%d", len(state.Partitions)),
+                                        fmt.Sprintf("%d", mirrored),
+                                        fmt.Sprintf("%d", lag),
+                                        fmt.Sprintf("%d", len(state.GroupSyncs)),
+                                        state.UpdatedAt.Local().Format("2006-01-02 15:04:05"),
+                                })
+                        }
+                        return formatter.OutputTable(headers, rows)
+                }
+
+                state, err := mirror.Load(args[0])
+                if errors.Is(err, os.ErrNotExist) {
+                        return fmt.Errorf("mirror '%s' not found in %s", args[0], mirror.Dir())
+                }
+                if err != nil {
+                        return err
+                }
+                if formatter.Format != "table" {
+                        return formatter.Output(state)
+                }
+
+                mirrored, lag := mirrorTotals(state)
+                fmt.Printf("Mirror:   %s (%s -> %s)\n", state.Name, state.From, state.To)
+                fmt.Printf("Status:   %s (updated %s)\n", state.Status(), state.UpdatedAt.Local().Format(time.RFC3339))
+                fmt.Printf("Topics:   %s", state.Topics)
+                if state.Exclude != "" {
+                        fmt.Printf(" (excluding %s)", state.Exclude)
+                }
+                fmt.Printf("\nMirrored: %d records, lag %d\n", mirrored, lag)
+                if state.LastError != "" {
+                        fmt.Printf("Error:    %s\n", state.LastError)
+                }
+                fmt.Println()
+
+                headers := []string{"Topic", "Partition", "Next Offset", "Source End", "Lag", "Mirrored"}
+                var rows [][]string
+                for _, p := range state.Snapshot() {
+                        rows = append(rows, []string{
+                                p.Topic, fmt.Sprintf("%d", p.Partition),
+                                fmt.Sprintf("%d", p.NextOffset), fmt.Sprintf("%d", p.SourceEnd),
+                                fmt.Sprintf("%d", p.Lag()), fmt.Sprintf("%d", p.Mirrored),
+                        })
+                }
+                if err := formatter.OutputTable(headers, rows); err != nil {
+                        return err
+                }
+
+                if len(state.GroupSyncs) == 0 {
+                        return nil
+                }
+                fmt.Println()
+                groups := make([]string, 0, len(state.GroupSyncs))
+                for group := range state.GroupSyncs {
+                        groups = append(groups, group)
+                }
+                sort.Strings(groups)
+                headers = []string{"Group", "Last Sync", "Partitions Committed", "Skipped (Ahead)", "Rewound", "Error"}
+                rows = nil
+                for _, group := range groups {
+                        sync := state.GroupSyncs[group]
+                        if sync == nil {
+                                continue
+                        }
+                        committed := 0
+                        for _, partitions := range sync.Offsets {
+                                committed += len(partitions)
+                        }
+                        rows = append(rows, []string{
+                                group, sync.SyncedAt.Local().Format("2006-01-02 15:04:05"),
+                                fmt.Sprintf("%d", committed), fmt.Sprintf("%d", sync.Skipped), fmt.Sprintf("%d", sync.Rewound), sync.Error,
+                        })
+                }
+                return formatter.OutputTable(headers, rows)
+        },
+}
+
+func mirrorTotals(state *mirror.State) (mirrored, lag int64) {
+        for _, p := range state.Snapshot() {
+                mirrored += p.Mirrored
+                lag += p.Lag()
+        }
+        return mirrored, lag
+}
+
+// clientForContext creates a client for a named cluster from the config
+func clientForContext(cfg *config.Config, name string) (*kafkaClient.Client, error) {
+        if _, ok := cfg.Clusters[name]; !ok {
+                return nil, fmt.Errorf("cluster '%s' not found in configuration", name)
+        }
+        return kafkaClient.NewClient(&config.Config{CurrentContext: name, Clusters: cfg.Clusters})
+}
+
+// anchoredRegexp compiles a pattern that must match the whole name
+func anchoredRegexp(pattern string) (*regexp.Regexp, error) {
+        return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+func init() {
+        mirrorCmd.AddCommand(mirrorStatusCmd)
+
+        mirrorCmd.Flags().String("from", "", "Source cluster (default: current context)")
+        mirrorCmd.Flags().String("to", "", "Destination cluster")
+        mirrorCmd.Flags().String("topics", "", "Regular expression of topics to mirror, e.g. 'orders.*'")
+        mirrorCmd.Flags().String("exclude", "", "Regular expression of topics to skip")
+        mirrorCmd.Flags().String("groups", ".*", "Regular expression of consumer groups whose offsets are synced ('' = none)")
+        mirrorCmd.Flags().String("name", "", "Mirror name, used for the state file (default: <from>-to-<to>)")
+        mirrorCmd.Flags().Duration("sync-interval", 30*time.Second, "How often to discover topics, sync group offsets and save state")
+        mirrorCmd.Flags().String("start", "earliest", "Where to start partitions the mirror has not seen before: earliest or latest")
+        mirrorCmd.Flags().Int("replication-factor", 0, "Replication factor for created topics (default: same as the source)")
+        mirrorCmd.Flags().Bool("force", false, "Start even if the state file says the mirror is already running")
+        addTransformFlags(mirrorCmd)
+        mirrorCmd.Flags().String("dead-letter-file", "", "Append records that fail a transform step to this file as NDJSON (replay with produce --input-format ndjson --base64 key,value,headers)")
+
+        mirrorCmd.RegisterFlagCompletionFunc("from", completeClusters)
+        mirrorCmd.RegisterFlagCompletionFunc("to", completeClusters)
+        mirrorCmd.RegisterFlagCompletionFunc("start", cobra.FixedCompletions([]string{"earliest", "latest"}, cobra.ShellCompDirectiveNoFileComp))
+}
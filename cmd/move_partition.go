@@ -0,0 +1,549 @@
+package cmd
+
+import (
+        "crypto/sha256"
+        "encoding/binary"
+        "encoding/hex"
+        "fmt"
+        "hash"
+        "os"
+        "strings"
+        "sync"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        kafkaClient "kafy/internal/kafka"
+)
+
+var topicsMovePartitionCmd = &cobra.Command{
+        Use:   "move-partition <topic>",
+        Short: "Move data from one partition to another partition within the same topic",
+        Long: `Move messages from a source partition to a destination partition of the same topic.
+The records in the range are written to the destination partition in order, preserving keys,
+values, headers and timestamps. Afterwards the copies are read back from the destination and
+compared with the source by count and checksum. A copy still running after --timeout (default 1h)
+stops there; the offsets not moved are reported and the move is not verified.
+
+By default the whole partition (as of the start of the move) is moved. Limit the range with
+--begin-offset/--end-offset or --from-time/--to-time (RFC3339, a date, or a duration ago such as 2h);
+the end is exclusive.
+
+With --delete-source, a verified move is followed by deleting the moved records from the source
+partition (DeleteRecords up to the end of the range). Since Kafka can only delete from the start of a
+partition, the range must begin at the partition's first offset. This drains a poisoned partition.
+
+Examples:
+  kafy topics move-partition orders --source-partition 0 --dest-partition 3
+  kafy topics move-partition orders --source-partition 0 --dest-partition 3 --begin-offset 1200 --end-offset 1500
+  kafy topics move-partition orders --source-partition 0 --dest-partition 3 --from-time 2h
+  kafy topics move-partition users --source-partition 2 --dest-partition 1 --delete-source`,
+        Args:              cobra.ExactArgs(1),
+        ValidArgsFunction: completeTopics,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicName := args[0]
+                sourcePartition, _ := cmd.Flags().GetInt32("source-partition")
+                destPartition, _ := cmd.Flags().GetInt32("dest-partition")
+                beginOffset, _ := cmd.Flags().GetInt64("begin-offset")
+                endOffset, _ := cmd.Flags().GetInt64("end-offset")
+                fromTime, _ := cmd.Flags().GetString("from-time")
+                toTime, _ := cmd.Flags().GetString("to-time")
+                deleteSource, _ := cmd.Flags().GetBool("delete-source")
+                force, _ := cmd.Flags().GetBool("force")
+                timeout, _ := cmd.Flags().GetDuration("timeout")
+
+                if sourcePartition == destPartition {
+                        return fmt.Errorf("source and destination partitions cannot be the same")
+                }
+                if (cmd.Flags().Changed("begin-offset") || cmd.Flags().Changed("end-offset")) && (fromTime != "" || toTime != "") {
+                        return fmt.Errorf("use either --begin-offset/--end-offset or --from-time/--to-time")
+                }
+                if !cmd.Flags().Changed("begin-offset") {
+                        beginOffset = -1
+                }
+                if !cmd.Flags().Changed("end-offset") {
+                        endOffset = -1
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                topicInfo, err := client.DescribeTopic(topicName)
+                if err != nil {
+                        return err
+                }
+                for _, partition := range []int32{sourcePartition, destPartition} {
+                        if partition < 0 || int(partition) >= topicInfo.Partitions {
+                                return fmt.Errorf("topic '%s' has no partition %d (it has %d partitions)", topicName, partition, topicInfo.Partitions)
+                        }
+                }
+
+                groupID := fmt.Sprintf("kafy-move-partition-%d", time.Now().Unix())
+                consumer, err := client.CreateConsumerWithOptions(groupID, kafkaClient.ConsumerOptions{
+                        OffsetReset:       "earliest",
+                        DisableAutoCommit: true,
+                })
+                if err != nil {
+                        return fmt.Errorf("failed to create consumer: %w", err)
+                }
+                defer consumer.Close()
+
+                lowWatermark, highWatermark, err := consumer.QueryWatermarkOffsets(topicName, sourcePartition, 10000)
+                if err != nil {
+                        return fmt.Errorf("failed to query watermark offsets: %w", err)
+                }
+
+                move := &partitionMove{
+                        topic:    topicName,
+                        source:   sourcePartition,
+                        dest:     destPartition,
+                        consumer: consumer,
+                        begin:    lowWatermark,
+                        end:      highWatermark,
+                        timeout:  timeout,
+                }
+                if beginOffset >= 0 {
+                        move.begin = beginOffset
+                }
+                if endOffset >= 0 {
+                        move.end = endOffset
+                }
+                if fromTime != "" {
+                        if move.begin, err = move.offsetForTime(fromTime, highWatermark); err != nil {
+                                return fmt.Errorf("invalid --from-time: %w", err)
+                        }
+                }
+                if toTime != "" {
+                        if move.end, err = move.offsetForTime(toTime, highWatermark); err != nil {
+                                return fmt.Errorf("invalid --to-time: %w", err)
+                        }
+                }
+                if move.begin < lowWatermark {
+                        move.begin = lowWatermark
+                }
+                if move.end > highWatermark {
+                        move.end = highWatermark
+                }
+
+                if move.begin >= move.end {
+                        fmt.Printf("No records to move from partition %d (range %d-%d, partition holds %d-%d).\n",
+                                sourcePartition, move.begin, move.end, lowWatermark, highWatermark)
+                        return nil
+                }
+                if deleteSource && move.begin != lowWatermark {
+                        return fmt.Errorf("--delete-source deletes everything before offset %d, but the range starts at %d and "+
+                                "partition %d starts at %d; move from the start of the partition to delete the source", move.end, move.begin, sourcePartition, lowWatermark)
+                }
+
+                // Confirm the operation if delete-source is enabled
+                if deleteSource && !force {
+                        fmt.Printf("WARNING: This will move offsets %d-%d from partition %d to partition %d and DELETE them from partition %d once verified.\n",
+                                move.begin, move.end-1, sourcePartition, destPartition, sourcePartition)
+                        fmt.Printf("Are you sure you want to continue? (y/N): ")
+                        var response string
+                        fmt.Scanln(&response)
+                        if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+                                fmt.Println("Cancelled")
+                                return nil
+                        }
+                }
+
+                // Idempotence keeps the copies in the original order
+                move.producer, err = client.CreateProducerWithOptions(kafkaClient.ProducerOptions{Acks: "all", Idempotent: true})
+                if err != nil {
+                        return fmt.Errorf("failed to create producer: %w", err)
+                }
+                defer move.producer.Close()
+
+                fmt.Fprintf(os.Stderr, "Moving offsets %d-%d (%d records at most) from partition %d to partition %d in topic '%s'...\n",
+                        move.begin, move.end-1, move.end-move.begin, sourcePartition, destPartition, topicName)
+                if err := move.copy(); err != nil {
+                        return err
+                }
+
+                fmt.Fprintf(os.Stderr, "Verifying %d records in partition %d...\n", len(move.destOffsets), destPartition)
+                result := move.verify()
+
+                if deleteSource {
+                        if result.Verified {
+                                newLow, err := client.DeleteRecords(topicName, map[int32]int64{sourcePartition: move.end})
+                                if err != nil {
+                                        outputMoveResult(result)
+                                        return err
+                                }
+                                result.SourceDeletedBefore = move.end
+                                result.SourceNewStart = newLow[sourcePartition]
+                        } else {
+                                result.Notes = append(result.Notes, "source records were not deleted because verification failed")
+                        }
+                }
+
+                if err := outputMoveResult(result); err != nil {
+                        return err
+                }
+                if !result.Verified {
+                        return fmt.Errorf("verification failed: %s", strings.Join(result.Notes, "; "))
+                }
+                return nil
+        },
+}
+
+// partitionMove copies a range of one partition into another and checks the copies
+type partitionMove struct {
+        topic    string
+        source   int32
+        dest     int32
+        begin    int64 // First source offset
+        end      int64 // Source offset to stop before
+        timeout  time.Duration
+        consumer *kafka.Consumer
+        producer *kafka.Producer
+
+        read         int64
+        sourceFirst  int64
+        sourceLast   int64
+        sourceDigest hash.Hash
+        shortfall    string // Why the copy stopped before the end of the range
+
+        mu          sync.Mutex
+        reports     int64
+        failure     error
+        destOffsets []int64 // Destination offset of each copy, in source order
+}
+
+// moveResult is the reconciliation summary of a move
+type moveResult struct {
+        Topic               string   `json:"topic" yaml:"topic"`
+        SourcePartition     int32    `json:"source_partition" yaml:"source_partition"`
+        SourceFirstOffset   int64    `json:"source_first_offset" yaml:"source_first_offset"`
+        SourceLastOffset    int64    `json:"source_last_offset" yaml:"source_last_offset"`
+        SourceRecords       int64    `json:"source_records" yaml:"source_records"`
+        SourceChecksum      string   `json:"source_checksum" yaml:"source_checksum"`
+        DestPartition       int32    `json:"dest_partition" yaml:"dest_partition"`
+        DestFirstOffset     int64    `json:"dest_first_offset" yaml:"dest_first_offset"`
+        DestLastOffset      int64    `json:"dest_last_offset" yaml:"dest_last_offset"`
+        DestRecords         int64    `json:"dest_records" yaml:"dest_records"`
+        DestChecksum        string   `json:"dest_checksum" yaml:"dest_checksum"`
+        Verified            bool     `json:"verified" yaml:"verified"`
+        SourceDeletedBefore int64    `json:"source_deleted_before,omitempty" yaml:"source_deleted_before,omitempty"`
+        SourceNewStart      int64    `json:"source_new_start,omitempty" yaml:"source_new_start,omitempty"`
+        Notes               []string `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
+
+// offsetForTime finds the first offset of the source partition at or after a time
+func (m *partitionMove) offsetForTime(spec string, highWatermark int64) (int64, error) {
+        t, err := parseTimeSpec(spec)
+        if err != nil {
+                return 0, err
+        }
+        offsets, err := m.consumer.OffsetsForTimes([]kafka.TopicPartition{
+                {Topic: &m.topic, Partition: m.source, Offset: kafka.Offset(t.UnixMilli())},
+        }, 10000)
+        if err != nil {
+                return 0, fmt.Errorf("failed to look up offset for %s: %w", t.Format(time.RFC3339), err)
+        }
+        if len(offsets) == 0 || offsets[0].Offset < 0 {
+                // No record at or after the time
+                return highWatermark, nil
+        }
+        return int64(offsets[0].Offset), nil
+}
+
+// parseTimeSpec accepts RFC3339, "2006-01-02 15:04:05", "2006-01-02", or a duration ago such as 2h or 30m
+func parseTimeSpec(spec string) (time.Time, error) {
+        spec = strings.TrimSpace(spec)
+        if d, err := time.ParseDuration(strings.TrimPrefix(spec, "-")); err == nil {
+                return time.Now().Add(-d), nil
+        }
+        for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+                if t, err := time.ParseInLocation(layout, spec, time.Local); err == nil {
+                        return t, nil
+                }
+        }
+        return time.Time{}, fmt.Errorf("'%s' is not a time (use RFC3339, YYYY-MM-DD or a duration ago like 2h)", spec)
+}
+
+// copy reads the range from the source partition and writes it to the destination partition
+func (m *partitionMove) copy() error {
+        m.sourceDigest = sha256.New()
+        m.sourceFirst, m.sourceLast = -1, -1
+
+        err := m.consumer.Assign([]kafka.TopicPartition{{Topic: &m.topic, Partition: m.source, Offset: kafka.Offset(m.begin)}})
+        if err != nil {
+                return fmt.Errorf("failed to assign source partition: %w", err)
+        }
+
+        go m.handleDeliveries()
+
+        stopAt := time.Now().Add(m.timeout)
+        for {
+                if m.timeout > 0 && time.Now().After(stopAt) {
+                        next := m.begin
+                        if m.sourceLast >= 0 {
+                                next = m.sourceLast + 1
+                        }
+                        m.shortfall = fmt.Sprintf("stopped after %s: source offsets %d-%d were not moved", m.timeout, next, m.end-1)
+                        break
+                }
+                msg, err := m.consumer.ReadMessage(1000 * time.Millisecond)
+                if err != nil {
+                        if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+                                // Transaction markers and compacted gaps never show up as records
+                                positions, err := m.consumer.Position([]kafka.TopicPartition{{Topic: &m.topic, Partition: m.source}})
+                                if err == nil && len(positions) == 1 && positions[0].Offset >= 0 && int64(positions[0].Offset) >= m.end {
+                                        break
+                                }
+                                continue
+                        }
+                        return fmt.Errorf("failed to read message: %w", err)
+                }
+                if err := m.deliveryError(); err != nil {
+                        return err
+                }
+
+                offset := int64(msg.TopicPartition.Offset)
+                if offset >= m.end {
+                        break
+                }
+                if m.sourceFirst < 0 {
+                        m.sourceFirst = offset
+                }
+                m.sourceLast = offset
+                m.read++
+                m.sourceDigest.Write(recordDigest(msg))
+
+                destMessage := &kafka.Message{
+                        TopicPartition: kafka.TopicPartition{
+                                Topic:     &m.topic,
+                                Partition: m.dest,
+                        },
+                        Key:       msg.Key,
+                        Value:     msg.Value,
+                        Headers:   msg.Headers,
+                        Timestamp: msg.Timestamp, // Preserve original timestamp
+                        Opaque:    offset,
+                }
+                for {
+                        err = m.producer.Produce(destMessage, nil)
+                        if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrQueueFull {
+                                m.producer.Flush(100)
+                                continue
+                        }
+                        break
+                }
+                if err != nil {
+                        return fmt.Errorf("failed to produce message to destination partition: %w", err)
+                }
+
+                if m.read%1000 == 0 {
+                        fmt.Fprintf(os.Stderr, "Moved %d/%d messages...\n", m.read, m.end-m.begin)
+                }
+                if offset >= m.end-1 {
+                        break
+                }
+        }
+
+        // Wait for every delivery report
+        deadline := time.Now().Add(60 * time.Second)
+        for {
+                m.mu.Lock()
+                reports := m.reports
+                m.mu.Unlock()
+                if reports >= m.read {
+                        break
+                }
+                if time.Now().After(deadline) {
+                        return fmt.Errorf("%d of %d records were not confirmed by the broker within 60s", m.read-reports, m.read)
+                }
+                m.producer.Flush(500)
+        }
+        return m.deliveryError()
+}
+
+// handleDeliveries collects destination offsets. With an idempotent producer
+// the reports for one partition arrive in the order the records were sent.
+func (m *partitionMove) handleDeliveries() {
+        for e := range m.producer.Events() {
+                msg, ok := e.(*kafka.Message)
+                if !ok {
+                        continue
+                }
+                m.mu.Lock()
+                m.reports++
+                if msg.TopicPartition.Error != nil {
+                        if m.failure == nil {
+                                m.failure = fmt.Errorf("delivery failed for the record from offset %v: %w", msg.Opaque, msg.TopicPartition.Error)
+                        }
+                } else {
+                        m.destOffsets = append(m.destOffsets, int64(msg.TopicPartition.Offset))
+                }
+                m.mu.Unlock()
+        }
+}
+
+func (m *partitionMove) deliveryError() error {
+        m.mu.Lock()
+        defer m.mu.Unlock()
+        return m.failure
+}
+
+// verify reads the copies back from the destination partition and compares them with the source
+func (m *partitionMove) verify() moveResult {
+        m.mu.Lock()
+        offsets := append([]int64(nil), m.destOffsets...)
+        m.mu.Unlock()
+
+        result := moveResult{
+                Topic:             m.topic,
+                SourcePartition:   m.source,
+                SourceFirstOffset: m.sourceFirst,
+                SourceLastOffset:  m.sourceLast,
+                SourceRecords:     m.read,
+                SourceChecksum:    hex.EncodeToString(m.sourceDigest.Sum(nil)),
+                DestPartition:     m.dest,
+                DestFirstOffset:   -1,
+                DestLastOffset:    -1,
+        }
+        digest := sha256.New()
+        if m.shortfall != "" {
+                result.Notes = append(result.Notes, m.shortfall)
+        }
+
+        for i := 1; i < len(offsets); i++ {
+                if offsets[i] <= offsets[i-1] {
+                        result.Notes = append(result.Notes, "copies were written out of order")
+                        result.DestChecksum = hex.EncodeToString(digest.Sum(nil))
+                        return result
+                }
+        }
+
+        if len(offsets) > 0 {
+                result.DestFirstOffset, result.DestLastOffset = offsets[0], offsets[len(offsets)-1]
+                err := m.consumer.Assign([]kafka.TopicPartition{{Topic: &m.topic, Partition: m.dest, Offset: kafka.Offset(offsets[0])}})
+                if err != nil {
+                        result.Notes = append(result.Notes, fmt.Sprintf("failed to read back partition %d: %v", m.dest, err))
+                }
+
+                next := 0
+                lastRead := time.Now()
+                for err == nil && next < len(offsets) {
+                        msg, readErr := m.consumer.ReadMessage(1000 * time.Millisecond)
+                        if readErr != nil {
+                                if kafkaErr, ok := readErr.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut && time.Since(lastRead) < 30*time.Second {
+                                        continue
+                                }
+                                result.Notes = append(result.Notes, fmt.Sprintf("stopped reading back partition %d at offset %d: %v", m.dest, offsets[next], readErr))
+                                break
+                        }
+                        lastRead = time.Now()
+
+                        // Other producers may have written to the destination partition in between
+                        offset := int64(msg.TopicPartition.Offset)
+                        if offset < offsets[next] {
+                                continue
+                        }
+                        if offset > offsets[next] {
+                                result.Notes = append(result.Notes, fmt.Sprintf("the copy at offset %d of partition %d is missing", offsets[next], m.dest))
+                                break
+                        }
+                        digest.Write(recordDigest(msg))
+                        result.DestRecords++
+                        next++
+                }
+        }
+        result.DestChecksum = hex.EncodeToString(digest.Sum(nil))
+
+        if result.DestRecords != result.SourceRecords {
+                result.Notes = append(result.Notes, fmt.Sprintf("%d records were read from the source but %d copies were found", result.SourceRecords, result.DestRecords))
+        } else if result.DestChecksum != result.SourceChecksum {
+                result.Notes = append(result.Notes, "the checksums of the source records and their copies differ")
+        }
+        result.Verified = len(result.Notes) == 0
+        return result
+}
+
+// recordDigest hashes the key, value, headers and (for CreateTime topics) the timestamp of a record
+func recordDigest(msg *kafka.Message) []byte {
+        h := sha256.New()
+        writeField := func(data []byte) {
+                var prefix [9]byte
+                if data != nil {
+                        prefix[0] = 1
+                }
+                binary.BigEndian.PutUint64(prefix[1:], uint64(len(data)))
+                h.Write(prefix[:])
+                h.Write(data)
+        }
+        writeField(msg.Key)
+        writeField(msg.Value)
+        for _, header := range msg.Headers {
+                writeField([]byte(header.Key))
+                writeField(header.Value)
+        }
+        if msg.TimestampType == kafka.TimestampCreateTime {
+                var ts [8]byte
+                binary.BigEndian.PutUint64(ts[:], uint64(msg.Timestamp.UnixMilli()))
+                h.Write(ts[:])
+        }
+        return h.Sum(nil)
+}
+
+func outputMoveResult(result moveResult) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                return formatter.Output(result)
+        }
+
+        offsetRange := func(first, last int64) string {
+                if first < 0 {
+                        return "-"
+                }
+                return fmt.Sprintf("%d-%d", first, last)
+        }
+        verified := "yes"
+        if !result.Verified {
+                verified = "NO"
+        }
+        deleted := "no"
+        if result.SourceDeletedBefore > 0 {
+                deleted = fmt.Sprintf("records before offset %d (partition now starts at %d)", result.SourceDeletedBefore, result.SourceNewStart)
+        }
+
+        headers := []string{"Item", "Source", "Destination"}
+        rows := [][]string{
+                {"Partition", fmt.Sprintf("%d", result.SourcePartition), fmt.Sprintf("%d", result.DestPartition)},
+                {"Offsets", offsetRange(result.SourceFirstOffset, result.SourceLastOffset), offsetRange(result.DestFirstOffset, result.DestLastOffset)},
+                {"Records", fmt.Sprintf("%d", result.SourceRecords), fmt.Sprintf("%d", result.DestRecords)},
+                {"Checksum (SHA-256)", result.SourceChecksum[:16], result.DestChecksum[:16]},
+        }
+        if err := formatter.OutputTable(headers, rows); err != nil {
+                return err
+        }
+        fmt.Printf("Verified: %s\n", verified)
+        fmt.Printf("Source deleted: %s\n", deleted)
+        for _, note := range result.Notes {
+                fmt.Printf("Note: %s\n", note)
+        }
+        return nil
+}
+
+func init() {
+        topicsMovePartitionCmd.Flags().Int32("source-partition", -1, "Source partition number to move data from")
+        topicsMovePartitionCmd.Flags().Int32("dest-partition", -1, "Destination partition number to move data to")
+        topicsMovePartitionCmd.Flags().Int64("begin-offset", -1, "First source offset to move (default: start of the partition)")
+        topicsMovePartitionCmd.Flags().Int64("end-offset", -1, "Source offset to stop before (default: end of the partition)")
+        topicsMovePartitionCmd.Flags().String("from-time", "", "Move records from this time (RFC3339, YYYY-MM-DD or a duration ago like 2h)")
+        topicsMovePartitionCmd.Flags().String("to-time", "", "Move records before this time")
+        topicsMovePartitionCmd.Flags().Bool("delete-source", false, "After a verified move, delete the moved records from the source partition")
+        topicsMovePartitionCmd.Flags().Bool("force", false, "Skip the --delete-source confirmation")
+        topicsMovePartitionCmd.Flags().Duration("timeout", time.Hour, "Stop copying after this long and report the records not moved (0 = no limit)")
+        topicsMovePartitionCmd.MarkFlagRequired("source-partition")
+        topicsMovePartitionCmd.MarkFlagRequired("dest-partition")
+}
@@ -46,8 +46,7 @@ var offsetsShowCmd = &cobra.Command{
                                         strconv.Itoa(int(offset)),
                                 })
                         }
-                        formatter.OutputTable(headers, rows)
-                        return nil
+                        return formatter.OutputTable(headers, rows)
                 }
                 
                 return formatter.Output(offsets)
@@ -0,0 +1,577 @@
+package cmd
+
+import (
+        "fmt"
+        "math"
+        "math/rand"
+        "os"
+        "os/signal"
+        "strconv"
+        "sync"
+        "sync/atomic"
+        "syscall"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        kafkaClient "kafy/internal/kafka"
+        "kafy/internal/perf"
+)
+
+// perfTimestampHeader carries the send time (Unix nanoseconds) so perf consume can measure end-to-end latency
+const perfTimestampHeader = "kafy-perf-ts"
+
+var perfCmd = &cobra.Command{
+        Use:   "perf",
+        Short: "Load test producers and consumers",
+        Long: `Measure producer and consumer throughput and latency against a topic.
+Results can be printed as JSON (--output json) to compare runs across broker or client configuration changes.`,
+}
+
+// perfResult is the report printed at the end of a perf run
+type perfResult struct {
+        Mode            string                 `json:"mode" yaml:"mode"`
+        Topic           string                 `json:"topic" yaml:"topic"`
+        StartedAt       time.Time              `json:"started_at" yaml:"started_at"`
+        DurationSeconds float64                `json:"duration_seconds" yaml:"duration_seconds"`
+        Messages        int64                  `json:"messages" yaml:"messages"`
+        Failed          int64                  `json:"failed" yaml:"failed"`
+        Bytes           int64                  `json:"bytes" yaml:"bytes"`
+        MessagesPerSec  float64                `json:"msgs_per_sec" yaml:"msgs_per_sec"`
+        MBPerSec        float64                `json:"mb_per_sec" yaml:"mb_per_sec"`
+        LatencyType     string                 `json:"latency_type" yaml:"latency_type"`
+        Latency         perf.LatencySummary    `json:"latency" yaml:"latency"`
+        Config          map[string]interface{} `json:"config" yaml:"config"`
+}
+
+var perfProduceCmd = &cobra.Command{
+        Use:   "produce <topic>",
+        Short: "Load test producing to a topic",
+        Long: `Produce synthetic messages at a target rate and report throughput and delivery latency
+(time from send until the broker acknowledged the message).
+
+Each message carries a '` + perfTimestampHeader + `' header with its send time, which 'kafy perf consume'
+uses to measure end-to-end latency.
+
+Examples:
+  kafy perf produce bench --duration 60s --rate 10000 --size 1024
+  kafy perf produce bench --count 1000000 --size uniform:100-4000 --keys 1000 --concurrency 4
+  kafy perf produce bench --duration 30s --acks all --compression lz4 --linger-ms 10 --output json`,
+        Args:              cobra.ExactArgs(1),
+        ValidArgsFunction: completeTopics,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicName := args[0]
+                count, _ := cmd.Flags().GetInt64("count")
+                duration, _ := cmd.Flags().GetDuration("duration")
+                rate, _ := cmd.Flags().GetFloat64("rate")
+                sizeSpec, _ := cmd.Flags().GetString("size")
+                keys, _ := cmd.Flags().GetInt("keys")
+                concurrency, _ := cmd.Flags().GetInt("concurrency")
+                reportInterval, _ := cmd.Flags().GetDuration("report-interval")
+
+                producerOpts := kafkaClient.ProducerOptions{}
+                producerOpts.Compression, _ = cmd.Flags().GetString("compression")
+                producerOpts.Acks, _ = cmd.Flags().GetString("acks")
+                producerOpts.Idempotent, _ = cmd.Flags().GetBool("idempotent")
+                producerOpts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+                if cmd.Flags().Changed("linger-ms") {
+                        lingerMs, _ := cmd.Flags().GetInt("linger-ms")
+                        producerOpts.LingerMs = &lingerMs
+                }
+                if err := producerOpts.Validate(); err != nil {
+                        return err
+                }
+
+                sizes, err := perf.ParseSizeDistribution(sizeSpec)
+                if err != nil {
+                        return err
+                }
+                if concurrency < 1 {
+                        return fmt.Errorf("--concurrency must be at least 1")
+                }
+                if keys < 0 {
+                        return fmt.Errorf("--keys must not be negative")
+                }
+                if count <= 0 && duration <= 0 {
+                        return fmt.Errorf("set --count or --duration")
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                workers := make([]*perfProducer, concurrency)
+                for i := range workers {
+                        producer, err := client.CreateProducerWithOptions(producerOpts)
+                        if err != nil {
+                                return err
+                        }
+                        defer producer.Close()
+                        workers[i] = newPerfProducer(producer)
+                }
+
+                stop := make(chan struct{})
+                var stopOnce sync.Once
+                stopAll := func() { stopOnce.Do(func() { close(stop) }) }
+
+                sigChan := make(chan os.Signal, 1)
+                signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+                defer signal.Stop(sigChan)
+                go func() {
+                        select {
+                        case <-sigChan:
+                                fmt.Fprintf(os.Stderr, "\nInterrupted, finishing up...\n")
+                                stopAll()
+                        case <-stop:
+                        }
+                }()
+                if duration > 0 {
+                        timer := time.AfterFunc(duration, stopAll)
+                        defer timer.Stop()
+                }
+
+                fmt.Fprintf(os.Stderr, "Producing to '%s' with %d producer(s)...\n", topicName, concurrency)
+                start := time.Now()
+                var sent int64
+                var wg sync.WaitGroup
+                for i, worker := range workers {
+                        wg.Add(1)
+                        go func(seed int64, worker *perfProducer) {
+                                defer wg.Done()
+                                worker.run(topicName, count, &sent, rate/float64(concurrency), sizes, keys, seed, stop)
+                        }(time.Now().UnixNano()+int64(i), worker)
+                }
+
+                reporterDone := make(chan struct{})
+                go func() {
+                        defer close(reporterDone)
+                        if reportInterval <= 0 {
+                                <-stop
+                                return
+                        }
+                        reportPerfProgress(start, reportInterval, stop, func() (int64, int64) {
+                                var messages, bytes int64
+                                for _, w := range workers {
+                                        messages += atomic.LoadInt64(&w.delivered)
+                                        bytes += atomic.LoadInt64(&w.bytes)
+                                }
+                                return messages, bytes
+                        })
+                }()
+
+                wg.Wait()
+                stopAll()
+                <-reporterDone
+
+                // Wait for outstanding deliveries so they are included in the results
+                for _, worker := range workers {
+                        worker.drain(30 * time.Second)
+                }
+                elapsed := time.Since(start)
+
+                latency := perf.NewHistogram()
+                result := perfResult{
+                        Mode:            "produce",
+                        Topic:           topicName,
+                        StartedAt:       start.UTC(),
+                        DurationSeconds: elapsed.Seconds(),
+                        LatencyType:     "delivery",
+                        Config: map[string]interface{}{
+                                "rate":        rate,
+                                "size":        sizes.String(),
+                                "keys":        keys,
+                                "concurrency": concurrency,
+                                "acks":        producerOpts.Acks,
+                                "compression": producerOpts.Compression,
+                                "idempotent":  producerOpts.Idempotent,
+                                "batch_size":  producerOpts.BatchSize,
+                        },
+                }
+                if producerOpts.LingerMs != nil {
+                        result.Config["linger_ms"] = *producerOpts.LingerMs
+                }
+                for _, worker := range workers {
+                        result.Messages += atomic.LoadInt64(&worker.delivered)
+                        result.Failed += atomic.LoadInt64(&worker.failed)
+                        result.Bytes += atomic.LoadInt64(&worker.bytes)
+                        latency.Merge(worker.latency)
+                }
+                result.Latency = latency.Summary()
+                result.setRates(elapsed)
+
+                if err := outputPerfResult(result); err != nil {
+                        return err
+                }
+                if result.Failed > 0 {
+                        return fmt.Errorf("%d messages failed to deliver", result.Failed)
+                }
+                return nil
+        },
+}
+
+// perfProducer is one load generating producer and its delivery statistics
+type perfProducer struct {
+        producer  *kafka.Producer
+        latency   *perf.Histogram
+        queued    int64
+        delivered int64
+        failed    int64
+        bytes     int64
+}
+
+func newPerfProducer(producer *kafka.Producer) *perfProducer {
+        p := &perfProducer{producer: producer, latency: perf.NewHistogram()}
+        go func() {
+                for e := range producer.Events() {
+                        msg, ok := e.(*kafka.Message)
+                        if !ok {
+                                continue
+                        }
+                        if msg.TopicPartition.Error != nil {
+                                atomic.AddInt64(&p.failed, 1)
+                                continue
+                        }
+                        if sentAt, ok := msg.Opaque.(time.Time); ok {
+                                p.latency.Record(time.Since(sentAt))
+                        }
+                        atomic.AddInt64(&p.bytes, int64(len(msg.Value)))
+                        atomic.AddInt64(&p.delivered, 1)
+                }
+        }()
+        return p
+}
+
+// run produces until stop is closed or the shared sent counter reaches count
+func (p *perfProducer) run(topic string, count int64, sent *int64, rate float64, sizes perf.SizeDistribution, keys int, seed int64, stop <-chan struct{}) {
+        r := rand.New(rand.NewSource(seed))
+        payloads := perf.NewPayloads(sizes.Max(), r)
+        pacer := perf.NewPacer(rate)
+
+        for {
+                select {
+                case <-stop:
+                        return
+                default:
+                }
+                if count > 0 && atomic.AddInt64(sent, 1) > count {
+                        return
+                }
+                pacer.Wait()
+
+                msg := &kafka.Message{
+                        TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+                        Value:          payloads.Get(sizes.Next(r), r),
+                }
+                if keys > 0 {
+                        msg.Key = []byte("key-" + strconv.Itoa(r.Intn(keys)))
+                }
+
+                for {
+                        now := time.Now()
+                        msg.Opaque = now
+                        msg.Headers = []kafka.Header{{Key: perfTimestampHeader, Value: strconv.AppendInt(nil, now.UnixNano(), 10)}}
+                        err := p.producer.Produce(msg, nil)
+                        if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrQueueFull {
+                                p.producer.Flush(10)
+                                continue
+                        }
+                        if err != nil {
+                                atomic.AddInt64(&p.failed, 1)
+                        } else {
+                                atomic.AddInt64(&p.queued, 1)
+                        }
+                        break
+                }
+        }
+}
+
+// drain waits for queued messages to be acknowledged
+func (p *perfProducer) drain(timeout time.Duration) {
+        deadline := time.Now().Add(timeout)
+        for time.Now().Before(deadline) {
+                p.producer.Flush(500)
+                if atomic.LoadInt64(&p.delivered)+atomic.LoadInt64(&p.failed) >= atomic.LoadInt64(&p.queued) {
+                        return
+                }
+        }
+}
+
+var perfConsumeCmd = &cobra.Command{
+        Use:   "consume <topic>",
+        Short: "Load test consuming from a topic",
+        Long: `Consume messages as fast as possible and report fetch throughput and end-to-end latency.
+
+End-to-end latency uses the send time embedded by 'kafy perf produce' when present and falls
+back to the record timestamp (millisecond precision) otherwise. Run it alongside 'kafy perf produce'
+for live latency, or with --from-beginning to measure raw fetch throughput.
+
+Examples:
+  kafy perf consume bench --duration 60s
+  kafy perf consume bench --from-beginning --count 1000000 --output json`,
+        Args:              cobra.ExactArgs(1),
+        ValidArgsFunction: completeTopics,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicName := args[0]
+                count, _ := cmd.Flags().GetInt64("count")
+                duration, _ := cmd.Flags().GetDuration("duration")
+                group, _ := cmd.Flags().GetString("group")
+                fromBeginning, _ := cmd.Flags().GetBool("from-beginning")
+                idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout")
+                reportInterval, _ := cmd.Flags().GetDuration("report-interval")
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                temporaryGroup := group == ""
+                if temporaryGroup {
+                        group = fmt.Sprintf("kafy-perf-%d", time.Now().UnixNano())
+                }
+                offsetReset := "latest"
+                if fromBeginning {
+                        offsetReset = "earliest"
+                }
+                consumer, err := client.CreateConsumerWithOffset(group, offsetReset)
+                if err != nil {
+                        return err
+                }
+                // Idle time counts from the assignment so group joins don't trip it
+                waitingSince := time.Now()
+                rebalance := func(c *kafka.Consumer, event kafka.Event) error {
+                        if _, ok := event.(kafka.AssignedPartitions); ok {
+                                waitingSince = time.Now()
+                        }
+                        return nil
+                }
+                if err := consumer.SubscribeTopics([]string{topicName}, rebalance); err != nil {
+                        consumer.Close()
+                        return fmt.Errorf("failed to subscribe: %w", err)
+                }
+
+                sigChan := make(chan os.Signal, 1)
+                signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+                defer signal.Stop(sigChan)
+
+                fmt.Fprintf(os.Stderr, "Consuming from '%s' (group: %s)...\n", topicName, group)
+
+                latency := perf.NewHistogram()
+                latencyType := "end_to_end"
+                var messages, bytes int64
+                var start, last time.Time
+                stop := make(chan struct{})
+                reporterDone := make(chan struct{})
+                deadline := time.Time{}
+                if duration > 0 {
+                        deadline = time.Now().Add(duration)
+                }
+
+        consumeLoop:
+                for {
+                        select {
+                        case <-sigChan:
+                                fmt.Fprintf(os.Stderr, "\nInterrupted, finishing up...\n")
+                                break consumeLoop
+                        default:
+                        }
+                        if !deadline.IsZero() && time.Now().After(deadline) {
+                                break
+                        }
+                        if count > 0 && messages >= count {
+                                break
+                        }
+
+                        msg, err := consumer.ReadMessage(100 * time.Millisecond)
+                        if err != nil {
+                                if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+                                        idleSince := waitingSince
+                                        if !last.IsZero() {
+                                                idleSince = last
+                                        }
+                                        if idleTimeout > 0 && time.Since(idleSince) > idleTimeout {
+                                                fmt.Fprintf(os.Stderr, "No messages for %s, stopping\n", idleTimeout)
+                                                break
+                                        }
+                                        continue
+                                }
+                                close(stop)
+                                consumer.Close()
+                                return fmt.Errorf("consumer error: %w", err)
+                        }
+
+                        now := time.Now()
+                        if start.IsZero() {
+                                // Measure from the first message so group join time is excluded
+                                start = now
+                                if reportInterval > 0 {
+                                        go func() {
+                                                defer close(reporterDone)
+                                                reportPerfProgress(start, reportInterval, stop, func() (int64, int64) {
+                                                        return atomic.LoadInt64(&messages), atomic.LoadInt64(&bytes)
+                                                })
+                                        }()
+                                } else {
+                                        close(reporterDone)
+                                }
+                        }
+                        last = now
+                        atomic.AddInt64(&messages, 1)
+                        atomic.AddInt64(&bytes, int64(len(msg.Value)))
+
+                        if sentAt, ok := perfSendTime(msg); ok {
+                                latency.Record(now.Sub(sentAt))
+                        } else if !msg.Timestamp.IsZero() {
+                                latencyType = "end_to_end (includes record timestamps)"
+                                latency.Record(now.Sub(msg.Timestamp))
+                        }
+                }
+
+                close(stop)
+                if !start.IsZero() {
+                        <-reporterDone
+                }
+                consumer.Close()
+                if temporaryGroup {
+                        client.DeleteConsumerGroup(group)
+                }
+
+                elapsed := time.Duration(0)
+                if !start.IsZero() {
+                        elapsed = last.Sub(start)
+                }
+                result := perfResult{
+                        Mode:            "consume",
+                        Topic:           topicName,
+                        StartedAt:       start.UTC(),
+                        DurationSeconds: elapsed.Seconds(),
+                        Messages:        messages,
+                        Bytes:           bytes,
+                        LatencyType:     latencyType,
+                        Latency:         latency.Summary(),
+                        Config: map[string]interface{}{
+                                "group":          group,
+                                "from_beginning": fromBeginning,
+                        },
+                }
+                result.setRates(elapsed)
+                return outputPerfResult(result)
+        },
+}
+
+// perfSendTime reads the send time embedded by perf produce
+func perfSendTime(msg *kafka.Message) (time.Time, bool) {
+        for _, header := range msg.Headers {
+                if header.Key == perfTimestampHeader {
+                        nanos, err := strconv.ParseInt(string(header.Value), 10, 64)
+                        if err != nil {
+                                return time.Time{}, false
+                        }
+                        return time.Unix(0, nanos), true
+                }
+        }
+        return time.Time{}, false
+}
+
+// reportPerfProgress prints interval throughput to stderr until stop is closed
+func reportPerfProgress(start time.Time, interval time.Duration, stop <-chan struct{}, snapshot func() (messages, bytes int64)) {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        var lastMessages, lastBytes int64
+        lastTime := start
+        for {
+                select {
+                case <-stop:
+                        return
+                case now := <-ticker.C:
+                        messages, bytes := snapshot()
+                        seconds := now.Sub(lastTime).Seconds()
+                        fmt.Fprintf(os.Stderr, "[%6.0fs] %d msgs total, %.0f msgs/s, %.2f MB/s\n",
+                                now.Sub(start).Seconds(), messages,
+                                float64(messages-lastMessages)/seconds,
+                                float64(bytes-lastBytes)/seconds/(1024*1024))
+                        lastMessages, lastBytes, lastTime = messages, bytes, now
+                }
+        }
+}
+
+func (r *perfResult) setRates(elapsed time.Duration) {
+        if elapsed <= 0 {
+                return
+        }
+        seconds := elapsed.Seconds()
+        r.MessagesPerSec = math.Round(float64(r.Messages)/seconds*100) / 100
+        r.MBPerSec = math.Round(float64(r.Bytes)/seconds/(1024*1024)*1000) / 1000
+}
+
+func outputPerfResult(result perfResult) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                return formatter.Output(result)
+        }
+
+        ms := func(v float64) string { return fmt.Sprintf("%.3f ms", v) }
+        headers := []string{"Metric", "Value"}
+        rows := [][]string{
+                {"Topic", result.Topic},
+                {"Duration", fmt.Sprintf("%.2fs", result.DurationSeconds)},
+                {"Messages", fmt.Sprintf("%d", result.Messages)},
+        }
+        if result.Mode == "produce" {
+                rows = append(rows, []string{"Failed", fmt.Sprintf("%d", result.Failed)})
+        }
+        rows = append(rows,
+                []string{"Data", formatBytes(result.Bytes)},
+                []string{"Throughput", fmt.Sprintf("%.0f msgs/s", result.MessagesPerSec)},
+                []string{"Bandwidth", fmt.Sprintf("%.2f MB/s", result.MBPerSec)},
+        )
+        if result.Latency.Samples > 0 {
+                label := "Delivery latency"
+                if result.Mode == "consume" {
+                        label = "End-to-end latency"
+                }
+                rows = append(rows,
+                        []string{label + " p50", ms(result.Latency.P50)},
+                        []string{label + " p95", ms(result.Latency.P95)},
+                        []string{label + " p99", ms(result.Latency.P99)},
+                        []string{label + " p99.9", ms(result.Latency.P999)},
+                        []string{label + " max", ms(result.Latency.Max)},
+                )
+        }
+        return formatter.OutputTable(headers, rows)
+}
+
+func init() {
+        perfCmd.AddCommand(perfProduceCmd)
+        perfCmd.AddCommand(perfConsumeCmd)
+
+        perfProduceCmd.Flags().Int64("count", 0, "Number of messages to produce (0 = until --duration elapses)")
+        perfProduceCmd.Flags().Duration("duration", 0, "How long to produce for (e.g. 60s)")
+        perfProduceCmd.Flags().Float64("rate", 0, "Target messages per second across all producers (0 = as fast as possible)")
+        perfProduceCmd.Flags().String("size", "1024", "Message size in bytes or a distribution: fixed:1024, uniform:100-2000, normal:1000,200")
+        perfProduceCmd.Flags().Int("keys", 0, "Number of distinct keys, chosen uniformly (0 = no keys)")
+        perfProduceCmd.Flags().Int("concurrency", 1, "Number of parallel producers")
+        perfProduceCmd.Flags().Duration("report-interval", 5*time.Second, "Print progress at this interval (0 = off)")
+        perfProduceCmd.Flags().String("compression", "", "Compression codec: none, gzip, snappy, lz4, zstd")
+        perfProduceCmd.Flags().String("acks", "", "Required acknowledgements: 0, 1 or all")
+        perfProduceCmd.Flags().Bool("idempotent", false, "Enable the idempotent producer")
+        perfProduceCmd.Flags().Int("linger-ms", 0, "Time to wait for more messages before sending a batch")
+        perfProduceCmd.Flags().Int("batch-size", 0, "Maximum batch size in bytes")
+
+        perfConsumeCmd.Flags().Int64("count", 0, "Stop after this many messages (0 = unlimited)")
+        perfConsumeCmd.Flags().Duration("duration", 0, "Stop this long after starting, even if nothing arrives (0 = unlimited)")
+        perfConsumeCmd.Flags().String("group", "", "Consumer group (default: a temporary group that is deleted afterwards)")
+        perfConsumeCmd.Flags().Bool("from-beginning", false, "Start from the earliest offset instead of only new messages")
+        perfConsumeCmd.Flags().Duration("idle-timeout", 10*time.Second, "Stop when no messages arrive for this long (0 = never)")
+        perfConsumeCmd.Flags().Duration("report-interval", 5*time.Second, "Print progress at this interval (0 = off)")
+}
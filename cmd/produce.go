@@ -2,7 +2,6 @@ package cmd
 
 import (
         "bufio"
-        "encoding/json"
         "fmt"
         "os"
         "strings"
@@ -10,6 +9,7 @@ import (
 
         "github.com/confluentinc/confluent-kafka-go/v2/kafka"
         "github.com/spf13/cobra"
+        "kafy/internal/generator"
         kafkaClient "kafy/internal/kafka"
 )
 
@@ -26,6 +26,43 @@ var produceCmd = &cobra.Command{
                 count, _ := cmd.Flags().GetInt("count")
                 size, _ := cmd.Flags().GetInt("size")
                 headerStrings, _ := cmd.Flags().GetStringSlice("header")
+                inputFormat, _ := cmd.Flags().GetString("input-format")
+                keyField, _ := cmd.Flags().GetString("key-field")
+                keySeparator, _ := cmd.Flags().GetString("key-separator")
+                base64Fields, _ := cmd.Flags().GetStringSlice("base64")
+                deadLetterFile, _ := cmd.Flags().GetString("dead-letter-file")
+                flushTimeout, _ := cmd.Flags().GetDuration("flush-timeout")
+                partition, _ := cmd.Flags().GetInt32("partition")
+                transactionSize, _ := cmd.Flags().GetInt("transaction-size")
+                abort, _ := cmd.Flags().GetBool("abort")
+                templateFile, _ := cmd.Flags().GetString("template")
+                avroSchemaFile, _ := cmd.Flags().GetString("avro-schema")
+                keyDistribution, _ := cmd.Flags().GetString("key-distribution")
+                seed, _ := cmd.Flags().GetInt64("seed")
+
+                producerOpts := kafkaClient.ProducerOptions{}
+                producerOpts.Partitioner, _ = cmd.Flags().GetString("partitioner")
+                producerOpts.Compression, _ = cmd.Flags().GetString("compression")
+                producerOpts.Acks, _ = cmd.Flags().GetString("acks")
+                producerOpts.Idempotent, _ = cmd.Flags().GetBool("idempotent")
+                producerOpts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+                producerOpts.MessageTimeout, _ = cmd.Flags().GetDuration("message-timeout")
+                producerOpts.TransactionalID, _ = cmd.Flags().GetString("transactional-id")
+                if cmd.Flags().Changed("linger-ms") {
+                        lingerMs, _ := cmd.Flags().GetInt("linger-ms")
+                        producerOpts.LingerMs = &lingerMs
+                }
+                if err := producerOpts.Validate(); err != nil {
+                        return err
+                }
+                if producerOpts.TransactionalID == "" && (abort || transactionSize > 0) {
+                        return fmt.Errorf("--abort and --transaction-size require --transactional-id")
+                }
+
+                // Wait at least as long as librdkafka may keep retrying a message
+                if producerOpts.MessageTimeout > 0 && !cmd.Flags().Changed("flush-timeout") {
+                        flushTimeout = producerOpts.MessageTimeout + 5*time.Second
+                }
 
                 // Parse headers from command line flags
                 headers, err := parseHeaders(headerStrings)
@@ -33,6 +70,37 @@ var produceCmd = &cobra.Command{
                         return fmt.Errorf("invalid header format: %w", err)
                 }
 
+                input, err := newProduceInput(topicName, format, inputFormat, key, keyField, keySeparator, base64Fields, headers)
+                if err != nil {
+                        return err
+                }
+                if count > 0 {
+                        if !cmd.Flags().Changed("seed") {
+                                seed = time.Now().UnixNano()
+                        }
+                        if input.Keys, err = generator.ParseKeyDistribution(keyDistribution, seed); err != nil {
+                                return err
+                        }
+                        if input.Values, err = newValueGenerator(templateFile, avroSchemaFile, size, seed); err != nil {
+                                return err
+                        }
+                } else if templateFile != "" || avroSchemaFile != "" || cmd.Flags().Changed("key-distribution") {
+                        return fmt.Errorf("--template, --avro-schema and --key-distribution require --count")
+                }
+                replay, err := newReplayer(cmd)
+                if err != nil {
+                        return err
+                }
+                if replay.timing && (count > 0 || inputFormat != "ndjson") {
+                        return fmt.Errorf("--replay-timing needs record timestamps from --input-format ndjson")
+                }
+                if cmd.Flags().Changed("partition") {
+                        if partition < 0 {
+                                return fmt.Errorf("--partition must not be negative")
+                        }
+                        input.Partition = partition
+                }
+
                 cfg, err := LoadConfigWithClusterOverride()
                 if err != nil {
                         return err
@@ -43,35 +111,53 @@ var produceCmd = &cobra.Command{
                         return err
                 }
 
-                producer, err := client.CreateProducer()
+                producer, err := client.CreateProducerWithOptions(producerOpts)
                 if err != nil {
                         return err
                 }
                 defer producer.Close()
 
-                // Handle delivery reports
-                go func() {
-                        for e := range producer.Events() {
-                                switch ev := e.(type) {
-                                case *kafka.Message:
-                                        if ev.TopicPartition.Error != nil {
-                                                fmt.Printf("Delivery failed: %v\n", ev.TopicPartition)
-                                        } else {
-                                                fmt.Printf("Delivered message to %v\n", ev.TopicPartition)
-                                        }
-                                }
+                // The topic may not exist yet if auto-creation is enabled, so only check when it does
+                topicPartitions := 0
+                if topicInfo, err := client.DescribeTopic(topicName); err == nil {
+                        topicPartitions = topicInfo.Partitions
+                        if input.Partition != kafka.PartitionAny && input.Partition >= int32(topicPartitions) {
+                                return fmt.Errorf("topic '%s' has %d partitions; partition %d does not exist", topicName, topicPartitions, input.Partition)
                         }
-                }()
+                }
 
-                if count > 0 {
-                        return produceTestMessages(producer, topicName, key, headers, count, size)
+                // Only show the progress line when it won't mix with typed input or piped output
+                showProgress := isTerminal(os.Stderr) && (count > 0 || file != "" || !isTerminal(os.Stdin))
+                tracker, err := newDeliveryTracker(producer, deadLetterFile, showProgress)
+                if err != nil {
+                        return err
+                }
+                // Check keyed messages land where Java services expect them
+                tracker.EnableJavaPartitionCheck(topicPartitions)
+
+                if producerOpts.TransactionalID != "" {
+                        if err := tracker.BeginTransactions(transactionSize, abort); err != nil {
+                                return err
+                        }
                 }
 
-                if file != "" {
-                        return produceFromFile(producer, topicName, key, headers, file, format)
+                if description := replay.Describe(); description != "" {
+                        fmt.Printf("Pacing messages: %s\n", description)
+                }
+
+                if count > 0 {
+                        err = produceTestMessages(tracker, input, replay, count, size)
+                } else if file != "" {
+                        err = produceFromFile(tracker, input, replay, file)
+                } else {
+                        input.LenientJSON = true
+                        err = produceInteractive(tracker, input, replay)
                 }
 
-                return produceInteractive(producer, topicName, key, headers, format)
+                if finishErr := tracker.Finish(flushTimeout); err == nil {
+                        err = finishErr
+                }
+                return err
         },
 }
 
@@ -114,42 +200,75 @@ func parseHeaders(headerStrings []string) ([]kafka.Header, error) {
         return headers, nil
 }
 
-func produceTestMessages(producer *kafka.Producer, topic, key string, headers []kafka.Header, count int, size int) error {
+// newValueGenerator loads the --template or --avro-schema generator, or returns nil for the built-in test messages
+func newValueGenerator(templateFile, avroSchemaFile string, size int, seed int64) (*generator.Generator, error) {
+        if templateFile == "" && avroSchemaFile == "" {
+                return nil, nil
+        }
+        if templateFile != "" && avroSchemaFile != "" {
+                return nil, fmt.Errorf("use either --template or --avro-schema, not both")
+        }
+        if size > 0 {
+                return nil, fmt.Errorf("--size cannot be combined with --template or --avro-schema")
+        }
+
+        if templateFile != "" {
+                data, err := os.ReadFile(templateFile)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to read template: %w", err)
+                }
+                return generator.NewTemplate(data, seed)
+        }
+        data, err := os.ReadFile(avroSchemaFile)
+        if err != nil {
+                return nil, fmt.Errorf("failed to read Avro schema: %w", err)
+        }
+        return generator.NewAvro(data, seed)
+}
+
+func produceTestMessages(tracker *deliveryTracker, input *produceInput, replay *replayer, count int, size int) error {
         for i := 0; i < count; i++ {
-                var message string
+                var message []byte
                 
-                if size > 0 {
+                if input.Values != nil {
+                        value, err := input.Values.Generate(i)
+                        if err != nil {
+                                return fmt.Errorf("failed to generate message %d: %w", i, err)
+                        }
+                        message = value
+                } else if size > 0 {
                         // Generate message of specific size
-                        message = generateMessageOfSize(i, size)
+                        message = []byte(generateMessageOfSize(i, size))
                 } else {
                         // Default message format
-                        message = fmt.Sprintf(`{"id": %d, "message": "test message %d", "timestamp": "%s"}`, 
-                                i, i, time.Now().Format(time.RFC3339))
+                        message = []byte(fmt.Sprintf(`{"id": %d, "message": "test message %d", "timestamp": "%s"}`, 
+                                i, i, time.Now().Format(time.RFC3339)))
                 }
                 
-                messageKey := key
-                if messageKey == "" {
-                        messageKey = fmt.Sprintf("key-%d", i)
+                // --key and --key-field take precedence over the key distribution
+                messageKey, err := input.keyFor(message)
+                if err != nil {
+                        return fmt.Errorf("message %d: %w", i, err)
+                }
+                if messageKey == nil {
+                        messageKey = input.Keys.Key(i)
                 }
 
-                err := producer.Produce(&kafka.Message{
-                        TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-                        Key:            []byte(messageKey),
-                        Value:          []byte(message),
-                        Headers:        headers,
-                }, nil)
+                msg := input.newMessage()
+                msg.Key = messageKey
+                msg.Value = message
+                msg.Timestamp = replay.Wait(msg.Timestamp)
+                err = tracker.Produce(msg)
                 
                 if err != nil {
                         return fmt.Errorf("failed to produce message %d: %w", i, err)
                 }
         }
 
-        // Wait for all messages to be delivered
-        producer.Flush(15 * 1000)
         if size > 0 {
-                fmt.Printf("Produced %d test messages of %d bytes each\n", count, size)
+                fmt.Printf("Queued %d test messages of %d bytes each\n", count, size)
         } else {
-                fmt.Printf("Produced %d test messages\n", count)
+                fmt.Printf("Queued %d test messages\n", count)
         }
         return nil
 }
@@ -188,7 +307,7 @@ func generateMessageOfSize(id int, targetSize int) string {
         return baseMessage + padding + suffix
 }
 
-func produceFromFile(producer *kafka.Producer, topic, key string, headers []kafka.Header, filename, format string) error {
+func produceFromFile(tracker *deliveryTracker, input *produceInput, replay *replayer, filename string) error {
         file, err := os.Open(filename)
         if err != nil {
                 return fmt.Errorf("failed to open file: %w", err)
@@ -196,43 +315,25 @@ func produceFromFile(producer *kafka.Producer, topic, key string, headers []kafk
         defer file.Close()
 
         scanner := bufio.NewScanner(file)
+        scanner.Buffer(make([]byte, 64*1024), maxInputLineSize)
         messageCount := 0
+        lineNumber := 0
 
         for scanner.Scan() {
+                lineNumber++
                 line := strings.TrimSpace(scanner.Text())
                 if line == "" {
                         continue
                 }
 
-                var messageValue []byte
-                switch format {
-                case "json":
-                        // Validate JSON
-                        var js json.RawMessage
-                        if err := json.Unmarshal([]byte(line), &js); err != nil {
-                                fmt.Printf("Skipping invalid JSON: %s\n", line)
-                                continue
-                        }
-                        messageValue = []byte(line)
-                case "yaml":
-                        // For now, treat as plain text
-                        messageValue = []byte(line)
-                default:
-                        messageValue = []byte(line)
-                }
-
-                messageKey := key
-                if messageKey == "" {
-                        messageKey = fmt.Sprintf("msg-%d", messageCount)
+                msg, err := input.parseLine(line, messageCount)
+                if err != nil {
+                        fmt.Printf("Skipping line %d: %v\n", lineNumber, err)
+                        continue
                 }
 
-                err := producer.Produce(&kafka.Message{
-                        TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-                        Key:            []byte(messageKey),
-                        Value:          messageValue,
-                        Headers:        headers,
-                }, nil)
-                
+                msg.Timestamp = replay.Wait(msg.Timestamp)
+                err = tracker.Produce(msg)
                 if err != nil {
                         return fmt.Errorf("failed to produce message: %w", err)
                 }
@@ -244,16 +345,15 @@ func produceFromFile(producer *kafka.Producer, topic, key string, headers []kafk
                 return fmt.Errorf("error reading file: %w", err)
         }
 
-        // Wait for all messages to be delivered
-        producer.Flush(15 * 1000)
-        fmt.Printf("Produced %d messages from file\n", messageCount)
+        fmt.Printf("Queued %d messages from file\n", messageCount)
         return nil
 }
 
-func produceInteractive(producer *kafka.Producer, topic, key string, headers []kafka.Header, format string) error {
-        fmt.Printf("Producing messages to topic '%s'. Type messages and press Enter. Ctrl+C to exit.\n", topic)
+func produceInteractive(tracker *deliveryTracker, input *produceInput, replay *replayer) error {
+        fmt.Printf("Producing messages to topic '%s'. Type messages and press Enter. Ctrl+C to exit.\n", input.Topic)
         
         scanner := bufio.NewScanner(os.Stdin)
+        scanner.Buffer(make([]byte, 64*1024), maxInputLineSize)
         messageCount := 0
 
         for scanner.Scan() {
@@ -262,31 +362,14 @@ func produceInteractive(producer *kafka.Producer, topic, key string, headers []k
                         continue
                 }
 
-                var messageValue []byte
-                switch format {
-                case "json":
-                        // Validate JSON
-                        var js json.RawMessage
-                        if err := json.Unmarshal([]byte(line), &js); err != nil {
-                                fmt.Printf("Invalid JSON, sending as plain text: %s\n", line)
-                        }
-                        messageValue = []byte(line)
-                default:
-                        messageValue = []byte(line)
-                }
-
-                messageKey := key
-                if messageKey == "" {
-                        messageKey = fmt.Sprintf("msg-%d", messageCount)
+                msg, err := input.parseLine(line, messageCount)
+                if err != nil {
+                        fmt.Printf("Skipping message: %v\n", err)
+                        continue
                 }
 
-                err := producer.Produce(&kafka.Message{
-                        TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-                        Key:            []byte(messageKey),
-                        Value:          messageValue,
-                        Headers:        headers,
-                }, nil)
-                
+                msg.Timestamp = replay.Wait(msg.Timestamp)
+                err = tracker.Produce(msg)
                 if err != nil {
                         fmt.Printf("Failed to produce message: %v\n", err)
                         continue
@@ -300,16 +383,47 @@ func produceInteractive(producer *kafka.Producer, topic, key string, headers []k
                 return fmt.Errorf("error reading input: %w", err)
         }
 
-        // Wait for all messages to be delivered
-        producer.Flush(15 * 1000)
         return nil
 }
 
+// maxInputLineSize caps a single input line, which is far above Kafka's default message size limit
+const maxInputLineSize = 16 * 1024 * 1024
+
 func init() {
         produceCmd.Flags().String("key", "", "Message key")
         produceCmd.Flags().StringSlice("header", []string{}, "Message headers in format 'key:value' or 'key=value' (can be specified multiple times)")
         produceCmd.Flags().String("file", "", "Produce messages from file")
         produceCmd.Flags().String("format", "text", "Message format (text, json, yaml)")
+        produceCmd.Flags().String("input-format", "text", "Input line format: text (each line is a value) or ndjson (each line is a {key, value, headers, partition, timestamp} record)")
+        produceCmd.Flags().StringSlice("base64", []string{}, "NDJSON fields holding base64-encoded binary data: key, value, headers")
+        produceCmd.Flags().String("key-field", "", "Use a field of the JSON value as the key (e.g. .order_id or .customer.id)")
+        produceCmd.Flags().String("key-separator", "", "Split text lines into key and value at the first occurrence of this separator")
         produceCmd.Flags().Int("count", 0, "Send random test messages")
         produceCmd.Flags().Int("size", 0, "Size in bytes for each generated test message (used with --count)")
+        produceCmd.Flags().String("template", "", "JSON template file with {{function}} expressions for generated messages (used with --count)")
+        produceCmd.Flags().String("avro-schema", "", "Avro schema file (.avsc) to generate messages from, emitted as JSON (used with --count)")
+        produceCmd.Flags().String("key-distribution", "sequential", "Keys for generated messages: sequential, uniform:N or zipf:N[,skew] for hot keys")
+        produceCmd.Flags().Int64("seed", 0, "Random seed for generated messages, to make runs reproducible (default: random)")
+        addReplayFlags(produceCmd, timestampsKeep)
+        produceCmd.Flags().String("dead-letter-file", "", "Write messages that fail to deliver to this file as NDJSON (replay with --input-format ndjson --base64 key,value,headers)")
+        produceCmd.Flags().Duration("flush-timeout", time.Minute, "How long to wait for outstanding deliveries before giving up")
+
+        // Producer tuning
+        produceCmd.Flags().Int32("partition", -1, "Send every message to this partition (overrides partitions in NDJSON records)")
+        produceCmd.Flags().String("partitioner", "", "Key partitioner: murmur2 (same as the Java client), consistent (librdkafka default) or random")
+        produceCmd.Flags().String("compression", "", "Compression codec: none, gzip, snappy, lz4, zstd")
+        produceCmd.Flags().String("acks", "", "Required acknowledgements: 0, 1 or all")
+        produceCmd.Flags().Bool("idempotent", false, "Enable the idempotent producer (requires acks=all)")
+        produceCmd.Flags().Int("linger-ms", 0, "Time to wait for more messages before sending a batch")
+        produceCmd.Flags().Int("batch-size", 0, "Maximum batch size in bytes")
+        produceCmd.Flags().Duration("message-timeout", 0, "Give up delivering a message after this long (e.g. 30s)")
+
+        // Transactions
+        produceCmd.Flags().String("transactional-id", "", "Produce inside a transaction with this transactional.id")
+        produceCmd.Flags().Int("transaction-size", 0, "Commit a transaction every N messages (0 = one transaction for the whole input)")
+        produceCmd.Flags().Bool("abort", false, "Abort the transactions instead of committing them (creates aborted batches for testing)")
+
+        produceCmd.RegisterFlagCompletionFunc("partitioner", cobra.FixedCompletions([]string{"murmur2", "consistent", "random"}, cobra.ShellCompDirectiveNoFileComp))
+        produceCmd.RegisterFlagCompletionFunc("compression", cobra.FixedCompletions(kafkaClient.CompressionTypes, cobra.ShellCompDirectiveNoFileComp))
+        produceCmd.RegisterFlagCompletionFunc("acks", cobra.FixedCompletions([]string{"0", "1", "all"}, cobra.ShellCompDirectiveNoFileComp))
 }
\ No newline at end of file
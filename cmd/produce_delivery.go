@@ -0,0 +1,406 @@
+package cmd
+
+import (
+        "context"
+        "encoding/json"
+        "fmt"
+        "os"
+        "sort"
+        "sync"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        kafkaClient "kafy/internal/kafka"
+)
+
+// deliveryTracker counts delivery reports for a producer, shows progress and
+// records failed messages, replacing per-message delivery output
+type deliveryTracker struct {
+        producer     *kafka.Producer
+        showProgress bool
+
+        mu         sync.Mutex
+        queued     int64
+        delivered  int64
+        failed     int64
+        failures   map[string]int64 // Failure count by error description
+        deadLetter *os.File
+        encoder    *json.Encoder
+        stop       chan struct{}
+        progressWG sync.WaitGroup
+
+        javaCheck *javaPartitionCheck
+        txn       *transactionState
+}
+
+// transactionState tracks the open transaction when producing transactionally
+type transactionState struct {
+        size            int  // Messages per transaction (0 = one transaction for everything)
+        abort           bool // Abort instead of commit, to create aborted batches for testing
+        open            bool
+        count           int
+        committed       int64
+        aborted         int64
+        abortedMessages int64
+}
+
+// javaPartitionCheck compares where keyed messages landed with where the Java
+// client's default (murmur2) partitioner would have put them
+type javaPartitionCheck struct {
+        partitions int
+        checked    int64
+        mismatches int64
+        exampleKey []byte
+        actual     int32
+        expected   int32
+}
+
+// javaCheckMarker tags messages placed by the partitioner so their delivery reports get checked
+type javaCheckMarker struct{}
+
+// deadLetterRecord is written for each failed message. It uses the
+// --input-format ndjson layout with base64 data, so failures can be replayed with
+// 'kafy produce <topic> --file <dead-letter-file> --input-format ndjson --base64 key,value,headers'.
+type deadLetterRecord struct {
+        Topic     string             `json:"topic"`
+        Partition *int32             `json:"partition,omitempty"`
+        Key       []byte             `json:"key"`
+        Value     []byte             `json:"value"`
+        Headers   []deadLetterHeader `json:"headers,omitempty"`
+        Timestamp int64              `json:"timestamp,omitempty"`
+        Error     string             `json:"error"`
+}
+
+type deadLetterHeader struct {
+        Key   string `json:"key"`
+        Value []byte `json:"value"`
+}
+
+func newDeliveryTracker(producer *kafka.Producer, deadLetterFile string, showProgress bool) (*deliveryTracker, error) {
+        t := &deliveryTracker{
+                producer:     producer,
+                showProgress: showProgress,
+                failures:     make(map[string]int64),
+                stop:         make(chan struct{}),
+        }
+
+        if deadLetterFile != "" {
+                file, err := os.Create(deadLetterFile)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to create dead letter file: %w", err)
+                }
+                t.deadLetter = file
+                t.encoder = json.NewEncoder(file)
+        }
+
+        go t.handleEvents()
+        if showProgress {
+                t.progressWG.Add(1)
+                go t.printProgress()
+        }
+        return t, nil
+}
+
+// EnableJavaPartitionCheck turns on the partition placement check for a topic with the given partition count
+func (t *deliveryTracker) EnableJavaPartitionCheck(partitions int) {
+        if partitions > 0 {
+                t.javaCheck = &javaPartitionCheck{partitions: partitions}
+        }
+}
+
+// Produce queues a message, waiting for room when the local queue is full.
+// Messages that cannot be queued count as failures.
+func (t *deliveryTracker) Produce(msg *kafka.Message) error {
+        if t.javaCheck != nil && msg.Key != nil && msg.TopicPartition.Partition == kafka.PartitionAny {
+                msg.Opaque = javaCheckMarker{}
+        }
+
+        var err error
+        for {
+                err = t.producer.Produce(msg, nil)
+                if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrQueueFull {
+                        t.producer.Flush(100)
+                        continue
+                }
+                break
+        }
+
+        t.mu.Lock()
+        t.queued++
+        if err != nil {
+                t.recordFailure(msg, err)
+        }
+        t.mu.Unlock()
+        if err != nil {
+                return err
+        }
+
+        if t.txn != nil {
+                t.txn.count++
+                if t.txn.size > 0 && t.txn.count >= t.txn.size {
+                        if err := t.endTransaction(); err != nil {
+                                return err
+                        }
+                        return t.beginTransaction()
+                }
+        }
+        return nil
+}
+
+// BeginTransactions initializes the transactional producer and opens the first
+// transaction. A new transaction is started every size messages (0 = never).
+func (t *deliveryTracker) BeginTransactions(size int, abort bool) error {
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+        if err := t.producer.InitTransactions(ctx); err != nil {
+                return fmt.Errorf("failed to initialize transactions: %w", err)
+        }
+        t.txn = &transactionState{size: size, abort: abort}
+        return t.beginTransaction()
+}
+
+func (t *deliveryTracker) beginTransaction() error {
+        if err := t.producer.BeginTransaction(); err != nil {
+                return fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        t.txn.open = true
+        t.txn.count = 0
+        return nil
+}
+
+// endTransaction commits the open transaction, or aborts it when --abort is set
+func (t *deliveryTracker) endTransaction() error {
+        txn := t.txn
+        if txn == nil || !txn.open {
+                return nil
+        }
+        txn.open = false
+
+        ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+        defer cancel()
+
+        if txn.abort || txn.count == 0 {
+                // Deliver first so the aborted records are really written to the log
+                // rather than purged from the local queue
+                for t.producer.Len() > 0 && ctx.Err() == nil {
+                        t.producer.Flush(500)
+                }
+                if err := t.producer.AbortTransaction(ctx); err != nil {
+                        return fmt.Errorf("failed to abort transaction: %w", err)
+                }
+                if txn.count > 0 {
+                        txn.aborted++
+                        txn.abortedMessages += int64(txn.count)
+                }
+                return nil
+        }
+
+        if err := t.producer.CommitTransaction(ctx); err != nil {
+                if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.TxnRequiresAbort() {
+                        if abortErr := t.producer.AbortTransaction(ctx); abortErr != nil {
+                                return fmt.Errorf("failed to commit transaction (%v) and to abort it: %w", err, abortErr)
+                        }
+                        txn.aborted++
+                        txn.abortedMessages += int64(txn.count)
+                }
+                return fmt.Errorf("failed to commit transaction: %w", err)
+        }
+        txn.committed++
+        return nil
+}
+
+func (t *deliveryTracker) handleEvents() {
+        for e := range t.producer.Events() {
+                switch ev := e.(type) {
+                case *kafka.Message:
+                        t.mu.Lock()
+                        if ev.TopicPartition.Error != nil {
+                                t.recordFailure(ev, ev.TopicPartition.Error)
+                        } else {
+                                t.delivered++
+                                if _, ok := ev.Opaque.(javaCheckMarker); ok {
+                                        t.checkJavaPartition(ev)
+                                }
+                        }
+                        t.mu.Unlock()
+                case kafka.Error:
+                        fmt.Fprintf(os.Stderr, "\nProducer error: %v\n", ev)
+                }
+        }
+}
+
+// checkJavaPartition must be called with t.mu held
+func (t *deliveryTracker) checkJavaPartition(msg *kafka.Message) {
+        check := t.javaCheck
+        check.checked++
+        expected := kafkaClient.JavaPartition(msg.Key, check.partitions)
+        if msg.TopicPartition.Partition == expected {
+                return
+        }
+        if check.mismatches == 0 {
+                check.exampleKey = msg.Key
+                check.actual = msg.TopicPartition.Partition
+                check.expected = expected
+        }
+        check.mismatches++
+}
+
+// recordFailure must be called with t.mu held
+func (t *deliveryTracker) recordFailure(msg *kafka.Message, err error) {
+        t.failed++
+        reason := err.Error()
+        if kafkaErr, ok := err.(kafka.Error); ok {
+                reason = kafkaErr.Code().String()
+        }
+        t.failures[reason]++
+
+        if t.encoder == nil {
+                return
+        }
+        if encodeErr := t.encoder.Encode(newDeadLetterRecord(msg, err)); encodeErr != nil {
+                fmt.Fprintf(os.Stderr, "\nFailed to write dead letter record: %v\n", encodeErr)
+        }
+}
+
+func newDeadLetterRecord(msg *kafka.Message, err error) deadLetterRecord {
+        record := deadLetterRecord{
+                Key:   msg.Key,
+                Value: msg.Value,
+                Error: err.Error(),
+        }
+        if msg.TopicPartition.Topic != nil {
+                record.Topic = *msg.TopicPartition.Topic
+        }
+        if msg.TopicPartition.Partition >= 0 {
+                partition := msg.TopicPartition.Partition
+                record.Partition = &partition
+        }
+        if !msg.Timestamp.IsZero() {
+                record.Timestamp = msg.Timestamp.UnixMilli()
+        }
+        for _, header := range msg.Headers {
+                record.Headers = append(record.Headers, deadLetterHeader{Key: header.Key, Value: header.Value})
+        }
+        return record
+}
+
+func (t *deliveryTracker) printProgress() {
+        defer t.progressWG.Done()
+        ticker := time.NewTicker(time.Second)
+        defer ticker.Stop()
+        for {
+                select {
+                case <-t.stop:
+                        return
+                case <-ticker.C:
+                        t.mu.Lock()
+                        line := t.statusLine()
+                        t.mu.Unlock()
+                        fmt.Fprintf(os.Stderr, "\r%s", line)
+                }
+        }
+}
+
+// statusLine must be called with t.mu held
+func (t *deliveryTracker) statusLine() string {
+        pending := t.queued - t.delivered - t.failed
+        return fmt.Sprintf("Queued: %d, Delivered: %d, Failed: %d, Pending: %d", t.queued, t.delivered, t.failed, pending)
+}
+
+// Finish waits up to timeout for outstanding delivery reports, prints a summary
+// and returns an error if any message was not delivered
+func (t *deliveryTracker) Finish(timeout time.Duration) error {
+        txnErr := t.endTransaction()
+
+        deadline := time.Now().Add(timeout)
+        for t.producer.Len() > 0 && time.Now().Before(deadline) {
+                t.producer.Flush(500)
+        }
+
+        // Flush returns once the queue is empty, but the last reports may still be
+        // on their way through the events channel
+        for {
+                t.mu.Lock()
+                done := t.delivered+t.failed >= t.queued
+                t.mu.Unlock()
+                if done || time.Now().After(deadline) {
+                        break
+                }
+                time.Sleep(50 * time.Millisecond)
+        }
+
+        t.mu.Lock()
+        queued, delivered, failed := t.queued, t.delivered, t.failed
+        undelivered := queued - delivered - failed
+        failures := make(map[string]int64, len(t.failures))
+        for reason, count := range t.failures {
+                failures[reason] = count
+        }
+        var javaCheck javaPartitionCheck
+        if t.javaCheck != nil {
+                javaCheck = *t.javaCheck
+        }
+        t.mu.Unlock()
+
+        if t.showProgress {
+                close(t.stop)
+                t.progressWG.Wait()
+                fmt.Fprintf(os.Stderr, "\r\033[K")
+        }
+
+        if t.deadLetter != nil {
+                t.mu.Lock()
+                t.encoder = nil
+                err := t.deadLetter.Close()
+                t.mu.Unlock()
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Failed to close dead letter file: %v\n", err)
+                }
+        }
+
+        fmt.Printf("Produced %d messages: %d delivered, %d failed", queued, delivered, failed)
+        if undelivered > 0 {
+                fmt.Printf(", %d still pending after %s", undelivered, timeout)
+        }
+        fmt.Printf("\n")
+
+        if failed > 0 {
+                reasons := make([]string, 0, len(failures))
+                for reason := range failures {
+                        reasons = append(reasons, reason)
+                }
+                sort.Slice(reasons, func(i, j int) bool { return failures[reasons[i]] > failures[reasons[j]] })
+                fmt.Printf("Failures by error:\n")
+                for _, reason := range reasons {
+                        fmt.Printf("  %6d  %s\n", failures[reason], reason)
+                }
+                if t.deadLetter != nil {
+                        fmt.Printf("Failed messages written to %s\n", t.deadLetter.Name())
+                }
+        }
+
+        if t.txn != nil {
+                fmt.Printf("Transactions: %d committed, %d aborted", t.txn.committed, t.txn.aborted)
+                if t.txn.abortedMessages > 0 {
+                        fmt.Printf(" (%d messages in aborted transactions)", t.txn.abortedMessages)
+                }
+                fmt.Printf("\n")
+        }
+
+        if javaCheck.mismatches > 0 {
+                fmt.Printf("Warning: %d of %d keyed messages landed on a different partition than the Java client would choose "+
+                        "(e.g. key %q went to partition %d, Java would use %d). Use --partitioner murmur2 for Java-compatible placement.\n",
+                        javaCheck.mismatches, javaCheck.checked, javaCheck.exampleKey, javaCheck.actual, javaCheck.expected)
+        }
+
+        if failed > 0 || undelivered > 0 {
+                return fmt.Errorf("%d of %d messages were not delivered", failed+undelivered, queued)
+        }
+        return txnErr
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+        info, err := f.Stat()
+        return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,320 @@
+package cmd
+
+import (
+        "bytes"
+        "encoding/base64"
+        "encoding/json"
+        "fmt"
+        "sort"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "kafy/internal/generator"
+)
+
+// produceInput turns input lines into messages according to the produce flags
+type produceInput struct {
+        Topic        string
+        Partition    int32           // Fixed partition for every message, or kafka.PartitionAny
+        Format       string          // Value format for plain lines: text, json, yaml
+        InputFormat  string          // text (one value per line) or ndjson (one record object per line)
+        Key          string          // Fixed key for every message
+        KeyField     string          // Path of a field in a JSON value to use as the key
+        KeySeparator string          // Split plain lines into key and value at the first separator
+        Base64       map[string]bool // NDJSON fields (key, value, headers) that hold base64 data
+        Headers      []kafka.Header  // Headers added to every message
+        LenientJSON  bool            // Send invalid JSON as text instead of rejecting it
+
+        Values *generator.Generator       // Value generator for --count (nil = built-in test messages)
+        Keys   *generator.KeyDistribution // Key distribution for --count
+}
+
+// ndjsonRecord is one structured input line. Fields are kept raw so an absent
+// field can be told apart from an explicit null (a null key or a tombstone).
+// This matches the shape of 'kafy consume --output json', so its output can be replayed.
+type ndjsonRecord struct {
+        Key       json.RawMessage `json:"key"`
+        Value     json.RawMessage `json:"value"`
+        Headers   json.RawMessage `json:"headers"`
+        Partition *int32          `json:"partition"`
+        Timestamp json.RawMessage `json:"timestamp"`
+}
+
+func newProduceInput(topic, format, inputFormat, key, keyField, keySeparator string, base64Fields []string, headers []kafka.Header) (*produceInput, error) {
+        switch inputFormat {
+        case "text", "ndjson":
+        default:
+                return nil, fmt.Errorf("unknown input format '%s' (supported: text, ndjson)", inputFormat)
+        }
+
+        input := &produceInput{
+                Topic:        topic,
+                Partition:    kafka.PartitionAny,
+                Format:       format,
+                InputFormat:  inputFormat,
+                Key:          key,
+                KeyField:     keyField,
+                KeySeparator: keySeparator,
+                Base64:       make(map[string]bool),
+                Headers:      headers,
+        }
+        for _, field := range base64Fields {
+                field = strings.ToLower(strings.TrimSpace(field))
+                switch field {
+                case "key", "value", "headers":
+                        input.Base64[field] = true
+                default:
+                        return nil, fmt.Errorf("unknown --base64 field '%s' (supported: key, value, headers)", field)
+                }
+        }
+        if len(input.Base64) > 0 && inputFormat != "ndjson" {
+                return nil, fmt.Errorf("--base64 requires --input-format ndjson")
+        }
+        if keySeparator != "" && inputFormat == "ndjson" {
+                return nil, fmt.Errorf("--key-separator only applies to --input-format text")
+        }
+        return input, nil
+}
+
+// parseLine builds the message for one input line. index is the zero-based
+// message number, used for the synthetic msg-N key.
+func (in *produceInput) parseLine(line string, index int) (*kafka.Message, error) {
+        if in.InputFormat == "ndjson" {
+                return in.parseRecord(line)
+        }
+
+        msg := in.newMessage()
+        value := line
+        if in.KeySeparator != "" {
+                key, rest, found := strings.Cut(line, in.KeySeparator)
+                if !found {
+                        return nil, fmt.Errorf("line has no key separator '%s'", in.KeySeparator)
+                }
+                msg.Key = []byte(key)
+                value = rest
+        }
+
+        if in.Format == "json" && !json.Valid([]byte(value)) {
+                if !in.LenientJSON {
+                        return nil, fmt.Errorf("invalid JSON")
+                }
+                fmt.Printf("Invalid JSON, sending as plain text: %s\n", value)
+        }
+        msg.Value = []byte(value)
+
+        if msg.Key == nil {
+                key, err := in.keyFor(msg.Value)
+                if err != nil {
+                        return nil, err
+                }
+                if key == nil {
+                        key = []byte(fmt.Sprintf("msg-%d", index))
+                }
+                msg.Key = key
+        }
+        return msg, nil
+}
+
+func (in *produceInput) parseRecord(line string) (*kafka.Message, error) {
+        var record ndjsonRecord
+        if err := json.Unmarshal([]byte(line), &record); err != nil {
+                return nil, fmt.Errorf("invalid NDJSON record: %w", err)
+        }
+
+        msg := in.newMessage()
+
+        value, _, err := rawField(record.Value, in.Base64["value"])
+        if err != nil {
+                return nil, fmt.Errorf("invalid value: %w", err)
+        }
+        msg.Value = value
+
+        key, present, err := rawField(record.Key, in.Base64["key"])
+        if err != nil {
+                return nil, fmt.Errorf("invalid key: %w", err)
+        }
+        if present {
+                msg.Key = key
+        } else if msg.Key, err = in.keyFor(msg.Value); err != nil {
+                return nil, err
+        }
+
+        headers, err := parseRecordHeaders(record.Headers, in.Base64["headers"])
+        if err != nil {
+                return nil, fmt.Errorf("invalid headers: %w", err)
+        }
+        msg.Headers = append(msg.Headers, headers...)
+
+        if record.Partition != nil && *record.Partition >= 0 && in.Partition == kafka.PartitionAny {
+                msg.TopicPartition.Partition = *record.Partition
+        }
+
+        if len(record.Timestamp) > 0 && string(record.Timestamp) != "null" {
+                timestamp, err := parseRecordTimestamp(record.Timestamp)
+                if err != nil {
+                        return nil, fmt.Errorf("invalid timestamp: %w", err)
+                }
+                msg.Timestamp = timestamp
+        }
+        return msg, nil
+}
+
+func (in *produceInput) newMessage() *kafka.Message {
+        msg := &kafka.Message{
+                TopicPartition: kafka.TopicPartition{Topic: &in.Topic, Partition: in.Partition},
+        }
+        if len(in.Headers) > 0 {
+                msg.Headers = append([]kafka.Header{}, in.Headers...)
+        }
+        return msg
+}
+
+// keyFor returns the key from --key or --key-field, or nil when neither applies
+func (in *produceInput) keyFor(value []byte) ([]byte, error) {
+        if in.Key != "" {
+                return []byte(in.Key), nil
+        }
+        if in.KeyField == "" {
+                return nil, nil
+        }
+
+        // UseNumber keeps large numeric IDs exact
+        decoder := json.NewDecoder(bytes.NewReader(value))
+        decoder.UseNumber()
+        var document interface{}
+        if err := decoder.Decode(&document); err != nil {
+                return nil, fmt.Errorf("--key-field needs a JSON value: %w", err)
+        }
+        field, ok := lookupJSONField(document, in.KeyField)
+        if !ok || field == nil {
+                return nil, fmt.Errorf("key field '%s' not found in value", in.KeyField)
+        }
+        switch v := field.(type) {
+        case string:
+                return []byte(v), nil
+        case json.Number:
+                return []byte(v.String()), nil
+        }
+        return json.Marshal(field)
+}
+
+// lookupJSONField follows a dotted path such as ".order.id" or "items.0.sku"
+func lookupJSONField(document interface{}, path string) (interface{}, bool) {
+        path = strings.TrimPrefix(strings.TrimSpace(path), ".")
+        if path == "" {
+                return document, true
+        }
+
+        current := document
+        for _, part := range strings.Split(path, ".") {
+                switch node := current.(type) {
+                case map[string]interface{}:
+                        next, ok := node[part]
+                        if !ok {
+                                return nil, false
+                        }
+                        current = next
+                case []interface{}:
+                        index, err := strconv.Atoi(part)
+                        if err != nil || index < 0 || index >= len(node) {
+                                return nil, false
+                        }
+                        current = node[index]
+                default:
+                        return nil, false
+                }
+        }
+        return current, true
+}
+
+// rawField converts a raw NDJSON field to bytes. Strings are used as-is (or
+// base64 decoded), other JSON values are sent as compact JSON, and null stays nil.
+func rawField(raw json.RawMessage, isBase64 bool) ([]byte, bool, error) {
+        if len(raw) == 0 {
+                return nil, false, nil
+        }
+        if string(raw) == "null" {
+                return nil, true, nil
+        }
+
+        var s string
+        if err := json.Unmarshal(raw, &s); err != nil {
+                if isBase64 {
+                        return nil, true, fmt.Errorf("expected a base64 string")
+                }
+                var compact bytes.Buffer
+                if err := json.Compact(&compact, raw); err != nil {
+                        return nil, true, err
+                }
+                return compact.Bytes(), true, nil
+        }
+
+        if isBase64 {
+                data, err := base64.StdEncoding.DecodeString(s)
+                if err != nil {
+                        return nil, true, fmt.Errorf("invalid base64: %w", err)
+                }
+                return data, true, nil
+        }
+        return []byte(s), true, nil
+}
+
+// parseRecordHeaders accepts either an object of name/value pairs or a list of
+// {"key": ..., "value": ...} objects (which preserves order and duplicates)
+func parseRecordHeaders(raw json.RawMessage, isBase64 bool) ([]kafka.Header, error) {
+        if len(raw) == 0 || string(raw) == "null" {
+                return nil, nil
+        }
+
+        var headers []kafka.Header
+        var list []struct {
+                Key   string          `json:"key"`
+                Value json.RawMessage `json:"value"`
+        }
+        if err := json.Unmarshal(raw, &list); err == nil {
+                for _, item := range list {
+                        value, _, err := rawField(item.Value, isBase64)
+                        if err != nil {
+                                return nil, fmt.Errorf("header '%s': %w", item.Key, err)
+                        }
+                        headers = append(headers, kafka.Header{Key: item.Key, Value: value})
+                }
+                return headers, nil
+        }
+
+        var object map[string]json.RawMessage
+        if err := json.Unmarshal(raw, &object); err != nil {
+                return nil, fmt.Errorf("expected an object or a list of {key, value} objects")
+        }
+        keys := make([]string, 0, len(object))
+        for key := range object {
+                keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        for _, key := range keys {
+                value, _, err := rawField(object[key], isBase64)
+                if err != nil {
+                        return nil, fmt.Errorf("header '%s': %w", key, err)
+                }
+                headers = append(headers, kafka.Header{Key: key, Value: value})
+        }
+        return headers, nil
+}
+
+// parseRecordTimestamp accepts epoch milliseconds or an RFC 3339 string
+func parseRecordTimestamp(raw json.RawMessage) (time.Time, error) {
+        var millis int64
+        if err := json.Unmarshal(raw, &millis); err == nil {
+                return time.UnixMilli(millis), nil
+        }
+        var s string
+        if err := json.Unmarshal(raw, &s); err != nil {
+                return time.Time{}, fmt.Errorf("expected epoch milliseconds or an RFC 3339 string")
+        }
+        if millis, err := strconv.ParseInt(s, 10, 64); err == nil {
+                return time.UnixMilli(millis), nil
+        }
+        return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,591 @@
+package cmd
+
+import (
+        "fmt"
+        "os"
+        "regexp"
+        "sort"
+        "strings"
+
+        "github.com/spf13/cobra"
+        kafkaClient "kafy/internal/kafka"
+        "kafy/internal/reassign"
+)
+
+var reassignCmd = &cobra.Command{
+        Use:   "reassign",
+        Short: "Generate, execute and verify partition reassignments",
+        Long: `Move partition replicas between brokers. Reassignment files use the JSON format of
+kafka-reassign-partitions.sh, so plans can be exchanged with the Kafka tools and with
+'kafy brokers balance --proposal-file'.
+
+  generate  propose a rack-aware assignment of topics over a set of brokers
+  execute   start a reassignment, with replication throttles on the brokers and topics involved
+  verify    report progress and remove the throttles once the reassignment is complete
+  cancel    stop a reassignment in progress
+
+Requires Kafka 2.4 or later.`,
+}
+
+var reassignGenerateCmd = &cobra.Command{
+        Use:   "generate",
+        Short: "Propose an assignment of topics over a set of brokers",
+        Long: `Propose moving the partitions of the topics matching --topics onto the brokers in --brokers.
+Replicas on other brokers are moved off, then replica counts and preferred leaders are evened out
+with as few replica moves as possible, smallest partitions first. Replicas of a partition are
+kept on distinct racks unless --disable-rack-aware is set.
+
+Only partitions whose replicas change are included. Save the plan with --file (or --output json)
+and start it with 'kafy reassign execute'.
+
+Examples:
+  kafy reassign generate --topics 'orders.*' --brokers 1,2,3,4 --file plan.json
+  kafy reassign generate --topics payments --brokers 4,5,6 --output json > plan.json`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicsPattern, _ := cmd.Flags().GetString("topics")
+                brokerIDs, _ := cmd.Flags().GetInt32Slice("brokers")
+                disableRackAware, _ := cmd.Flags().GetBool("disable-rack-aware")
+                file, _ := cmd.Flags().GetString("file")
+
+                topicFilter, err := anchoredRegexp(topicsPattern)
+                if err != nil {
+                        return fmt.Errorf("invalid --topics pattern: %w", err)
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                brokers, err := client.ListBrokers()
+                if err != nil {
+                        return fmt.Errorf("failed to list brokers: %w", err)
+                }
+                if err := checkBrokerIDs(brokers, brokerIDs); err != nil {
+                        return err
+                }
+
+                current, err := currentAssignment(client, topicFilter)
+                if err != nil {
+                        return err
+                }
+                if len(current) == 0 {
+                        return fmt.Errorf("no topics match '%s'", topicsPattern)
+                }
+
+                opts := reassign.Options{Brokers: brokerIDs, Sizes: make(map[reassign.Key]int64)}
+                if !disableRackAware {
+                        if opts.Racks, err = client.BrokerRacks(); err != nil {
+                                fmt.Fprintf(os.Stderr, "Warning: could not read broker racks, placing replicas without rack awareness: %v\n", err)
+                        }
+                }
+                replicaSizes, failed := client.DescribeLogDirs(brokers)
+                for id, err := range failed {
+                        fmt.Fprintf(os.Stderr, "Warning: could not read the log directories of broker %d, data movement is estimated without it: %v\n", id, err)
+                }
+                for _, r := range replicaSizes {
+                        key := reassign.Key{Topic: r.Topic, Partition: r.Partition}
+                        if !r.Future && r.Size > opts.Sizes[key] {
+                                opts.Sizes[key] = r.Size
+                        }
+                }
+
+                proposed, err := reassign.Balance(current, opts)
+                if err != nil {
+                        return err
+                }
+                plan := reassign.NewPlan(reassign.Changed(current, proposed))
+                moves, bytes := reassign.Movement(current, proposed, opts.Sizes)
+
+                if file != "" {
+                        if err := plan.WriteFile(file); err != nil {
+                                return err
+                        }
+                }
+
+                formatter := getFormatter()
+                if formatter.Format != "table" {
+                        return formatter.Output(plan)
+                }
+                if len(plan.Partitions) == 0 {
+                        fmt.Println("The topics are already balanced over these brokers; no reassignment needed")
+                        return nil
+                }
+
+                before := assignmentByKey(current)
+                var rows [][]string
+                for _, p := range plan.Partitions {
+                        rows = append(rows, []string{p.Topic, fmt.Sprintf("%d", p.Partition), formatReplicas(before[p.Key()]), formatReplicas(p.Replicas)})
+                }
+                if err := formatter.OutputTable([]string{"Topic", "Partition", "Current Replicas", "Proposed Replicas"}, rows); err != nil {
+                        return err
+                }
+                fmt.Printf("\n%d partitions, %d replica moves", len(plan.Partitions), moves)
+                if bytes > 0 {
+                        fmt.Printf(" (%s to copy)", formatBytes(bytes))
+                }
+                if file != "" {
+                        fmt.Printf(", written to %s\n", file)
+                } else {
+                        fmt.Println(", use --file to save the plan")
+                }
+                return nil
+        },
+}
+
+var reassignExecuteCmd = &cobra.Command{
+        Use:   "execute <file>",
+        Short: "Start the reassignment in a plan file",
+        Long: `Start moving the partitions in a reassignment file with AlterPartitionReassignments.
+
+Replication is throttled to --throttle bytes per second on every broker that sends or receives a
+copy, and only for the replicas being moved (the leader and follower throttled replicas configs
+of their topics). 'kafy reassign verify' removes the throttles once the reassignment completes.
+
+The current assignment of the partitions is printed, or written to --rollback-file, so the
+reassignment can be undone by executing it.
+
+Examples:
+  kafy reassign execute plan.json
+  kafy reassign execute plan.json --throttle 100MB --rollback-file rollback.json
+  kafy reassign execute plan.json --no-throttle`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+                throttleSpec, _ := cmd.Flags().GetString("throttle")
+                noThrottle, _ := cmd.Flags().GetBool("no-throttle")
+                rollbackFile, _ := cmd.Flags().GetString("rollback-file")
+
+                var throttle int64
+                if !noThrottle {
+                        var err error
+                        if throttle, err = parseByteSize(throttleSpec); err != nil {
+                                return fmt.Errorf("invalid --throttle: %w", err)
+                        }
+                }
+
+                plan, err := reassign.LoadPlan(args[0])
+                if err != nil {
+                        return err
+                }
+                for _, p := range plan.Partitions {
+                        for _, dir := range p.LogDirs {
+                                if dir != "any" {
+                                        return fmt.Errorf("%s/%d: moving replicas between log directories is not supported, use \"any\" in log_dirs", p.Topic, p.Partition)
+                                }
+                        }
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                brokers, err := client.ListBrokers()
+                if err != nil {
+                        return fmt.Errorf("failed to list brokers: %w", err)
+                }
+                current, err := planAssignment(client, plan)
+                if err != nil {
+                        return err
+                }
+                for _, p := range plan.Partitions {
+                        if err := checkBrokerIDs(brokers, p.Replicas); err != nil {
+                                return fmt.Errorf("%s/%d: %w", p.Topic, p.Partition, err)
+                        }
+                }
+
+                ongoing, err := client.ListPartitionReassignments()
+                if err != nil {
+                        return fmt.Errorf("failed to list reassignments in progress: %w", err)
+                }
+                for _, r := range ongoing {
+                        if _, ok := current[reassign.Key{Topic: r.Topic, Partition: r.Partition}]; ok {
+                                return fmt.Errorf("%s/%d is already being reassigned; wait for it with 'kafy reassign verify' or stop it with 'kafy reassign cancel'", r.Topic, r.Partition)
+                        }
+                }
+
+                var targets []kafkaClient.ReassignmentTarget
+                var rollback []reassign.Partition
+                for _, p := range plan.Partitions {
+                        before := current[p.Key()]
+                        if reassign.SameReplicas(before, p.Replicas) {
+                                continue
+                        }
+                        targets = append(targets, kafkaClient.ReassignmentTarget{Topic: p.Topic, Partition: p.Partition, Replicas: p.Replicas})
+                        rollback = append(rollback, reassign.Partition{Topic: p.Topic, Partition: p.Partition, Replicas: before})
+                }
+                if len(targets) == 0 {
+                        fmt.Println("Every partition in the plan already has its target replicas; nothing to do")
+                        return nil
+                }
+
+                rollbackPlan := reassign.NewPlan(rollback)
+                if rollbackFile != "" {
+                        if err := rollbackPlan.WriteFile(rollbackFile); err != nil {
+                                return err
+                        }
+                        fmt.Printf("Current assignment written to %s; execute it to roll back\n", rollbackFile)
+                } else {
+                        fmt.Println("Current assignment; save it and execute it to roll back:")
+                        if err := rollbackPlan.Write(os.Stdout); err != nil {
+                                return err
+                        }
+                        fmt.Println()
+                }
+
+                throttledBrokers := reassign.Brokers(current, plan)
+                throttled := throttle > 0 && len(throttledBrokers) > 0
+                leaders, followers := reassign.Throttles(current, plan)
+                if throttled {
+                        if err := client.SetReplicationThrottles(throttle, throttledBrokers, leaders, followers); err != nil {
+                                return fmt.Errorf("failed to set replication throttles: %w", err)
+                        }
+                        fmt.Printf("Throttled replication to %s/s on brokers %s\n", formatBytes(throttle), formatReplicas(throttledBrokers))
+                }
+
+                failed, err := client.AlterPartitionReassignments(targets)
+                var failures []string
+                for tp, err := range failed {
+                        failures = append(failures, fmt.Sprintf("%s/%d: %v", tp.Topic, tp.Partition, err))
+                }
+                sort.Strings(failures)
+                for _, failure := range failures {
+                        fmt.Fprintf(os.Stderr, "Failed to reassign %s\n", failure)
+                }
+                if err == nil && len(failed) == len(targets) {
+                        err = fmt.Errorf("none of the %d partitions could be reassigned", len(targets))
+                }
+                if err != nil {
+                        // Nothing is moving, so the throttles would only slow down normal replication
+                        if throttled {
+                                var rates []int32
+                                if len(ongoing) == 0 {
+                                        rates = throttledBrokers
+                                }
+                                if removeErr := client.RemoveReplicationThrottles(rates, leaders, followers); removeErr != nil {
+                                        fmt.Fprintf(os.Stderr, "Failed to remove the replication throttles: %v\n", removeErr)
+                                } else {
+                                        fmt.Fprintln(os.Stderr, "Replication throttles removed")
+                                }
+                        }
+                        return err
+                }
+
+                started := len(targets) - len(failed)
+                fmt.Printf("Started reassignment of %d partitions; check progress with 'kafy reassign verify %s'\n", started, args[0])
+                if len(failed) > 0 {
+                        return fmt.Errorf("%d of %d partitions could not be reassigned", len(failed), len(targets))
+                }
+                return nil
+        },
+}
+
+var reassignVerifyCmd = &cobra.Command{
+        Use:     "verify <file>",
+        Aliases: []string{"status"},
+        Short:   "Report the progress of a reassignment and remove its throttles when complete",
+        Long: `Report, for every partition in a reassignment file, whether its reassignment is complete, in
+progress (with the replicas being added and removed) or has ended with other replicas than the
+plan asks for.
+
+Once no partition of the plan is being reassigned, its partitions are removed from the throttled
+replicas configs of their topics, and the broker throttle rates too unless other reassignments are
+in progress. Use --preserve-throttles to keep them.
+
+Examples:
+  kafy reassign verify plan.json
+  kafy reassign status plan.json --output json`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+                preserveThrottles, _ := cmd.Flags().GetBool("preserve-throttles")
+
+                plan, err := reassign.LoadPlan(args[0])
+                if err != nil {
+                        return err
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                current, err := planAssignment(client, plan)
+                if err != nil {
+                        return err
+                }
+                ongoing, err := client.ListPartitionReassignments()
+                if err != nil {
+                        return fmt.Errorf("failed to list reassignments in progress: %w", err)
+                }
+                inProgress := make(map[reassign.Key]kafkaClient.PartitionReassignment)
+                for _, r := range ongoing {
+                        inProgress[reassign.Key{Topic: r.Topic, Partition: r.Partition}] = r
+                }
+
+                var statuses []reassignmentStatus
+                pending := 0
+                for _, p := range plan.Partitions {
+                        status := reassignmentStatus{Topic: p.Topic, Partition: p.Partition, Replicas: current[p.Key()], Target: p.Replicas}
+                        if r, ok := inProgress[p.Key()]; ok {
+                                status.Status = "in progress"
+                                status.Adding, status.Removing = r.Adding, r.Removing
+                                pending++
+                                delete(inProgress, p.Key())
+                        } else if reassign.SameReplicas(status.Replicas, p.Replicas) {
+                                status.Status = "complete"
+                        } else {
+                                status.Status = "mismatch"
+                        }
+                        statuses = append(statuses, status)
+                }
+
+                if err := outputReassignmentStatus(statuses); err != nil {
+                        return err
+                }
+
+                if pending > 0 {
+                        fmt.Fprintf(os.Stderr, "%d of %d partitions are still being reassigned\n", pending, len(plan.Partitions))
+                        return nil
+                }
+                if !preserveThrottles {
+                        // Broker rates also throttle reassignments started elsewhere, so they stay while any is running
+                        if err := removeReassignmentThrottles(client, plan, len(inProgress) == 0); err != nil {
+                                return err
+                        }
+                }
+                return nil
+        },
+}
+
+var reassignCancelCmd = &cobra.Command{
+        Use:   "cancel <file>",
+        Short: "Cancel the reassignment of the partitions in a plan file",
+        Long: `Stop the reassignments in progress of the partitions in a reassignment file. Partitions go back
+to the replicas they had before, and the replicas added so far are removed. The replication
+throttles are then removed unless --preserve-throttles is set.
+
+Examples:
+  kafy reassign cancel plan.json`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+                preserveThrottles, _ := cmd.Flags().GetBool("preserve-throttles")
+
+                plan, err := reassign.LoadPlan(args[0])
+                if err != nil {
+                        return err
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                ongoing, err := client.ListPartitionReassignments()
+                if err != nil {
+                        return fmt.Errorf("failed to list reassignments in progress: %w", err)
+                }
+                inPlan := make(map[reassign.Key]bool)
+                for _, p := range plan.Partitions {
+                        inPlan[p.Key()] = true
+                }
+                var targets []kafkaClient.ReassignmentTarget
+                others := 0
+                for _, r := range ongoing {
+                        if inPlan[reassign.Key{Topic: r.Topic, Partition: r.Partition}] {
+                                targets = append(targets, kafkaClient.ReassignmentTarget{Topic: r.Topic, Partition: r.Partition})
+                        } else {
+                                others++
+                        }
+                }
+
+                if len(targets) == 0 {
+                        fmt.Println("No partition of the plan is being reassigned")
+                } else {
+                        failed, err := client.AlterPartitionReassignments(targets)
+                        if err != nil {
+                                return err
+                        }
+                        for tp, err := range failed {
+                                fmt.Fprintf(os.Stderr, "Failed to cancel the reassignment of %s/%d: %v\n", tp.Topic, tp.Partition, err)
+                        }
+                        fmt.Printf("Cancelled the reassignment of %d partitions\n", len(targets)-len(failed))
+                        if len(failed) > 0 {
+                                return fmt.Errorf("%d of %d reassignments could not be cancelled", len(failed), len(targets))
+                        }
+                }
+
+                if !preserveThrottles {
+                        return removeReassignmentThrottles(client, plan, others == 0)
+                }
+                return nil
+        },
+}
+
+type reassignmentStatus struct {
+        Topic     string  `json:"topic"`
+        Partition int32   `json:"partition"`
+        Status    string  `json:"status"`
+        Replicas  []int32 `json:"replicas"`
+        Target    []int32 `json:"target_replicas"`
+        Adding    []int32 `json:"adding_replicas,omitempty"`
+        Removing  []int32 `json:"removing_replicas,omitempty"`
+}
+
+func outputReassignmentStatus(statuses []reassignmentStatus) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                return formatter.Output(statuses)
+        }
+
+        var rows [][]string
+        for _, s := range statuses {
+                rows = append(rows, []string{
+                        s.Topic,
+                        fmt.Sprintf("%d", s.Partition),
+                        s.Status,
+                        formatReplicas(s.Replicas),
+                        formatReplicas(s.Target),
+                        formatReplicas(s.Adding),
+                        formatReplicas(s.Removing),
+                })
+        }
+        return formatter.OutputTable([]string{"Topic", "Partition", "Status", "Replicas", "Target", "Adding", "Removing"}, rows)
+}
+
+// removeReassignmentThrottles removes the plan's partitions from the throttled
+// replicas configs of their topics and, when withBrokers is set, the throttle
+// rates of all brokers
+func removeReassignmentThrottles(client *kafkaClient.Client, plan *reassign.Plan, withBrokers bool) error {
+        brokers, err := client.ListBrokers()
+        if err != nil {
+                return fmt.Errorf("failed to list brokers: %w", err)
+        }
+        var brokerIDs []int32
+        for _, broker := range brokers {
+                brokerIDs = append(brokerIDs, broker.ID)
+        }
+        replicas := reassign.PlanReplicas(plan, brokerIDs)
+        if !withBrokers {
+                brokerIDs = nil
+        }
+
+        if err := client.RemoveReplicationThrottles(brokerIDs, replicas, replicas); err != nil {
+                return fmt.Errorf("failed to remove replication throttles: %w", err)
+        }
+        if withBrokers {
+                fmt.Fprintln(os.Stderr, "Replication throttles removed")
+        } else {
+                fmt.Fprintln(os.Stderr, "Topic replication throttles removed; broker throttle rates kept while other reassignments are in progress")
+        }
+        return nil
+}
+
+// currentAssignment returns the replicas of every partition of the matching topics
+func currentAssignment(client *kafkaClient.Client, topicFilter *regexp.Regexp) ([]reassign.Partition, error) {
+        topics, err := client.ListTopicPartitions()
+        if err != nil {
+                return nil, fmt.Errorf("failed to list topics: %w", err)
+        }
+        var partitions []reassign.Partition
+        for _, topic := range topics {
+                if topicFilter != nil && !topicFilter.MatchString(topic.Name) {
+                        continue
+                }
+                for _, p := range topic.PartitionDetails {
+                        partitions = append(partitions, reassign.Partition{Topic: topic.Name, Partition: p.ID, Replicas: p.Replicas})
+                }
+        }
+        return partitions, nil
+}
+
+// planAssignment returns the current replicas of the partitions in a plan,
+// failing when one does not exist
+func planAssignment(client *kafkaClient.Client, plan *reassign.Plan) (map[reassign.Key][]int32, error) {
+        partitions, err := currentAssignment(client, nil)
+        if err != nil {
+                return nil, err
+        }
+        all := assignmentByKey(partitions)
+        current := make(map[reassign.Key][]int32)
+        for _, p := range plan.Partitions {
+                replicas, ok := all[p.Key()]
+                if !ok {
+                        return nil, fmt.Errorf("partition %s/%d does not exist", p.Topic, p.Partition)
+                }
+                current[p.Key()] = replicas
+        }
+        return current, nil
+}
+
+func assignmentByKey(partitions []reassign.Partition) map[reassign.Key][]int32 {
+        byKey := make(map[reassign.Key][]int32)
+        for _, p := range partitions {
+                byKey[p.Key()] = p.Replicas
+        }
+        return byKey
+}
+
+func checkBrokerIDs(brokers []kafkaClient.BrokerInfo, ids []int32) error {
+        known := make(map[int32]bool)
+        for _, broker := range brokers {
+                known[broker.ID] = true
+        }
+        for _, id := range ids {
+                if !known[id] {
+                        return fmt.Errorf("broker %d is not in the cluster", id)
+                }
+        }
+        return nil
+}
+
+func formatReplicas(replicas []int32) string {
+        if len(replicas) == 0 {
+                return "-"
+        }
+        ids := make([]string, len(replicas))
+        for i, r := range replicas {
+                ids[i] = fmt.Sprintf("%d", r)
+        }
+        return strings.Join(ids, ",")
+}
+
+func init() {
+        reassignCmd.AddCommand(reassignGenerateCmd)
+        reassignCmd.AddCommand(reassignExecuteCmd)
+        reassignCmd.AddCommand(reassignVerifyCmd)
+        reassignCmd.AddCommand(reassignCancelCmd)
+
+        reassignGenerateCmd.Flags().String("topics", "", "Regular expression of the topics to reassign")
+        reassignGenerateCmd.Flags().Int32Slice("brokers", nil, "Brokers to place the replicas on (e.g. 1,2,3)")
+        reassignGenerateCmd.Flags().Bool("disable-rack-aware", false, "Ignore broker racks when placing replicas")
+        reassignGenerateCmd.Flags().String("file", "", "Write the plan to this file")
+        reassignGenerateCmd.MarkFlagRequired("topics")
+        reassignGenerateCmd.MarkFlagRequired("brokers")
+
+        reassignExecuteCmd.Flags().String("throttle", "50MB", "Replication throttle per broker, in bytes per second (e.g. 10MB, 1GB)")
+        reassignExecuteCmd.Flags().Bool("no-throttle", false, "Do not throttle replication")
+        reassignExecuteCmd.Flags().String("rollback-file", "", "Write the current assignment of the partitions to this file")
+
+        reassignVerifyCmd.Flags().Bool("preserve-throttles", false, "Keep the replication throttles when the reassignment is complete")
+        reassignCancelCmd.Flags().Bool("preserve-throttles", false, "Keep the replication throttles")
+}
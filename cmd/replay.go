@@ -0,0 +1,162 @@
+package cmd
+
+import (
+        "fmt"
+        "os"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/spf13/cobra"
+        "kafy/internal/perf"
+)
+
+// Timestamp modes for replayed records
+const (
+        timestampsKeep  = "keep"  // Send the original record timestamp
+        timestampsNow   = "now"   // Let the producer stamp the send time
+        timestampsShift = "shift" // Keep the gaps between records but move the first one to now
+)
+
+// replayer paces replayed messages, either at a fixed rate or following the
+// gaps between the original record timestamps, and rewrites their timestamps
+type replayer struct {
+        pacer      *perf.Pacer
+        rate       string
+        timing     bool
+        speed      float64
+        timestamps string
+        changed    bool // Any replay flag was set, so the settings are worth reporting
+
+        // interrupt cuts a long --replay-timing wait short; the signal is put back for the caller's loop
+        interrupt chan os.Signal
+
+        start time.Time // Wall clock time of the first timed record
+        first time.Time // Original timestamp of the first timed record
+}
+
+// addReplayFlags registers the pacing flags shared by produce and cp
+func addReplayFlags(cmd *cobra.Command, defaultTimestamps string) {
+        cmd.Flags().String("rate", "", "Limit the send rate, e.g. 500/s, 30000/m or 500 (per second)")
+        cmd.Flags().Bool("replay-timing", false, "Keep the original gaps between records, based on their timestamps")
+        cmd.Flags().String("speed", "1x", "Speed up (or slow down) --replay-timing, e.g. 10x or 0.5x")
+        cmd.Flags().String("timestamps", defaultTimestamps, "Record timestamps: keep (original), now (send time) or shift (original gaps, starting now)")
+        cmd.RegisterFlagCompletionFunc("timestamps", cobra.FixedCompletions([]string{timestampsKeep, timestampsNow, timestampsShift}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func newReplayer(cmd *cobra.Command) (*replayer, error) {
+        rateSpec, _ := cmd.Flags().GetString("rate")
+        timing, _ := cmd.Flags().GetBool("replay-timing")
+        speedSpec, _ := cmd.Flags().GetString("speed")
+        timestamps, _ := cmd.Flags().GetString("timestamps")
+
+        rate, err := parseRate(rateSpec)
+        if err != nil {
+                return nil, err
+        }
+        speed, err := parseSpeed(speedSpec)
+        if err != nil {
+                return nil, err
+        }
+        if cmd.Flags().Changed("speed") && !timing {
+                return nil, fmt.Errorf("--speed requires --replay-timing")
+        }
+        switch timestamps {
+        case timestampsKeep, timestampsNow, timestampsShift:
+        default:
+                return nil, fmt.Errorf("unknown --timestamps mode '%s' (supported: keep, now, shift)", timestamps)
+        }
+
+        return &replayer{
+                pacer:      perf.NewPacer(rate),
+                rate:       rateSpec,
+                timing:     timing,
+                speed:      speed,
+                timestamps: timestamps,
+                changed:    rate > 0 || timing || cmd.Flags().Changed("timestamps"),
+        }, nil
+}
+
+// parseRate accepts "500", "500/s", "30000/m" or "100000/h"
+func parseRate(spec string) (float64, error) {
+        spec = strings.ToLower(strings.TrimSpace(spec))
+        if spec == "" {
+                return 0, nil
+        }
+        number, unit, _ := strings.Cut(spec, "/")
+        rate, err := strconv.ParseFloat(number, 64)
+        if err != nil || rate <= 0 {
+                return 0, fmt.Errorf("invalid rate '%s' (use e.g. 500/s or 30000/m)", spec)
+        }
+        switch unit {
+        case "", "s", "sec":
+        case "m", "min":
+                rate /= 60
+        case "h", "hour":
+                rate /= 3600
+        default:
+                return 0, fmt.Errorf("invalid rate unit '%s' (use s, m or h)", unit)
+        }
+        return rate, nil
+}
+
+// parseSpeed accepts "10x", "10" or "0.5x"
+func parseSpeed(spec string) (float64, error) {
+        speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(spec)), "x"), 64)
+        if err != nil || speed <= 0 {
+                return 0, fmt.Errorf("invalid speed '%s' (use e.g. 10x or 0.5x)", spec)
+        }
+        return speed, nil
+}
+
+// Wait blocks until a record with the given original timestamp is due and
+// returns the timestamp to send it with (zero lets the producer set it)
+func (r *replayer) Wait(original time.Time) time.Time {
+        if r.timing && !original.IsZero() {
+                if r.start.IsZero() {
+                        r.start, r.first = time.Now(), original
+                }
+                // Records older than the first one (e.g. from another partition) are sent immediately
+                due := r.start.Add(time.Duration(float64(original.Sub(r.first)) / r.speed))
+                if delay := time.Until(due); delay > 0 {
+                        timer := time.NewTimer(delay)
+                        select {
+                        case <-timer.C:
+                        case sig := <-r.interrupt:
+                                timer.Stop()
+                                r.interrupt <- sig
+                        }
+                }
+        }
+        r.pacer.Wait()
+
+        switch r.timestamps {
+        case timestampsNow:
+                return time.Time{}
+        case timestampsShift:
+                if original.IsZero() {
+                        return time.Time{}
+                }
+                if r.start.IsZero() {
+                        r.start, r.first = time.Now(), original
+                }
+                return r.start.Add(time.Duration(float64(original.Sub(r.first)) / r.speed))
+        }
+        return original
+}
+
+// Describe summarises the replay settings, or returns "" when none were set
+func (r *replayer) Describe() string {
+        if !r.changed {
+                return ""
+        }
+        var parts []string
+        if r.timing {
+                parts = append(parts, fmt.Sprintf("original timing at %gx", r.speed))
+        }
+        if r.rate != "" {
+                parts = append(parts, "at most "+r.rate)
+        }
+        parts = append(parts, "timestamps: "+r.timestamps)
+        return strings.Join(parts, ", ")
+}
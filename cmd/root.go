@@ -15,6 +15,8 @@ const version = "0.0.8"
 var (
 	outputFormat    string
 	clusterOverride string
+	noHeaders       bool
+	sortBy          string
 	rootCmd         = &cobra.Command{
 		Use:     "kafy <command> <subcommand> [flags]",
 		Version: version,
@@ -24,6 +26,11 @@ It provides a kubectl-inspired interface for working with topics, consumer group
 producers, consumers, and cluster administration.
 
 `,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Reject unknown output formats up front instead of silently falling back to table
+			_, err := output.ParseFormat(outputFormat)
+			return err
+		},
 		// Usage:
 		//   kafy [command] [subcommand] [flags] [options]
 
@@ -74,13 +81,13 @@ func wrapAllCommands(cmd *cobra.Command) {
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format (table, json, yaml)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format ("+strings.Join(output.FormatNames(), ", ")+")")
 	rootCmd.PersistentFlags().StringVarP(&clusterOverride, "cluster", "c", "", "Use specified cluster instead of current context")
+	rootCmd.PersistentFlags().BoolVar(&noHeaders, "no-headers", false, "Omit the header row in table, csv and tsv output")
+	rootCmd.PersistentFlags().StringVar(&sortBy, "sort-by", "", "Sort rows by column name (prefix with '-' for descending, e.g. --sort-by=-lag)")
 
 	// Add completion for output format
-	rootCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"table", "json", "yaml"}, cobra.ShellCompDirectiveDefault
-	})
+	rootCmd.RegisterFlagCompletionFunc("output", completeOutputFormats)
 
 	// Enable completion command
 	rootCmd.CompletionOptions.DisableDefaultCmd = false
@@ -98,10 +105,33 @@ func init() {
 	rootCmd.AddCommand(healthCmd)
 	rootCmd.AddCommand(tailCmd)
 	rootCmd.AddCommand(utilCmd)
+	rootCmd.AddCommand(perfCmd)
+	rootCmd.AddCommand(mirrorCmd)
+	rootCmd.AddCommand(exporterCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(reassignCmd)
+}
+
+// ExitError ends the program with a specific exit code instead of 1
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
 }
 
 func getFormatter() *output.Formatter {
-	return output.NewFormatter(outputFormat)
+	formatter, err := output.NewFormatter(outputFormat)
+	handleError(err)
+	formatter.NoHeaders = noHeaders
+	formatter.SortBy = sortBy
+	return formatter
 }
 
 func handleError(err error) {
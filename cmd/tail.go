@@ -10,6 +10,7 @@ import (
 
         "github.com/confluentinc/confluent-kafka-go/v2/kafka"
         "github.com/spf13/cobra"
+        "kafy/internal/decode"
         kafkaClient "kafy/internal/kafka"
 )
 
@@ -20,9 +21,12 @@ var tailCmd = &cobra.Command{
         ValidArgsFunction: completeTopics,
         RunE: func(cmd *cobra.Command, args []string) error {
                 topicNames := args
-                output, _ := cmd.Flags().GetString("output")
                 keyFilter, _ := cmd.Flags().GetString("key-filter")
-                noValue, _ := cmd.Flags().GetBool("no-value")
+
+                printOpts, err := newMessagePrintOptions(cmd)
+                if err != nil {
+                        return err
+                }
 
                 // Execute consume with --from-latest flag
                 cfg, err := LoadConfigWithClusterOverride()
@@ -55,22 +59,22 @@ var tailCmd = &cobra.Command{
                 signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
                 if len(topicNames) == 1 {
-                        fmt.Printf("Tailing messages from topic '%s' (latest messages only). Press Ctrl+C to exit.\n", topicNames[0])
+                        fmt.Fprintf(os.Stderr, "Tailing messages from topic '%s' (latest messages only). Press Ctrl+C to exit.\n", topicNames[0])
                 } else {
-                        fmt.Printf("Tailing messages from %d topics: %s (latest messages only). Press Ctrl+C to exit.\n", len(topicNames), strings.Join(topicNames, ", "))
+                        fmt.Fprintf(os.Stderr, "Tailing messages from %d topics: %s (latest messages only). Press Ctrl+C to exit.\n", len(topicNames), strings.Join(topicNames, ", "))
                 }
 
                 for {
                         select {
                         case sig := <-sigChan:
-                                fmt.Printf("\nCaught signal %v: cleaning up and terminating\n", sig)
+                                fmt.Fprintf(os.Stderr, "\nCaught signal %v: cleaning up and terminating\n", sig)
                                 // Close consumer first to leave the group
                                 consumer.Close()
                                 // Wait a moment for the group to become empty
                                 time.Sleep(100 * time.Millisecond)
                                 // Clean up the auto-generated consumer group
                                 if err := client.DeleteConsumerGroup(group); err != nil {
-                                        fmt.Printf("Warning: Failed to delete consumer group '%s': %v\n", group, err)
+                                        fmt.Fprintf(os.Stderr, "Warning: Failed to delete consumer group '%s': %v\n", group, err)
                                 }
                                 return nil
                         default:
@@ -87,14 +91,14 @@ var tailCmd = &cobra.Command{
 
                                 // Apply key filtering if specified
                                 if keyFilter != "" {
-                                        messageKey := string(msg.Key)
+                                        messageKey := messageKeyText(msg, printOpts)
                                         if !matchesKeyFilter(messageKey, keyFilter) {
                                                 continue // Skip this message
                                         }
                                 }
                                 
                                 // Use the same message printing function as consume command
-                                if err := printMessage(msg, output, noValue); err != nil {
+                                if err := printMessage(msg, "", printOpts); err != nil {
                                         fmt.Printf("Error formatting message: %v\n", err)
                                 }
                         }
@@ -106,4 +110,9 @@ func init() {
         tailCmd.Flags().String("output", "table", "Output format (table, json, yaml, hex)")
         tailCmd.Flags().String("key-filter", "", "Filter messages by key (supports wildcards: *, prefix*, *suffix, *contains*)")
         tailCmd.Flags().Bool("no-value", false, "Hide message values from output")
+        tailCmd.Flags().String("key-decoder", "string", "Decode message keys as: "+decode.Usage)
+        tailCmd.Flags().String("value-decoder", "string", "Decode message values as: "+decode.Usage)
+
+        tailCmd.RegisterFlagCompletionFunc("key-decoder", completeDecoders)
+        tailCmd.RegisterFlagCompletionFunc("value-decoder", completeDecoders)
 }
\ No newline at end of file
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+        "fmt"
+        "os"
+        "sort"
+        "strconv"
+
+        "github.com/spf13/cobra"
+        kafkaClient "kafy/internal/kafka"
+)
+
+var topicsLagCmd = &cobra.Command{
+        Use:   "lag <topic>",
+        Short: "Show the lag of every consumer group on a topic",
+        Long: `List every consumer group with committed offsets on a topic, with its state, member count,
+total lag and largest partition lag, most lagging group first.
+
+The committed offsets of all groups are read concurrently (--concurrency) to build an index of
+groups by topic, so this stays quick on clusters with thousands of groups.
+
+Examples:
+  kafy topics lag orders
+  kafy topics lag orders --concurrency 64 --output json`,
+        Args:              cobra.ExactArgs(1),
+        ValidArgsFunction: completeTopics,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicName := args[0]
+                concurrency, _ := cmd.Flags().GetInt("concurrency")
+                if concurrency < 1 {
+                        return fmt.Errorf("--concurrency must be at least 1")
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                highWatermarks, err := client.GetTopicOffsets(topicName)
+                if err != nil {
+                        return err
+                }
+
+                summaries, err := client.ListConsumerGroups()
+                if err != nil {
+                        return fmt.Errorf("failed to list consumer groups: %w", err)
+                }
+                groups := make([]string, 0, len(summaries))
+                for _, summary := range summaries {
+                        groups = append(groups, summary.GroupID)
+                }
+
+                index, failed, err := client.BuildGroupOffsetIndex(groups, concurrency)
+                if err != nil {
+                        return err
+                }
+                if len(failed) > 0 {
+                        fmt.Fprintf(os.Stderr, "Warning: could not read the offsets of %d groups\n", len(failed))
+                }
+
+                var rows []topicGroupLag
+                for _, summary := range summaries {
+                        committed, ok := index[topicName][summary.GroupID]
+                        if !ok {
+                                continue
+                        }
+                        row := topicGroupLag{
+                                Group:        summary.GroupID,
+                                State:        summary.State,
+                                Members:      summary.MemberCount,
+                                Partitions:   len(committed),
+                                MaxPartition: -1,
+                        }
+                        for partition, offset := range committed {
+                                var lag int64
+                                if high := int64(highWatermarks[partition]); high > offset {
+                                        lag = high - offset
+                                }
+                                row.TotalLag += lag
+                                if lag > row.MaxLag || row.MaxPartition < 0 {
+                                        row.MaxLag, row.MaxPartition = lag, partition
+                                }
+                        }
+                        rows = append(rows, row)
+                }
+
+                sort.Slice(rows, func(i, j int) bool {
+                        if rows[i].TotalLag != rows[j].TotalLag {
+                                return rows[i].TotalLag > rows[j].TotalLag
+                        }
+                        return rows[i].Group < rows[j].Group
+                })
+
+                formatter := getFormatter()
+                if formatter.Format != "table" {
+                        return formatter.Output(rows)
+                }
+                if len(rows) == 0 {
+                        fmt.Printf("No consumer group has committed offsets on topic '%s'\n", topicName)
+                        return nil
+                }
+
+                headers := []string{"Group", "State", "Members", "Partitions", "Total Lag", "Max Lag", "Max Lag Partition"}
+                var tableRows [][]string
+                for _, row := range rows {
+                        tableRows = append(tableRows, []string{
+                                row.Group,
+                                row.State,
+                                strconv.Itoa(row.Members),
+                                fmt.Sprintf("%d/%d", row.Partitions, len(highWatermarks)),
+                                strconv.FormatInt(row.TotalLag, 10),
+                                strconv.FormatInt(row.MaxLag, 10),
+                                strconv.Itoa(int(row.MaxPartition)),
+                        })
+                }
+                return formatter.OutputTable(headers, tableRows)
+        },
+}
+
+// topicGroupLag is the lag of one consumer group on a topic
+type topicGroupLag struct {
+        Group        string `json:"group" yaml:"group"`
+        State        string `json:"state" yaml:"state"`
+        Members      int    `json:"members" yaml:"members"`
+        Partitions   int    `json:"partitions" yaml:"partitions"` // Partitions with a committed offset
+        TotalLag     int64  `json:"total_lag" yaml:"total_lag"`
+        MaxLag       int64  `json:"max_lag" yaml:"max_lag"`
+        MaxPartition int32  `json:"max_lag_partition" yaml:"max_lag_partition"`
+}
+
+func init() {
+        topicsLagCmd.Flags().Int("concurrency", 16, "Number of groups whose offsets are read at the same time")
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+        "encoding/json"
+        "fmt"
+        "os"
+        "sort"
+        "strings"
+        "sync"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        "kafy/internal/transform"
+)
+
+// recordTransformer runs copied records through the --transform steps and
+// writes records that fail a step to the dead letter file. Records are
+// transformed one at a time, since external commands answer in order.
+type recordTransformer struct {
+        mu         sync.Mutex
+        pipeline   *transform.Pipeline
+        keepTarget bool // Records must stay on their topic and partition (mirror)
+        deadLetter *deadLetterWriter
+        dropped    int64
+        failed     int64
+}
+
+// deadLetterWriter appends records that could not be copied to an NDJSON file
+// in the deadLetterRecord layout. It is safe for concurrent use.
+type deadLetterWriter struct {
+        mu      sync.Mutex
+        file    *os.File
+        encoder *json.Encoder
+}
+
+// addTransformFlags registers the --transform flags shared by cp and mirror
+func addTransformFlags(cmd *cobra.Command) {
+        cmd.Flags().StringArray("transform", nil, "Transform step applied to every record, in the order given (repeatable, see --help)")
+        cmd.Flags().Bool("transform-strict", false, "Fail records whose value has no field a mask or hash path selects")
+}
+
+// openDeadLetterFile returns nil when no file was given
+func openDeadLetterFile(path string) (*deadLetterWriter, error) {
+        if path == "" {
+                return nil, nil
+        }
+        file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+        if err != nil {
+                return nil, fmt.Errorf("failed to open dead letter file: %w", err)
+        }
+        return &deadLetterWriter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Write records a message together with the reason it was set aside
+func (w *deadLetterWriter) Write(msg *kafka.Message, reason error) error {
+        w.mu.Lock()
+        defer w.mu.Unlock()
+        if err := w.encoder.Encode(newDeadLetterRecord(msg, reason)); err != nil {
+                return fmt.Errorf("failed to write dead letter record: %w", err)
+        }
+        return nil
+}
+
+func (w *deadLetterWriter) Name() string {
+        return w.file.Name()
+}
+
+func (w *deadLetterWriter) Close() {
+        w.mu.Lock()
+        defer w.mu.Unlock()
+        if err := w.file.Close(); err != nil {
+                fmt.Fprintf(os.Stderr, "Failed to close dead letter file: %v\n", err)
+        }
+}
+
+// transformHelp describes the transform steps for a command's long help
+func transformHelp() string {
+        return `Transforms (--transform, applied in order):
+  ` + strings.Join(transform.Steps, "\n  ") + `
+
+  Paths select JSON value fields: .customer.email, $.items[*].card, .tags[0].
+  Conditions: <operand> ==, !=, =~, !~, >, >=, <, <= <value>; <operand> (present);
+  !<operand> (absent); tombstone. Operands: key, value, topic, partition,
+  header.<name> or a field path. Templates fill in {operand}, e.g. {topic}-{.region}.
+  Tombstones pass mask and hash unchanged. Records whose value has no field a mask
+  or hash path selects are counted in the summary; --transform-strict fails them.
+  Hash keys are read from $KAFY_HASH_KEY, or from env:<var> or file:<path> given in
+  the step, never from the command line. exec is the only scripting hook.`
+}
+
+// newRecordTransformer returns nil when no --transform was given. Records a
+// step fails on go to deadLetter, which may be nil.
+func newRecordTransformer(cmd *cobra.Command, keepTarget bool, deadLetter *deadLetterWriter) (*recordTransformer, error) {
+        specs, _ := cmd.Flags().GetStringArray("transform")
+        if len(specs) == 0 {
+                return nil, nil
+        }
+
+        strict, _ := cmd.Flags().GetBool("transform-strict")
+        pipeline, err := transform.NewPipeline(specs, strict)
+        if err != nil {
+                return nil, err
+        }
+        t := &recordTransformer{pipeline: pipeline, keepTarget: keepTarget, deadLetter: deadLetter}
+        if keepTarget {
+                for _, spec := range specs {
+                        if strings.HasPrefix(strings.TrimSpace(spec), "route:") {
+                                pipeline.Close()
+                                return nil, fmt.Errorf("'%s': route steps are not supported here, records keep their topic and partition", spec)
+                        }
+                }
+        }
+        return t, nil
+}
+
+// Apply transforms dest, the message about to be produced for source. It
+// returns false when the record was dropped or failed; a failure is only an
+// error when there is no dead letter file to write it to.
+func (t *recordTransformer) Apply(source, dest *kafka.Message) (bool, error) {
+        t.mu.Lock()
+        defer t.mu.Unlock()
+
+        record := &transform.Record{
+                Partition: dest.TopicPartition.Partition,
+                Key:       dest.Key,
+                Value:     dest.Value,
+                Timestamp: dest.Timestamp,
+        }
+        if dest.TopicPartition.Topic != nil {
+                record.Topic = *dest.TopicPartition.Topic
+        }
+        for _, header := range dest.Headers {
+                record.Headers = append(record.Headers, transform.Header{Key: header.Key, Value: header.Value})
+        }
+
+        keep, err := t.pipeline.Apply(record)
+        if err == nil && keep && t.keepTarget && (record.Topic != *dest.TopicPartition.Topic || record.Partition != dest.TopicPartition.Partition) {
+                err = fmt.Errorf("transform moved the record to %s/%d, but records must keep their topic and partition", record.Topic, record.Partition)
+        }
+        if err != nil {
+                t.failed++
+                if t.deadLetter == nil {
+                        return false, fmt.Errorf("transform failed at %s: %w (use --dead-letter-file to set such records aside)", source.TopicPartition, err)
+                }
+                return false, t.deadLetter.Write(source, err)
+        }
+        if !keep {
+                t.dropped++
+                return false, nil
+        }
+
+        topic := record.Topic
+        dest.TopicPartition.Topic = &topic
+        dest.TopicPartition.Partition = record.Partition
+        if record.Partition < 0 {
+                dest.TopicPartition.Partition = kafka.PartitionAny
+        }
+        dest.Key = record.Key
+        dest.Value = record.Value
+        dest.Timestamp = record.Timestamp
+        dest.Headers = nil
+        for _, header := range record.Headers {
+                dest.Headers = append(dest.Headers, kafka.Header{Key: header.Key, Value: header.Value})
+        }
+        return true, nil
+}
+
+// Summary describes what the transforms dropped and set aside
+func (t *recordTransformer) Summary() string {
+        t.mu.Lock()
+        defer t.mu.Unlock()
+        summary := fmt.Sprintf("Transforms: %d dropped, %d failed", t.dropped, t.failed)
+        if t.failed > 0 && t.deadLetter != nil {
+                summary += fmt.Sprintf(" (written to %s)", t.deadLetter.Name())
+        }
+        unmatched := t.pipeline.Unmatched()
+        steps := make([]string, 0, len(unmatched))
+        for step := range unmatched {
+                steps = append(steps, step)
+        }
+        sort.Strings(steps)
+        for _, step := range steps {
+                summary += fmt.Sprintf("; %s matched no field in %d records", step, unmatched[step])
+        }
+        return summary
+}
+
+// Close stops external transform commands
+func (t *recordTransformer) Close() {
+        if err := t.pipeline.Close(); err != nil {
+                fmt.Fprintf(os.Stderr, "Transform command: %v\n", err)
+        }
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+        "fmt"
+        "sort"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+        "github.com/spf13/cobra"
+        kafkaClient "kafy/internal/kafka"
+)
+
+var topicsTruncateCmd = &cobra.Command{
+        Use:   "truncate <topic>",
+        Short: "Delete records from the start of a topic's partitions",
+        Long: `Delete records from the start of each partition, up to an offset or timestamp.
+The records are removed with the DeleteRecords API, so the topic and its configuration stay in place.
+A preview of the records each partition would lose is shown before asking for confirmation.
+
+Choose what to delete with one of:
+  --before-offset N      Delete records before offset N in every partition
+  --before-timestamp T   Delete records older than T (RFC3339, YYYY-MM-DD or a duration ago like 24h)
+  --all                  Delete every record
+
+Override individual partitions with --partition P=N (an offset), --partition P=all or
+--partition P=none (leave the partition alone). Without one of the options above, only the
+partitions given with --partition are truncated.
+
+Examples:
+  kafy topics truncate orders --before-timestamp 2024-05-01
+  kafy topics truncate orders --before-offset 10000 --partition 3=none
+  kafy topics truncate orders --partition 0=1500 --partition 2=all
+  kafy topics truncate scratch --all --force`,
+        Args:              cobra.ExactArgs(1),
+        ValidArgsFunction: completeTopics,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                topicName := args[0]
+                beforeOffset, _ := cmd.Flags().GetInt64("before-offset")
+                beforeTimestamp, _ := cmd.Flags().GetString("before-timestamp")
+                all, _ := cmd.Flags().GetBool("all")
+                overrides, _ := cmd.Flags().GetStringArray("partition")
+                dryRun, _ := cmd.Flags().GetBool("dry-run")
+                force, _ := cmd.Flags().GetBool("force")
+
+                chosen := 0
+                for _, set := range []bool{cmd.Flags().Changed("before-offset"), beforeTimestamp != "", all} {
+                        if set {
+                                chosen++
+                        }
+                }
+                if chosen > 1 {
+                        return fmt.Errorf("use only one of --before-offset, --before-timestamp and --all")
+                }
+                if chosen == 0 && len(overrides) == 0 {
+                        return fmt.Errorf("must specify one of --before-offset, --before-timestamp, --all or --partition")
+                }
+                if cmd.Flags().Changed("before-offset") && beforeOffset < 0 {
+                        return fmt.Errorf("--before-offset must not be negative")
+                }
+
+                var before time.Time
+                if beforeTimestamp != "" {
+                        t, err := parseTimeSpec(beforeTimestamp)
+                        if err != nil {
+                                return fmt.Errorf("invalid --before-timestamp: %w", err)
+                        }
+                        before = t
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                highWatermarks, err := client.GetTopicOffsets(topicName)
+                if err != nil {
+                        return err
+                }
+
+                targets, err := parseTruncateOverrides(overrides, highWatermarks)
+                if err != nil {
+                        return err
+                }
+
+                groupID := fmt.Sprintf("kafy-truncate-%d", time.Now().Unix())
+                consumer, err := client.CreateConsumer(groupID)
+                if err != nil {
+                        return fmt.Errorf("failed to create consumer: %w", err)
+                }
+                defer consumer.Close()
+
+                // Partitions without an override get the topic-wide target
+                if chosen > 0 {
+                        for partition, high := range highWatermarks {
+                                if _, exists := targets[partition]; exists {
+                                        continue
+                                }
+                                switch {
+                                case all:
+                                        targets[partition] = int64(high)
+                                case beforeTimestamp != "":
+                                        offset, err := offsetBeforeTime(consumer, topicName, partition, before, int64(high))
+                                        if err != nil {
+                                                return err
+                                        }
+                                        targets[partition] = offset
+                                default:
+                                        targets[partition] = beforeOffset
+                                }
+                        }
+                }
+
+                plan, err := planTruncate(consumer, topicName, targets)
+                if err != nil {
+                        return err
+                }
+
+                var total int64
+                deletes := make(map[int32]int64)
+                for _, p := range plan {
+                        total += p.Records
+                        if p.Records > 0 {
+                                deletes[p.Partition] = p.DeleteBefore
+                        }
+                }
+
+                if err := outputTruncatePlan(plan); err != nil {
+                        return err
+                }
+                if total == 0 {
+                        fmt.Println("Nothing to delete")
+                        return nil
+                }
+                if dryRun {
+                        fmt.Printf("Dry run: %d records in %d partitions would be deleted from topic '%s'\n", total, len(deletes), topicName)
+                        return nil
+                }
+
+                if !force {
+                        fmt.Printf("Are you sure you want to permanently delete %d records from %d partitions of topic '%s'? (y/N): ", total, len(deletes), topicName)
+                        var response string
+                        fmt.Scanln(&response)
+                        if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+                                fmt.Println("Cancelled")
+                                return nil
+                        }
+                }
+
+                lowWatermarks, err := client.DeleteRecords(topicName, deletes)
+                if err != nil {
+                        return err
+                }
+
+                partitions := make([]int32, 0, len(lowWatermarks))
+                for partition := range lowWatermarks {
+                        partitions = append(partitions, partition)
+                }
+                sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+                for _, partition := range partitions {
+                        fmt.Printf("Partition %d now starts at offset %d\n", partition, lowWatermarks[partition])
+                }
+                fmt.Printf("Deleted %d records from topic '%s'\n", total, topicName)
+                return nil
+        },
+}
+
+// truncatePartition is the preview of what truncating one partition removes
+type truncatePartition struct {
+        Partition    int32 `json:"partition" yaml:"partition"`
+        StartOffset  int64 `json:"start_offset" yaml:"start_offset"`
+        EndOffset    int64 `json:"end_offset" yaml:"end_offset"`
+        DeleteBefore int64 `json:"delete_before" yaml:"delete_before"`
+        Records      int64 `json:"records" yaml:"records"`
+}
+
+// parseTruncateOverrides parses --partition P=N, P=all and P=none. Skipped
+// partitions map to -1.
+func parseTruncateOverrides(overrides []string, highWatermarks map[int32]kafka.Offset) (map[int32]int64, error) {
+        targets := make(map[int32]int64)
+        for _, override := range overrides {
+                partitionText, value, found := strings.Cut(override, "=")
+                partition, err := strconv.ParseInt(strings.TrimSpace(partitionText), 10, 32)
+                if !found || err != nil {
+                        return nil, fmt.Errorf("invalid --partition '%s' (expected <partition>=<offset>, <partition>=all or <partition>=none)", override)
+                }
+                high, exists := highWatermarks[int32(partition)]
+                if !exists {
+                        return nil, fmt.Errorf("partition %d does not exist", partition)
+                }
+
+                switch value = strings.ToLower(strings.TrimSpace(value)); value {
+                case "all":
+                        targets[int32(partition)] = int64(high)
+                case "none":
+                        targets[int32(partition)] = -1
+                default:
+                        offset, err := strconv.ParseInt(value, 10, 64)
+                        if err != nil || offset < 0 {
+                                return nil, fmt.Errorf("invalid offset '%s' for partition %d", value, partition)
+                        }
+                        targets[int32(partition)] = offset
+                }
+        }
+        return targets, nil
+}
+
+// offsetBeforeTime returns the first offset of a partition at or after t, or
+// the high watermark when every record is older
+func offsetBeforeTime(consumer *kafka.Consumer, topic string, partition int32, t time.Time, highWatermark int64) (int64, error) {
+        offsets, err := consumer.OffsetsForTimes([]kafka.TopicPartition{
+                {Topic: &topic, Partition: partition, Offset: kafka.Offset(t.UnixMilli())},
+        }, 10000)
+        if err != nil {
+                return 0, fmt.Errorf("failed to look up offset for %s in partition %d: %w", t.Format(time.RFC3339), partition, err)
+        }
+        if len(offsets) == 0 || offsets[0].Offset < 0 {
+                return highWatermark, nil
+        }
+        return int64(offsets[0].Offset), nil
+}
+
+// planTruncate clamps each target to the partition's current range
+func planTruncate(consumer *kafka.Consumer, topic string, targets map[int32]int64) ([]truncatePartition, error) {
+        var plan []truncatePartition
+        for partition, target := range targets {
+                low, high, err := consumer.QueryWatermarkOffsets(topic, partition, 10000)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to query watermark offsets for partition %d: %w", partition, err)
+                }
+                p := truncatePartition{Partition: partition, StartOffset: low, EndOffset: high, DeleteBefore: low}
+                if target > high {
+                        target = high
+                }
+                if target > low {
+                        p.DeleteBefore = target
+                        p.Records = target - low
+                }
+                plan = append(plan, p)
+        }
+        sort.Slice(plan, func(i, j int) bool { return plan[i].Partition < plan[j].Partition })
+        return plan, nil
+}
+
+func outputTruncatePlan(plan []truncatePartition) error {
+        formatter := getFormatter()
+        if formatter.Format != "table" {
+                return formatter.Output(plan)
+        }
+
+        headers := []string{"Partition", "Start Offset", "End Offset", "Delete Before", "Records To Delete"}
+        var rows [][]string
+        for _, p := range plan {
+                rows = append(rows, []string{
+                        strconv.Itoa(int(p.Partition)),
+                        strconv.FormatInt(p.StartOffset, 10),
+                        strconv.FormatInt(p.EndOffset, 10),
+                        strconv.FormatInt(p.DeleteBefore, 10),
+                        strconv.FormatInt(p.Records, 10),
+                })
+        }
+        return formatter.OutputTable(headers, rows)
+}
+
+func init() {
+        topicsTruncateCmd.Flags().Int64("before-offset", 0, "Delete records before this offset in every partition")
+        topicsTruncateCmd.Flags().String("before-timestamp", "", "Delete records older than this time (RFC3339, YYYY-MM-DD or a duration ago like 24h)")
+        topicsTruncateCmd.Flags().Bool("all", false, "Delete every record in the topic")
+        topicsTruncateCmd.Flags().StringArray("partition", nil, "Per-partition target: <partition>=<offset>, <partition>=all or <partition>=none (repeatable)")
+        topicsTruncateCmd.Flags().Bool("dry-run", false, "Only show what would be deleted")
+        topicsTruncateCmd.Flags().Bool("force", false, "Skip confirmation")
+}
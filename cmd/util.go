@@ -6,6 +6,7 @@ import (
         "math/big"
         "strings"
 
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
         "github.com/spf13/cobra"
         "kafy/config"
         kafkaClient "kafy/internal/kafka"
@@ -76,7 +77,9 @@ func displayMetadataAsTable(metadata interface{}, formatter interface{}) error {
                         brokerRows = append(brokerRows, row)
                 }
                 
-                getFormatter().OutputTable(brokerHeaders, brokerRows)
+                if err := getFormatter().OutputTable(brokerHeaders, brokerRows); err != nil {
+                        return err
+                }
         } else {
                 fmt.Println("No brokers found")
         }
@@ -116,7 +119,9 @@ func displayMetadataAsTable(metadata interface{}, formatter interface{}) error {
                         }
                 }
                 
-                getFormatter().OutputTable(topicHeaders, topicRows)
+                if err := getFormatter().OutputTable(topicHeaders, topicRows); err != nil {
+                        return err
+                }
         } else {
                 fmt.Println("No topics found")
         }
@@ -124,6 +129,23 @@ func displayMetadataAsTable(metadata interface{}, formatter interface{}) error {
         return nil
 }
 
+// transientReadError reports whether a consumer read error is worth retrying:
+// a poll timeout or a broker connection problem that librdkafka recovers from.
+// Authorization failures, unknown topics and the like are not fatal to
+// librdkafka either, but waiting does not fix them.
+func transientReadError(err error) bool {
+        kafkaErr, ok := err.(kafka.Error)
+        if !ok {
+                return false
+        }
+        switch kafkaErr.Code() {
+        case kafka.ErrTimedOut, kafka.ErrTransport, kafka.ErrAllBrokersDown, kafka.ErrPartitionEOF,
+                kafka.ErrNetworkException, kafka.ErrRequestTimedOut, kafka.ErrLeaderNotAvailable, kafka.ErrNotLeaderForPartition:
+                return true
+        }
+        return false
+}
+
 // LoadConfigWithClusterOverride loads config and optionally overrides the cluster context
 func LoadConfigWithClusterOverride() (*config.Config, error) {
         cfg, err := config.LoadConfig()
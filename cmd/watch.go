@@ -0,0 +1,156 @@
+package cmd
+
+import (
+        "fmt"
+        "os"
+        "os/signal"
+        "strings"
+        "syscall"
+        "time"
+
+        "github.com/spf13/cobra"
+        "kafy/internal/alert"
+        kafkaClient "kafy/internal/kafka"
+)
+
+var watchCmd = &cobra.Command{
+        Use:   "watch",
+        Short: "Evaluate health and lag rules periodically and send alerts",
+        Long: `Run the checks of 'kafy health check' at an interval and notify webhooks, Slack, Teams or a
+command when a problem starts, escalates from warn to fail, or resolves. Each problem (a check and
+the topic, partition, group or broker it is about) is notified once, not at every evaluation.
+
+The alert policy takes every threshold of a health check policy, plus:
+
+  interval: 1m              # time between evaluations
+  repeat: 4h                # re-send alerts still firing after this long (default: never)
+  severity: warn            # lowest finding status that alerts: warn or fail
+  max-group-lag: {warn: 10000, fail: 100000}
+  groups: [billing, shipping]
+  notify:
+    - type: webhook         # POST the alerts as JSON
+      url: http://localhost:8080/alerts
+      headers: {Authorization: "Bearer $ALERT_TOKEN"}
+    - type: slack           # Slack incoming webhook
+      url: https://hooks.slack.com/services/...
+    - type: teams           # Teams incoming webhook (MessageCard)
+      url: https://example.webhook.office.com/...
+    - type: command         # Run through sh with the JSON on stdin
+      command: ./page-oncall.sh
+
+Alert state is saved under ~/.kafy/watch/<context>.json, so --once can run from cron and still only
+notify changes. Undelivered notifications are retried at the next evaluation, for the failed targets only.
+
+Examples:
+  kafy watch --policy alerts.yaml
+  kafy watch --policy alerts.yaml --once
+  kafy watch --policy alerts.yaml --cluster prod --interval 30s`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+                policyPath, _ := cmd.Flags().GetString("policy")
+                once, _ := cmd.Flags().GetBool("once")
+
+                policy, err := alert.Load(policyPath)
+                if err != nil {
+                        return err
+                }
+                if cmd.Flags().Changed("interval") {
+                        policy.Interval, _ = cmd.Flags().GetDuration("interval")
+                        if policy.Interval <= 0 {
+                                return fmt.Errorf("--interval must be positive")
+                        }
+                }
+
+                cfg, err := LoadConfigWithClusterOverride()
+                if err != nil {
+                        return err
+                }
+
+                client, err := kafkaClient.NewClient(cfg)
+                if err != nil {
+                        return err
+                }
+
+                state, err := alert.LoadState(cfg.CurrentContext)
+                if err != nil {
+                        return err
+                }
+
+                if once {
+                        return evaluateAlerts(client, cfg.CurrentContext, policy, state)
+                }
+
+                sigChan := make(chan os.Signal, 1)
+                signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+                defer signal.Stop(sigChan)
+
+                fmt.Printf("Watching %s every %s with %d notify targets (Ctrl-C to stop)\n", cfg.CurrentContext, policy.Interval, len(policy.Notify))
+                ticker := time.NewTicker(policy.Interval)
+                defer ticker.Stop()
+                for {
+                        if err := evaluateAlerts(client, cfg.CurrentContext, policy, state); err != nil {
+                                fmt.Fprintf(os.Stderr, "%s %v\n", time.Now().Format("15:04:05"), err)
+                        }
+
+                        select {
+                        case <-sigChan:
+                                return nil
+                        case <-ticker.C:
+                        }
+                }
+        },
+}
+
+// evaluateAlerts runs the checks once, notifies every target of the alerts that
+// changed and saves the alert state
+func evaluateAlerts(client *kafkaClient.Client, cluster string, policy *alert.Config, state *alert.State) error {
+        report, err := runHealthChecks(client, &policy.Policy)
+        if err != nil {
+                return err
+        }
+
+        now := time.Now()
+        state.Update(report, policy.Severity, now)
+        var targets []string
+        for _, target := range policy.Notify {
+                targets = append(targets, target.ID())
+        }
+        state.Retain(targets)
+        firing := len(state.Firing())
+
+        status := fmt.Sprintf("%s %s: %d firing", now.Format("15:04:05"), report.Status, firing)
+        // Each target keeps its own delivery state, so one failing target is
+        // retried on its own without resending to the others
+        var failed []string
+        notified := 0
+        for _, target := range policy.Notify {
+                pending := state.Pending(target.ID(), policy.Repeat, now)
+                if len(pending) == 0 {
+                        continue
+                }
+                if err := target.Send(alert.NewNotification(cluster, pending, now)); err != nil {
+                        failed = append(failed, err.Error())
+                        continue
+                }
+                state.MarkNotified(target.ID(), pending, now)
+                notified++
+        }
+        if notified > 0 {
+                status += fmt.Sprintf(", notified %d of %d targets", notified, len(policy.Notify))
+        }
+        if err := state.Save(); err != nil {
+                return err
+        }
+        fmt.Println(status)
+
+        if len(failed) > 0 {
+                return fmt.Errorf("failed to deliver notifications: %s", strings.Join(failed, "; "))
+        }
+        return nil
+}
+
+func init() {
+        watchCmd.Flags().String("policy", "", "YAML alert policy with thresholds and notify targets (see --help)")
+        watchCmd.Flags().Bool("once", false, "Evaluate once and exit, e.g. from cron")
+        watchCmd.Flags().Duration("interval", time.Minute, "Time between evaluations (overrides the policy)")
+        watchCmd.MarkFlagRequired("policy")
+}
@@ -1,12 +1,15 @@
 module kafy
 
-go 1.21
+go 1.22
 
 
 require (
         github.com/confluentinc/confluent-kafka-go/v2 v2.11.1
+        github.com/fxamacker/cbor/v2 v2.9.2
         github.com/jedib0t/go-pretty/v6 v6.6.8
+        github.com/klauspost/compress v1.18.0
         github.com/spf13/cobra v1.10.1
+        github.com/vmihailenco/msgpack/v5 v5.4.1
         gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -17,6 +20,8 @@ require (
         github.com/rivo/uniseg v0.4.7 // indirect
         github.com/rogpeppe/go-internal v1.8.0 // indirect
         github.com/spf13/pflag v1.0.9 // indirect
+        github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+        github.com/x448/float16 v0.8.4 // indirect
         golang.org/x/sys v0.30.0 // indirect
         golang.org/x/text v0.22.0 // indirect
         gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
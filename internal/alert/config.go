@@ -0,0 +1,79 @@
+package alert
+
+import (
+        "bytes"
+        "crypto/sha256"
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "io"
+        "os"
+        "time"
+
+        "gopkg.in/yaml.v3"
+        "kafy/internal/health"
+)
+
+// Config is an alert policy: the health thresholds to evaluate, how often,
+// and where to send notifications
+type Config struct {
+        health.Policy `yaml:",inline"`
+        Interval      time.Duration `yaml:"interval"` // Time between evaluations (default 1m)
+        Repeat        time.Duration `yaml:"repeat"`   // Re-send alerts still firing after this long (default never)
+        Severity      health.Status `yaml:"severity"` // Lowest finding status that alerts (default warn)
+        Notify        []Target      `yaml:"notify"`
+}
+
+// Target is one notification destination
+type Target struct {
+        Type    string            `yaml:"type"` // webhook, slack, teams or command
+        URL     string            `yaml:"url"`
+        Command string            `yaml:"command"`
+        Headers map[string]string `yaml:"headers"`
+        Timeout time.Duration     `yaml:"timeout"`
+}
+
+// ID identifies the target in alert state without storing its URL, which often embeds a secret
+func (t Target) ID() string {
+        sum := sha256.Sum256([]byte(t.Type + "\x00" + t.URL + "\x00" + t.Command))
+        return hex.EncodeToString(sum[:8])
+}
+
+// Load reads and validates an alert policy file
+func Load(path string) (*Config, error) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+                return nil, fmt.Errorf("failed to read alert policy: %w", err)
+        }
+        cfg := &Config{Interval: time.Minute, Severity: health.Warn}
+        decoder := yaml.NewDecoder(bytes.NewReader(data))
+        decoder.KnownFields(true)
+        if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+                return nil, fmt.Errorf("failed to parse alert policy %s: %w", path, err)
+        }
+
+        if cfg.Interval <= 0 {
+                return nil, fmt.Errorf("alert policy %s: interval must be positive", path)
+        }
+        if cfg.Severity == health.Pass {
+                return nil, fmt.Errorf("alert policy %s: severity must be warn or fail", path)
+        }
+        if len(cfg.Notify) == 0 {
+                return nil, fmt.Errorf("alert policy %s: no notify targets", path)
+        }
+        for i, t := range cfg.Notify {
+                switch t.Type {
+                case "webhook", "slack", "teams":
+                        if t.URL == "" {
+                                return nil, fmt.Errorf("alert policy %s: notify target %d (%s) needs a url", path, i+1, t.Type)
+                        }
+                case "command":
+                        if t.Command == "" {
+                                return nil, fmt.Errorf("alert policy %s: notify target %d needs a command", path, i+1)
+                        }
+                default:
+                        return nil, fmt.Errorf("alert policy %s: notify target %d has unknown type '%s' (expected webhook, slack, teams or command)", path, i+1, t.Type)
+                }
+        }
+        return cfg, nil
+}
@@ -0,0 +1,197 @@
+package alert
+
+import (
+        "bytes"
+        "context"
+        "encoding/json"
+        "fmt"
+        "io"
+        "net/http"
+        "os"
+        "os/exec"
+        "strings"
+        "time"
+
+        "kafy/internal/health"
+)
+
+// Event is one alert in a notification
+type Event struct {
+        Status     string        `json:"status"` // firing or resolved
+        Severity   health.Status `json:"severity"`
+        Check      string        `json:"check"`
+        Subject    string        `json:"subject,omitempty"`
+        Message    string        `json:"message"`
+        Since      time.Time     `json:"since"`
+        ResolvedAt *time.Time    `json:"resolved_at,omitempty"`
+}
+
+// Notification is the body of a generic webhook and the stdin of a command
+type Notification struct {
+        Cluster  string    `json:"cluster"`
+        Time     time.Time `json:"time"`
+        Firing   int       `json:"firing"`
+        Resolved int       `json:"resolved"`
+        Alerts   []Event   `json:"alerts"`
+}
+
+// NewNotification builds a notification from pending alerts
+func NewNotification(cluster string, alerts []*Alert, now time.Time) Notification {
+        n := Notification{Cluster: cluster, Time: now.UTC()}
+        for _, a := range alerts {
+                e := Event{
+                        Status:     "firing",
+                        Severity:   a.Severity,
+                        Check:      a.Check,
+                        Subject:    a.Subject,
+                        Message:    a.Message,
+                        Since:      a.Since.UTC(),
+                        ResolvedAt: a.ResolvedAt,
+                }
+                if a.ResolvedAt != nil {
+                        e.Status = "resolved"
+                        n.Resolved++
+                } else {
+                        n.Firing++
+                }
+                n.Alerts = append(n.Alerts, e)
+        }
+        return n
+}
+
+// Title summarises the notification, e.g. "[prod] 2 firing, 1 resolved"
+func (n Notification) Title() string {
+        var parts []string
+        if n.Firing > 0 {
+                parts = append(parts, fmt.Sprintf("%d firing", n.Firing))
+        }
+        if n.Resolved > 0 {
+                parts = append(parts, fmt.Sprintf("%d resolved", n.Resolved))
+        }
+        return fmt.Sprintf("[%s] kafy alerts: %s", n.Cluster, strings.Join(parts, ", "))
+}
+
+// Lines describes each alert on one line
+func (n Notification) Lines() []string {
+        var lines []string
+        for _, e := range n.Alerts {
+                label := strings.ToUpper(e.Severity.String())
+                if e.Status == "resolved" {
+                        label = "RESOLVED"
+                }
+                line := fmt.Sprintf("%s %s", label, e.Check)
+                if e.Subject != "" {
+                        line += " " + e.Subject
+                }
+                lines = append(lines, line+": "+e.Message)
+        }
+        return lines
+}
+
+// Send delivers the notification to the target
+func (t Target) Send(n Notification) error {
+        timeout := t.Timeout
+        if timeout <= 0 {
+                timeout = 10 * time.Second
+        }
+        ctx, cancel := context.WithTimeout(context.Background(), timeout)
+        defer cancel()
+
+        var payload interface{}
+        switch t.Type {
+        case "webhook":
+                payload = n
+        case "slack":
+                payload = slackPayload(n)
+        case "teams":
+                payload = teamsPayload(n)
+        case "command":
+                return t.run(ctx, n)
+        default:
+                return fmt.Errorf("unknown notify type '%s'", t.Type)
+        }
+
+        body, err := json.Marshal(payload)
+        if err != nil {
+                return err
+        }
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+        if err != nil {
+                return err
+        }
+        req.Header.Set("Content-Type", "application/json")
+        for name, value := range t.Headers {
+                req.Header.Set(name, os.ExpandEnv(value))
+        }
+        resp, err := http.DefaultClient.Do(req)
+        if err != nil {
+                return fmt.Errorf("%s %s: %w", t.Type, t.URL, err)
+        }
+        defer resp.Body.Close()
+        if resp.StatusCode < 200 || resp.StatusCode > 299 {
+                text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+                return fmt.Errorf("%s %s: HTTP %d: %s", t.Type, t.URL, resp.StatusCode, strings.TrimSpace(string(text)))
+        }
+        return nil
+}
+
+// run executes the command through the shell with the notification as JSON on stdin
+func (t Target) run(ctx context.Context, n Notification) error {
+        body, err := json.Marshal(n)
+        if err != nil {
+                return err
+        }
+        cmd := exec.CommandContext(ctx, "sh", "-c", t.Command)
+        cmd.Stdin = bytes.NewReader(body)
+        cmd.Env = append(os.Environ(),
+                "KAFY_ALERT_CLUSTER="+n.Cluster,
+                fmt.Sprintf("KAFY_ALERT_FIRING=%d", n.Firing),
+                fmt.Sprintf("KAFY_ALERT_RESOLVED=%d", n.Resolved),
+                "KAFY_ALERT_TITLE="+n.Title(),
+        )
+        if out, err := cmd.CombinedOutput(); err != nil {
+                return fmt.Errorf("command '%s': %w: %s", t.Command, err, strings.TrimSpace(string(out)))
+        }
+        return nil
+}
+
+// slackPayload is an incoming webhook message; Slack renders *bold* and :emoji:
+func slackPayload(n Notification) map[string]interface{} {
+        lines := []string{"*" + n.Title() + "*"}
+        for i, line := range n.Lines() {
+                lines = append(lines, slackIcon(n.Alerts[i])+" "+line)
+        }
+        return map[string]interface{}{"text": strings.Join(lines, "\n")}
+}
+
+func slackIcon(e Event) string {
+        switch {
+        case e.Status == "resolved":
+                return ":large_green_circle:"
+        case e.Severity == health.Fail:
+                return ":red_circle:"
+        }
+        return ":large_yellow_circle:"
+}
+
+// teamsPayload is a MessageCard, accepted by Teams incoming webhooks
+func teamsPayload(n Notification) map[string]interface{} {
+        color := "FFC000"
+        for _, e := range n.Alerts {
+                if e.Status == "firing" && e.Severity == health.Fail {
+                        color = "D13438"
+                        break
+                }
+        }
+        if n.Firing == 0 {
+                color = "2EB886"
+        }
+        return map[string]interface{}{
+                "@type":      "MessageCard",
+                "@context":   "http://schema.org/extensions",
+                "summary":    n.Title(),
+                "title":      n.Title(),
+                "themeColor": color,
+                "text":       strings.Join(n.Lines(), "\n\n"),
+        }
+}
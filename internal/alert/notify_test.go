@@ -0,0 +1,146 @@
+package alert
+
+import (
+        "encoding/json"
+        "io"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "testing"
+        "time"
+
+        "kafy/internal/health"
+)
+
+// received is one request captured by the test server
+type received struct {
+        header http.Header
+        body   []byte
+}
+
+// server records every request and answers with status
+func server(t *testing.T, status int) (*httptest.Server, *[]received) {
+        t.Helper()
+        var requests []received
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                if r.Method != http.MethodPost {
+                        t.Errorf("method = %s, want POST", r.Method)
+                }
+                body, _ := io.ReadAll(r.Body)
+                requests = append(requests, received{header: r.Header.Clone(), body: body})
+                w.WriteHeader(status)
+                io.WriteString(w, "nope")
+        }))
+        t.Cleanup(srv.Close)
+        return srv, &requests
+}
+
+func sampleNotification() Notification {
+        now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+        resolved := now
+        return NewNotification("prod", []*Alert{
+                {Check: "under-replicated", Subject: "orders/0", Severity: health.Fail, Message: "1 of 3 in sync", Since: now},
+                {Check: "group-lag", Subject: "group billing", Severity: health.Warn, Message: "lag 20000", Since: now},
+                {Check: "offline", Subject: "payments/2", Severity: health.Fail, Message: "no leader", Since: now, ResolvedAt: &resolved},
+        }, now)
+}
+
+func TestWebhookPayload(t *testing.T) {
+        srv, requests := server(t, http.StatusOK)
+        t.Setenv("ALERT_TOKEN", "secret")
+        target := Target{Type: "webhook", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer $ALERT_TOKEN"}}
+        if err := target.Send(sampleNotification()); err != nil {
+                t.Fatal(err)
+        }
+
+        if len(*requests) != 1 {
+                t.Fatalf("got %d requests, want 1", len(*requests))
+        }
+        req := (*requests)[0]
+        if got := req.header.Get("Content-Type"); got != "application/json" {
+                t.Errorf("Content-Type = %q", got)
+        }
+        if got := req.header.Get("Authorization"); got != "Bearer secret" {
+                t.Errorf("Authorization = %q, want the header with the variable expanded", got)
+        }
+        var n Notification
+        if err := json.Unmarshal(req.body, &n); err != nil {
+                t.Fatalf("body is not a notification: %v\n%s", err, req.body)
+        }
+        if n.Cluster != "prod" || n.Firing != 2 || n.Resolved != 1 || len(n.Alerts) != 3 {
+                t.Fatalf("notification = %+v", n)
+        }
+        if e := n.Alerts[0]; e.Status != "firing" || e.Severity != health.Fail || e.Check != "under-replicated" || e.Subject != "orders/0" {
+                t.Errorf("first alert = %+v", e)
+        }
+        if e := n.Alerts[2]; e.Status != "resolved" || e.ResolvedAt == nil {
+                t.Errorf("resolved alert = %+v", e)
+        }
+}
+
+func TestSlackPayload(t *testing.T) {
+        srv, requests := server(t, http.StatusOK)
+        if err := (Target{Type: "slack", URL: srv.URL}).Send(sampleNotification()); err != nil {
+                t.Fatal(err)
+        }
+
+        var payload struct {
+                Text string `json:"text"`
+        }
+        if err := json.Unmarshal((*requests)[0].body, &payload); err != nil {
+                t.Fatal(err)
+        }
+        want := strings.Join([]string{
+                "*[prod] kafy alerts: 2 firing, 1 resolved*",
+                ":red_circle: FAIL under-replicated orders/0: 1 of 3 in sync",
+                ":large_yellow_circle: WARN group-lag group billing: lag 20000",
+                ":large_green_circle: RESOLVED offline payments/2: no leader",
+        }, "\n")
+        if payload.Text != want {
+                t.Errorf("text =\n%s\nwant\n%s", payload.Text, want)
+        }
+}
+
+func TestTeamsPayload(t *testing.T) {
+        srv, requests := server(t, http.StatusOK)
+        target := Target{Type: "teams", URL: srv.URL}
+        if err := target.Send(sampleNotification()); err != nil {
+                t.Fatal(err)
+        }
+
+        var card map[string]string
+        if err := json.Unmarshal((*requests)[0].body, &card); err != nil {
+                t.Fatal(err)
+        }
+        if card["@type"] != "MessageCard" || card["title"] != "[prod] kafy alerts: 2 firing, 1 resolved" {
+                t.Errorf("card = %v", card)
+        }
+        if card["themeColor"] != "D13438" {
+                t.Errorf("themeColor = %s, want red while a fail is firing", card["themeColor"])
+        }
+        if !strings.Contains(card["text"], "RESOLVED offline payments/2: no leader") {
+                t.Errorf("text = %q", card["text"])
+        }
+
+        // Only resolutions: green
+        now := time.Now()
+        resolved := NewNotification("prod", []*Alert{{Check: "offline", Severity: health.Fail, ResolvedAt: &now}}, now)
+        if err := target.Send(resolved); err != nil {
+                t.Fatal(err)
+        }
+        card = nil
+        if err := json.Unmarshal((*requests)[1].body, &card); err != nil {
+                t.Fatal(err)
+        }
+        if card["themeColor"] != "2EB886" {
+                t.Errorf("themeColor = %s, want green with nothing firing", card["themeColor"])
+        }
+}
+
+func TestSendReportsHTTPErrors(t *testing.T) {
+        srv, _ := server(t, http.StatusInternalServerError)
+        err := (Target{Type: "webhook", URL: srv.URL}).Send(sampleNotification())
+        if err == nil || !strings.Contains(err.Error(), "HTTP 500: nope") {
+                t.Fatalf("err = %v, want the status and body", err)
+        }
+}
@@ -0,0 +1,209 @@
+package alert
+
+import (
+        "encoding/json"
+        "fmt"
+        "os"
+        "path/filepath"
+        "sort"
+        "time"
+
+        "kafy/config"
+        "kafy/internal/health"
+)
+
+// Alert is one problem being tracked: a check and the subject of its finding
+type Alert struct {
+        Check      string        `json:"check"`
+        Subject    string        `json:"subject,omitempty"`
+        Severity   health.Status `json:"severity"`
+        Message    string        `json:"message"`
+        Since      time.Time     `json:"since"`
+        ResolvedAt *time.Time    `json:"resolved_at,omitempty"`
+
+        Deliveries map[string]Delivery `json:"deliveries,omitempty"` // By target ID; absent when never notified
+}
+
+// Delivery is what a notify target was last told about an alert
+type Delivery struct {
+        Severity health.Status `json:"severity"`
+        At       time.Time     `json:"at"`
+}
+
+// Key identifies an alert across evaluations
+func (a *Alert) Key() string {
+        return a.Check + "|" + a.Subject
+}
+
+// State is the set of tracked alerts, persisted between evaluations so that
+// each target is only notified when an alert fires, escalates, repeats or resolves
+type State struct {
+        Alerts map[string]*Alert `json:"alerts"`
+        path   string
+}
+
+// Dir is where alert state files live (~/.kafy/watch)
+func Dir() string {
+        return filepath.Join(filepath.Dir(config.DefaultConfigPath()), "watch")
+}
+
+// LoadState reads the alert state of a cluster; a cluster never watched has empty state
+func LoadState(name string) (*State, error) {
+        state := &State{Alerts: make(map[string]*Alert), path: filepath.Join(Dir(), name+".json")}
+        data, err := os.ReadFile(state.path)
+        if os.IsNotExist(err) {
+                return state, nil
+        }
+        if err != nil {
+                return nil, err
+        }
+        if err := json.Unmarshal(data, state); err != nil {
+                return nil, fmt.Errorf("failed to parse alert state %s: %w", state.path, err)
+        }
+        if state.Alerts == nil {
+                state.Alerts = make(map[string]*Alert)
+        }
+        return state, nil
+}
+
+// Save writes the state atomically
+func (s *State) Save() error {
+        data, err := json.MarshalIndent(s, "", "  ")
+        if err != nil {
+                return err
+        }
+        if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+                return fmt.Errorf("failed to create alert state directory: %w", err)
+        }
+        tmp := s.path + ".tmp"
+        if err := os.WriteFile(tmp, data, 0644); err != nil {
+                return fmt.Errorf("failed to write alert state: %w", err)
+        }
+        return os.Rename(tmp, s.path)
+}
+
+// Update records the findings of a report at or above severity. Alerts
+// that are no longer found are marked resolved.
+func (s *State) Update(report *health.Report, severity health.Status, now time.Time) {
+        current := make(map[string]*Alert)
+        for _, c := range report.Checks {
+                for _, f := range c.Findings {
+                        if f.Status < severity {
+                                continue
+                        }
+                        a := &Alert{Check: c.Name, Subject: f.Subject(), Severity: f.Status, Message: f.Message}
+                        if existing, ok := current[a.Key()]; ok && existing.Severity >= a.Severity {
+                                continue
+                        }
+                        current[a.Key()] = a
+                }
+        }
+
+        for key, a := range current {
+                existing, ok := s.Alerts[key]
+                if !ok {
+                        a.Since = now
+                        s.Alerts[key] = a
+                        continue
+                }
+                // A resolution nobody was told about yet is simply withdrawn
+                existing.Severity, existing.Message, existing.ResolvedAt = a.Severity, a.Message, nil
+                // After a de-escalation, escalating again is news
+                for target, d := range existing.Deliveries {
+                        if d.Severity > a.Severity {
+                                d.Severity = a.Severity
+                                existing.Deliveries[target] = d
+                        }
+                }
+        }
+        for key, a := range s.Alerts {
+                if _, ok := current[key]; ok || a.ResolvedAt != nil {
+                        continue
+                }
+                // Resolved alerts nobody was told about are dropped
+                if len(a.Deliveries) == 0 {
+                        delete(s.Alerts, key)
+                        continue
+                }
+                resolved := now
+                a.ResolvedAt = &resolved
+        }
+}
+
+// Retain forgets deliveries to targets that are no longer notified, so their
+// resolutions are not kept waiting for them
+func (s *State) Retain(targets []string) {
+        keep := make(map[string]bool)
+        for _, target := range targets {
+                keep[target] = true
+        }
+        for key, a := range s.Alerts {
+                for target := range a.Deliveries {
+                        if !keep[target] {
+                                delete(a.Deliveries, target)
+                        }
+                }
+                if a.ResolvedAt != nil && len(a.Deliveries) == 0 {
+                        delete(s.Alerts, key)
+                }
+        }
+}
+
+// Pending returns the alerts to notify target of: new or escalated alerts,
+// alerts still firing after repeat (when set) and resolved alerts it was told about
+func (s *State) Pending(target string, repeat time.Duration, now time.Time) []*Alert {
+        var pending []*Alert
+        for _, a := range s.Alerts {
+                d, told := a.Deliveries[target]
+                switch {
+                case a.ResolvedAt != nil:
+                        if told {
+                                pending = append(pending, a)
+                        }
+                case !told || a.Severity > d.Severity:
+                        pending = append(pending, a)
+                case repeat > 0 && now.Sub(d.At) >= repeat:
+                        pending = append(pending, a)
+                }
+        }
+        sort.Slice(pending, func(i, j int) bool {
+                if (pending[i].ResolvedAt == nil) != (pending[j].ResolvedAt == nil) {
+                        return pending[i].ResolvedAt == nil
+                }
+                if pending[i].Severity != pending[j].Severity {
+                        return pending[i].Severity > pending[j].Severity
+                }
+                return pending[i].Key() < pending[j].Key()
+        })
+        return pending
+}
+
+// MarkNotified records that the alerts were delivered to target. Resolved
+// alerts are forgotten once every target that was told has been told.
+func (s *State) MarkNotified(target string, alerts []*Alert, now time.Time) {
+        for _, a := range alerts {
+                if a.ResolvedAt != nil {
+                        delete(a.Deliveries, target)
+                        if len(a.Deliveries) == 0 {
+                                delete(s.Alerts, a.Key())
+                        }
+                        continue
+                }
+                if a.Deliveries == nil {
+                        a.Deliveries = make(map[string]Delivery)
+                }
+                a.Deliveries[target] = Delivery{Severity: a.Severity, At: now}
+        }
+}
+
+// Firing returns the unresolved alerts
+func (s *State) Firing() []*Alert {
+        var firing []*Alert
+        for _, a := range s.Alerts {
+                if a.ResolvedAt == nil {
+                        firing = append(firing, a)
+                }
+        }
+        sort.Slice(firing, func(i, j int) bool { return firing[i].Key() < firing[j].Key() })
+        return firing
+}
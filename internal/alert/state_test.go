@@ -0,0 +1,186 @@
+package alert
+
+import (
+        "testing"
+        "time"
+
+        "kafy/internal/health"
+)
+
+// report builds a report with one check holding a finding per topic
+func report(findings map[string]health.Status) *health.Report {
+        check := health.Check{Name: "under-replicated"}
+        for topic, status := range findings {
+                check.Add(health.Finding{Status: status, Topic: topic, Message: topic + " is " + status.String()})
+        }
+        r := &health.Report{}
+        r.Add(check)
+        return r
+}
+
+func newState() *State {
+        return &State{Alerts: make(map[string]*Alert)}
+}
+
+// keys lists the keys of alerts, with a resolved marker
+func keys(alerts []*Alert) []string {
+        var out []string
+        for _, a := range alerts {
+                key := a.Key() + "=" + a.Severity.String()
+                if a.ResolvedAt != nil {
+                        key += " resolved"
+                }
+                out = append(out, key)
+        }
+        return out
+}
+
+func expectPending(t *testing.T, s *State, target string, repeat time.Duration, now time.Time, want ...string) []*Alert {
+        t.Helper()
+        pending := s.Pending(target, repeat, now)
+        got := keys(pending)
+        if len(got) != len(want) {
+                t.Fatalf("pending for %s = %v, want %v", target, got, want)
+        }
+        for i := range got {
+                if got[i] != want[i] {
+                        t.Fatalf("pending for %s = %v, want %v", target, got, want)
+                }
+        }
+        return pending
+}
+
+func TestStateNotifiesOncePerChange(t *testing.T) {
+        s := newState()
+        now := time.Now()
+
+        s.Update(report(map[string]health.Status{"orders": health.Warn}), health.Warn, now)
+        pending := expectPending(t, s, "a", 0, now, "under-replicated|orders=warn")
+        s.MarkNotified("a", pending, now)
+
+        // Still firing at the same severity: nothing new to say
+        now = now.Add(time.Minute)
+        s.Update(report(map[string]health.Status{"orders": health.Warn}), health.Warn, now)
+        expectPending(t, s, "a", 0, now)
+
+        // Escalation is notified
+        s.Update(report(map[string]health.Status{"orders": health.Fail}), health.Warn, now)
+        pending = expectPending(t, s, "a", 0, now, "under-replicated|orders=fail")
+        s.MarkNotified("a", pending, now)
+
+        // Resolution is notified, then the alert is forgotten
+        s.Update(report(nil), health.Warn, now)
+        pending = expectPending(t, s, "a", 0, now, "under-replicated|orders=fail resolved")
+        s.MarkNotified("a", pending, now)
+        if len(s.Alerts) != 0 {
+                t.Fatalf("resolved alert kept after notifying: %v", s.Alerts)
+        }
+}
+
+func TestStateReescalationAfterDeescalation(t *testing.T) {
+        s := newState()
+        now := time.Now()
+
+        s.Update(report(map[string]health.Status{"orders": health.Fail}), health.Warn, now)
+        s.MarkNotified("a", s.Pending("a", 0, now), now)
+
+        s.Update(report(map[string]health.Status{"orders": health.Warn}), health.Warn, now)
+        expectPending(t, s, "a", 0, now)
+
+        s.Update(report(map[string]health.Status{"orders": health.Fail}), health.Warn, now)
+        expectPending(t, s, "a", 0, now, "under-replicated|orders=fail")
+}
+
+func TestStateRepeat(t *testing.T) {
+        s := newState()
+        now := time.Now()
+
+        s.Update(report(map[string]health.Status{"orders": health.Warn}), health.Warn, now)
+        s.MarkNotified("a", s.Pending("a", time.Hour, now), now)
+
+        expectPending(t, s, "a", time.Hour, now.Add(59*time.Minute))
+        expectPending(t, s, "a", time.Hour, now.Add(time.Hour), "under-replicated|orders=warn")
+}
+
+func TestStateBelowSeverityIsIgnored(t *testing.T) {
+        s := newState()
+        now := time.Now()
+
+        s.Update(report(map[string]health.Status{"orders": health.Warn, "payments": health.Fail}), health.Fail, now)
+        expectPending(t, s, "a", 0, now, "under-replicated|payments=fail")
+}
+
+func TestStateUnnotifiedResolutionIsDropped(t *testing.T) {
+        s := newState()
+        now := time.Now()
+
+        s.Update(report(map[string]health.Status{"orders": health.Warn}), health.Warn, now)
+        s.Update(report(nil), health.Warn, now)
+        if len(s.Alerts) != 0 {
+                t.Fatalf("resolution nobody was told about kept: %v", s.Alerts)
+        }
+        expectPending(t, s, "a", 0, now)
+}
+
+func TestStateTracksTargetsSeparately(t *testing.T) {
+        s := newState()
+        now := time.Now()
+
+        s.Update(report(map[string]health.Status{"orders": health.Warn}), health.Warn, now)
+        // a is delivered, b fails
+        s.MarkNotified("a", s.Pending("a", 0, now), now)
+
+        now = now.Add(time.Minute)
+        s.Update(report(map[string]health.Status{"orders": health.Warn}), health.Warn, now)
+        expectPending(t, s, "a", 0, now)
+        s.MarkNotified("b", expectPending(t, s, "b", 0, now, "under-replicated|orders=warn"), now)
+
+        // Resolution waits for every target that was told
+        s.Update(report(nil), health.Warn, now)
+        s.MarkNotified("a", expectPending(t, s, "a", 0, now, "under-replicated|orders=warn resolved"), now)
+        if len(s.Alerts) != 1 {
+                t.Fatalf("resolved alert forgotten before b was told")
+        }
+        s.MarkNotified("b", expectPending(t, s, "b", 0, now, "under-replicated|orders=warn resolved"), now)
+        if len(s.Alerts) != 0 {
+                t.Fatalf("resolved alert kept after every target was told")
+        }
+}
+
+func TestStateRetain(t *testing.T) {
+        s := newState()
+        now := time.Now()
+
+        s.Update(report(map[string]health.Status{"orders": health.Warn}), health.Warn, now)
+        s.MarkNotified("a", s.Pending("a", 0, now), now)
+        s.MarkNotified("b", s.Pending("b", 0, now), now)
+        s.Update(report(nil), health.Warn, now)
+
+        // b was removed from the policy
+        s.Retain([]string{"a"})
+        s.MarkNotified("a", expectPending(t, s, "a", 0, now, "under-replicated|orders=warn resolved"), now)
+        if len(s.Alerts) != 0 {
+                t.Fatalf("resolved alert kept waiting for a removed target")
+        }
+}
+
+func TestStateSaveAndLoad(t *testing.T) {
+        t.Setenv("HOME", t.TempDir())
+        s, err := LoadState("test")
+        if err != nil {
+                t.Fatal(err)
+        }
+        now := time.Now()
+        s.Update(report(map[string]health.Status{"orders": health.Fail}), health.Warn, now)
+        s.MarkNotified("a", s.Pending("a", 0, now), now)
+        if err := s.Save(); err != nil {
+                t.Fatal(err)
+        }
+
+        loaded, err := LoadState("test")
+        if err != nil {
+                t.Fatal(err)
+        }
+        expectPending(t, loaded, "a", 0, now)
+        expectPending(t, loaded, "b", 0, now, "under-replicated|orders=fail")
+}
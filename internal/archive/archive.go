@@ -0,0 +1,317 @@
+package archive
+
+import (
+        "bufio"
+        "bytes"
+        "compress/gzip"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "os"
+        "time"
+)
+
+// Version is the archive format version written into every header line
+const Version = 1
+
+// Line types. An archive is newline-delimited JSON: one header line, then
+// record lines, then one footer line per partition once the dump completes.
+const (
+        TypeHeader = "header"
+        TypeRecord = "record"
+        TypeFooter = "footer"
+)
+
+// Timestamp types, matching Kafka's CreateTime/LogAppendTime distinction
+const (
+        TimestampNone          = "none"
+        TimestampCreateTime    = "create_time"
+        TimestampLogAppendTime = "log_append_time"
+)
+
+// Header describes the archived topic. Split archives repeat it at the start
+// of every part so each file is self-describing.
+type Header struct {
+        Type        string            `json:"type"`
+        Version     int               `json:"version"`
+        Topic       string            `json:"topic"`
+        CreatedAt   time.Time         `json:"created_at"`
+        Part        int               `json:"part"`
+        Compression string            `json:"compression"`
+        Replication int               `json:"replication"`
+        Partitions  []PartitionMeta   `json:"partitions"`
+        Configs     map[string]string `json:"configs,omitempty"`
+}
+
+// PartitionMeta captures a partition's state at the time of the dump
+type PartitionMeta struct {
+        Partition     int32   `json:"partition"`
+        LowWatermark  int64   `json:"low_watermark"`
+        HighWatermark int64   `json:"high_watermark"`
+        Leader        int32   `json:"leader"`
+        Replicas      []int32 `json:"replicas"`
+}
+
+// Record is a single message. Keys, values and header values are []byte so
+// they are base64 encoded, and a nil slice round-trips as null, which keeps
+// null keys and tombstones distinct from empty ones.
+type Record struct {
+        Type          string         `json:"type"`
+        Partition     int32          `json:"partition"`
+        Offset        int64          `json:"offset"`
+        Timestamp     int64          `json:"timestamp"` // Milliseconds since the epoch
+        TimestampType string         `json:"timestamp_type"`
+        Key           []byte         `json:"key"`
+        Value         []byte         `json:"value"`
+        Headers       []RecordHeader `json:"headers,omitempty"`
+}
+
+type RecordHeader struct {
+        Key   string `json:"key"`
+        Value []byte `json:"value"`
+}
+
+// Footer closes out a partition and lets restore verify nothing was lost
+type Footer struct {
+        Type        string `json:"type"`
+        Partition   int32  `json:"partition"`
+        Records     int64  `json:"records"`
+        FirstOffset int64  `json:"first_offset"`
+        LastOffset  int64  `json:"last_offset"`
+}
+
+// WriterOptions controls where and how an archive is written
+type WriterOptions struct {
+        Out       io.Writer // Destination when Path is empty
+        Path      string    // File to write; split parts get a .0001, .0002, ... suffix
+        Gzip      bool      // Compress each file with gzip
+        SplitSize int64     // Start a new part once a file reaches this many bytes (0 = never split)
+}
+
+// Writer writes an archive, rolling over to a new part when the split size is reached
+type Writer struct {
+        opts    WriterOptions
+        header  Header
+        part    int
+        file    *os.File
+        counter *countingWriter
+        gz      *gzip.Writer
+        buf     *bufio.Writer
+        pending int64    // Uncompressed bytes written since the last gzip flush
+        Files   []string // Files written so far
+}
+
+// NewWriter starts an archive and writes the header line
+func NewWriter(opts WriterOptions, header Header) (*Writer, error) {
+        if opts.SplitSize > 0 && opts.Path == "" {
+                return nil, errors.New("splitting an archive requires an output file")
+        }
+        if opts.Path == "" && opts.Out == nil {
+                opts.Out = os.Stdout
+        }
+
+        header.Type = TypeHeader
+        header.Version = Version
+        header.Compression = "none"
+        if opts.Gzip {
+                header.Compression = "gzip"
+        }
+
+        w := &Writer{opts: opts, header: header}
+        if err := w.openPart(); err != nil {
+                return nil, err
+        }
+        return w, nil
+}
+
+func (w *Writer) openPart() error {
+        w.part++
+        var out io.Writer = w.opts.Out
+        if w.opts.Path != "" {
+                path := w.opts.Path
+                if w.opts.SplitSize > 0 {
+                        path = fmt.Sprintf("%s.%04d", w.opts.Path, w.part)
+                }
+                file, err := os.Create(path)
+                if err != nil {
+                        return fmt.Errorf("failed to create archive file: %w", err)
+                }
+                w.file = file
+                w.Files = append(w.Files, path)
+                out = file
+        }
+
+        w.counter = &countingWriter{w: out}
+        out = w.counter
+        if w.opts.Gzip {
+                w.gz = gzip.NewWriter(out)
+                out = w.gz
+        }
+        w.buf = bufio.NewWriter(out)
+        w.pending = 0
+
+        w.header.Part = w.part
+        return w.writeLine(w.header)
+}
+
+func (w *Writer) closePart() error {
+        if err := w.buf.Flush(); err != nil {
+                return err
+        }
+        if w.gz != nil {
+                if err := w.gz.Close(); err != nil {
+                        return err
+                }
+                w.gz = nil
+        }
+        if w.file != nil {
+                if err := w.file.Close(); err != nil {
+                        return err
+                }
+                w.file = nil
+        }
+        return nil
+}
+
+func (w *Writer) writeLine(v interface{}) error {
+        line, err := json.Marshal(v)
+        if err != nil {
+                return err
+        }
+        line = append(line, '\n')
+        n, err := w.buf.Write(line)
+        w.pending += int64(n)
+        return err
+}
+
+// WriteRecord appends a record, starting a new part first if the current one is full
+func (w *Writer) WriteRecord(record Record) error {
+        if w.opts.SplitSize > 0 && w.size() >= w.opts.SplitSize {
+                if err := w.closePart(); err != nil {
+                        return err
+                }
+                if err := w.openPart(); err != nil {
+                        return err
+                }
+        }
+
+        record.Type = TypeRecord
+        return w.writeLine(record)
+}
+
+// gzipFlushInterval bounds how much uncompressed data may sit inside the gzip
+// writer before it is flushed, so split sizes stay reasonably accurate
+const gzipFlushInterval = 1 << 20
+
+// size estimates the bytes written to the current part
+func (w *Writer) size() int64 {
+        if w.gz == nil {
+                return w.counter.n + int64(w.buf.Buffered())
+        }
+        if w.pending >= gzipFlushInterval {
+                if w.buf.Flush() == nil && w.gz.Flush() == nil {
+                        w.pending = 0
+                }
+        }
+        return w.counter.n
+}
+
+// WriteFooter records the final count for a partition
+func (w *Writer) WriteFooter(footer Footer) error {
+        footer.Type = TypeFooter
+        return w.writeLine(footer)
+}
+
+// Close flushes and closes the current part
+func (w *Writer) Close() error {
+        return w.closePart()
+}
+
+type countingWriter struct {
+        w io.Writer
+        n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+        n, err := c.w.Write(p)
+        c.n += int64(n)
+        return n, err
+}
+
+// Entry is one decoded archive line; exactly one field is set
+type Entry struct {
+        Header *Header
+        Record *Record
+        Footer *Footer
+}
+
+// Reader reads archive lines from a plain or gzip-compressed stream
+type Reader struct {
+        r    *bufio.Reader
+        line int
+}
+
+// NewReader detects gzip compression from the stream's magic bytes
+func NewReader(in io.Reader) (*Reader, error) {
+        buffered := bufio.NewReader(in)
+        magic, err := buffered.Peek(2)
+        if err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+                gz, err := gzip.NewReader(buffered)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to open gzip archive: %w", err)
+                }
+                buffered = bufio.NewReader(gz)
+        }
+        return &Reader{r: buffered}, nil
+}
+
+// Next returns the next entry, or io.EOF at the end of the stream
+func (r *Reader) Next() (*Entry, error) {
+        for {
+                line, err := r.r.ReadBytes('\n')
+                if len(bytes.TrimSpace(line)) == 0 {
+                        if err != nil {
+                                return nil, err
+                        }
+                        continue
+                }
+                if err != nil && err != io.EOF {
+                        return nil, err
+                }
+                r.line++
+                return r.parse(line)
+        }
+}
+
+func (r *Reader) parse(line []byte) (*Entry, error) {
+        var kind struct {
+                Type string `json:"type"`
+        }
+        if err := json.Unmarshal(line, &kind); err != nil {
+                return nil, fmt.Errorf("line %d: invalid archive line: %w", r.line, err)
+        }
+
+        var entry Entry
+        var target interface{}
+        switch kind.Type {
+        case TypeHeader:
+                entry.Header = &Header{}
+                target = entry.Header
+        case TypeRecord:
+                entry.Record = &Record{}
+                target = entry.Record
+        case TypeFooter:
+                entry.Footer = &Footer{}
+                target = entry.Footer
+        default:
+                return nil, fmt.Errorf("line %d: unknown archive line type '%s'", r.line, kind.Type)
+        }
+        if err := json.Unmarshal(line, target); err != nil {
+                return nil, fmt.Errorf("line %d: invalid %s: %w", r.line, kind.Type, err)
+        }
+        if entry.Header != nil && entry.Header.Version > Version {
+                return nil, fmt.Errorf("archive version %d is newer than supported version %d", entry.Header.Version, Version)
+        }
+        return &entry, nil
+}
@@ -0,0 +1,60 @@
+package decode
+
+import (
+        "encoding/base64"
+        "fmt"
+        "math/big"
+        "time"
+
+        "github.com/fxamacker/cbor/v2"
+)
+
+// cborMode decodes tags 0 and 1 to times and bignums to big.Int; cborValue
+// turns those and the other Go types it produces into JSON-encodable values
+var cborMode, _ = cbor.DecOptions{
+        MaxNestedLevels: maxNesting,
+        BigIntDec:       cbor.BigIntDecodePointer,
+}.DecMode()
+
+type cborDecoder struct{}
+
+func (cborDecoder) Decode(data []byte) (interface{}, error) {
+        var value interface{}
+        if err := cborMode.Unmarshal(data, &value); err != nil {
+                return nil, err
+        }
+        return cborValue(value), nil
+}
+
+func cborValue(value interface{}) interface{} {
+        switch v := value.(type) {
+        case map[interface{}]interface{}:
+                object := make(map[string]interface{}, len(v))
+                for key, item := range v {
+                        object[mapKey(cborValue(key))] = cborValue(item)
+                }
+                return object
+        case []interface{}:
+                for i := range v {
+                        v[i] = cborValue(v[i])
+                }
+                return v
+        case []byte:
+                return base64.StdEncoding.EncodeToString(v)
+        case float64:
+                return jsonFloat(v)
+        case float32:
+                return jsonFloat(float64(v))
+        case time.Time:
+                return v.UTC().Format(time.RFC3339Nano)
+        case *big.Int:
+                return v.String()
+        case big.Int:
+                return v.String()
+        case cbor.SimpleValue:
+                return fmt.Sprintf("simple(%d)", v)
+        case cbor.Tag:
+                return map[string]interface{}{"tag": v.Number, "value": cborValue(v.Content)}
+        }
+        return value
+}
@@ -0,0 +1,268 @@
+package decode
+
+import (
+        "bytes"
+        "compress/gzip"
+        "encoding/base64"
+        "encoding/binary"
+        "encoding/hex"
+        "encoding/json"
+        "fmt"
+        "io"
+        "math"
+        "strconv"
+        "strings"
+
+        "github.com/klauspost/compress/zstd"
+)
+
+// Decoder turns raw key or value bytes into a value that can be printed as
+// text or embedded in JSON output
+type Decoder interface {
+        Decode(data []byte) (interface{}, error)
+}
+
+// Names lists the decoder specs offered in help text and shell completion
+var Names = []string{
+        "string", "hex", "base64",
+        "int16", "int32", "int64", "uint16", "uint32", "uint64", "float32", "float64",
+        "uuid", "json", "msgpack", "cbor",
+        "gzip+json", "snappy+json", "zstd+json",
+}
+
+// Usage describes the decoder spec syntax for flag help
+const Usage = "string, hex, base64, int16/int32/int64, uint16/uint32/uint64, float32/float64 " +
+        "(append :le for little-endian), uuid, json, msgpack, cbor; prefix with gzip+, snappy+ or zstd+ " +
+        "to decompress first (e.g. zstd+json)"
+
+// Parse builds a decoder from a spec such as "int64", "int64:le" or "gzip+json".
+// Compression wrappers are applied left to right before the final decoder.
+func Parse(spec string) (Decoder, error) {
+        spec = strings.ToLower(strings.TrimSpace(spec))
+        if spec == "" {
+                spec = "string"
+        }
+
+        parts := strings.Split(spec, "+")
+        var decompressors []decompressor
+        for _, name := range parts[:len(parts)-1] {
+                d, ok := wrappers[name]
+                if !ok {
+                        return nil, fmt.Errorf("unknown compression wrapper '%s' in decoder '%s' (supported: gzip, snappy, zstd)", name, spec)
+                }
+                decompressors = append(decompressors, d)
+        }
+
+        last := parts[len(parts)-1]
+        if d, ok := wrappers[last]; ok {
+                // A bare wrapper such as "gzip" decompresses to text
+                decompressors = append(decompressors, d)
+                last = "string"
+        }
+
+        base, err := parseBase(last)
+        if err != nil {
+                return nil, err
+        }
+
+        if len(decompressors) == 0 {
+                return base, nil
+        }
+        return &decompressing{decompressors: decompressors, next: base}, nil
+}
+
+func parseBase(spec string) (Decoder, error) {
+        name, option, _ := strings.Cut(spec, ":")
+        var order binary.ByteOrder = binary.BigEndian
+        switch option {
+        case "", "be", "big":
+        case "le", "little":
+                order = binary.LittleEndian
+        default:
+                return nil, fmt.Errorf("unknown byte order '%s' in decoder '%s' (use :be or :le)", option, spec)
+        }
+
+        switch name {
+        case "string", "text":
+                return stringDecoder{}, nil
+        case "hex":
+                return hexDecoder{}, nil
+        case "base64":
+                return base64Decoder{}, nil
+        case "int16", "int32", "int64", "uint16", "uint32", "uint64", "float32", "float64":
+                return numberDecoder{kind: name, order: order}, nil
+        case "uuid":
+                return uuidDecoder{}, nil
+        case "json":
+                return jsonDecoder{}, nil
+        case "msgpack":
+                return msgpackDecoder{}, nil
+        case "cbor":
+                return cborDecoder{}, nil
+        }
+        return nil, fmt.Errorf("unknown decoder '%s' (supported: %s)", spec, Usage)
+}
+
+// Format renders a decoded value as text. Structured values are rendered as
+// JSON, indented when pretty is true.
+func Format(value interface{}, pretty bool) string {
+        switch v := value.(type) {
+        case string:
+                return v
+        case json.RawMessage:
+                if pretty {
+                        var out bytes.Buffer
+                        if err := json.Indent(&out, v, "", "  "); err == nil {
+                                return out.String()
+                        }
+                }
+                return string(v)
+        case nil:
+                return "null"
+        }
+
+        var raw []byte
+        var err error
+        if pretty {
+                raw, err = json.MarshalIndent(value, "", "  ")
+        } else {
+                raw, err = json.Marshal(value)
+        }
+        if err != nil {
+                return fmt.Sprintf("%v", value)
+        }
+        return string(raw)
+}
+
+type stringDecoder struct{}
+
+func (stringDecoder) Decode(data []byte) (interface{}, error) {
+        return string(data), nil
+}
+
+type hexDecoder struct{}
+
+func (hexDecoder) Decode(data []byte) (interface{}, error) {
+        return hex.EncodeToString(data), nil
+}
+
+type base64Decoder struct{}
+
+func (base64Decoder) Decode(data []byte) (interface{}, error) {
+        return base64.StdEncoding.EncodeToString(data), nil
+}
+
+type numberDecoder struct {
+        kind  string
+        order binary.ByteOrder
+}
+
+func (d numberDecoder) Decode(data []byte) (interface{}, error) {
+        size := map[string]int{
+                "int16": 2, "uint16": 2,
+                "int32": 4, "uint32": 4, "float32": 4,
+                "int64": 8, "uint64": 8, "float64": 8,
+        }[d.kind]
+        if len(data) != size {
+                return nil, fmt.Errorf("%s needs %d bytes, got %d", d.kind, size, len(data))
+        }
+
+        switch d.kind {
+        case "int16":
+                return int64(int16(d.order.Uint16(data))), nil
+        case "uint16":
+                return uint64(d.order.Uint16(data)), nil
+        case "int32":
+                return int64(int32(d.order.Uint32(data))), nil
+        case "uint32":
+                return uint64(d.order.Uint32(data)), nil
+        case "int64":
+                return int64(d.order.Uint64(data)), nil
+        case "uint64":
+                return d.order.Uint64(data), nil
+        case "float32":
+                return jsonFloat(float64(math.Float32frombits(d.order.Uint32(data)))), nil
+        default:
+                return jsonFloat(math.Float64frombits(d.order.Uint64(data))), nil
+        }
+}
+
+// jsonFloat keeps NaN and infinities printable, since encoding/json rejects them
+func jsonFloat(f float64) interface{} {
+        if math.IsNaN(f) || math.IsInf(f, 0) {
+                return strconv.FormatFloat(f, 'g', -1, 64)
+        }
+        return f
+}
+
+type uuidDecoder struct{}
+
+func (uuidDecoder) Decode(data []byte) (interface{}, error) {
+        if len(data) == 36 && bytes.Count(data, []byte("-")) == 4 {
+                // Already in canonical text form
+                return string(data), nil
+        }
+        if len(data) != 16 {
+                return nil, fmt.Errorf("uuid needs 16 bytes, got %d", len(data))
+        }
+        h := hex.EncodeToString(data)
+        return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], nil
+}
+
+type jsonDecoder struct{}
+
+func (jsonDecoder) Decode(data []byte) (interface{}, error) {
+        if !json.Valid(data) {
+                return nil, fmt.Errorf("invalid JSON")
+        }
+        return json.RawMessage(data), nil
+}
+
+// decompressor expands a compressed payload
+type decompressor func(data []byte) ([]byte, error)
+
+var wrappers = map[string]decompressor{
+        "gzip":   gunzip,
+        "snappy": unsnappy,
+        "zstd":   unzstd,
+}
+
+type decompressing struct {
+        decompressors []decompressor
+        next          Decoder
+}
+
+func (d *decompressing) Decode(data []byte) (interface{}, error) {
+        for _, decompress := range d.decompressors {
+                var err error
+                if data, err = decompress(data); err != nil {
+                        return nil, err
+                }
+        }
+        return d.next.Decode(data)
+}
+
+func gunzip(data []byte) ([]byte, error) {
+        reader, err := gzip.NewReader(bytes.NewReader(data))
+        if err != nil {
+                return nil, fmt.Errorf("gzip: %w", err)
+        }
+        defer reader.Close()
+
+        out, err := io.ReadAll(reader)
+        if err != nil {
+                return nil, fmt.Errorf("gzip: %w", err)
+        }
+        return out, nil
+}
+
+// zstdDecoder decodes whole payloads; it is safe for concurrent use
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+
+func unzstd(data []byte) ([]byte, error) {
+        out, err := zstdDecoder.DecodeAll(data, nil)
+        if err != nil {
+                return nil, fmt.Errorf("zstd: %w", err)
+        }
+        return out, nil
+}
@@ -0,0 +1,234 @@
+package decode
+
+import (
+        "bytes"
+        "encoding/json"
+        "math/big"
+        "strings"
+        "testing"
+        "time"
+
+        "github.com/fxamacker/cbor/v2"
+        "github.com/klauspost/compress/s2"
+        "github.com/klauspost/compress/snappy/xerial"
+        "github.com/klauspost/compress/zstd"
+        "github.com/vmihailenco/msgpack/v5"
+)
+
+const sample = `{"id":42,"name":"kafy","tags":["a","b"],"nested":{"ok":true,"ratio":0.5}}`
+
+// decodeText decodes data with the decoder spec and encodes the result as JSON
+func decodeText(t *testing.T, spec string, data []byte) string {
+        t.Helper()
+        decoder, err := Parse(spec)
+        if err != nil {
+                t.Fatalf("Parse(%q): %v", spec, err)
+        }
+        value, err := decoder.Decode(data)
+        if err != nil {
+                t.Fatalf("%s: %v", spec, err)
+        }
+        raw, err := json.Marshal(value)
+        if err != nil {
+                t.Fatalf("%s: decoded value is not JSON-encodable: %v", spec, err)
+        }
+        return string(raw)
+}
+
+// sameJSON compares two JSON texts regardless of key order
+func sameJSON(t *testing.T, got, want string) {
+        t.Helper()
+        var a, b interface{}
+        if err := json.Unmarshal([]byte(got), &a); err != nil {
+                t.Fatalf("output is not JSON: %v\n%s", err, got)
+        }
+        if err := json.Unmarshal([]byte(want), &b); err != nil {
+                t.Fatalf("expected value is not JSON: %v", err)
+        }
+        gotText, _ := json.Marshal(a)
+        wantText, _ := json.Marshal(b)
+        if !bytes.Equal(gotText, wantText) {
+                t.Errorf("got %s, want %s", gotText, wantText)
+        }
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+        payload := []byte(strings.Repeat(sample, 50))
+
+        var framed bytes.Buffer
+        writer := s2.NewWriter(&framed, s2.WriterSnappyCompat())
+        if _, err := writer.Write(payload); err != nil {
+                t.Fatal(err)
+        }
+        if err := writer.Close(); err != nil {
+                t.Fatal(err)
+        }
+
+        cases := map[string][]byte{
+                "block":  s2.EncodeSnappy(nil, payload),
+                "xerial": xerial.Encode(nil, payload),
+                "framed": framed.Bytes(),
+        }
+        for name, compressed := range cases {
+                t.Run(name, func(t *testing.T) {
+                        out, err := unsnappy(compressed)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if !bytes.Equal(out, payload) {
+                                t.Errorf("decompressed %d bytes, want %d", len(out), len(payload))
+                        }
+                })
+        }
+
+        if _, err := unsnappy([]byte{0xff, 0x00, 0x01}); err == nil {
+                t.Error("expected an error for corrupt input")
+        }
+}
+
+func TestZstdRoundTrip(t *testing.T) {
+        payload := []byte(strings.Repeat(sample, 50))
+        for _, level := range []zstd.EncoderLevel{zstd.SpeedFastest, zstd.SpeedDefault, zstd.SpeedBestCompression} {
+                encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+                if err != nil {
+                        t.Fatal(err)
+                }
+                compressed := encoder.EncodeAll(payload, nil)
+                encoder.Close()
+
+                out, err := unzstd(compressed)
+                if err != nil {
+                        t.Fatalf("level %s: %v", level, err)
+                }
+                if !bytes.Equal(out, payload) {
+                        t.Errorf("level %s: decompressed %d bytes, want %d", level, len(out), len(payload))
+                }
+        }
+
+        if _, err := unzstd([]byte("not zstd")); err == nil {
+                t.Error("expected an error for corrupt input")
+        }
+}
+
+func TestCompressedJSON(t *testing.T) {
+        encoder, err := zstd.NewWriter(nil)
+        if err != nil {
+                t.Fatal(err)
+        }
+        defer encoder.Close()
+
+        sameJSON(t, decodeText(t, "zstd+json", encoder.EncodeAll([]byte(sample), nil)), sample)
+        sameJSON(t, decodeText(t, "snappy+json", xerial.Encode(nil, []byte(sample))), sample)
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+        var document map[string]interface{}
+        if err := json.Unmarshal([]byte(sample), &document); err != nil {
+                t.Fatal(err)
+        }
+        encoded, err := msgpack.Marshal(document)
+        if err != nil {
+                t.Fatal(err)
+        }
+        sameJSON(t, decodeText(t, "msgpack", encoded), sample)
+
+        cases := []struct {
+                name  string
+                value interface{}
+                want  string
+        }{
+                {"negative int", int64(-300), `-300`},
+                {"uint64", uint64(1) << 63, `9223372036854775808`},
+                {"float32", float32(1.5), `1.5`},
+                {"binary", []byte{0, 1, 2}, `"AAEC"`},
+                {"timestamp", time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC), `"2024-05-01T12:30:00.0000005Z"`},
+                {"integer keys", map[int]string{1: "one"}, `{"1":"one"}`},
+                {"nil", nil, `null`},
+        }
+        for _, c := range cases {
+                t.Run(c.name, func(t *testing.T) {
+                        encoded, err := msgpack.Marshal(c.value)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        sameJSON(t, decodeText(t, "msgpack", encoded), c.want)
+                })
+        }
+}
+
+func TestMsgpackExtensionAndErrors(t *testing.T) {
+        // fixext 2 of application type 5
+        sameJSON(t, decodeText(t, "msgpack", []byte{0xd5, 0x05, 0xca, 0xfe}), `{"ext_type":5,"data":"yv4="}`)
+
+        decoder, _ := Parse("msgpack")
+        for name, data := range map[string][]byte{
+                "truncated": {0x92, 0x01},
+                "trailing":  {0x01, 0x02},
+                "huge map":  {0xdf, 0xff, 0xff, 0xff, 0xff},
+        } {
+                if _, err := decoder.Decode(data); err == nil {
+                        t.Errorf("%s: expected an error", name)
+                }
+        }
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+        var document map[string]interface{}
+        if err := json.Unmarshal([]byte(sample), &document); err != nil {
+                t.Fatal(err)
+        }
+        encoded, err := cbor.Marshal(document)
+        if err != nil {
+                t.Fatal(err)
+        }
+        sameJSON(t, decodeText(t, "cbor", encoded), sample)
+
+        huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+        timeMode, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano, TimeTag: cbor.EncTagRequired}.EncMode()
+        if err != nil {
+                t.Fatal(err)
+        }
+        cases := []struct {
+                name  string
+                value interface{}
+                want  string
+        }{
+                {"negative int", int64(-300), `-300`},
+                {"half float", float32(1.5), `1.5`},
+                {"binary", []byte{0, 1, 2}, `"AAEC"`},
+                {"bignum", huge, `"123456789012345678901234567890"`},
+                {"integer keys", map[int]string{1: "one"}, `{"1":"one"}`},
+                {"unknown tag", cbor.Tag{Number: 1000, Content: "x"}, `{"tag":1000,"value":"x"}`},
+                {"simple value", cbor.SimpleValue(16), `"simple(16)"`},
+        }
+        for _, c := range cases {
+                t.Run(c.name, func(t *testing.T) {
+                        encoded, err := cbor.Marshal(c.value)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        sameJSON(t, decodeText(t, "cbor", encoded), c.want)
+                })
+        }
+
+        t.Run("time tag", func(t *testing.T) {
+                encoded, err := timeMode.Marshal(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+                if err != nil {
+                        t.Fatal(err)
+                }
+                sameJSON(t, decodeText(t, "cbor", encoded), `"2024-05-01T12:30:00Z"`)
+        })
+}
+
+func TestCBORErrors(t *testing.T) {
+        decoder, _ := Parse("cbor")
+        for name, data := range map[string][]byte{
+                "truncated": {0x82, 0x01},
+                "trailing":  {0x01, 0x02},
+                "break":     {0xff},
+        } {
+                if _, err := decoder.Decode(data); err == nil {
+                        t.Errorf("%s: expected an error", name)
+                }
+        }
+}
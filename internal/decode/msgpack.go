@@ -0,0 +1,144 @@
+package decode
+
+import (
+        "bytes"
+        "encoding/base64"
+        "encoding/binary"
+        "errors"
+        "fmt"
+        "time"
+
+        "github.com/vmihailenco/msgpack/v5"
+        "github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+type msgpackDecoder struct{}
+
+func (msgpackDecoder) Decode(data []byte) (interface{}, error) {
+        reader := bytes.NewReader(data)
+        d := msgpack.NewDecoder(reader)
+        value, err := msgpackValue(d, reader, 0)
+        if err != nil {
+                return nil, fmt.Errorf("msgpack: %w", err)
+        }
+        if reader.Len() > 0 {
+                return nil, fmt.Errorf("msgpack: %d trailing bytes", reader.Len())
+        }
+        return value, nil
+}
+
+// maxNesting guards against stack exhaustion on hostile payloads
+const maxNesting = 512
+
+var errTruncated = errors.New("unexpected end of data")
+
+// msgpackValue walks one value with the library's decoder. Containers and
+// extensions are handled here so that maps with non-string keys and
+// application-defined extension types still decode to JSON-encodable values.
+func msgpackValue(d *msgpack.Decoder, reader *bytes.Reader, depth int) (interface{}, error) {
+        if depth > maxNesting {
+                return nil, errors.New("nesting too deep")
+        }
+        code, err := d.PeekCode()
+        if err != nil {
+                return nil, err
+        }
+
+        switch {
+        case msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32:
+                n, err := d.DecodeMapLen()
+                if err != nil {
+                        return nil, err
+                }
+                if n > reader.Len() {
+                        return nil, errTruncated
+                }
+                object := make(map[string]interface{}, n)
+                for i := 0; i < n; i++ {
+                        key, err := msgpackValue(d, reader, depth+1)
+                        if err != nil {
+                                return nil, err
+                        }
+                        value, err := msgpackValue(d, reader, depth+1)
+                        if err != nil {
+                                return nil, err
+                        }
+                        object[mapKey(key)] = value
+                }
+                return object, nil
+        case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+                n, err := d.DecodeArrayLen()
+                if err != nil {
+                        return nil, err
+                }
+                if n > reader.Len() {
+                        return nil, errTruncated
+                }
+                items := make([]interface{}, 0, n)
+                for i := 0; i < n; i++ {
+                        item, err := msgpackValue(d, reader, depth+1)
+                        if err != nil {
+                                return nil, err
+                        }
+                        items = append(items, item)
+                }
+                return items, nil
+        case msgpcode.IsExt(code):
+                extType, n, err := d.DecodeExtHeader()
+                if err != nil {
+                        return nil, err
+                }
+                if n > reader.Len() {
+                        return nil, errTruncated
+                }
+                payload := make([]byte, n)
+                if err := d.ReadFull(payload); err != nil {
+                        return nil, err
+                }
+                return msgpackExt(extType, payload), nil
+        }
+
+        value, err := d.DecodeInterface()
+        if err != nil {
+                return nil, err
+        }
+        switch v := value.(type) {
+        case []byte:
+                return base64.StdEncoding.EncodeToString(v), nil
+        case float32:
+                return jsonFloat(float64(v)), nil
+        case float64:
+                return jsonFloat(v), nil
+        }
+        return value, nil
+}
+
+// msgpackExt renders the predefined timestamp extension as RFC 3339 text and
+// any other extension as its type and base64 payload
+func msgpackExt(extType int8, payload []byte) interface{} {
+        if extType == -1 {
+                switch len(payload) {
+                case 4:
+                        return time.Unix(int64(binary.BigEndian.Uint32(payload)), 0).UTC().Format(time.RFC3339Nano)
+                case 8:
+                        v := binary.BigEndian.Uint64(payload)
+                        return time.Unix(int64(v&0x3ffffffff), int64(v>>34)).UTC().Format(time.RFC3339Nano)
+                case 12:
+                        nanos := binary.BigEndian.Uint32(payload)
+                        secs := int64(binary.BigEndian.Uint64(payload[4:]))
+                        return time.Unix(secs, int64(nanos)).UTC().Format(time.RFC3339Nano)
+                }
+        }
+        return map[string]interface{}{
+                "ext_type": extType,
+                "data":     base64.StdEncoding.EncodeToString(payload),
+        }
+}
+
+// mapKey renders a non-string map key so the result stays JSON-encodable
+func mapKey(key interface{}) string {
+        if s, ok := key.(string); ok {
+                return s
+        }
+        return Format(key, false)
+}
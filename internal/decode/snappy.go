@@ -0,0 +1,29 @@
+package decode
+
+import (
+        "bytes"
+        "fmt"
+        "io"
+
+        "github.com/klauspost/compress/s2"
+        "github.com/klauspost/compress/snappy/xerial"
+)
+
+// framedMagic is the stream identifier chunk of the snappy framing format
+var framedMagic = []byte{0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'}
+
+// unsnappy decompresses a snappy payload in raw block, xerial (snappy-java,
+// used by many Kafka clients) or framed form
+func unsnappy(data []byte) ([]byte, error) {
+        var out []byte
+        var err error
+        if bytes.HasPrefix(data, framedMagic) {
+                out, err = io.ReadAll(s2.NewReader(bytes.NewReader(data)))
+        } else {
+                out, err = xerial.Decode(data)
+        }
+        if err != nil {
+                return nil, fmt.Errorf("snappy: %w", err)
+        }
+        return out, nil
+}
@@ -0,0 +1,200 @@
+package exporter
+
+import (
+        "bytes"
+        "fmt"
+        "io"
+        "math"
+        "net/http"
+        "os"
+        "sort"
+        "strconv"
+        "strings"
+        "sync"
+        "time"
+)
+
+// Label is one name/value pair of a sample
+type Label struct {
+        Name  string
+        Value string
+}
+
+// Sample is one value of a metric family
+type Sample struct {
+        Labels []Label
+        Value  float64
+}
+
+// Family is a metric with its help text, type (gauge or counter) and samples
+type Family struct {
+        Name    string
+        Help    string
+        Type    string
+        Samples []Sample
+}
+
+// NewGauge creates an empty gauge family
+func NewGauge(name, help string) *Family {
+        return &Family{Name: name, Help: help, Type: "gauge"}
+}
+
+// NewCounter creates an empty counter family
+func NewCounter(name, help string) *Family {
+        return &Family{Name: name, Help: help, Type: "counter"}
+}
+
+// Add appends a sample. labels are name, value pairs.
+func (f *Family) Add(value float64, labels ...string) {
+        sample := Sample{Value: value}
+        for i := 0; i+1 < len(labels); i += 2 {
+                sample.Labels = append(sample.Labels, Label{Name: labels[i], Value: labels[i+1]})
+        }
+        f.Samples = append(f.Samples, sample)
+}
+
+// Collector gathers the metric families of one source, such as one cluster
+type Collector interface {
+        Name() string
+        Collect() ([]*Family, error)
+}
+
+// Exporter serves the metrics of its collectors in the Prometheus text
+// format. Collectors run concurrently and their output is cached for the
+// cache TTL, so frequent or parallel scrapes do not multiply the load on
+// the clusters.
+type Exporter struct {
+        collectors []Collector
+        cacheTTL   time.Duration
+
+        mu       sync.Mutex
+        cached   []byte
+        cachedAt time.Time
+}
+
+func New(cacheTTL time.Duration, collectors ...Collector) *Exporter {
+        return &Exporter{collectors: collectors, cacheTTL: cacheTTL}
+}
+
+// ServeHTTP writes the metrics, collecting them first when the cache is stale
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+        body := e.Gather()
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+        w.Write(body)
+}
+
+// Gather returns the exposition of every collector, from the cache when it is fresh
+func (e *Exporter) Gather() []byte {
+        e.mu.Lock()
+        defer e.mu.Unlock()
+
+        if e.cached != nil && time.Since(e.cachedAt) < e.cacheTTL {
+                return e.cached
+        }
+
+        up := NewGauge("kafy_up", "Whether the last collection from the source succeeded")
+        duration := NewGauge("kafy_collect_duration_seconds", "Time taken to collect the metrics of the source")
+        results := make([][]*Family, len(e.collectors))
+        var wg sync.WaitGroup
+        var mu sync.Mutex
+        for i, c := range e.collectors {
+                wg.Add(1)
+                go func(i int, c Collector) {
+                        defer wg.Done()
+                        started := time.Now()
+                        families, err := c.Collect()
+                        elapsed := time.Since(started).Seconds()
+
+                        mu.Lock()
+                        defer mu.Unlock()
+                        results[i] = families
+                        value := 1.0
+                        if err != nil {
+                                value = 0
+                                fmt.Fprintf(os.Stderr, "%s collection from %s failed: %v\n", time.Now().Format("15:04:05"), c.Name(), err)
+                        }
+                        up.Add(value, "source", c.Name())
+                        duration.Add(elapsed, "source", c.Name())
+                }(i, c)
+        }
+        wg.Wait()
+
+        all := []*Family{up, duration}
+        for _, families := range results {
+                all = append(all, families...)
+        }
+
+        var buf bytes.Buffer
+        Write(&buf, Merge(all))
+        e.cached, e.cachedAt = buf.Bytes(), time.Now()
+        return e.cached
+}
+
+// Merge combines families with the same name, as each family may only
+// appear once in an exposition, and sorts them by name
+func Merge(families []*Family) []*Family {
+        byName := make(map[string]*Family)
+        var merged []*Family
+        for _, f := range families {
+                if existing, ok := byName[f.Name]; ok {
+                        existing.Samples = append(existing.Samples, f.Samples...)
+                        continue
+                }
+                copied := *f
+                copied.Samples = append([]Sample(nil), f.Samples...)
+                byName[f.Name] = &copied
+                merged = append(merged, &copied)
+        }
+        sort.SliceStable(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+        return merged
+}
+
+// Write writes the families in the Prometheus text exposition format
+func Write(w io.Writer, families []*Family) error {
+        var b strings.Builder
+        for _, f := range families {
+                if len(f.Samples) == 0 {
+                        continue
+                }
+                fmt.Fprintf(&b, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+                fmt.Fprintf(&b, "# TYPE %s %s\n", f.Name, f.Type)
+                for _, s := range f.Samples {
+                        b.WriteString(f.Name)
+                        if len(s.Labels) > 0 {
+                                b.WriteByte('{')
+                                for i, l := range s.Labels {
+                                        if i > 0 {
+                                                b.WriteByte(',')
+                                        }
+                                        fmt.Fprintf(&b, "%s=\"%s\"", l.Name, escapeLabel(l.Value))
+                                }
+                                b.WriteByte('}')
+                        }
+                        b.WriteByte(' ')
+                        b.WriteString(formatValue(s.Value))
+                        b.WriteByte('\n')
+                }
+        }
+        _, err := io.WriteString(w, b.String())
+        return err
+}
+
+func formatValue(v float64) string {
+        switch {
+        case math.IsNaN(v):
+                return "NaN"
+        case math.IsInf(v, 1):
+                return "+Inf"
+        case math.IsInf(v, -1):
+                return "-Inf"
+        }
+        return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+        helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+        labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,280 @@
+package generator
+
+import (
+        "encoding/json"
+        "fmt"
+        "math"
+        "math/rand"
+        "strings"
+        "time"
+)
+
+// maxAvroDepth stops recursive schemas; optional (null) branches are taken beyond it
+const maxAvroDepth = 4
+
+// NewAvro builds a generator from an Avro schema. Values are emitted as plain JSON
+// (unions are not wrapped in {"type": value}), with realistic strings picked from
+// field names such as "email" or "city" and logical types such as timestamp-millis honoured.
+func NewAvro(schema []byte, seed int64) (*Generator, error) {
+        var parsed interface{}
+        if err := json.Unmarshal(schema, &parsed); err != nil {
+                return nil, fmt.Errorf("invalid Avro schema: %w", err)
+        }
+        compiler := &avroCompiler{names: make(map[string]*avroNamedNode)}
+        root, err := compiler.compile(parsed, "", "")
+        if err != nil {
+                return nil, fmt.Errorf("invalid Avro schema: %w", err)
+        }
+        return &Generator{root: root, r: rand.New(rand.NewSource(seed)), now: time.Now}, nil
+}
+
+type avroCompiler struct {
+        names map[string]*avroNamedNode
+}
+
+// avroNamedNode lets records refer to named types, including themselves
+type avroNamedNode struct {
+        node node
+}
+
+func (n *avroNamedNode) eval(ctx *genContext) (interface{}, error) {
+        return n.node.eval(ctx)
+}
+
+func (c *avroCompiler) compile(schema interface{}, fieldName, namespace string) (node, error) {
+        switch s := schema.(type) {
+        case string:
+                return c.compileType(s, map[string]interface{}{}, fieldName, namespace)
+        case []interface{}:
+                return c.compileUnion(s, fieldName, namespace)
+        case map[string]interface{}:
+                typeName, ok := s["type"]
+                if !ok {
+                        return nil, fmt.Errorf("schema object without a type")
+                }
+                if name, ok := typeName.(string); ok {
+                        return c.compileType(name, s, fieldName, namespace)
+                }
+                // {"type": {...}} or {"type": [...]} wraps another schema
+                return c.compile(typeName, fieldName, namespace)
+        }
+        return nil, fmt.Errorf("unexpected schema element %v", schema)
+}
+
+func (c *avroCompiler) compileType(typeName string, s map[string]interface{}, fieldName, namespace string) (node, error) {
+        logical, _ := s["logicalType"].(string)
+        fn := func(f func(ctx *genContext) interface{}) (node, error) {
+                return &exprNode{name: typeName, fn: func(ctx *genContext) (interface{}, error) { return f(ctx), nil }}, nil
+        }
+
+        switch typeName {
+        case "null":
+                return &literalNode{value: nil}, nil
+        case "boolean":
+                return fn(func(ctx *genContext) interface{} { return ctx.r.Intn(2) == 1 })
+        case "int", "long":
+                switch logical {
+                case "date":
+                        return fn(func(ctx *genContext) interface{} { return ctx.now.Unix() / 86400 })
+                case "time-millis":
+                        return fn(func(ctx *genContext) interface{} { return int64(ctx.r.Intn(86400000)) })
+                case "time-micros":
+                        return fn(func(ctx *genContext) interface{} { return ctx.r.Int63n(86400000000) })
+                case "timestamp-millis", "local-timestamp-millis":
+                        return fn(func(ctx *genContext) interface{} { return ctx.now.UnixMilli() })
+                case "timestamp-micros", "local-timestamp-micros":
+                        return fn(func(ctx *genContext) interface{} { return ctx.now.UnixMicro() })
+                }
+                max := int64(1000000)
+                if typeName == "int" {
+                        max = 10000
+                }
+                return fn(func(ctx *genContext) interface{} { return ctx.r.Int63n(max) })
+        case "float", "double":
+                return fn(func(ctx *genContext) interface{} { return math.Round(ctx.r.Float64()*100000) / 100 })
+        case "bytes":
+                if logical == "decimal" {
+                        scale, _ := s["scale"].(float64)
+                        return fn(func(ctx *genContext) interface{} {
+                                return fmt.Sprintf("%.*f", int(scale), ctx.r.Float64()*1000)
+                        })
+                }
+                return fn(func(ctx *genContext) interface{} { return randomString(ctx.r, 16, "0123456789abcdef") })
+        case "string":
+                if logical == "uuid" {
+                        return fn(func(ctx *genContext) interface{} { return fakeUUID(ctx.r) })
+                }
+                return fn(func(ctx *genContext) interface{} {
+                        if value, ok := fakeForFieldName(fieldName, ctx.r); ok {
+                                return value
+                        }
+                        return fakeWord(ctx.r) + "-" + randomString(ctx.r, 6, "abcdefghijklmnopqrstuvwxyz0123456789")
+                })
+        case "record", "error":
+                return c.compileRecord(s, namespace)
+        case "enum":
+                symbols, _ := s["symbols"].([]interface{})
+                if len(symbols) == 0 {
+                        return nil, fmt.Errorf("enum without symbols")
+                }
+                named := c.register(s, namespace)
+                named.node = &exprNode{name: "enum", fn: func(ctx *genContext) (interface{}, error) {
+                        return symbols[ctx.r.Intn(len(symbols))], nil
+                }}
+                return named, nil
+        case "fixed":
+                size, _ := s["size"].(float64)
+                named := c.register(s, namespace)
+                named.node = &exprNode{name: "fixed", fn: func(ctx *genContext) (interface{}, error) {
+                        return randomString(ctx.r, int(size), "0123456789abcdef"), nil
+                }}
+                return named, nil
+        case "array":
+                items, err := c.compile(s["items"], fieldName, namespace)
+                if err != nil {
+                        return nil, fmt.Errorf("array items: %w", err)
+                }
+                return &avroArrayNode{items: items}, nil
+        case "map":
+                values, err := c.compile(s["values"], fieldName, namespace)
+                if err != nil {
+                        return nil, fmt.Errorf("map values: %w", err)
+                }
+                return &avroMapNode{values: values}, nil
+        }
+
+        // A reference to a named type defined earlier
+        for _, name := range []string{typeName, namespace + "." + typeName} {
+                if named, ok := c.names[name]; ok {
+                        return named, nil
+                }
+        }
+        return nil, fmt.Errorf("unknown type '%s'", typeName)
+}
+
+func (c *avroCompiler) compileRecord(s map[string]interface{}, namespace string) (node, error) {
+        named := c.register(s, namespace)
+        if ns, ok := s["namespace"].(string); ok {
+                namespace = ns
+        }
+
+        fields, _ := s["fields"].([]interface{})
+        record := &objectNode{}
+        named.node = &avroRecordNode{record: record}
+        for _, f := range fields {
+                field, ok := f.(map[string]interface{})
+                if !ok {
+                        return nil, fmt.Errorf("record field must be an object")
+                }
+                name, _ := field["name"].(string)
+                if name == "" {
+                        return nil, fmt.Errorf("record field without a name")
+                }
+                value, err := c.compile(field["type"], name, namespace)
+                if err != nil {
+                        return nil, fmt.Errorf("field '%s': %w", name, err)
+                }
+                record.keys = append(record.keys, name)
+                record.values = append(record.values, value)
+        }
+        return named, nil
+}
+
+// register records a named type under its short and full names
+func (c *avroCompiler) register(s map[string]interface{}, namespace string) *avroNamedNode {
+        named := &avroNamedNode{}
+        name, _ := s["name"].(string)
+        if name == "" {
+                return named
+        }
+        if ns, ok := s["namespace"].(string); ok {
+                namespace = ns
+        }
+        c.names[name] = named
+        if namespace != "" && !strings.Contains(name, ".") {
+                c.names[namespace+"."+name] = named
+        }
+        return named
+}
+
+func (c *avroCompiler) compileUnion(branches []interface{}, fieldName, namespace string) (node, error) {
+        union := &avroUnionNode{}
+        for _, branch := range branches {
+                if branch == "null" {
+                        union.nullable = true
+                        continue
+                }
+                n, err := c.compile(branch, fieldName, namespace)
+                if err != nil {
+                        return nil, err
+                }
+                union.branches = append(union.branches, n)
+        }
+        return union, nil
+}
+
+// avroRecordNode tracks nesting depth so recursive schemas terminate
+type avroRecordNode struct {
+        record *objectNode
+}
+
+func (n *avroRecordNode) eval(ctx *genContext) (interface{}, error) {
+        ctx.depth++
+        defer func() { ctx.depth-- }()
+        if ctx.depth > maxAvroDepth*4 {
+                return nil, fmt.Errorf("schema recursion is too deep; make the recursive field optional with a null union")
+        }
+        return n.record.eval(ctx)
+}
+
+// avroUnionNode picks a branch; null is chosen 10% of the time, or always once the schema recursed too deep
+type avroUnionNode struct {
+        nullable bool
+        branches []node
+}
+
+func (n *avroUnionNode) eval(ctx *genContext) (interface{}, error) {
+        if n.nullable && (len(n.branches) == 0 || ctx.depth >= maxAvroDepth || ctx.r.Intn(10) == 0) {
+                return nil, nil
+        }
+        return n.branches[ctx.r.Intn(len(n.branches))].eval(ctx)
+}
+
+type avroArrayNode struct {
+        items node
+}
+
+func (n *avroArrayNode) eval(ctx *genContext) (interface{}, error) {
+        length := 1 + ctx.r.Intn(3)
+        if ctx.depth >= maxAvroDepth {
+                length = 0
+        }
+        values := make([]interface{}, length)
+        for i := range values {
+                value, err := n.items.eval(ctx)
+                if err != nil {
+                        return nil, err
+                }
+                values[i] = value
+        }
+        return values, nil
+}
+
+type avroMapNode struct {
+        values node
+}
+
+func (n *avroMapNode) eval(ctx *genContext) (interface{}, error) {
+        object := &orderedObject{values: make(map[string]interface{})}
+        if ctx.depth >= maxAvroDepth {
+                return object, nil
+        }
+        for i := 1 + ctx.r.Intn(3); i > 0; i-- {
+                value, err := n.values.eval(ctx)
+                if err != nil {
+                        return nil, err
+                }
+                object.set(fakeWord(ctx.r), value)
+        }
+        return object, nil
+}
@@ -0,0 +1,135 @@
+package generator
+
+import (
+        "fmt"
+        "math/rand"
+        "strings"
+)
+
+var (
+        firstNames = []string{
+                "James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth",
+                "William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Carlos", "Karen",
+                "Wei", "Aiko", "Priya", "Arjun", "Fatima", "Omar", "Sofia", "Lucas", "Emma", "Noah",
+                "Olivia", "Liam", "Mia", "Mateo", "Chloe", "Hiroshi", "Anna", "Ivan", "Zara", "Kwame",
+        }
+        lastNames = []string{
+                "Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+                "Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin",
+                "Lee", "Perez", "Thompson", "White", "Harris", "Clark", "Lewis", "Walker", "Young", "Allen",
+                "Chen", "Wang", "Kumar", "Singh", "Tanaka", "Sato", "Müller", "Schmidt", "Rossi", "Okafor",
+        }
+        emailDomains = []string{"example.com", "example.org", "example.net", "mail.test", "corp.test"}
+        cities       = []string{
+                "New York", "London", "Tokyo", "Paris", "Berlin", "Sydney", "Toronto", "Singapore", "Mumbai", "São Paulo",
+                "Chicago", "Madrid", "Amsterdam", "Seoul", "Dublin", "Austin", "Nairobi", "Stockholm", "Zurich", "Cape Town",
+        }
+        countries = []string{
+                "US", "GB", "JP", "FR", "DE", "AU", "CA", "SG", "IN", "BR",
+                "ES", "NL", "KR", "IE", "KE", "SE", "CH", "ZA", "MX", "IT",
+        }
+        companyWords = []string{
+                "Acme", "Globex", "Initech", "Umbrella", "Stark", "Wayne", "Hooli", "Vandelay", "Soylent", "Tyrell",
+                "Cyberdyne", "Wonka", "Aperture", "Massive", "Pied Piper", "Oscorp", "Gringotts", "Monarch", "Nakatomi", "Dunder",
+        }
+        companySuffixes = []string{"Inc", "LLC", "Ltd", "Group", "Corp", "Labs", "Systems", "Industries"}
+        words           = []string{
+                "alpha", "bravo", "cloud", "delta", "event", "fast", "green", "harbor", "index", "jolly",
+                "kernel", "lunar", "metric", "north", "orbit", "pixel", "quick", "river", "stream", "topic",
+                "union", "vector", "winter", "yellow", "zone", "order", "payment", "shipment", "invoice", "account",
+        }
+        streets = []string{"Main St", "Oak Ave", "Pine Rd", "Maple Dr", "Cedar Ln", "Elm St", "Park Ave", "Lake Rd", "Hill St", "River Rd"}
+)
+
+func pick(r *rand.Rand, list []string) string {
+        return list[r.Intn(len(list))]
+}
+
+func fakeFirstName(r *rand.Rand) string { return pick(r, firstNames) }
+func fakeLastName(r *rand.Rand) string  { return pick(r, lastNames) }
+func fakeName(r *rand.Rand) string      { return fakeFirstName(r) + " " + fakeLastName(r) }
+func fakeCity(r *rand.Rand) string      { return pick(r, cities) }
+func fakeCountry(r *rand.Rand) string   { return pick(r, countries) }
+func fakeWord(r *rand.Rand) string      { return pick(r, words) }
+
+func fakeEmail(r *rand.Rand) string {
+        return fmt.Sprintf("%s.%s%d@%s",
+                strings.ToLower(fakeFirstName(r)), strings.ToLower(fakeLastName(r)), r.Intn(1000), pick(r, emailDomains))
+}
+
+func fakeCompany(r *rand.Rand) string {
+        return pick(r, companyWords) + " " + pick(r, companySuffixes)
+}
+
+func fakePhone(r *rand.Rand) string {
+        return fmt.Sprintf("+1-%03d-555-%04d", 200+r.Intn(800), r.Intn(10000))
+}
+
+func fakeAddress(r *rand.Rand) string {
+        return fmt.Sprintf("%d %s", 1+r.Intn(9999), pick(r, streets))
+}
+
+func fakeIP(r *rand.Rand) string {
+        return fmt.Sprintf("10.%d.%d.%d", r.Intn(256), r.Intn(256), 1+r.Intn(254))
+}
+
+func fakeSentence(r *rand.Rand, n int) string {
+        parts := make([]string, n)
+        for i := range parts {
+                parts[i] = fakeWord(r)
+        }
+        sentence := strings.Join(parts, " ")
+        return strings.ToUpper(sentence[:1]) + sentence[1:] + "."
+}
+
+// fakeUUID returns a random (version 4) UUID
+func fakeUUID(r *rand.Rand) string {
+        var b [16]byte
+        r.Read(b[:])
+        b[6] = b[6]&0x0f | 0x40
+        b[8] = b[8]&0x3f | 0x80
+        return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func randomString(r *rand.Rand, n int, alphabet string) string {
+        b := make([]byte, n)
+        for i := range b {
+                b[i] = alphabet[r.Intn(len(alphabet))]
+        }
+        return string(b)
+}
+
+// fakeForFieldName guesses a realistic string for a field from its name, or returns false
+func fakeForFieldName(name string, r *rand.Rand) (string, bool) {
+        lower := strings.ToLower(name)
+        switch {
+        case strings.Contains(lower, "email"):
+                return fakeEmail(r), true
+        case lower == "first_name" || lower == "firstname":
+                return fakeFirstName(r), true
+        case lower == "last_name" || lower == "lastname" || lower == "surname":
+                return fakeLastName(r), true
+        case lower == "name" || strings.HasSuffix(lower, "_name") || strings.HasSuffix(lower, "name"):
+                if strings.Contains(lower, "company") {
+                        return fakeCompany(r), true
+                }
+                return fakeName(r), true
+        case strings.Contains(lower, "company"):
+                return fakeCompany(r), true
+        case strings.Contains(lower, "phone"):
+                return fakePhone(r), true
+        case strings.Contains(lower, "city"):
+                return fakeCity(r), true
+        case strings.Contains(lower, "country"):
+                return fakeCountry(r), true
+        case strings.Contains(lower, "address") || strings.Contains(lower, "street"):
+                return fakeAddress(r), true
+        case lower == "ip" || strings.HasSuffix(lower, "_ip"):
+                return fakeIP(r), true
+        case lower == "id" || lower == "uuid" || strings.HasSuffix(lower, "_id") || strings.HasSuffix(lower, "uuid"):
+                return fakeUUID(r), true
+        case strings.Contains(lower, "description") || strings.Contains(lower, "comment") || strings.Contains(lower, "message"):
+                return fakeSentence(r, 4+r.Intn(6)), true
+        }
+        return "", false
+}
@@ -0,0 +1,71 @@
+package generator
+
+import (
+        "fmt"
+        "math/rand"
+        "strconv"
+        "strings"
+)
+
+// KeyDistribution picks message keys from a fixed key space
+type KeyDistribution struct {
+        kind string
+        n    uint64
+        r    *rand.Rand
+        zipf *rand.Zipf
+}
+
+// ParseKeyDistribution accepts "sequential" (a new key per message), "uniform:N"
+// (N keys, equally likely) and "zipf:N" or "zipf:N,S" (N keys where a few hot keys
+// get most messages; larger S means more skew, default 1.2, must be above 1)
+func ParseKeyDistribution(spec string, seed int64) (*KeyDistribution, error) {
+        spec = strings.ToLower(strings.TrimSpace(spec))
+        kind, args, _ := strings.Cut(spec, ":")
+        invalid := fmt.Errorf("invalid key distribution '%s' (use sequential, uniform:1000 or zipf:1000,1.2)", spec)
+
+        d := &KeyDistribution{kind: kind, r: rand.New(rand.NewSource(seed))}
+        switch kind {
+        case "sequential":
+                if args != "" {
+                        return nil, invalid
+                }
+                return d, nil
+        case "uniform", "zipf":
+        default:
+                return nil, invalid
+        }
+
+        countArg, skewArg, hasSkew := strings.Cut(args, ",")
+        n, err := strconv.ParseUint(countArg, 10, 64)
+        if err != nil || n == 0 {
+                return nil, invalid
+        }
+        d.n = n
+        if kind == "uniform" {
+                if hasSkew {
+                        return nil, invalid
+                }
+                return d, nil
+        }
+
+        skew := 1.2
+        if hasSkew {
+                skew, err = strconv.ParseFloat(skewArg, 64)
+                if err != nil || skew <= 1 {
+                        return nil, fmt.Errorf("zipf skew must be a number above 1, got '%s'", skewArg)
+                }
+        }
+        d.zipf = rand.NewZipf(d.r, skew, 1, n-1)
+        return d, nil
+}
+
+// Key returns the key for message number index. Key "key-0" is the hottest under zipf.
+func (d *KeyDistribution) Key(index int) []byte {
+        switch d.kind {
+        case "uniform":
+                return []byte("key-" + strconv.FormatUint(uint64(d.r.Int63n(int64(d.n))), 10))
+        case "zipf":
+                return []byte("key-" + strconv.FormatUint(d.zipf.Uint64(), 10))
+        }
+        return []byte("key-" + strconv.Itoa(index))
+}
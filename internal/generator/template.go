@@ -0,0 +1,670 @@
+package generator
+
+import (
+        "bytes"
+        "encoding/json"
+        "fmt"
+        "io"
+        "math"
+        "math/rand"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// Generator produces synthetic message values from a template or an Avro schema
+type Generator struct {
+        root node
+        text bool // Top level of the template is a string: emit it without JSON quoting
+        r    *rand.Rand
+        now  func() time.Time
+}
+
+// genContext is the state for generating one message
+type genContext struct {
+        r     *rand.Rand
+        index int
+        now   time.Time
+        root  interface{} // Document generated so far, for ref
+        depth int         // Record nesting depth, for recursive Avro schemas
+}
+
+type node interface {
+        eval(ctx *genContext) (interface{}, error)
+}
+
+// Generate returns the value for message number index (zero based)
+func (g *Generator) Generate(index int) ([]byte, error) {
+        ctx := &genContext{r: g.r, index: index, now: g.now()}
+        value, err := g.root.eval(ctx)
+        if err != nil {
+                return nil, err
+        }
+        if g.text {
+                if s, ok := value.(string); ok {
+                        return []byte(s), nil
+                }
+        }
+
+        var buf bytes.Buffer
+        encoder := json.NewEncoder(&buf)
+        encoder.SetEscapeHTML(false)
+        if err := encoder.Encode(value); err != nil {
+                return nil, err
+        }
+        return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// NewTemplate compiles a JSON template. String values may contain {{function args}}
+// expressions; a string that is exactly one expression takes the expression's type,
+// so "{{int 1 100}}" becomes a number rather than a string.
+func NewTemplate(data []byte, seed int64) (*Generator, error) {
+        decoder := json.NewDecoder(bytes.NewReader(data))
+        decoder.UseNumber()
+        root, err := parseTemplateValue(decoder, "")
+        if err != nil {
+                return nil, fmt.Errorf("invalid template: %w", err)
+        }
+        if _, err := decoder.Token(); err != io.EOF {
+                return nil, fmt.Errorf("invalid template: unexpected data after the top-level value")
+        }
+
+        _, isString := root.(*stringNode)
+        _, isExpr := root.(*exprNode)
+        return &Generator{root: root, text: isString || isExpr, r: rand.New(rand.NewSource(seed)), now: time.Now}, nil
+}
+
+// parseTemplateValue reads one JSON value, keeping object key order
+func parseTemplateValue(decoder *json.Decoder, path string) (node, error) {
+        token, err := decoder.Token()
+        if err != nil {
+                return nil, err
+        }
+
+        switch t := token.(type) {
+        case json.Delim:
+                switch t {
+                case '{':
+                        object := &objectNode{}
+                        for decoder.More() {
+                                keyToken, err := decoder.Token()
+                                if err != nil {
+                                        return nil, err
+                                }
+                                key := keyToken.(string)
+                                child, err := parseTemplateValue(decoder, path+"."+key)
+                                if err != nil {
+                                        return nil, err
+                                }
+                                object.keys = append(object.keys, key)
+                                object.values = append(object.values, child)
+                        }
+                        if _, err := decoder.Token(); err != nil {
+                                return nil, err
+                        }
+                        return object, nil
+                case '[':
+                        array := &arrayNode{}
+                        for i := 0; decoder.More(); i++ {
+                                child, err := parseTemplateValue(decoder, fmt.Sprintf("%s.%d", path, i))
+                                if err != nil {
+                                        return nil, err
+                                }
+                                array.items = append(array.items, child)
+                        }
+                        if _, err := decoder.Token(); err != nil {
+                                return nil, err
+                        }
+                        return array, nil
+                }
+        case string:
+                n, err := parseTemplateString(t)
+                if err != nil {
+                        if path == "" {
+                                return nil, err
+                        }
+                        return nil, fmt.Errorf("%s: %w", path, err)
+                }
+                return n, nil
+        }
+        return &literalNode{value: token}, nil
+}
+
+// parseTemplateString splits a string into literal text and {{...}} expressions
+func parseTemplateString(s string) (node, error) {
+        var parts []node
+        rest := s
+        for {
+                start := strings.Index(rest, "{{")
+                if start < 0 {
+                        break
+                }
+                end := strings.Index(rest[start:], "}}")
+                if end < 0 {
+                        return nil, fmt.Errorf("unterminated expression in '%s'", s)
+                }
+                if start > 0 {
+                        parts = append(parts, &literalNode{value: rest[:start]})
+                }
+                expr, err := compileExpression(rest[start+2 : start+end])
+                if err != nil {
+                        return nil, err
+                }
+                parts = append(parts, expr)
+                rest = rest[start+end+2:]
+        }
+        if rest != "" {
+                parts = append(parts, &literalNode{value: rest})
+        }
+
+        if len(parts) == 1 {
+                if expr, ok := parts[0].(*exprNode); ok {
+                        return expr, nil
+                }
+        }
+        if len(parts) == 0 {
+                return &stringNode{}, nil
+        }
+        return &stringNode{parts: parts}, nil
+}
+
+type literalNode struct {
+        value interface{}
+}
+
+func (n *literalNode) eval(ctx *genContext) (interface{}, error) {
+        return n.value, nil
+}
+
+// stringNode concatenates literal text and expression results
+type stringNode struct {
+        parts []node
+}
+
+func (n *stringNode) eval(ctx *genContext) (interface{}, error) {
+        var sb strings.Builder
+        for _, part := range n.parts {
+                value, err := part.eval(ctx)
+                if err != nil {
+                        return nil, err
+                }
+                switch v := value.(type) {
+                case string:
+                        sb.WriteString(v)
+                default:
+                        data, err := json.Marshal(v)
+                        if err != nil {
+                                return nil, err
+                        }
+                        sb.Write(data)
+                }
+        }
+        return sb.String(), nil
+}
+
+type arrayNode struct {
+        items []node
+}
+
+func (n *arrayNode) eval(ctx *genContext) (interface{}, error) {
+        values := make([]interface{}, len(n.items))
+        for i, item := range n.items {
+                value, err := item.eval(ctx)
+                if err != nil {
+                        return nil, err
+                }
+                values[i] = value
+        }
+        return values, nil
+}
+
+type objectNode struct {
+        keys   []string
+        values []node
+}
+
+func (n *objectNode) eval(ctx *genContext) (interface{}, error) {
+        object := &orderedObject{values: make(map[string]interface{}, len(n.keys))}
+        if ctx.root == nil {
+                ctx.root = object
+        }
+        if err := n.fill(ctx, object); err != nil {
+                return nil, err
+        }
+        return object, nil
+}
+
+func (n *objectNode) fill(ctx *genContext, object *orderedObject) error {
+        for i, key := range n.keys {
+                // Nested objects are attached before they are filled so refs can see their earlier fields
+                if child, ok := n.values[i].(*objectNode); ok {
+                        nested := &orderedObject{values: make(map[string]interface{}, len(child.keys))}
+                        object.set(key, nested)
+                        if err := child.fill(ctx, nested); err != nil {
+                                return err
+                        }
+                        continue
+                }
+                value, err := n.values[i].eval(ctx)
+                if err != nil {
+                        return err
+                }
+                object.set(key, value)
+        }
+        return nil
+}
+
+// orderedObject is a JSON object that keeps template field order
+type orderedObject struct {
+        keys   []string
+        values map[string]interface{}
+}
+
+func (o *orderedObject) set(key string, value interface{}) {
+        if _, exists := o.values[key]; !exists {
+                o.keys = append(o.keys, key)
+        }
+        o.values[key] = value
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+        var buf bytes.Buffer
+        buf.WriteByte('{')
+        for i, key := range o.keys {
+                if i > 0 {
+                        buf.WriteByte(',')
+                }
+                name, err := json.Marshal(key)
+                if err != nil {
+                        return nil, err
+                }
+                buf.Write(name)
+                buf.WriteByte(':')
+
+                var value bytes.Buffer
+                encoder := json.NewEncoder(&value)
+                encoder.SetEscapeHTML(false)
+                if err := encoder.Encode(o.values[key]); err != nil {
+                        return nil, err
+                }
+                buf.Write(bytes.TrimRight(value.Bytes(), "\n"))
+        }
+        buf.WriteByte('}')
+        return buf.Bytes(), nil
+}
+
+// exprNode is a compiled {{function args}} expression
+type exprNode struct {
+        name string
+        fn   func(ctx *genContext) (interface{}, error)
+}
+
+func (n *exprNode) eval(ctx *genContext) (interface{}, error) {
+        return n.fn(ctx)
+}
+
+// Functions lists the template functions with their arguments, for help text
+var Functions = []string{
+        "uuid", "name", "first_name", "last_name", "email", "phone", "company", "city", "country", "address", "ip",
+        "word", "sentence [words]", "string [length]", "hex [length]",
+        "int <min> <max>", "float <min> <max> [decimals]", "bool [probability]",
+        "enum <value[:weight]>...", "seq [start] [step]", "index",
+        "timestamp [rfc3339|unix|unix_ms|<go layout>] [spread]", "date [spread]", "ref <.path>",
+}
+
+// compileExpression parses the inside of {{...}}
+func compileExpression(source string) (*exprNode, error) {
+        args, err := splitArgs(source)
+        if err != nil {
+                return nil, err
+        }
+        if len(args) == 0 {
+                return nil, fmt.Errorf("empty expression '{{%s}}'", source)
+        }
+        name, args := args[0], args[1:]
+
+        argCount := func(min, max int) error {
+                if len(args) < min || len(args) > max {
+                        return fmt.Errorf("wrong number of arguments in '{{%s}}'", strings.TrimSpace(source))
+                }
+                return nil
+        }
+        intArg := func(i int, def int64) (int64, error) {
+                if i >= len(args) {
+                        return def, nil
+                }
+                v, err := strconv.ParseInt(args[i], 10, 64)
+                if err != nil {
+                        return 0, fmt.Errorf("'%s' is not an integer in '{{%s}}'", args[i], strings.TrimSpace(source))
+                }
+                return v, nil
+        }
+        floatArg := func(i int, def float64) (float64, error) {
+                if i >= len(args) {
+                        return def, nil
+                }
+                v, err := strconv.ParseFloat(args[i], 64)
+                if err != nil {
+                        return 0, fmt.Errorf("'%s' is not a number in '{{%s}}'", args[i], strings.TrimSpace(source))
+                }
+                return v, nil
+        }
+        simple := func(f func(r *rand.Rand) string) (*exprNode, error) {
+                if err := argCount(0, 0); err != nil {
+                        return nil, err
+                }
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) { return f(ctx.r), nil }}, nil
+        }
+
+        switch name {
+        case "uuid":
+                return simple(fakeUUID)
+        case "name":
+                return simple(fakeName)
+        case "first_name":
+                return simple(fakeFirstName)
+        case "last_name":
+                return simple(fakeLastName)
+        case "email":
+                return simple(fakeEmail)
+        case "phone":
+                return simple(fakePhone)
+        case "company":
+                return simple(fakeCompany)
+        case "city":
+                return simple(fakeCity)
+        case "country":
+                return simple(fakeCountry)
+        case "address":
+                return simple(fakeAddress)
+        case "ip":
+                return simple(fakeIP)
+        case "word":
+                return simple(fakeWord)
+
+        case "sentence":
+                if err := argCount(0, 1); err != nil {
+                        return nil, err
+                }
+                n, err := intArg(0, 0)
+                if err != nil {
+                        return nil, err
+                }
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        words := int(n)
+                        if words <= 0 {
+                                words = 4 + ctx.r.Intn(8)
+                        }
+                        return fakeSentence(ctx.r, words), nil
+                }}, nil
+
+        case "string", "hex":
+                if err := argCount(0, 1); err != nil {
+                        return nil, err
+                }
+                n, err := intArg(0, 16)
+                if err != nil {
+                        return nil, err
+                }
+                alphabet := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+                if name == "hex" {
+                        alphabet = "0123456789abcdef"
+                }
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        return randomString(ctx.r, int(n), alphabet), nil
+                }}, nil
+
+        case "int":
+                if err := argCount(2, 2); err != nil {
+                        return nil, err
+                }
+                min, err := intArg(0, 0)
+                if err != nil {
+                        return nil, err
+                }
+                max, err := intArg(1, 0)
+                if err != nil {
+                        return nil, err
+                }
+                if max < min {
+                        return nil, fmt.Errorf("max is below min in '{{%s}}'", strings.TrimSpace(source))
+                }
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        return min + ctx.r.Int63n(max-min+1), nil
+                }}, nil
+
+        case "float":
+                if err := argCount(2, 3); err != nil {
+                        return nil, err
+                }
+                min, err := floatArg(0, 0)
+                if err != nil {
+                        return nil, err
+                }
+                max, err := floatArg(1, 0)
+                if err != nil {
+                        return nil, err
+                }
+                decimals, err := intArg(2, 2)
+                if err != nil {
+                        return nil, err
+                }
+                if max < min {
+                        return nil, fmt.Errorf("max is below min in '{{%s}}'", strings.TrimSpace(source))
+                }
+                scale := math.Pow(10, float64(decimals))
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        return math.Round((min+ctx.r.Float64()*(max-min))*scale) / scale, nil
+                }}, nil
+
+        case "bool":
+                if err := argCount(0, 1); err != nil {
+                        return nil, err
+                }
+                p, err := floatArg(0, 0.5)
+                if err != nil {
+                        return nil, err
+                }
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        return ctx.r.Float64() < p, nil
+                }}, nil
+
+        case "enum":
+                if len(args) == 0 {
+                        return nil, fmt.Errorf("enum needs at least one value in '{{%s}}'", strings.TrimSpace(source))
+                }
+                values, weights, total := parseWeightedValues(args)
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        n := ctx.r.Float64() * total
+                        for i, w := range weights {
+                                if n < w {
+                                        return values[i], nil
+                                }
+                                n -= w
+                        }
+                        return values[len(values)-1], nil
+                }}, nil
+
+        case "seq":
+                if err := argCount(0, 2); err != nil {
+                        return nil, err
+                }
+                start, err := intArg(0, 1)
+                if err != nil {
+                        return nil, err
+                }
+                step, err := intArg(1, 1)
+                if err != nil {
+                        return nil, err
+                }
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        return start + int64(ctx.index)*step, nil
+                }}, nil
+
+        case "index":
+                if err := argCount(0, 0); err != nil {
+                        return nil, err
+                }
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        return int64(ctx.index), nil
+                }}, nil
+
+        case "timestamp", "now", "date":
+                if err := argCount(0, 2); err != nil {
+                        return nil, err
+                }
+                layout := "rfc3339"
+                spreadArg := ""
+                if name == "date" {
+                        layout = "2006-01-02"
+                        if len(args) > 1 {
+                                return nil, fmt.Errorf("wrong number of arguments in '{{%s}}'", strings.TrimSpace(source))
+                        }
+                        if len(args) == 1 {
+                                spreadArg = args[0]
+                        }
+                } else {
+                        if len(args) > 0 {
+                                layout = args[0]
+                        }
+                        if len(args) > 1 {
+                                spreadArg = args[1]
+                        }
+                }
+                var spread time.Duration
+                if spreadArg != "" {
+                        d, err := parseSpread(spreadArg)
+                        if err != nil {
+                                return nil, fmt.Errorf("invalid spread '%s' in '{{%s}}' (use e.g. 24h, -7d or 30m)", spreadArg, strings.TrimSpace(source))
+                        }
+                        spread = d
+                }
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        t := ctx.now
+                        // A spread picks a random time between now and now+spread (negative spreads go back in time)
+                        if spread > 0 {
+                                t = t.Add(time.Duration(ctx.r.Int63n(int64(spread))))
+                        } else if spread < 0 {
+                                t = t.Add(-time.Duration(ctx.r.Int63n(int64(-spread))))
+                        }
+                        return formatTimestamp(t, layout), nil
+                }}, nil
+
+        case "ref":
+                if err := argCount(1, 1); err != nil {
+                        return nil, err
+                }
+                path := args[0]
+                return &exprNode{name: name, fn: func(ctx *genContext) (interface{}, error) {
+                        value, ok := lookupPath(ctx.root, path)
+                        if !ok {
+                                return nil, fmt.Errorf("ref '%s' does not point to an earlier field", path)
+                        }
+                        return value, nil
+                }}, nil
+        }
+        return nil, fmt.Errorf("unknown function '%s' (available: %s)", name, strings.Join(Functions, ", "))
+}
+
+// splitArgs splits an expression on whitespace; double-quoted arguments may contain spaces
+func splitArgs(source string) ([]string, error) {
+        var args []string
+        var current strings.Builder
+        inQuotes, hasArg := false, false
+        for i := 0; i < len(source); i++ {
+                c := source[i]
+                switch {
+                case c == '"':
+                        inQuotes = !inQuotes
+                        hasArg = true
+                case c == '\\' && inQuotes && i+1 < len(source):
+                        i++
+                        current.WriteByte(source[i])
+                case (c == ' ' || c == '\t') && !inQuotes:
+                        if hasArg {
+                                args = append(args, current.String())
+                                current.Reset()
+                                hasArg = false
+                        }
+                default:
+                        current.WriteByte(c)
+                        hasArg = true
+                }
+        }
+        if inQuotes {
+                return nil, fmt.Errorf("unterminated quote in '{{%s}}'", source)
+        }
+        if hasArg {
+                args = append(args, current.String())
+        }
+        return args, nil
+}
+
+// parseWeightedValues reads enum values with optional ":weight" suffixes
+func parseWeightedValues(args []string) ([]string, []float64, float64) {
+        values := make([]string, len(args))
+        weights := make([]float64, len(args))
+        var total float64
+        for i, arg := range args {
+                values[i], weights[i] = arg, 1
+                if idx := strings.LastIndex(arg, ":"); idx > 0 {
+                        if w, err := strconv.ParseFloat(arg[idx+1:], 64); err == nil && w >= 0 {
+                                values[i], weights[i] = arg[:idx], w
+                        }
+                }
+                total += weights[i]
+        }
+        return values, weights, total
+}
+
+// parseSpread accepts Go durations plus a "d" suffix for days
+func parseSpread(s string) (time.Duration, error) {
+        if strings.HasSuffix(s, "d") {
+                days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+                if err != nil {
+                        return 0, err
+                }
+                return time.Duration(days * float64(24*time.Hour)), nil
+        }
+        return time.ParseDuration(s)
+}
+
+func formatTimestamp(t time.Time, layout string) interface{} {
+        switch layout {
+        case "unix":
+                return t.Unix()
+        case "unix_ms":
+                return t.UnixMilli()
+        case "rfc3339":
+                return t.UTC().Format(time.RFC3339Nano)
+        }
+        return t.UTC().Format(layout)
+}
+
+// lookupPath follows a dotted path such as ".customer.id" or ".items.0" through generated values
+func lookupPath(document interface{}, path string) (interface{}, bool) {
+        path = strings.TrimPrefix(strings.TrimSpace(path), ".")
+        current := document
+        if path == "" {
+                return current, current != nil
+        }
+        for _, part := range strings.Split(path, ".") {
+                switch node := current.(type) {
+                case *orderedObject:
+                        next, ok := node.values[part]
+                        if !ok {
+                                return nil, false
+                        }
+                        current = next
+                case []interface{}:
+                        index, err := strconv.Atoi(part)
+                        if err != nil || index < 0 || index >= len(node) {
+                                return nil, false
+                        }
+                        current = node[index]
+                default:
+                        return nil, false
+                }
+        }
+        return current, true
+}
@@ -0,0 +1,190 @@
+package health
+
+import (
+        "fmt"
+        "sort"
+        "strings"
+        "time"
+)
+
+// GroupSample is the state of one consumer group at one point in time
+type GroupSample struct {
+        Time      time.Time
+        State     string // Stable, Empty, PreparingRebalance, CompletingRebalance, Dead, Unknown
+        Members   int
+        Assigned  map[string]map[int32]bool  // Partitions assigned to a member
+        Committed map[string]map[int32]int64 // Committed offsets
+        LogStart  map[string]map[int32]int64 // Low watermarks of the committed partitions
+        LogEnd    map[string]map[int32]int64 // High watermarks of the committed partitions
+}
+
+// Lag is the total lag of the committed partitions
+func (s GroupSample) Lag() int64 {
+        var total int64
+        for topic, partitions := range s.Committed {
+                for partition, committed := range partitions {
+                        if end, ok := s.LogEnd[topic][partition]; ok && end > committed {
+                                total += end - committed
+                        }
+                }
+        }
+        return total
+}
+
+// GroupSamples are the samples of one group, oldest first
+type GroupSamples struct {
+        Group   string
+        Samples []GroupSample
+        Error   string // Why the group could not be sampled, e.g. it was deleted meanwhile
+}
+
+// ToolGroupPrefix starts the names of the temporary groups kafy's own commands use
+const ToolGroupPrefix = "kafy-"
+
+// CheckGroups assesses consumer groups from one or more samples. partitions
+// holds the partition count of each topic. Lag growth and stuck rebalances
+// need at least two samples. kafy's own groups are skipped.
+func CheckGroups(groups []GroupSamples, partitions map[string]int) []Check {
+        sorted := append([]GroupSamples(nil), groups...)
+        sort.Slice(sorted, func(i, j int) bool { return sorted[i].Group < sorted[j].Group })
+
+        rebalancing := Check{Name: "group-rebalancing", Description: "Groups stuck in PreparingRebalance or CompletingRebalance"}
+        offsetLoss := Check{Name: "group-offset-loss", Description: "Committed offsets below the log start offset; records were deleted before being consumed"}
+        inactive := Check{Name: "group-inactive", Description: "Empty groups with committed offsets on topics that have records waiting"}
+        unassigned := Check{Name: "group-unassigned", Description: "Partitions of subscribed topics assigned to no member of a stable group"}
+        growth := Check{Name: "group-lag-growth", Description: "Groups whose lag grew in every sample"}
+        sampling := Check{Name: "group-sampling", Description: "Groups whose state or offsets could not be read"}
+
+        for _, g := range sorted {
+                if strings.HasPrefix(g.Group, ToolGroupPrefix) {
+                        continue
+                }
+                sampling.Checked++
+                if g.Error != "" {
+                        sampling.Add(Finding{Status: Warn, Group: g.Group, Message: g.Error})
+                        continue
+                }
+                if len(g.Samples) == 0 {
+                        continue
+                }
+                first, last := g.Samples[0], g.Samples[len(g.Samples)-1]
+                span := last.Time.Sub(first.Time).Round(time.Second)
+                finding := func(status Status, format string, args ...interface{}) Finding {
+                        return Finding{Status: status, Group: g.Group, Message: fmt.Sprintf(format, args...)}
+                }
+
+                rebalancing.Checked++
+                stuck := true
+                for _, s := range g.Samples {
+                        if !isRebalancing(s.State) {
+                                stuck = false
+                        }
+                }
+                if stuck {
+                        if len(g.Samples) > 1 {
+                                rebalancing.Add(finding(Fail, "in %s for %d samples over %s", last.State, len(g.Samples), span))
+                        } else {
+                                rebalancing.Add(finding(Warn, "in %s (sample more than once to tell whether it is stuck)", last.State))
+                        }
+                }
+
+                offsetLoss.Checked++
+                for _, topic := range sortedTopics(last.Committed) {
+                        for _, partition := range sortedPartitions(last.Committed[topic]) {
+                                committed := last.Committed[topic][partition]
+                                if start, ok := last.LogStart[topic][partition]; ok && committed < start {
+                                        p := partition
+                                        offsetLoss.Add(Finding{
+                                                Status:    Fail,
+                                                Group:     g.Group,
+                                                Topic:     topic,
+                                                Partition: &p,
+                                                Message:   fmt.Sprintf("committed offset %d is below log start %d (%d records lost)", committed, start, start-committed),
+                                        })
+                                }
+                        }
+                }
+
+                if last.State == "Empty" {
+                        inactive.Checked++
+                        var waiting []string
+                        var records int64
+                        for _, topic := range sortedTopics(last.Committed) {
+                                var topicLag int64
+                                for partition, committed := range last.Committed[topic] {
+                                        if end, ok := last.LogEnd[topic][partition]; ok && end > committed {
+                                                topicLag += end - committed
+                                        }
+                                }
+                                if topicLag > 0 {
+                                        waiting = append(waiting, topic)
+                                        records += topicLag
+                                }
+                        }
+                        if len(waiting) > 0 {
+                                inactive.Add(finding(Warn, "no members, %d records waiting on %s", records, strings.Join(waiting, ", ")))
+                        }
+                }
+
+                if last.State == "Stable" && len(last.Assigned) > 0 {
+                        unassigned.Checked++
+                        for _, topic := range sortedTopics(last.Assigned) {
+                                var missing []string
+                                for partition := int32(0); partition < int32(partitions[topic]); partition++ {
+                                        if !last.Assigned[topic][partition] {
+                                                missing = append(missing, fmt.Sprintf("%d", partition))
+                                        }
+                                }
+                                if len(missing) > 0 {
+                                        unassigned.Add(Finding{
+                                                Status:  Warn,
+                                                Group:   g.Group,
+                                                Topic:   topic,
+                                                Message: fmt.Sprintf("partitions %s of %s are assigned to no member", strings.Join(missing, ","), topic),
+                                        })
+                                }
+                        }
+                }
+
+                if len(g.Samples) > 1 {
+                        growth.Checked++
+                        grew := true
+                        for i := 1; i < len(g.Samples); i++ {
+                                if g.Samples[i].Lag() <= g.Samples[i-1].Lag() {
+                                        grew = false
+                                        break
+                                }
+                        }
+                        if grew {
+                                growth.Add(finding(Warn, "lag grew from %d to %d over %d samples in %s", first.Lag(), last.Lag(), len(g.Samples), span))
+                        }
+                }
+        }
+
+        if growth.Checked == 0 {
+                growth.Description += " (needs two or more samples)"
+        }
+        return []Check{sampling, rebalancing, offsetLoss, inactive, unassigned, growth}
+}
+
+func isRebalancing(state string) bool {
+        return state == "PreparingRebalance" || state == "CompletingRebalance"
+}
+
+func sortedTopics[V any](m map[string]V) []string {
+        topics := make([]string, 0, len(m))
+        for topic := range m {
+                topics = append(topics, topic)
+        }
+        sort.Strings(topics)
+        return topics
+}
+
+func sortedPartitions[V any](m map[int32]V) []int32 {
+        partitions := make([]int32, 0, len(m))
+        for partition := range m {
+                partitions = append(partitions, partition)
+        }
+        sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+        return partitions
+}
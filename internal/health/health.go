@@ -0,0 +1,137 @@
+package health
+
+import (
+        "fmt"
+        "sort"
+)
+
+// Status is the outcome of a check, ordered by severity
+type Status int
+
+const (
+        Pass Status = iota
+        Warn
+        Fail
+)
+
+func (s Status) String() string {
+        switch s {
+        case Warn:
+                return "warn"
+        case Fail:
+                return "fail"
+        default:
+                return "pass"
+        }
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+        return []byte(s.String()), nil
+}
+
+func (s *Status) UnmarshalText(text []byte) error {
+        switch string(text) {
+        case "pass":
+                *s = Pass
+        case "warn":
+                *s = Warn
+        case "fail":
+                *s = Fail
+        default:
+                return fmt.Errorf("unknown status '%s' (expected pass, warn or fail)", text)
+        }
+        return nil
+}
+
+// Finding is one problem a check found, about a topic, partition, group or broker
+type Finding struct {
+        Status    Status `json:"status" yaml:"status"`
+        Topic     string `json:"topic,omitempty" yaml:"topic,omitempty"`
+        Partition *int32 `json:"partition,omitempty" yaml:"partition,omitempty"`
+        Group     string `json:"group,omitempty" yaml:"group,omitempty"`
+        Broker    *int32 `json:"broker,omitempty" yaml:"broker,omitempty"`
+        Message   string `json:"message" yaml:"message"`
+}
+
+// Subject names what the finding is about, e.g. "orders/3", "broker 2" or "group billing orders/3"
+func (f Finding) Subject() string {
+        subject := f.Topic
+        if f.Partition != nil {
+                subject = fmt.Sprintf("%s/%d", f.Topic, *f.Partition)
+        }
+        switch {
+        case f.Group != "" && subject != "":
+                return "group " + f.Group + " " + subject
+        case f.Group != "":
+                return "group " + f.Group
+        case f.Broker != nil:
+                return fmt.Sprintf("broker %d", *f.Broker)
+        }
+        return subject
+}
+
+// Check is the result of one check: the worst status among its findings
+type Check struct {
+        Name        string    `json:"name" yaml:"name"`
+        Description string    `json:"description" yaml:"description"`
+        Status      Status    `json:"status" yaml:"status"`
+        Checked     int       `json:"checked" yaml:"checked"` // Number of items examined
+        Findings    []Finding `json:"findings,omitempty" yaml:"findings,omitempty"`
+}
+
+// Add records a finding and raises the check's status to match
+func (c *Check) Add(f Finding) {
+        c.Findings = append(c.Findings, f)
+        if f.Status > c.Status {
+                c.Status = f.Status
+        }
+}
+
+// Report is a set of checks
+type Report struct {
+        Status Status  `json:"status" yaml:"status"`
+        Checks []Check `json:"checks" yaml:"checks"`
+}
+
+// Add appends checks and updates the overall status
+func (r *Report) Add(checks ...Check) {
+        for _, c := range checks {
+                r.Checks = append(r.Checks, c)
+                if c.Status > r.Status {
+                        r.Status = c.Status
+                }
+        }
+}
+
+// RankedFinding is a finding together with the check that produced it
+type RankedFinding struct {
+        Check string
+        Finding
+}
+
+// Ranked returns every finding of the report, most severe first
+func (r *Report) Ranked() []RankedFinding {
+        var ranked []RankedFinding
+        for _, c := range r.Checks {
+                for _, f := range c.Findings {
+                        ranked = append(ranked, RankedFinding{Check: c.Name, Finding: f})
+                }
+        }
+        sort.SliceStable(ranked, func(i, j int) bool {
+                a, b := ranked[i], ranked[j]
+                if a.Status != b.Status {
+                        return a.Status > b.Status
+                }
+                if a.Group != b.Group {
+                        return a.Group < b.Group
+                }
+                if a.Topic != b.Topic {
+                        return a.Topic < b.Topic
+                }
+                if a.Partition != nil && b.Partition != nil {
+                        return *a.Partition < *b.Partition
+                }
+                return false
+        })
+        return ranked
+}
@@ -0,0 +1,88 @@
+package health
+
+import (
+        "encoding/xml"
+        "fmt"
+        "io"
+        "strconv"
+        "strings"
+        "time"
+)
+
+type junitSuites struct {
+        XMLName  xml.Name     `xml:"testsuites"`
+        Name     string       `xml:"name,attr"`
+        Tests    int          `xml:"tests,attr"`
+        Failures int          `xml:"failures,attr"`
+        Time     string       `xml:"time,attr"`
+        Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+        Name      string      `xml:"name,attr"`
+        Tests     int         `xml:"tests,attr"`
+        Failures  int         `xml:"failures,attr"`
+        Timestamp string      `xml:"timestamp,attr"`
+        Time      string      `xml:"time,attr"`
+        Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+        Name      string        `xml:"name,attr"`
+        ClassName string        `xml:"classname,attr"`
+        Failure   *junitFailure `xml:"failure,omitempty"`
+        SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+        Message string `xml:"message,attr"`
+        Type    string `xml:"type,attr"`
+        Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as a JUnit XML test suite with one test case
+// per check. Failed checks are test failures; warnings are passing test cases
+// whose findings appear in system-out, so they show up without failing the build.
+func WriteJUnit(w io.Writer, suite string, report *Report, started time.Time, elapsed time.Duration) error {
+        seconds := strconv.FormatFloat(elapsed.Seconds(), 'f', 3, 64)
+        s := junitSuite{Name: suite, Timestamp: started.UTC().Format("2006-01-02T15:04:05"), Time: seconds}
+        for _, c := range report.Checks {
+                tc := junitCase{Name: c.Name, ClassName: suite}
+                var lines []string
+                for _, f := range c.Findings {
+                        lines = append(lines, formatFinding(f))
+                }
+                switch c.Status {
+                case Fail:
+                        tc.Failure = &junitFailure{
+                                Message: fmt.Sprintf("%s: %d findings", c.Description, len(c.Findings)),
+                                Type:    "fail",
+                                Text:    strings.Join(lines, "\n"),
+                        }
+                        s.Failures++
+                case Warn:
+                        tc.SystemOut = "WARN " + c.Description + "\n" + strings.Join(lines, "\n")
+                }
+                s.Cases = append(s.Cases, tc)
+                s.Tests++
+        }
+
+        out := junitSuites{Name: suite, Tests: s.Tests, Failures: s.Failures, Time: seconds, Suites: []junitSuite{s}}
+        if _, err := io.WriteString(w, xml.Header); err != nil {
+                return err
+        }
+        encoder := xml.NewEncoder(w)
+        encoder.Indent("", "  ")
+        if err := encoder.Encode(out); err != nil {
+                return err
+        }
+        _, err := io.WriteString(w, "\n")
+        return err
+}
+
+func formatFinding(f Finding) string {
+        if subject := f.Subject(); subject != "" {
+                return fmt.Sprintf("[%s] %s: %s", f.Status, subject, f.Message)
+        }
+        return fmt.Sprintf("[%s] %s", f.Status, f.Message)
+}
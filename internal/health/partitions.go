@@ -0,0 +1,66 @@
+package health
+
+import (
+        "fmt"
+        "sort"
+)
+
+// PartitionState is the replica state of one partition from cluster metadata
+type PartitionState struct {
+        Topic     string
+        Partition int32
+        Leader    int32 // -1 when the partition has no leader
+        Replicas  []int32
+        Isr       []int32
+}
+
+// CheckPartitions runs the partition checks over every partition. minISR holds
+// each topic's min.insync.replicas; topics without an entry skip that check.
+// Preferred leader findings pass: leadership moves back on the next election,
+// so only a policy threshold makes them warn or fail.
+func CheckPartitions(partitions []PartitionState, minISR map[string]int) []Check {
+        sorted := append([]PartitionState(nil), partitions...)
+        sort.Slice(sorted, func(i, j int) bool {
+                if sorted[i].Topic != sorted[j].Topic {
+                        return sorted[i].Topic < sorted[j].Topic
+                }
+                return sorted[i].Partition < sorted[j].Partition
+        })
+
+        offline := Check{Name: "offline-partitions", Description: "Partitions without a leader cannot be read or written"}
+        underMinISR := Check{Name: "under-min-isr", Description: "Partitions with fewer in-sync replicas than min.insync.replicas reject acks=all writes"}
+        underReplicated := Check{Name: "under-replicated", Description: "Partitions whose in-sync replicas are fewer than their replicas"}
+        preferred := Check{Name: "preferred-leader", Description: "Partitions not led by their preferred (first) replica (informational)"}
+
+        for _, p := range sorted {
+                partition := p.Partition
+                finding := func(status Status, format string, args ...interface{}) Finding {
+                        return Finding{Status: status, Topic: p.Topic, Partition: &partition, Message: fmt.Sprintf(format, args...)}
+                }
+                offline.Checked++
+                underReplicated.Checked++
+                preferred.Checked++
+
+                if p.Leader < 0 {
+                        offline.Add(finding(Fail, "no leader (replicas %v)", p.Replicas))
+                        continue
+                }
+
+                if required, ok := minISR[p.Topic]; ok {
+                        underMinISR.Checked++
+                        if len(p.Isr) < required {
+                                underMinISR.Add(finding(Fail, "%d in-sync replicas %v, min.insync.replicas is %d", len(p.Isr), p.Isr, required))
+                        }
+                }
+
+                if len(p.Isr) < len(p.Replicas) {
+                        underReplicated.Add(finding(Warn, "%d of %d replicas in sync (replicas %v, isr %v)", len(p.Isr), len(p.Replicas), p.Replicas, p.Isr))
+                }
+
+                if len(p.Replicas) > 0 && p.Leader != p.Replicas[0] {
+                        preferred.Add(finding(Pass, "led by broker %d instead of preferred leader %d", p.Leader, p.Replicas[0]))
+                }
+        }
+
+        return []Check{offline, underMinISR, underReplicated, preferred}
+}
@@ -0,0 +1,122 @@
+package health
+
+import (
+        "bytes"
+        "errors"
+        "fmt"
+        "io"
+        "os"
+        "strconv"
+        "time"
+
+        "gopkg.in/yaml.v3"
+)
+
+// Policy holds the thresholds health check gates on. Unset thresholds keep
+// the default behaviour of their check.
+type Policy struct {
+        MinLiveBrokers       *Threshold    `yaml:"min-live-brokers"`
+        MaxOfflinePartitions *Threshold    `yaml:"max-offline-partitions"`
+        MaxUnderReplicated   *Threshold    `yaml:"max-under-replicated"`
+        MaxUnderMinISR       *Threshold    `yaml:"max-under-min-isr"`
+        MaxPreferredLeader   *Threshold    `yaml:"max-preferred-leader-imbalance"` // Partitions away from their preferred leader
+        MaxGroupLag          *Threshold    `yaml:"max-group-lag"`
+        Groups               []string      `yaml:"groups"` // Groups the group checks apply to (default: all)
+        GroupSamples         int           `yaml:"group-samples"`
+        GroupSampleInterval  time.Duration `yaml:"group-sample-interval"`
+        MaxPartitionSkew     *Threshold    `yaml:"max-partition-skew"`
+        RequiredTopics       []string      `yaml:"required-topics"`
+}
+
+// Threshold is a warn and/or fail limit. In YAML it is either a number (the
+// fail limit) or a mapping with warn and fail keys.
+type Threshold struct {
+        Warn *float64 `yaml:"warn"`
+        Fail *float64 `yaml:"fail"`
+}
+
+func (t *Threshold) UnmarshalYAML(node *yaml.Node) error {
+        if node.Kind == yaml.ScalarNode {
+                value, err := strconv.ParseFloat(node.Value, 64)
+                if err != nil {
+                        return fmt.Errorf("line %d: threshold must be a number or {warn: N, fail: N}", node.Line)
+                }
+                t.Fail = &value
+                return nil
+        }
+        type plain Threshold
+        var p plain
+        if err := node.Decode(&p); err != nil {
+                return err
+        }
+        if p.Warn == nil && p.Fail == nil {
+                return fmt.Errorf("line %d: threshold needs warn or fail", node.Line)
+        }
+        *t = Threshold(p)
+        return nil
+}
+
+// AtMost grades a value that should not exceed the threshold
+func (t *Threshold) AtMost(value float64) Status {
+        switch {
+        case t.Fail != nil && value > *t.Fail:
+                return Fail
+        case t.Warn != nil && value > *t.Warn:
+                return Warn
+        }
+        return Pass
+}
+
+// AtLeast grades a value that should not fall below the threshold
+func (t *Threshold) AtLeast(value float64) Status {
+        switch {
+        case t.Fail != nil && value < *t.Fail:
+                return Fail
+        case t.Warn != nil && value < *t.Warn:
+                return Warn
+        }
+        return Pass
+}
+
+func (t *Threshold) String() string {
+        format := func(v float64) string {
+                return strconv.FormatFloat(v, 'f', -1, 64)
+        }
+        switch {
+        case t.Warn != nil && t.Fail != nil:
+                return fmt.Sprintf("warn %s, fail %s", format(*t.Warn), format(*t.Fail))
+        case t.Warn != nil:
+                return "warn " + format(*t.Warn)
+        case t.Fail != nil:
+                return "fail " + format(*t.Fail)
+        }
+        return "none"
+}
+
+// LoadPolicy reads a policy file
+func LoadPolicy(path string) (*Policy, error) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+                return nil, fmt.Errorf("failed to read policy file: %w", err)
+        }
+        policy := &Policy{}
+        decoder := yaml.NewDecoder(bytes.NewReader(data))
+        decoder.KnownFields(true)
+        if err := decoder.Decode(policy); err != nil && !errors.Is(err, io.EOF) {
+                return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+        }
+        return policy, nil
+}
+
+// ApplyCount grades a check by its number of findings instead of by each
+// finding. The findings take the check's new status.
+func ApplyCount(c *Check, t *Threshold) {
+        if t == nil {
+                return
+        }
+        c.Status = t.AtMost(float64(len(c.Findings)))
+        c.Description += fmt.Sprintf(" (threshold: %s)", t)
+        for i := range c.Findings {
+                c.Findings[i].Status = c.Status
+        }
+}
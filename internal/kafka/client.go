@@ -4,6 +4,7 @@ import (
         "context"
         "fmt"
         "strconv"
+        "sync"
         "time"
 
         "kafy/config"
@@ -56,7 +57,107 @@ func (c *Client) CreateAdminClient() (*kafka.AdminClient, error) {
 }
 
 func (c *Client) CreateProducer() (*kafka.Producer, error) {
+        return c.CreateProducerWithOptions(ProducerOptions{})
+}
+
+// ProducerOptions tunes producer behaviour. Zero values keep the librdkafka defaults.
+type ProducerOptions struct {
+        Partitioner     string        // murmur2 (Java client compatible), consistent or random
+        Compression     string        // none, gzip, snappy, lz4 or zstd
+        Acks            string        // 0, 1 or all
+        Idempotent      bool          // Enable exactly-once, in-order delivery per partition
+        LingerMs        *int          // Time to wait for more messages before sending a batch
+        BatchSize       int           // Maximum batch size in bytes
+        MessageTimeout  time.Duration // Give up on a message after this long
+        Retries         *int          // Times a failed send is retried within MessageTimeout
+        TransactionalID string        // Enables transactions; implies idempotence
+}
+
+// Partitioners maps the partitioner names accepted by ProducerOptions to librdkafka's.
+// Keyless messages are spread randomly in every mode.
+var Partitioners = map[string]string{
+        "murmur2":    "murmur2_random",
+        "consistent": "consistent_random",
+        "random":     "random",
+}
+
+// CompressionTypes lists the supported producer compression codecs
+var CompressionTypes = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+
+// Validate checks option values before they reach librdkafka, which gives less helpful errors
+func (o ProducerOptions) Validate() error {
+        if o.Partitioner != "" {
+                if _, ok := Partitioners[o.Partitioner]; !ok {
+                        return fmt.Errorf("unknown partitioner '%s' (supported: murmur2, consistent, random)", o.Partitioner)
+                }
+        }
+        if o.Compression != "" {
+                valid := false
+                for _, codec := range CompressionTypes {
+                        if o.Compression == codec {
+                                valid = true
+                        }
+                }
+                if !valid {
+                        return fmt.Errorf("unknown compression '%s' (supported: none, gzip, snappy, lz4, zstd)", o.Compression)
+                }
+        }
+        switch o.Acks {
+        case "", "0", "1", "all", "-1":
+        default:
+                return fmt.Errorf("invalid acks '%s' (supported: 0, 1, all)", o.Acks)
+        }
+        if (o.Idempotent || o.TransactionalID != "") && o.Acks != "" && o.Acks != "all" && o.Acks != "-1" {
+                return fmt.Errorf("idempotent and transactional producers require acks=all")
+        }
+        if o.LingerMs != nil && *o.LingerMs < 0 {
+                return fmt.Errorf("linger must not be negative")
+        }
+        if o.BatchSize < 0 {
+                return fmt.Errorf("batch size must not be negative")
+        }
+        if o.MessageTimeout < 0 {
+                return fmt.Errorf("message timeout must not be negative")
+        }
+        if o.Retries != nil && *o.Retries < 0 {
+                return fmt.Errorf("retries must not be negative")
+        }
+        return nil
+}
+
+func (c *Client) CreateProducerWithOptions(opts ProducerOptions) (*kafka.Producer, error) {
+        if err := opts.Validate(); err != nil {
+                return nil, err
+        }
+
         config := c.GetKafkaConfig()
+        if opts.Partitioner != "" {
+                config["partitioner"] = Partitioners[opts.Partitioner]
+        }
+        if opts.Compression != "" {
+                config["compression.type"] = opts.Compression
+        }
+        if opts.Acks != "" {
+                config["acks"] = opts.Acks
+        }
+        if opts.Idempotent {
+                config["enable.idempotence"] = true
+        }
+        if opts.LingerMs != nil {
+                config["linger.ms"] = *opts.LingerMs
+        }
+        if opts.BatchSize > 0 {
+                config["batch.size"] = opts.BatchSize
+        }
+        if opts.MessageTimeout > 0 {
+                config["message.timeout.ms"] = int(opts.MessageTimeout / time.Millisecond)
+        }
+        if opts.Retries != nil {
+                config["retries"] = *opts.Retries
+        }
+        if opts.TransactionalID != "" {
+                config["transactional.id"] = opts.TransactionalID
+        }
         return kafka.NewProducer(&config)
 }
 
@@ -71,11 +172,42 @@ func (c *Client) CreateConsumer(groupID string) (*kafka.Consumer, error) {
 }
 
 func (c *Client) CreateConsumerWithOffset(groupID string, offsetReset string) (*kafka.Consumer, error) {
+        return c.CreateConsumerWithOptions(groupID, ConsumerOptions{OffsetReset: offsetReset})
+}
+
+// Isolation levels for ConsumerOptions
+const (
+        ReadCommitted   = "read_committed"
+        ReadUncommitted = "read_uncommitted"
+)
+
+// ConsumerOptions tunes consumer behaviour. Zero values keep the librdkafka defaults.
+type ConsumerOptions struct {
+        OffsetReset       string // earliest or latest
+        IsolationLevel    string // read_committed (default) or read_uncommitted
+        DisableAutoCommit bool   // Commit nothing, so a temporary group leaves no offsets behind
+}
+
+func (c *Client) CreateConsumerWithOptions(groupID string, opts ConsumerOptions) (*kafka.Consumer, error) {
+        switch opts.IsolationLevel {
+        case "", ReadCommitted, ReadUncommitted:
+        default:
+                return nil, fmt.Errorf("invalid isolation level '%s' (supported: read_committed, read_uncommitted)", opts.IsolationLevel)
+        }
+
         configMap := c.GetKafkaConfig()
         if groupID != "" {
                 configMap["group.id"] = groupID
         }
-        configMap["auto.offset.reset"] = offsetReset
+        if opts.OffsetReset != "" {
+                configMap["auto.offset.reset"] = opts.OffsetReset
+        }
+        if opts.IsolationLevel != "" {
+                configMap["isolation.level"] = opts.IsolationLevel
+        }
+        if opts.DisableAutoCommit {
+                configMap["enable.auto.commit"] = false
+        }
 
         return kafka.NewConsumer(&configMap)
 }
@@ -225,6 +357,48 @@ func (c *Client) DescribeTopic(topicName string) (*TopicInfo, error) {
         return topicInfo, nil
 }
 
+// ListTopicPartitions returns every topic with its partition replica state,
+// without the partition sizes DescribeTopic looks up
+func (c *Client) ListTopicPartitions() ([]TopicInfo, error) {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return nil, err
+        }
+        defer adminClient.Close()
+
+        metadata, err := adminClient.GetMetadata(nil, false, 10*1000)
+        if err != nil {
+                return nil, err
+        }
+
+        var topics []TopicInfo
+        for _, topic := range metadata.Topics {
+                if topic.Error.Code() != kafka.ErrNoError {
+                        continue
+                }
+
+                topicInfo := TopicInfo{
+                        Name:             topic.Topic,
+                        Partitions:       len(topic.Partitions),
+                        PartitionDetails: make([]PartitionInfo, 0, len(topic.Partitions)),
+                }
+                for _, partition := range topic.Partitions {
+                        topicInfo.PartitionDetails = append(topicInfo.PartitionDetails, PartitionInfo{
+                                ID:       partition.ID,
+                                Leader:   partition.Leader,
+                                Replicas: partition.Replicas,
+                                Isr:      partition.Isrs,
+                        })
+                }
+                if len(topic.Partitions) > 0 {
+                        topicInfo.Replicas = len(topic.Partitions[0].Replicas)
+                }
+                topics = append(topics, topicInfo)
+        }
+
+        return topics, nil
+}
+
 // getPartitionSizes retrieves the estimated disk size of each partition for a given topic
 func (c *Client) getPartitionSizes(topicName string, partitions []PartitionInfo) ([]int64, error) {
         // Create one consumer for all partition queries to improve efficiency
@@ -259,17 +433,36 @@ func (c *Client) getPartitionSizes(topicName string, partitions []PartitionInfo)
 }
 
 func (c *Client) CreateTopic(name string, partitions, replication int) error {
-        adminClient, err := c.CreateAdminClient()
-        if err != nil {
-                return err
-        }
-        defer adminClient.Close()
+        return c.CreateTopicWithConfigs(name, partitions, replication, nil)
+}
 
-        topicSpec := kafka.TopicSpecification{
+// CreateTopicWithConfigs creates a topic with per-topic config overrides
+func (c *Client) CreateTopicWithConfigs(name string, partitions, replication int, configs map[string]string) error {
+        return c.createTopic(kafka.TopicSpecification{
                 Topic:             name,
                 NumPartitions:     partitions,
                 ReplicationFactor: replication,
+                Config:            configs,
+        })
+}
+
+// CreateTopicWithAssignment creates a topic whose partition i is placed on the
+// brokers in assignment[i]; the first broker is the preferred leader
+func (c *Client) CreateTopicWithAssignment(name string, assignment [][]int32, configs map[string]string) error {
+        return c.createTopic(kafka.TopicSpecification{
+                Topic:             name,
+                NumPartitions:     len(assignment),
+                ReplicaAssignment: assignment,
+                Config:            configs,
+        })
+}
+
+func (c *Client) createTopic(topicSpec kafka.TopicSpecification) error {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return err
         }
+        defer adminClient.Close()
 
         ctx := context.Background()
         results, err := adminClient.CreateTopics(
@@ -291,17 +484,29 @@ func (c *Client) CreateTopic(name string, partitions, replication int) error {
 }
 
 func (c *Client) AlterTopicPartitions(topicName string, newPartitionCount int) error {
+        return c.createPartitions(kafka.PartitionsSpecification{
+                Topic:      topicName,
+                IncreaseTo: newPartitionCount,
+        })
+}
+
+// AddTopicPartitionsWithAssignment adds a partition per entry of assignment,
+// placed on its brokers; the first broker is the preferred leader
+func (c *Client) AddTopicPartitionsWithAssignment(topicName string, currentPartitionCount int, assignment [][]int32) error {
+        return c.createPartitions(kafka.PartitionsSpecification{
+                Topic:             topicName,
+                IncreaseTo:        currentPartitionCount + len(assignment),
+                ReplicaAssignment: assignment,
+        })
+}
+
+func (c *Client) createPartitions(partitionSpec kafka.PartitionsSpecification) error {
         adminClient, err := c.CreateAdminClient()
         if err != nil {
                 return err
         }
         defer adminClient.Close()
 
-        partitionSpec := kafka.PartitionsSpecification{
-                Topic:      topicName,
-                IncreaseTo: newPartitionCount,
-        }
-
         ctx := context.Background()
         results, err := adminClient.CreatePartitions(
                 ctx,
@@ -602,6 +807,194 @@ func (c *Client) DeleteConsumerGroup(groupID string) error {
         return nil
 }
 
+// GetConsumerGroupOffsets returns the committed offsets of a group (topic -> partition -> offset)
+func (c *Client) GetConsumerGroupOffsets(groupID string) (map[string]map[int32]int64, error) {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return nil, err
+        }
+        defer adminClient.Close()
+
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+
+        groupOffsets, err := adminClient.ListConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{
+                {
+                        Group: groupID,
+                },
+        }, nil)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get consumer group offsets: %w", err)
+        }
+
+        result := make(map[string]map[int32]int64)
+        for _, cg := range groupOffsets.ConsumerGroupsTopicPartitions {
+                for _, tp := range cg.Partitions {
+                        if tp.Topic == nil || tp.Error != nil || tp.Offset < 0 {
+                                continue
+                        }
+                        if result[*tp.Topic] == nil {
+                                result[*tp.Topic] = make(map[int32]int64)
+                        }
+                        result[*tp.Topic][tp.Partition] = int64(tp.Offset)
+                }
+        }
+        return result, nil
+}
+
+// GroupOffsetIndex holds committed offsets by topic, then group, then partition
+type GroupOffsetIndex map[string]map[string]map[int32]int64
+
+// BuildGroupOffsetIndex reads the committed offsets of the groups, up to
+// concurrency groups at a time, and indexes them by topic. Groups whose
+// offsets could not be read are returned with their error.
+func (c *Client) BuildGroupOffsetIndex(groups []string, concurrency int) (GroupOffsetIndex, map[string]error, error) {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return nil, nil, err
+        }
+        defer adminClient.Close()
+
+        if concurrency < 1 {
+                concurrency = 1
+        }
+
+        index := make(GroupOffsetIndex)
+        failed := make(map[string]error)
+        var mu sync.Mutex
+        var wg sync.WaitGroup
+        work := make(chan string)
+
+        for i := 0; i < concurrency; i++ {
+                wg.Add(1)
+                go func() {
+                        defer wg.Done()
+                        for group := range work {
+                                ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+                                result, err := adminClient.ListConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{{Group: group}}, nil)
+                                cancel()
+
+                                mu.Lock()
+                                if err != nil {
+                                        failed[group] = err
+                                        mu.Unlock()
+                                        continue
+                                }
+                                for _, cg := range result.ConsumerGroupsTopicPartitions {
+                                        for _, tp := range cg.Partitions {
+                                                if tp.Topic == nil || tp.Error != nil || tp.Offset < 0 {
+                                                        continue
+                                                }
+                                                if index[*tp.Topic] == nil {
+                                                        index[*tp.Topic] = make(map[string]map[int32]int64)
+                                                }
+                                                if index[*tp.Topic][group] == nil {
+                                                        index[*tp.Topic][group] = make(map[int32]int64)
+                                                }
+                                                index[*tp.Topic][group][tp.Partition] = int64(tp.Offset)
+                                        }
+                                }
+                                mu.Unlock()
+                        }
+                }()
+        }
+
+        for _, group := range groups {
+                work <- group
+        }
+        close(work)
+        wg.Wait()
+
+        return index, failed, nil
+}
+
+// AlterConsumerGroupOffsets commits offsets for a group. Kafka rejects this while the group has active members.
+func (c *Client) AlterConsumerGroupOffsets(groupID string, offsets map[string]map[int32]int64) error {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return err
+        }
+        defer adminClient.Close()
+
+        var partitions []kafka.TopicPartition
+        for topic, topicOffsets := range offsets {
+                topic := topic
+                for partition, offset := range topicOffsets {
+                        partitions = append(partitions, kafka.TopicPartition{
+                                Topic:     &topic,
+                                Partition: partition,
+                                Offset:    kafka.Offset(offset),
+                        })
+                }
+        }
+        if len(partitions) == 0 {
+                return nil
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+
+        result, err := adminClient.AlterConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{
+                {
+                        Group:      groupID,
+                        Partitions: partitions,
+                },
+        })
+        if err != nil {
+                return fmt.Errorf("failed to alter offsets for group '%s': %w", groupID, err)
+        }
+
+        for _, cg := range result.ConsumerGroupsTopicPartitions {
+                for _, tp := range cg.Partitions {
+                        if tp.Error != nil {
+                                return fmt.Errorf("failed to alter offsets for group '%s': %v", groupID, tp.Error)
+                        }
+                }
+        }
+        return nil
+}
+
+// DeleteRecords deletes every record before the given offset in each partition
+// (partition -> offset) and returns the new low watermarks
+func (c *Client) DeleteRecords(topic string, before map[int32]int64) (map[int32]int64, error) {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return nil, err
+        }
+        defer adminClient.Close()
+
+        var partitions []kafka.TopicPartition
+        for partition, offset := range before {
+                partitions = append(partitions, kafka.TopicPartition{
+                        Topic:     &topic,
+                        Partition: partition,
+                        Offset:    kafka.Offset(offset),
+                })
+        }
+        if len(partitions) == 0 {
+                return map[int32]int64{}, nil
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+        defer cancel()
+
+        result, err := adminClient.DeleteRecords(ctx, partitions)
+        if err != nil {
+                return nil, fmt.Errorf("failed to delete records from topic '%s': %w", topic, err)
+        }
+
+        lowWatermarks := make(map[int32]int64)
+        for _, r := range result.DeleteRecordsResults {
+                if r.TopicPartition.Error != nil {
+                        return lowWatermarks, fmt.Errorf("failed to delete records from partition %d: %v", r.TopicPartition.Partition, r.TopicPartition.Error)
+                }
+                if r.DeletedRecords != nil {
+                        lowWatermarks[r.TopicPartition.Partition] = int64(r.DeletedRecords.LowWatermark)
+                }
+        }
+        return lowWatermarks, nil
+}
+
 // Topic configuration methods
 func (c *Client) GetTopicConfig(topicName string) (map[string]string, error) {
         adminClient, err := c.CreateAdminClient()
@@ -644,6 +1037,79 @@ func (c *Client) GetTopicConfig(topicName string) (map[string]string, error) {
         return configs, nil
 }
 
+// GetTopicConfigOverrides returns only the configs set on the topic itself, not broker or default values
+func (c *Client) GetTopicConfigOverrides(topicName string) (map[string]string, error) {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return nil, err
+        }
+        defer adminClient.Close()
+
+        ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+        defer cancel()
+
+        results, err := adminClient.DescribeConfigs(ctx, []kafka.ConfigResource{
+                {
+                        Type: kafka.ResourceTopic,
+                        Name: topicName,
+                },
+        }, kafka.SetAdminRequestTimeout(20*time.Second))
+        if err != nil {
+                return nil, fmt.Errorf("failed to describe configs for topic %s: %w", topicName, err)
+        }
+        if len(results) == 0 {
+                return nil, fmt.Errorf("no configuration results returned for topic %s", topicName)
+        }
+        if results[0].Error.Code() != kafka.ErrNoError {
+                return nil, fmt.Errorf("error describing topic %s: %s", topicName, results[0].Error)
+        }
+
+        configs := make(map[string]string)
+        for name, entry := range results[0].Config {
+                if entry.Source == kafka.ConfigSourceDynamicTopic && !entry.IsSensitive {
+                        configs[name] = entry.Value
+                }
+        }
+        return configs, nil
+}
+
+// GetTopicsConfigValue returns the effective value of one config for each topic, in a single request.
+// Topics the broker could not describe are left out.
+func (c *Client) GetTopicsConfigValue(topicNames []string, name string) (map[string]string, error) {
+        values := make(map[string]string)
+        if len(topicNames) == 0 {
+                return values, nil
+        }
+
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return nil, err
+        }
+        defer adminClient.Close()
+
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+
+        resources := make([]kafka.ConfigResource, 0, len(topicNames))
+        for _, topicName := range topicNames {
+                resources = append(resources, kafka.ConfigResource{Type: kafka.ResourceTopic, Name: topicName})
+        }
+
+        results, err := adminClient.DescribeConfigs(ctx, resources, kafka.SetAdminRequestTimeout(30*time.Second))
+        if err != nil {
+                return nil, fmt.Errorf("failed to describe topic configs: %w", err)
+        }
+        for _, result := range results {
+                if result.Error.Code() != kafka.ErrNoError {
+                        continue
+                }
+                if entry, ok := result.Config[name]; ok {
+                        values[result.Name] = entry.Value
+                }
+        }
+        return values, nil
+}
+
 func (c *Client) SetTopicConfig(topicName, key, value string) error {
         adminClient, err := c.CreateAdminClient()
         if err != nil {
@@ -881,6 +1347,30 @@ func (c *Client) ListBrokers() ([]BrokerInfo, error) {
         return brokers, nil
 }
 
+// BrokerRacks returns the rack of each broker that has one
+func (c *Client) BrokerRacks() (map[int32]string, error) {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return nil, err
+        }
+        defer adminClient.Close()
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+        result, err := adminClient.DescribeCluster(ctx)
+        if err != nil {
+                return nil, err
+        }
+
+        racks := make(map[int32]string)
+        for _, node := range result.Nodes {
+                if node.Rack != nil && *node.Rack != "" {
+                        racks[int32(node.ID)] = *node.Rack
+                }
+        }
+        return racks, nil
+}
+
 func (c *Client) DescribeBroker(brokerID int32) (*BrokerInfo, error) {
         adminClient, err := c.CreateAdminClient()
         if err != nil {
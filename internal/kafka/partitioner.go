@@ -0,0 +1,49 @@
+package kafka
+
+// Murmur2 is the 32-bit murmur2 hash used by the Java client's default partitioner
+func Murmur2(data []byte) int32 {
+        const (
+                seed uint32 = 0x9747b28c
+                m    uint32 = 0x5bd1e995
+                r           = 24
+        )
+
+        length := len(data)
+        h := seed ^ uint32(length)
+
+        for i := 0; i+4 <= length; i += 4 {
+                k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+                k *= m
+                k ^= k >> r
+                k *= m
+                h *= m
+                h ^= k
+        }
+
+        tail := length &^ 3
+        switch length & 3 {
+        case 3:
+                h ^= uint32(data[tail+2]) << 16
+                fallthrough
+        case 2:
+                h ^= uint32(data[tail+1]) << 8
+                fallthrough
+        case 1:
+                h ^= uint32(data[tail])
+                h *= m
+        }
+
+        h ^= h >> 13
+        h *= m
+        h ^= h >> 15
+        return int32(h)
+}
+
+// JavaPartition returns the partition the Java client's default partitioner
+// picks for a non-null key
+func JavaPartition(key []byte, numPartitions int) int32 {
+        if numPartitions <= 0 {
+                return -1
+        }
+        return (Murmur2(key) & 0x7fffffff) % int32(numPartitions)
+}
@@ -0,0 +1,509 @@
+package kafka
+
+import (
+        "crypto/hmac"
+        "crypto/rand"
+        "crypto/sha256"
+        "crypto/sha512"
+        "crypto/tls"
+        "encoding/base64"
+        "encoding/binary"
+        "errors"
+        "fmt"
+        "hash"
+        "io"
+        "net"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// The protocol client speaks the Kafka wire protocol directly for the few
+// admin requests librdkafka does not expose. It supports the same security
+// settings as the librdkafka clients: TLS and SASL PLAIN or SCRAM.
+
+// API keys of the requests the protocol client sends
+const (
+        apiSaslHandshake               int16 = 17
+        apiDescribeLogDirs             int16 = 35
+        apiSaslAuthenticate            int16 = 36
+        apiAlterPartitionReassignments int16 = 45
+        apiListPartitionReassignments  int16 = 46
+)
+
+const protocolTimeout = 30 * time.Second
+
+// protocolConn is a connection to one broker
+type protocolConn struct {
+        conn          net.Conn
+        correlationID int32
+}
+
+// dialBroker connects and authenticates to a broker
+func (c *Client) dialBroker(host string, port int32) (*protocolConn, error) {
+        address := net.JoinHostPort(host, strconv.Itoa(int(port)))
+        dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+        var conn net.Conn
+        var err error
+        if c.cluster.Security != nil && c.cluster.Security.SSL {
+                conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: host})
+        } else {
+                conn, err = dialer.Dial("tcp", address)
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+        }
+
+        p := &protocolConn{conn: conn}
+        if c.cluster.Security != nil && c.cluster.Security.SASL != nil {
+                sasl := c.cluster.Security.SASL
+                if err := p.authenticate(sasl.Mechanism, sasl.Username, sasl.Password); err != nil {
+                        conn.Close()
+                        return nil, fmt.Errorf("SASL authentication with %s failed: %w", address, err)
+                }
+        }
+        return p, nil
+}
+
+func (p *protocolConn) Close() error {
+        return p.conn.Close()
+}
+
+// roundTrip sends a request with a v1 header and returns the response body after the correlation id
+func (p *protocolConn) roundTrip(apiKey, version int16, body []byte) (*decoder, error) {
+        return p.send(apiKey, version, body, false)
+}
+
+// flexibleRoundTrip sends a request of a flexible version, with a v2 header,
+// and returns the response body after the header tagged fields
+func (p *protocolConn) flexibleRoundTrip(apiKey, version int16, body []byte) (*decoder, error) {
+        return p.send(apiKey, version, body, true)
+}
+
+func (p *protocolConn) send(apiKey, version int16, body []byte, flexible bool) (*decoder, error) {
+        p.correlationID++
+        e := &encoder{}
+        e.int32(0) // Size, filled in below
+        e.int16(apiKey)
+        e.int16(version)
+        e.int32(p.correlationID)
+        e.string("kafy") // The client id stays a non-compact string in v2 headers
+        if flexible {
+                e.tags()
+        }
+        e.buf = append(e.buf, body...)
+        binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+
+        p.conn.SetDeadline(time.Now().Add(protocolTimeout))
+        if _, err := p.conn.Write(e.buf); err != nil {
+                return nil, err
+        }
+
+        var size [4]byte
+        if _, err := io.ReadFull(p.conn, size[:]); err != nil {
+                return nil, err
+        }
+        response := make([]byte, binary.BigEndian.Uint32(size[:]))
+        if _, err := io.ReadFull(p.conn, response); err != nil {
+                return nil, err
+        }
+        d := &decoder{buf: response}
+        if id := d.int32(); id != p.correlationID {
+                return nil, fmt.Errorf("response correlation id %d does not match request %d", id, p.correlationID)
+        }
+        if flexible {
+                d.skipTags()
+        }
+        return d, d.err
+}
+
+func (p *protocolConn) authenticate(mechanism, username, password string) error {
+        e := &encoder{}
+        e.string(mechanism)
+        d, err := p.roundTrip(apiSaslHandshake, 1, e.buf)
+        if err != nil {
+                return err
+        }
+        code := d.int16()
+        var enabled []string
+        for n := d.arrayLen(); n > 0; n-- {
+                enabled = append(enabled, d.string())
+        }
+        if d.err != nil {
+                return d.err
+        }
+        if code != 0 {
+                return fmt.Errorf("broker does not support %s (enabled: %s)", mechanism, strings.Join(enabled, ", "))
+        }
+
+        switch strings.ToUpper(mechanism) {
+        case "PLAIN":
+                _, err := p.saslAuthenticate([]byte("\x00" + username + "\x00" + password))
+                return err
+        case "SCRAM-SHA-256":
+                return p.scram(sha256.New, username, password)
+        case "SCRAM-SHA-512":
+                return p.scram(sha512.New, username, password)
+        }
+        return fmt.Errorf("SASL mechanism %s is not supported for this request", mechanism)
+}
+
+func (p *protocolConn) saslAuthenticate(auth []byte) ([]byte, error) {
+        e := &encoder{}
+        e.bytes(auth)
+        d, err := p.roundTrip(apiSaslAuthenticate, 1, e.buf)
+        if err != nil {
+                return nil, err
+        }
+        code := d.int16()
+        message := d.nullableString()
+        response := d.bytes()
+        if d.err != nil {
+                return nil, d.err
+        }
+        if code != 0 {
+                return nil, fmt.Errorf("%s (error code %d)", message, code)
+        }
+        return response, nil
+}
+
+// scramNonce returns a random client nonce
+var scramNonce = func() (string, error) {
+        nonce := make([]byte, 24)
+        if _, err := rand.Read(nonce); err != nil {
+                return "", err
+        }
+        return base64.RawStdEncoding.EncodeToString(nonce), nil
+}
+
+// scram runs the SCRAM exchange of RFC 5802
+func (p *protocolConn) scram(newHash func() hash.Hash, username, password string) error {
+        clientNonce, err := scramNonce()
+        if err != nil {
+                return err
+        }
+        user := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(username)
+        clientFirstBare := "n=" + user + ",r=" + clientNonce
+
+        serverFirst, err := p.saslAuthenticate([]byte("n,," + clientFirstBare))
+        if err != nil {
+                return err
+        }
+        attrs := scramAttributes(string(serverFirst))
+        salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+        if err != nil {
+                return fmt.Errorf("invalid SCRAM salt: %w", err)
+        }
+        iterations, err := strconv.Atoi(attrs["i"])
+        if err != nil || iterations < 1 {
+                return fmt.Errorf("invalid SCRAM iteration count '%s'", attrs["i"])
+        }
+        if !strings.HasPrefix(attrs["r"], clientNonce) {
+                return errors.New("SCRAM server nonce does not extend the client nonce")
+        }
+
+        mac := func(key []byte, data string) []byte {
+                h := hmac.New(newHash, key)
+                h.Write([]byte(data))
+                return h.Sum(nil)
+        }
+        salted := pbkdf2(newHash, []byte(password), salt, iterations)
+        clientKey := mac(salted, "Client Key")
+        h := newHash()
+        h.Write(clientKey)
+        storedKey := h.Sum(nil)
+
+        clientFinalWithoutProof := "c=biws,r=" + attrs["r"]
+        authMessage := clientFirstBare + "," + string(serverFirst) + "," + clientFinalWithoutProof
+        signature := mac(storedKey, authMessage)
+        proof := make([]byte, len(clientKey))
+        for i := range clientKey {
+                proof[i] = clientKey[i] ^ signature[i]
+        }
+
+        serverFinal, err := p.saslAuthenticate([]byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)))
+        if err != nil {
+                return err
+        }
+        final := scramAttributes(string(serverFinal))
+        if final["e"] != "" {
+                return fmt.Errorf("SCRAM: %s", final["e"])
+        }
+        expected := base64.StdEncoding.EncodeToString(mac(mac(salted, "Server Key"), authMessage))
+        if !hmac.Equal([]byte(final["v"]), []byte(expected)) {
+                return errors.New("SCRAM server signature does not match")
+        }
+        return nil
+}
+
+func scramAttributes(message string) map[string]string {
+        attrs := make(map[string]string)
+        for _, field := range strings.Split(message, ",") {
+                if key, value, ok := strings.Cut(field, "="); ok {
+                        attrs[key] = value
+                }
+        }
+        return attrs
+}
+
+func pbkdf2(newHash func() hash.Hash, password, salt []byte, iterations int) []byte {
+        prf := hmac.New(newHash, password)
+        prf.Write(salt)
+        prf.Write([]byte{0, 0, 0, 1})
+        u := prf.Sum(nil)
+        result := append([]byte(nil), u...)
+        for i := 1; i < iterations; i++ {
+                prf.Reset()
+                prf.Write(u)
+                u = prf.Sum(u[:0])
+                for j := range result {
+                        result[j] ^= u[j]
+                }
+        }
+        return result
+}
+
+// encoder builds request bodies
+type encoder struct {
+        buf []byte
+}
+
+func (e *encoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *encoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *encoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *encoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *encoder) string(s string) {
+        e.int16(int16(len(s)))
+        e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+        e.int32(int32(len(b)))
+        e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) uvarint(v uint64) { e.buf = binary.AppendUvarint(e.buf, v) }
+
+func (e *encoder) compactString(s string) {
+        e.uvarint(uint64(len(s)) + 1)
+        e.buf = append(e.buf, s...)
+}
+
+// compactArrayLen writes the length of a compact array; -1 writes a null array
+func (e *encoder) compactArrayLen(n int) {
+        e.uvarint(uint64(n + 1))
+}
+
+// tags writes an empty tagged field section
+func (e *encoder) tags() { e.uvarint(0) }
+
+// decoder reads response bodies. The first error is kept and later reads return zero values.
+type decoder struct {
+        buf []byte
+        err error
+}
+
+func (d *decoder) take(n int) []byte {
+        if d.err != nil {
+                return nil
+        }
+        if n < 0 || n > len(d.buf) {
+                d.err = errors.New("truncated response")
+                return nil
+        }
+        b := d.buf[:n]
+        d.buf = d.buf[n:]
+        return b
+}
+
+func (d *decoder) int8() int8 {
+        if b := d.take(1); b != nil {
+                return int8(b[0])
+        }
+        return 0
+}
+
+func (d *decoder) int16() int16 {
+        if b := d.take(2); b != nil {
+                return int16(binary.BigEndian.Uint16(b))
+        }
+        return 0
+}
+
+func (d *decoder) int32() int32 {
+        if b := d.take(4); b != nil {
+                return int32(binary.BigEndian.Uint32(b))
+        }
+        return 0
+}
+
+func (d *decoder) int64() int64 {
+        if b := d.take(8); b != nil {
+                return int64(binary.BigEndian.Uint64(b))
+        }
+        return 0
+}
+
+func (d *decoder) bool() bool {
+        return d.int8() != 0
+}
+
+func (d *decoder) string() string {
+        return string(d.take(int(d.int16())))
+}
+
+func (d *decoder) nullableString() string {
+        n := d.int16()
+        if n < 0 {
+                return ""
+        }
+        return string(d.take(int(n)))
+}
+
+func (d *decoder) bytes() []byte {
+        n := d.int32()
+        if n < 0 {
+                return nil
+        }
+        return d.take(int(n))
+}
+
+func (d *decoder) uvarint() uint64 {
+        if d.err != nil {
+                return 0
+        }
+        v, n := binary.Uvarint(d.buf)
+        if n <= 0 {
+                d.err = errors.New("truncated response")
+                return 0
+        }
+        d.buf = d.buf[n:]
+        return v
+}
+
+// compactString reads a compact string; null strings read as ""
+func (d *decoder) compactString() string {
+        n := d.uvarint()
+        if n == 0 {
+                return ""
+        }
+        return string(d.take(int(n - 1)))
+}
+
+// compactArrayLen reads a compact array length; null arrays have length 0
+func (d *decoder) compactArrayLen() int {
+        n := int(d.uvarint()) - 1
+        if n < 0 || d.err != nil {
+                return 0
+        }
+        if n > len(d.buf) {
+                d.err = errors.New("truncated response")
+                return 0
+        }
+        return n
+}
+
+func (d *decoder) compactInt32s() []int32 {
+        var values []int32
+        for n := d.compactArrayLen(); n > 0; n-- {
+                values = append(values, d.int32())
+        }
+        return values
+}
+
+// skipTags skips a tagged field section; kafy reads no tagged fields
+func (d *decoder) skipTags() {
+        for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+                d.uvarint() // Tag
+                d.take(int(d.uvarint()))
+        }
+}
+
+// arrayLen reads an array length; null arrays have length 0
+func (d *decoder) arrayLen() int {
+        n := d.int32()
+        if n < 0 || d.err != nil {
+                return 0
+        }
+        if int(n) > len(d.buf) {
+                d.err = errors.New("truncated response")
+                return 0
+        }
+        return int(n)
+}
+
+// ReplicaLogDir is the size of one replica in a broker log directory
+type ReplicaLogDir struct {
+        Broker    int32
+        Dir       string
+        Topic     string
+        Partition int32
+        Size      int64
+        OffsetLag int64 // How far a future replica is behind the current one
+        Future    bool  // Replica being moved to this directory
+}
+
+// DescribeLogDirs reads the log directories of every broker, one broker at a
+// time. Brokers that cannot be reached are returned with their error.
+func (c *Client) DescribeLogDirs(brokers []BrokerInfo) ([]ReplicaLogDir, map[int32]error) {
+        var replicas []ReplicaLogDir
+        failed := make(map[int32]error)
+        for _, broker := range brokers {
+                found, err := c.describeBrokerLogDirs(broker)
+                if err != nil {
+                        failed[broker.ID] = err
+                        continue
+                }
+                replicas = append(replicas, found...)
+        }
+        return replicas, failed
+}
+
+func (c *Client) describeBrokerLogDirs(broker BrokerInfo) ([]ReplicaLogDir, error) {
+        conn, err := c.dialBroker(broker.Host, broker.Port)
+        if err != nil {
+                return nil, err
+        }
+        defer conn.Close()
+
+        e := &encoder{}
+        e.int32(-1) // All topics
+        d, err := conn.roundTrip(apiDescribeLogDirs, 1, e.buf)
+        if err != nil {
+                return nil, fmt.Errorf("DescribeLogDirs on broker %d: %w", broker.ID, err)
+        }
+        replicas, err := readLogDirs(d, broker.ID)
+        if err != nil {
+                return nil, fmt.Errorf("DescribeLogDirs on broker %d: %w", broker.ID, err)
+        }
+        return replicas, nil
+}
+
+// readLogDirs decodes a DescribeLogDirs v1 response; directories with errors are left out
+func readLogDirs(d *decoder, broker int32) ([]ReplicaLogDir, error) {
+        d.int32() // Throttle time
+        var replicas []ReplicaLogDir
+        for dirs := d.arrayLen(); dirs > 0; dirs-- {
+                code := d.int16()
+                dir := d.string()
+                for topics := d.arrayLen(); topics > 0; topics-- {
+                        topic := d.string()
+                        for partitions := d.arrayLen(); partitions > 0; partitions-- {
+                                r := ReplicaLogDir{Broker: broker, Dir: dir, Topic: topic}
+                                r.Partition = d.int32()
+                                r.Size = d.int64()
+                                r.OffsetLag = d.int64()
+                                r.Future = d.bool()
+                                if code == 0 {
+                                        replicas = append(replicas, r)
+                                }
+                        }
+                }
+        }
+        if d.err != nil {
+                return nil, d.err
+        }
+        return replicas, nil
+}
@@ -0,0 +1,477 @@
+package kafka
+
+import (
+        "bytes"
+        "crypto/sha1"
+        "crypto/sha256"
+        "encoding/binary"
+        "encoding/hex"
+        "io"
+        "net"
+        "reflect"
+        "strings"
+        "testing"
+)
+
+// fakeBroker serves the requests of the returned connection with handle, which
+// gets the API key, version and the request after the client id, and returns
+// the response after the correlation id
+func fakeBroker(t *testing.T, handle func(apiKey, version int16, request *decoder) []byte) *protocolConn {
+        t.Helper()
+        client, server := net.Pipe()
+        t.Cleanup(func() {
+                client.Close()
+                server.Close()
+        })
+        go func() {
+                for {
+                        var size [4]byte
+                        if _, err := io.ReadFull(server, size[:]); err != nil {
+                                return
+                        }
+                        frame := make([]byte, binary.BigEndian.Uint32(size[:]))
+                        if _, err := io.ReadFull(server, frame); err != nil {
+                                return
+                        }
+                        d := &decoder{buf: frame}
+                        apiKey, version, id := d.int16(), d.int16(), d.int32()
+                        if clientID := d.string(); clientID != "kafy" {
+                                t.Errorf("client id = %q", clientID)
+                        }
+                        body := handle(apiKey, version, d)
+                        e := &encoder{}
+                        e.int32(int32(4 + len(body)))
+                        e.int32(id)
+                        e.buf = append(e.buf, body...)
+                        if _, err := server.Write(e.buf); err != nil {
+                                return
+                        }
+                }
+        }()
+        return &protocolConn{conn: client}
+}
+
+func TestEncoderDecoderRoundTrip(t *testing.T) {
+        e := &encoder{}
+        e.int8(-3)
+        e.int16(-300)
+        e.int32(70000)
+        e.int64(-1 << 40)
+        e.string("orders")
+        e.bytes([]byte{1, 2, 3})
+        e.uvarint(300)
+        e.compactString("")
+        e.compactString("payments")
+        e.compactArrayLen(-1)
+        e.compactArrayLen(2)
+        e.int32(7)
+        e.int32(8)
+        e.tags()
+
+        d := &decoder{buf: e.buf}
+        if v := d.int8(); v != -3 {
+                t.Errorf("int8 = %d", v)
+        }
+        if v := d.int16(); v != -300 {
+                t.Errorf("int16 = %d", v)
+        }
+        if v := d.int32(); v != 70000 {
+                t.Errorf("int32 = %d", v)
+        }
+        if v := d.int64(); v != -1<<40 {
+                t.Errorf("int64 = %d", v)
+        }
+        if v := d.string(); v != "orders" {
+                t.Errorf("string = %q", v)
+        }
+        if v := d.bytes(); !bytes.Equal(v, []byte{1, 2, 3}) {
+                t.Errorf("bytes = %v", v)
+        }
+        if v := d.uvarint(); v != 300 {
+                t.Errorf("uvarint = %d", v)
+        }
+        if v := d.compactString(); v != "" {
+                t.Errorf("empty compact string = %q", v)
+        }
+        if v := d.compactString(); v != "payments" {
+                t.Errorf("compact string = %q", v)
+        }
+        if v := d.compactArrayLen(); v != 0 {
+                t.Errorf("null compact array length = %d", v)
+        }
+        if v := d.compactInt32s(); !reflect.DeepEqual(v, []int32{7, 8}) {
+                t.Errorf("compact int32s = %v", v)
+        }
+        d.skipTags()
+        if d.err != nil || len(d.buf) != 0 {
+                t.Fatalf("err = %v, %d bytes left", d.err, len(d.buf))
+        }
+}
+
+func TestDecoderTruncated(t *testing.T) {
+        tests := []struct {
+                name string
+                buf  []byte
+                read func(d *decoder)
+        }{
+                {"int32", []byte{0, 1}, func(d *decoder) { d.int32() }},
+                {"string", []byte{0, 5, 'a'}, func(d *decoder) { d.string() }},
+                {"bytes", []byte{0, 0, 0, 5, 'a'}, func(d *decoder) { d.bytes() }},
+                {"array", []byte{0x7f, 0xff, 0xff, 0xff}, func(d *decoder) { d.arrayLen() }},
+                {"compact array", []byte{0x7f}, func(d *decoder) { d.compactArrayLen() }},
+                {"uvarint", []byte{0xff, 0xff}, func(d *decoder) { d.uvarint() }},
+                {"tags", []byte{1, 0, 9}, func(d *decoder) { d.skipTags() }},
+        }
+        for _, tt := range tests {
+                d := &decoder{buf: tt.buf}
+                tt.read(d)
+                if d.err == nil {
+                        t.Errorf("%s: no error reading past the end", tt.name)
+                }
+                if v := d.int8(); v != 0 {
+                        t.Errorf("%s: read %d after an error, want 0", tt.name, v)
+                }
+        }
+}
+
+func TestFlexibleRoundTrip(t *testing.T) {
+        conn := fakeBroker(t, func(apiKey, version int16, request *decoder) []byte {
+                if apiKey != apiListPartitionReassignments || version != 0 {
+                        t.Errorf("request = %d v%d", apiKey, version)
+                }
+                // The v2 request header ends with an empty tagged field section
+                if tags := request.uvarint(); tags != 0 {
+                        t.Errorf("request header has %d tagged fields", tags)
+                }
+                if timeout := request.int32(); timeout <= 0 {
+                        t.Errorf("timeout = %d", timeout)
+                }
+                if topics := request.uvarint(); topics != 0 {
+                        t.Errorf("topics = %d, want a null array for all topics", topics)
+                }
+
+                e := &encoder{}
+                // A response header tagged field the client must skip
+                e.uvarint(1)
+                e.uvarint(9)
+                e.uvarint(2)
+                e.buf = append(e.buf, 'x', 'y')
+                listReassignmentsResponse(e)
+                return e.buf
+        })
+
+        e := &encoder{}
+        e.int32(30000)
+        e.compactArrayLen(-1)
+        e.tags()
+        d, err := conn.flexibleRoundTrip(apiListPartitionReassignments, 0, e.buf)
+        if err != nil {
+                t.Fatal(err)
+        }
+        reassignments, err := readListReassignments(d)
+        if err != nil {
+                t.Fatal(err)
+        }
+        want := []PartitionReassignment{{Topic: "orders", Partition: 2, Replicas: []int32{1, 2, 3}, Adding: []int32{3}, Removing: []int32{1}}}
+        if !reflect.DeepEqual(reassignments, want) {
+                t.Errorf("reassignments = %+v, want %+v", reassignments, want)
+        }
+}
+
+// listReassignmentsResponse encodes a ListPartitionReassignments v0 response
+// with one reassignment and tagged fields to skip
+func listReassignmentsResponse(e *encoder) {
+        e.int32(0) // Throttle time
+        e.int16(0)
+        e.compactString("")
+        e.compactArrayLen(1)
+        e.compactString("orders")
+        e.compactArrayLen(1)
+        e.int32(2)
+        for _, replicas := range [][]int32{{1, 2, 3}, {3}, {1}} {
+                e.compactArrayLen(len(replicas))
+                for _, r := range replicas {
+                        e.int32(r)
+                }
+        }
+        e.uvarint(1) // Partition tagged field
+        e.uvarint(0)
+        e.uvarint(1)
+        e.int8(1)
+        e.tags()
+        e.tags()
+}
+
+func TestReadListReassignmentsTruncated(t *testing.T) {
+        e := &encoder{}
+        listReassignmentsResponse(e)
+        for n := 0; n < len(e.buf); n++ {
+                if _, err := readListReassignments(&decoder{buf: e.buf[:n]}); err == nil {
+                        t.Fatalf("no error for a response cut to %d of %d bytes", n, len(e.buf))
+                }
+        }
+}
+
+func TestAlterReassignmentsRequest(t *testing.T) {
+        d := &decoder{buf: alterReassignmentsRequest([]ReassignmentTarget{
+                {Topic: "payments", Partition: 1, Replicas: []int32{3, 1, 2}},
+                {Topic: "orders", Partition: 0},
+                {Topic: "payments", Partition: 0, Replicas: []int32{1}},
+        })}
+
+        if timeout := d.int32(); timeout <= 0 {
+                t.Errorf("timeout = %d", timeout)
+        }
+        type target struct {
+                topic     string
+                partition int32
+                replicas  []int32
+                null      bool
+        }
+        var got []target
+        for topics := d.compactArrayLen(); topics > 0; topics-- {
+                topic := d.compactString()
+                for partitions := d.compactArrayLen(); partitions > 0; partitions-- {
+                        tg := target{topic: topic, partition: d.int32()}
+                        if n := d.uvarint(); n == 0 {
+                                tg.null = true
+                        } else {
+                                for i := uint64(1); i < n; i++ {
+                                        tg.replicas = append(tg.replicas, d.int32())
+                                }
+                        }
+                        d.skipTags()
+                        got = append(got, tg)
+                }
+                d.skipTags()
+        }
+        d.skipTags()
+        if d.err != nil || len(d.buf) != 0 {
+                t.Fatalf("err = %v, %d bytes left", d.err, len(d.buf))
+        }
+
+        // Topics in name order, partitions in the order given; nil replicas cancel
+        want := []target{
+                {topic: "orders", partition: 0, null: true},
+                {topic: "payments", partition: 1, replicas: []int32{3, 1, 2}},
+                {topic: "payments", partition: 0, replicas: []int32{1}},
+        }
+        if !reflect.DeepEqual(got, want) {
+                t.Errorf("request = %+v, want %+v", got, want)
+        }
+}
+
+func TestReadAlterReassignments(t *testing.T) {
+        e := &encoder{}
+        e.int32(0)
+        e.int16(0)
+        e.compactString("")
+        e.compactArrayLen(1)
+        e.compactString("orders")
+        e.compactArrayLen(2)
+        e.int32(0)
+        e.int16(0)
+        e.compactString("")
+        e.tags()
+        e.int32(1)
+        e.int16(37)
+        e.compactString("replica 9 is not alive")
+        e.tags()
+        e.tags()
+        e.tags()
+
+        failed, err := readAlterReassignments(&decoder{buf: e.buf})
+        if err != nil {
+                t.Fatal(err)
+        }
+        if len(failed) != 1 {
+                t.Fatalf("failed = %v, want orders/1 only", failed)
+        }
+        if err := failed[TopicPartition{Topic: "orders", Partition: 1}]; err == nil || !strings.Contains(err.Error(), "replica 9 is not alive") {
+                t.Errorf("orders/1 error = %v", err)
+        }
+
+        // A top-level error fails the whole request
+        e = &encoder{}
+        e.int32(0)
+        e.int16(41) // NOT_CONTROLLER
+        e.compactString("")
+        e.compactArrayLen(0)
+        e.tags()
+        if _, err := readAlterReassignments(&decoder{buf: e.buf}); err == nil {
+                t.Error("no error for a top-level error code")
+        }
+}
+
+func TestReadLogDirs(t *testing.T) {
+        e := &encoder{}
+        e.int32(0)
+        e.int32(2)
+        for i, dir := range []string{"/data/a", "/data/b"} {
+                code := int16(0)
+                if i == 1 {
+                        code = 57 // KAFKA_STORAGE_ERROR
+                }
+                e.int16(code)
+                e.string(dir)
+                e.int32(1)
+                e.string("orders")
+                e.int32(2)
+                for partition := int32(0); partition < 2; partition++ {
+                        e.int32(partition)
+                        e.int64(1000 * int64(partition+1))
+                        e.int64(0)
+                        e.int8(int8(partition))
+                }
+        }
+
+        replicas, err := readLogDirs(&decoder{buf: e.buf}, 3)
+        if err != nil {
+                t.Fatal(err)
+        }
+        want := []ReplicaLogDir{
+                {Broker: 3, Dir: "/data/a", Topic: "orders", Partition: 0, Size: 1000},
+                {Broker: 3, Dir: "/data/a", Topic: "orders", Partition: 1, Size: 2000, Future: true},
+        }
+        if !reflect.DeepEqual(replicas, want) {
+                t.Errorf("replicas = %+v, want %+v", replicas, want)
+        }
+        if _, err := readLogDirs(&decoder{buf: e.buf[:len(e.buf)-1]}, 3); err == nil {
+                t.Error("no error for a truncated response")
+        }
+}
+
+func TestPBKDF2(t *testing.T) {
+        // RFC 6070
+        for iterations, want := range map[int]string{
+                1:    "0c60c80f961f0e71f3a9b524af6012062fe037a6",
+                2:    "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957",
+                4096: "4b007901b765489abead49d926f721d065a429c1",
+        } {
+                got := hex.EncodeToString(pbkdf2(sha1.New, []byte("password"), []byte("salt"), iterations))
+                if got != want {
+                        t.Errorf("sha1, %d iterations: %s, want %s", iterations, got, want)
+                }
+        }
+
+        // RFC 7914 section 11, first block
+        got := hex.EncodeToString(pbkdf2(sha256.New, []byte("passwd"), []byte("salt"), 1))
+        if want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"; got != want {
+                t.Errorf("sha256: %s, want %s", got, want)
+        }
+}
+
+// scramBroker answers a SASL handshake and a SCRAM exchange with the server
+// messages, checking the client messages against want
+func scramBroker(t *testing.T, mechanism string, want, server []string) *protocolConn {
+        step := 0
+        return fakeBroker(t, func(apiKey, version int16, request *decoder) []byte {
+                e := &encoder{}
+                switch apiKey {
+                case apiSaslHandshake:
+                        if got := request.string(); got != mechanism {
+                                t.Errorf("mechanism = %s", got)
+                        }
+                        e.int16(0)
+                        e.int32(1)
+                        e.string(mechanism)
+                case apiSaslAuthenticate:
+                        if got := string(request.bytes()); got != want[step] {
+                                t.Errorf("client message %d = %q, want %q", step+1, got, want[step])
+                        }
+                        e.int16(0)
+                        e.int16(-1) // No error message
+                        e.bytes([]byte(server[step]))
+                        e.int64(0) // Session lifetime
+                        step++
+                default:
+                        t.Errorf("unexpected request %d", apiKey)
+                }
+                return e.buf
+        })
+}
+
+// withNonce fixes the SCRAM client nonce for the test
+func withNonce(t *testing.T, nonce string) {
+        saved := scramNonce
+        scramNonce = func() (string, error) { return nonce, nil }
+        t.Cleanup(func() { scramNonce = saved })
+}
+
+func TestScramSHA256(t *testing.T) {
+        // RFC 7677 section 3
+        withNonce(t, "rOprNGfwEbeRWgbNEkqO")
+        conn := scramBroker(t, "SCRAM-SHA-256", []string{
+                "n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+                "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+        }, []string{
+                "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+                "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+        })
+        if err := conn.authenticate("SCRAM-SHA-256", "user", "pencil"); err != nil {
+                t.Fatal(err)
+        }
+}
+
+func TestScramSHA1(t *testing.T) {
+        // RFC 5802 section 5
+        withNonce(t, "fyko+d2lbbFgONRv9qkxdawL")
+        conn := scramBroker(t, "SCRAM-SHA-1", []string{
+                "n,,n=user,r=fyko+d2lbbFgONRv9qkxdawL",
+                "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+        }, []string{
+                "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+                "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+        })
+        // authenticate only offers the SHA-2 mechanisms, so run the exchange directly
+        handshake := &encoder{}
+        handshake.string("SCRAM-SHA-1")
+        if _, err := conn.roundTrip(apiSaslHandshake, 1, handshake.buf); err != nil {
+                t.Fatal(err)
+        }
+        if err := conn.scram(sha1.New, "user", "pencil"); err != nil {
+                t.Fatal(err)
+        }
+}
+
+func TestScramRejectsServer(t *testing.T) {
+        tests := []struct {
+                name   string
+                server []string
+                want   string
+        }{
+                {"forged signature", []string{
+                        "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+                        "v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+                }, "server signature does not match"},
+                {"foreign nonce", []string{"r=somebodyElse,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"}, "does not extend the client nonce"},
+                {"server error", []string{
+                        "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+                        "e=invalid-proof",
+                }, "invalid-proof"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        withNonce(t, "rOprNGfwEbeRWgbNEkqO")
+                        // The client messages are checked by TestScramSHA256
+                        step := 0
+                        conn := fakeBroker(t, func(apiKey, version int16, request *decoder) []byte {
+                                e := &encoder{}
+                                e.int16(0)
+                                if apiKey == apiSaslHandshake {
+                                        e.int32(0)
+                                        return e.buf
+                                }
+                                e.int16(-1)
+                                e.bytes([]byte(tt.server[step]))
+                                e.int64(0)
+                                step++
+                                return e.buf
+                        })
+                        err := conn.authenticate("SCRAM-SHA-256", "user", "pencil")
+                        if err == nil || !strings.Contains(err.Error(), tt.want) {
+                                t.Fatalf("err = %v, want %q", err, tt.want)
+                        }
+                })
+        }
+}
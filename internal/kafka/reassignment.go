@@ -0,0 +1,290 @@
+package kafka
+
+import (
+        "context"
+        "fmt"
+        "sort"
+        "strconv"
+        "time"
+
+        "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Replication throttle configs, as set by kafka-reassign-partitions.sh
+const (
+        LeaderThrottledRate       = "leader.replication.throttled.rate"
+        FollowerThrottledRate     = "follower.replication.throttled.rate"
+        LeaderThrottledReplicas   = "leader.replication.throttled.replicas"
+        FollowerThrottledReplicas = "follower.replication.throttled.replicas"
+)
+
+// ReassignmentTarget is the requested replica list of a partition. Nil
+// replicas cancel the reassignment in progress.
+type ReassignmentTarget struct {
+        Topic     string
+        Partition int32
+        Replicas  []int32
+}
+
+// PartitionReassignment is a reassignment in progress
+type PartitionReassignment struct {
+        Topic     string
+        Partition int32
+        Replicas  []int32 // Current replica set, including the replicas being added
+        Adding    []int32
+        Removing  []int32
+}
+
+// dialController connects to the controller, which handles reassignment requests
+func (c *Client) dialController() (*protocolConn, error) {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return nil, err
+        }
+        defer adminClient.Close()
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+        result, err := adminClient.DescribeCluster(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to find the controller: %w", err)
+        }
+        if result.Controller == nil {
+                return nil, fmt.Errorf("the cluster has no active controller")
+        }
+        return c.dialBroker(result.Controller.Host, int32(result.Controller.Port))
+}
+
+// AlterPartitionReassignments starts (or with nil replicas, cancels) the
+// reassignment of partitions. Partitions the controller rejects are returned
+// with their error. Requires Kafka 2.4 or later.
+func (c *Client) AlterPartitionReassignments(targets []ReassignmentTarget) (map[TopicPartition]error, error) {
+        conn, err := c.dialController()
+        if err != nil {
+                return nil, err
+        }
+        defer conn.Close()
+        d, err := conn.flexibleRoundTrip(apiAlterPartitionReassignments, 0, alterReassignmentsRequest(targets))
+        if err != nil {
+                return nil, fmt.Errorf("AlterPartitionReassignments: %w", err)
+        }
+        failed, err := readAlterReassignments(d)
+        if err != nil {
+                return nil, fmt.Errorf("AlterPartitionReassignments: %w", err)
+        }
+        return failed, nil
+}
+
+// alterReassignmentsRequest encodes an AlterPartitionReassignments v0 request
+func alterReassignmentsRequest(targets []ReassignmentTarget) []byte {
+        byTopic := make(map[string][]ReassignmentTarget)
+        var topics []string
+        for _, t := range targets {
+                if byTopic[t.Topic] == nil {
+                        topics = append(topics, t.Topic)
+                }
+                byTopic[t.Topic] = append(byTopic[t.Topic], t)
+        }
+        sort.Strings(topics)
+
+        e := &encoder{}
+        e.int32(int32(protocolTimeout / time.Millisecond))
+        e.compactArrayLen(len(topics))
+        for _, topic := range topics {
+                e.compactString(topic)
+                e.compactArrayLen(len(byTopic[topic]))
+                for _, t := range byTopic[topic] {
+                        e.int32(t.Partition)
+                        if t.Replicas == nil {
+                                e.compactArrayLen(-1)
+                        } else {
+                                e.compactArrayLen(len(t.Replicas))
+                                for _, r := range t.Replicas {
+                                        e.int32(r)
+                                }
+                        }
+                        e.tags()
+                }
+                e.tags()
+        }
+        e.tags()
+        return e.buf
+}
+
+// readAlterReassignments decodes an AlterPartitionReassignments v0 response
+// into the partitions that were rejected
+func readAlterReassignments(d *decoder) (map[TopicPartition]error, error) {
+        d.int32() // Throttle time
+        if err := protocolError(d.int16(), d.compactString()); err != nil {
+                return nil, err
+        }
+        failed := make(map[TopicPartition]error)
+        for topics := d.compactArrayLen(); topics > 0; topics-- {
+                topic := d.compactString()
+                for partitions := d.compactArrayLen(); partitions > 0; partitions-- {
+                        partition := d.int32()
+                        if err := protocolError(d.int16(), d.compactString()); err != nil {
+                                failed[TopicPartition{Topic: topic, Partition: partition}] = err
+                        }
+                        d.skipTags()
+                }
+                d.skipTags()
+        }
+        d.skipTags()
+        if d.err != nil {
+                return nil, d.err
+        }
+        return failed, nil
+}
+
+// ListPartitionReassignments returns the reassignments in progress
+func (c *Client) ListPartitionReassignments() ([]PartitionReassignment, error) {
+        e := &encoder{}
+        e.int32(int32(protocolTimeout / time.Millisecond))
+        e.compactArrayLen(-1) // All topics
+        e.tags()
+
+        conn, err := c.dialController()
+        if err != nil {
+                return nil, err
+        }
+        defer conn.Close()
+        d, err := conn.flexibleRoundTrip(apiListPartitionReassignments, 0, e.buf)
+        if err != nil {
+                return nil, fmt.Errorf("ListPartitionReassignments: %w", err)
+        }
+        reassignments, err := readListReassignments(d)
+        if err != nil {
+                return nil, fmt.Errorf("ListPartitionReassignments: %w", err)
+        }
+        return reassignments, nil
+}
+
+// readListReassignments decodes a ListPartitionReassignments v0 response
+func readListReassignments(d *decoder) ([]PartitionReassignment, error) {
+        d.int32() // Throttle time
+        if err := protocolError(d.int16(), d.compactString()); err != nil {
+                return nil, err
+        }
+        var reassignments []PartitionReassignment
+        for topics := d.compactArrayLen(); topics > 0; topics-- {
+                topic := d.compactString()
+                for partitions := d.compactArrayLen(); partitions > 0; partitions-- {
+                        r := PartitionReassignment{Topic: topic}
+                        r.Partition = d.int32()
+                        r.Replicas = d.compactInt32s()
+                        r.Adding = d.compactInt32s()
+                        r.Removing = d.compactInt32s()
+                        d.skipTags()
+                        reassignments = append(reassignments, r)
+                }
+                d.skipTags()
+        }
+        d.skipTags()
+        if d.err != nil {
+                return nil, d.err
+        }
+        return reassignments, nil
+}
+
+func protocolError(code int16, message string) error {
+        if code == 0 {
+                return nil
+        }
+        if message == "" {
+                message = kafka.ErrorCode(code).String()
+        }
+        return fmt.Errorf("%s (error code %d)", message, code)
+}
+
+// SetReplicationThrottles limits replication on the brokers to rate bytes per
+// second, for the replicas listed per topic in the partition:broker,... format
+// of the throttled replicas configs. The replicas are appended to the lists
+// already set, and other config overrides are left alone.
+func (c *Client) SetReplicationThrottles(rate int64, brokers []int32, leaderReplicas, followerReplicas map[string]string) error {
+        value := strconv.FormatInt(rate, 10)
+        for _, broker := range brokers {
+                err := c.incrementalAlterConfigs(kafka.ResourceBroker, []string{strconv.Itoa(int(broker))}, kafka.AlterConfigOpTypeSet, map[string]string{
+                        LeaderThrottledRate:   value,
+                        FollowerThrottledRate: value,
+                })
+                if err != nil {
+                        return err
+                }
+        }
+        return c.alterThrottledReplicas(kafka.AlterConfigOpTypeAppend, leaderReplicas, followerReplicas)
+}
+
+// RemoveReplicationThrottles deletes the throttle rates of the brokers and
+// subtracts the replicas from the throttled replicas lists of their topics, so
+// throttles set for other partitions stay in place
+func (c *Client) RemoveReplicationThrottles(brokers []int32, leaderReplicas, followerReplicas map[string]string) error {
+        for _, broker := range brokers {
+                err := c.incrementalAlterConfigs(kafka.ResourceBroker, []string{strconv.Itoa(int(broker))}, kafka.AlterConfigOpTypeDelete, map[string]string{
+                        LeaderThrottledRate:   "",
+                        FollowerThrottledRate: "",
+                })
+                if err != nil {
+                        return err
+                }
+        }
+        return c.alterThrottledReplicas(kafka.AlterConfigOpTypeSubtract, leaderReplicas, followerReplicas)
+}
+
+// alterThrottledReplicas appends or subtracts replicas, per topic, to the throttled replicas lists
+func (c *Client) alterThrottledReplicas(op kafka.AlterConfigOpType, leaderReplicas, followerReplicas map[string]string) error {
+        topics := make(map[string]bool)
+        for topic := range leaderReplicas {
+                topics[topic] = true
+        }
+        for topic := range followerReplicas {
+                topics[topic] = true
+        }
+        for topic := range topics {
+                configs := make(map[string]string)
+                if replicas := leaderReplicas[topic]; replicas != "" {
+                        configs[LeaderThrottledReplicas] = replicas
+                }
+                if replicas := followerReplicas[topic]; replicas != "" {
+                        configs[FollowerThrottledReplicas] = replicas
+                }
+                if err := c.incrementalAlterConfigs(kafka.ResourceTopic, []string{topic}, op, configs); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// incrementalAlterConfigs applies one operation to configs of resources of one
+// type. librdkafka allows a single broker resource per request, so brokers are
+// passed one at a time.
+func (c *Client) incrementalAlterConfigs(resourceType kafka.ResourceType, names []string, op kafka.AlterConfigOpType, configs map[string]string) error {
+        adminClient, err := c.CreateAdminClient()
+        if err != nil {
+                return err
+        }
+        defer adminClient.Close()
+
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+
+        var entries []kafka.ConfigEntry
+        for name, value := range configs {
+                entries = append(entries, kafka.ConfigEntry{Name: name, Value: value, IncrementalOperation: op})
+        }
+        var resources []kafka.ConfigResource
+        for _, name := range names {
+                resources = append(resources, kafka.ConfigResource{Type: resourceType, Name: name, Config: entries})
+        }
+
+        results, err := adminClient.IncrementalAlterConfigs(ctx, resources, kafka.SetAdminRequestTimeout(30*time.Second))
+        if err != nil {
+                return fmt.Errorf("failed to alter configs: %w", err)
+        }
+        for _, result := range results {
+                if result.Error.Code() != kafka.ErrNoError {
+                        return fmt.Errorf("error altering config for %s %s: %s", result.Type, result.Name, result.Error)
+                }
+        }
+        return nil
+}
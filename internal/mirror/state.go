@@ -0,0 +1,359 @@
+package mirror
+
+import (
+        "encoding/json"
+        "fmt"
+        "os"
+        "path/filepath"
+        "sort"
+        "strings"
+        "sync"
+        "time"
+
+        "kafy/config"
+)
+
+// maxSegments caps the offset mapping kept per partition. Compacted topics
+// produce one segment per gap; beyond the cap the oldest segments are
+// coalesced, so no mirrored range is ever forgotten.
+const maxSegments = 1000
+
+// State is the persisted progress of one mirror: where each source partition
+// is up to, how source offsets map to destination offsets, and the last group sync
+type State struct {
+        Name         string                 `json:"name"`
+        From         string                 `json:"from"`
+        To           string                 `json:"to"`
+        Topics       string                 `json:"topics"`
+        Exclude      string                 `json:"exclude,omitempty"`
+        Groups       string                 `json:"groups"`
+        SyncInterval time.Duration          `json:"sync_interval"`
+        Running      bool                   `json:"running"`
+        StartedAt    time.Time              `json:"started_at"`
+        UpdatedAt    time.Time              `json:"updated_at"`
+        LastError    string                 `json:"last_error,omitempty"`
+        Partitions   map[string]*Partition  `json:"partitions"` // Keyed by "topic/partition"
+        GroupSyncs   map[string]*GroupSync  `json:"group_syncs"`
+
+        mu     sync.Mutex
+        failed map[string]bool // Partitions with a failed delivery; their position must not advance
+}
+
+// Partition tracks one mirrored source partition
+type Partition struct {
+        Topic      string    `json:"topic"`
+        Partition  int32     `json:"partition"`
+        NextOffset int64     `json:"next_offset"` // Next source offset to mirror
+        SourceEnd  int64     `json:"source_end"`  // Source high watermark at the last update
+        Mirrored   int64     `json:"mirrored"`
+        Segments   []Segment `json:"segments"`
+}
+
+// Segment maps a run of consecutive source offsets onto consecutive destination
+// offsets. A coarse segment was coalesced from several: it covers Length source
+// offsets and the destination offsets from Dest up to DestEnd, but where each
+// record inside it landed is no longer known.
+type Segment struct {
+        Source  int64 `json:"source"`
+        Dest    int64 `json:"dest"`
+        Length  int64 `json:"length"`
+        Coarse  bool  `json:"coarse,omitempty"`
+        DestEnd int64 `json:"dest_end,omitempty"`
+}
+
+// end is the destination offset after the segment's last record
+func (s Segment) end() int64 {
+        if s.Coarse {
+                return s.DestEnd
+        }
+        return s.Dest + s.Length
+}
+
+// GroupSync is the result of the last offset sync for one consumer group
+type GroupSync struct {
+        SyncedAt time.Time                  `json:"synced_at"`
+        Offsets  map[string]map[int32]int64 `json:"offsets"` // Destination offsets committed
+        Skipped  int                        `json:"skipped"` // Partitions where the destination group was already further along
+        Rewound  int                        `json:"rewound"` // Partitions translated to the start of a coarse segment; records may be read again
+        Error    string                     `json:"error,omitempty"`
+}
+
+// Dir is where mirror state files live (~/.kafy/mirror)
+func Dir() string {
+        return filepath.Join(filepath.Dir(config.DefaultConfigPath()), "mirror")
+}
+
+func statePath(name string) string {
+        return filepath.Join(Dir(), name+".json")
+}
+
+// New returns empty state for a mirror
+func New(name, from, to string) *State {
+        return &State{
+                Name:       name,
+                From:       from,
+                To:         to,
+                Partitions: make(map[string]*Partition),
+                GroupSyncs: make(map[string]*GroupSync),
+        }
+}
+
+// Load reads the state of a mirror; the error wraps os.ErrNotExist when it has never run
+func Load(name string) (*State, error) {
+        data, err := os.ReadFile(statePath(name))
+        if err != nil {
+                return nil, err
+        }
+        state := New(name, "", "")
+        if err := json.Unmarshal(data, state); err != nil {
+                return nil, fmt.Errorf("failed to parse mirror state %s: %w", statePath(name), err)
+        }
+        return state, nil
+}
+
+// List loads every mirror state, sorted by name
+func List() ([]*State, error) {
+        entries, err := os.ReadDir(Dir())
+        if os.IsNotExist(err) {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, err
+        }
+
+        var states []*State
+        for _, entry := range entries {
+                if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+                        continue
+                }
+                state, err := Load(strings.TrimSuffix(entry.Name(), ".json"))
+                if err != nil {
+                        return nil, err
+                }
+                states = append(states, state)
+        }
+        sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+        return states, nil
+}
+
+// Save writes the state atomically so a crash never leaves a truncated file
+func (s *State) Save() error {
+        s.mu.Lock()
+        s.UpdatedAt = time.Now().UTC()
+        data, err := json.MarshalIndent(s, "", "  ")
+        s.mu.Unlock()
+        if err != nil {
+                return err
+        }
+
+        if err := os.MkdirAll(Dir(), 0755); err != nil {
+                return fmt.Errorf("failed to create mirror state directory: %w", err)
+        }
+        tmp := statePath(s.Name) + ".tmp"
+        if err := os.WriteFile(tmp, data, 0644); err != nil {
+                return fmt.Errorf("failed to write mirror state: %w", err)
+        }
+        return os.Rename(tmp, statePath(s.Name))
+}
+
+func partitionKey(topic string, partition int32) string {
+        return fmt.Sprintf("%s/%d", topic, partition)
+}
+
+// NextOffset returns where a tracked partition resumes, or false if it has not been mirrored yet
+func (s *State) NextOffset(topic string, partition int32) (int64, bool) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        p, ok := s.Partitions[partitionKey(topic, partition)]
+        if !ok {
+                return 0, false
+        }
+        return p.NextOffset, true
+}
+
+// Snapshot returns copies of the tracked partitions (without their offset mappings)
+func (s *State) Snapshot() []Partition {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        partitions := make([]Partition, 0, len(s.Partitions))
+        for _, p := range s.Partitions {
+                copied := *p
+                copied.Segments = nil
+                partitions = append(partitions, copied)
+        }
+        sort.Slice(partitions, func(i, j int) bool {
+                if partitions[i].Topic != partitions[j].Topic {
+                        return partitions[i].Topic < partitions[j].Topic
+                }
+                return partitions[i].Partition < partitions[j].Partition
+        })
+        return partitions
+}
+
+// Track starts tracking a partition whose first source offset will be nextOffset
+func (s *State) Track(topic string, partition int32, nextOffset int64) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        key := partitionKey(topic, partition)
+        if _, ok := s.Partitions[key]; !ok {
+                s.Partitions[key] = &Partition{Topic: topic, Partition: partition, NextOffset: nextOffset}
+        }
+}
+
+// RecordDelivery notes that a source record was written at a destination offset
+func (s *State) RecordDelivery(topic string, partition int32, source, dest int64) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        key := partitionKey(topic, partition)
+        p, ok := s.Partitions[key]
+        if !ok {
+                p = &Partition{Topic: topic, Partition: partition}
+                s.Partitions[key] = p
+        }
+        p.Mirrored++
+        p.addMapping(source, dest)
+        if !s.failed[key] && source >= p.NextOffset {
+                p.NextOffset = source + 1
+        }
+}
+
+// RecordFailure stops a partition's position from advancing past a record that was not delivered
+func (s *State) RecordFailure(topic string, partition int32, source int64, err error) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        key := partitionKey(topic, partition)
+        if s.failed == nil {
+                s.failed = make(map[string]bool)
+        }
+        s.failed[key] = true
+        if p, ok := s.Partitions[key]; ok && source < p.NextOffset {
+                p.NextOffset = source
+        }
+        s.LastError = fmt.Sprintf("%s: offset %d: %v", key, source, err)
+}
+
+// Stop marks the mirror as no longer running, keeping err as the last error
+func (s *State) Stop(err error) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        s.Running = false
+        if err != nil {
+                s.LastError = err.Error()
+        }
+}
+
+// SetSourceEnd records the source high watermark, for lag reporting
+func (s *State) SetSourceEnd(topic string, partition int32, end int64) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if p, ok := s.Partitions[partitionKey(topic, partition)]; ok {
+                p.SourceEnd = end
+        }
+}
+
+// SetGroupSync stores the result of a group offset sync
+func (s *State) SetGroupSync(group string, sync *GroupSync) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        s.GroupSyncs[group] = sync
+}
+
+// Translate converts a committed source offset into the destination offset for
+// the same partition. exact is false when the offset fell into a coarse segment
+// and the group was moved back to its start. ok is false when nothing has been
+// mirrored yet.
+func (s *State) Translate(topic string, partition int32, committed int64) (offset int64, exact, ok bool) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        p, found := s.Partitions[partitionKey(topic, partition)]
+        if !found {
+                return 0, false, false
+        }
+        return p.translate(committed)
+}
+
+func (p *Partition) addMapping(source, dest int64) {
+        // After a crash records since the last save are mirrored again; the newer copies win
+        for len(p.Segments) > 0 && p.Segments[len(p.Segments)-1].Source >= source {
+                p.Segments = p.Segments[:len(p.Segments)-1]
+        }
+        if n := len(p.Segments); n > 0 && p.Segments[n-1].Source+p.Segments[n-1].Length > source {
+                last := &p.Segments[n-1]
+                last.Length = source - last.Source
+                if last.Coarse {
+                        // Where the remaining records landed is unknown; rewinding is safe
+                        last.DestEnd = last.Dest
+                }
+        }
+
+        if n := len(p.Segments); n > 0 {
+                last := &p.Segments[n-1]
+                if !last.Coarse && source == last.Source+last.Length && dest == last.Dest+last.Length {
+                        last.Length++
+                        return
+                }
+        }
+        p.Segments = append(p.Segments, Segment{Source: source, Dest: dest, Length: 1})
+        if len(p.Segments) > maxSegments {
+                // Groups that far behind lose exactness, not records
+                first, second := p.Segments[0], p.Segments[1]
+                p.Segments[1] = Segment{
+                        Source:  first.Source,
+                        Dest:    first.Dest,
+                        Length:  second.Source + second.Length - first.Source,
+                        Coarse:  true,
+                        DestEnd: second.end(),
+                }
+                p.Segments = append([]Segment(nil), p.Segments[1:]...)
+        }
+}
+
+// translate maps a committed offset (the next record the group will read) so
+// that the group resumes exactly after the last record it consumed. When that
+// record was never mirrored (compacted away, or not mirrored yet) the result
+// is the next destination offset after it, so nothing is skipped. Inside a
+// coarse segment the group resumes at the segment's start, which may repeat
+// records but never skips any.
+func (p *Partition) translate(committed int64) (int64, bool, bool) {
+        if len(p.Segments) == 0 {
+                return 0, false, false
+        }
+        consumed := committed - 1
+
+        i := sort.Search(len(p.Segments), func(i int) bool { return p.Segments[i].Source > consumed }) - 1
+        if i < 0 {
+                // The group is behind everything mirrored: start at the first mirrored record
+                return p.Segments[0].Dest, true, true
+        }
+        segment := p.Segments[i]
+        if consumed < segment.Source+segment.Length {
+                if segment.Coarse {
+                        return segment.Dest, false, true
+                }
+                return segment.Dest + (consumed - segment.Source) + 1, true, true
+        }
+        return segment.end(), true, true
+}
+
+// Lag is the number of source records not yet mirrored, as of the last update
+func (p *Partition) Lag() int64 {
+        if p.SourceEnd > p.NextOffset {
+                return p.SourceEnd - p.NextOffset
+        }
+        return 0
+}
+
+// Status describes whether the mirror process is alive, judged by how recently it saved its state
+func (s *State) Status() string {
+        if !s.Running {
+                return "stopped"
+        }
+        interval := s.SyncInterval
+        if interval <= 0 {
+                interval = 30 * time.Second
+        }
+        if time.Since(s.UpdatedAt) > 3*interval {
+                return "stale"
+        }
+        return "running"
+}
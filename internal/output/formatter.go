@@ -1,9 +1,14 @@
 package output
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"gopkg.in/yaml.v3"
@@ -12,24 +17,51 @@ import (
 type Format string
 
 const (
-	FormatTable Format = "table"
-	FormatJSON  Format = "json"
-	FormatYAML  Format = "yaml"
+	FormatTable    Format = "table"
+	FormatJSON     Format = "json"
+	FormatYAML     Format = "yaml"
+	FormatCSV      Format = "csv"
+	FormatTSV      Format = "tsv"
+	FormatNDJSON   Format = "ndjson"
+	FormatMarkdown Format = "markdown"
 )
 
+// Formats lists every supported output format in the order shown to users
+var Formats = []Format{FormatTable, FormatJSON, FormatYAML, FormatCSV, FormatTSV, FormatNDJSON, FormatMarkdown}
+
+// FormatNames returns the supported output formats as plain strings (used for flag help and completion)
+func FormatNames() []string {
+	names := make([]string, len(Formats))
+	for i, format := range Formats {
+		names[i] = string(format)
+	}
+	return names
+}
+
+// ParseFormat validates an output format name
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format '%s' (supported: %s)", name, strings.Join(FormatNames(), ", "))
+}
+
 type Formatter struct {
-	Format Format
+	Format    Format
+	NoHeaders bool   // Omit the header row (table, csv, tsv)
+	SortBy    string // Column to sort rows by; prefix with '-' for descending order
+
+	tables int // Tables output so far; commands may print several
 }
 
-func NewFormatter(format string) *Formatter {
-	switch format {
-	case "json":
-		return &Formatter{Format: FormatJSON}
-	case "yaml":
-		return &Formatter{Format: FormatYAML}
-	default:
-		return &Formatter{Format: FormatTable}
+func NewFormatter(format string) (*Formatter, error) {
+	parsed, err := ParseFormat(format)
+	if err != nil {
+		return nil, err
 	}
+	return &Formatter{Format: parsed}, nil
 }
 
 func (f *Formatter) Output(data interface{}) error {
@@ -38,6 +70,14 @@ func (f *Formatter) Output(data interface{}) error {
 		return f.outputJSON(data)
 	case FormatYAML:
 		return f.outputYAML(data)
+	case FormatNDJSON:
+		return f.outputNDJSON(data)
+	case FormatCSV, FormatTSV, FormatMarkdown:
+		headers, rows, err := flattenData(data)
+		if err != nil {
+			return err
+		}
+		return f.OutputTable(headers, rows)
 	default:
 		return f.outputTable(data)
 	}
@@ -55,38 +95,90 @@ func (f *Formatter) outputYAML(data interface{}) error {
 	return encoder.Encode(data)
 }
 
+// outputNDJSON writes one compact JSON document per line. Lists are split so
+// each element gets its own line, which keeps large results streamable.
+func (f *Formatter) outputNDJSON(data interface{}) error {
+	normalized, err := normalize(data)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	if items, ok := normalized.([]interface{}); ok {
+		for _, item := range items {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return encoder.Encode(normalized)
+}
+
 func (f *Formatter) outputTable(data interface{}) error {
 	// This is a basic implementation - we'll enhance it per command
 	fmt.Printf("%+v\n", data)
 	return nil
 }
 
-func (f *Formatter) OutputTable(headers []string, rows [][]string) {
-	if f.Format != FormatTable {
-		// For non-table formats, convert to structured data
-		result := make([]map[string]string, len(rows))
-		for i, row := range rows {
-			item := make(map[string]string)
-			for j, header := range headers {
-				if j < len(row) {
-					item[header] = row[j]
-				}
+func (f *Formatter) OutputTable(headers []string, rows [][]string) error {
+	return f.OutputTableWithTotals(headers, rows, nil)
+}
+
+// OutputTableWithTotals prints a table whose totals rows sum up the others.
+// They follow the rows and are left out of --sort-by.
+func (f *Formatter) OutputTableWithTotals(headers []string, rows, totals [][]string) error {
+	f.tables++
+	if f.SortBy != "" {
+		// Only tables with the column are sorted, so a secondary table such as a
+		// summary does not fail the command. A primary table without it is most
+		// likely a typo, which is worth a warning.
+		if err := sortRows(headers, rows, f.SortBy); err != nil && f.tables == 1 {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if len(totals) > 0 {
+		rows = append(rows[:len(rows):len(rows)], totals...)
+	}
+
+	switch f.Format {
+	case FormatTable:
+		f.renderTable(headers, rows)
+		return nil
+	case FormatCSV:
+		return f.renderDelimited(os.Stdout, headers, rows, ',')
+	case FormatTSV:
+		return f.renderDelimited(os.Stdout, headers, rows, '\t')
+	case FormatMarkdown:
+		return f.renderMarkdown(os.Stdout, headers, rows)
+	}
+
+	// For structured formats, convert to a list of objects
+	result := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		item := make(map[string]string)
+		for j, header := range headers {
+			if j < len(row) {
+				item[header] = row[j]
 			}
-			result[i] = item
 		}
-		f.Output(result)
-		return
+		result[i] = item
 	}
+	return f.Output(result)
+}
 
+func (f *Formatter) renderTable(headers []string, rows [][]string) {
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
-	
+
 	// Add headers
-	headerRow := make(table.Row, len(headers))
-	for i, header := range headers {
-		headerRow[i] = header
+	if !f.NoHeaders {
+		headerRow := make(table.Row, len(headers))
+		for i, header := range headers {
+			headerRow[i] = header
+		}
+		t.AppendHeader(headerRow)
 	}
-	t.AppendHeader(headerRow)
 
 	// Add rows
 	for _, row := range rows {
@@ -98,4 +190,205 @@ func (f *Formatter) OutputTable(headers []string, rows [][]string) {
 	}
 
 	t.Render()
-}
\ No newline at end of file
+}
+
+func (f *Formatter) renderDelimited(w io.Writer, headers []string, rows [][]string, delimiter rune) error {
+	if delimiter == '\t' {
+		// TSV has no quoting, so escape the characters that would break the layout
+		escape := strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+		writeRow := func(b *strings.Builder, cells []string) {
+			escaped := make([]string, len(cells))
+			for i, cell := range cells {
+				escaped[i] = escape.Replace(cell)
+			}
+			b.WriteString(strings.Join(escaped, "\t") + "\n")
+		}
+
+		var b strings.Builder
+		if !f.NoHeaders {
+			writeRow(&b, headers)
+		}
+		for _, row := range rows {
+			writeRow(&b, row)
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+	if !f.NoHeaders {
+		if err := writer.Write(headers); err != nil {
+			return err
+		}
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+func (f *Formatter) renderMarkdown(w io.Writer, headers []string, rows [][]string) error {
+	escape := strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>")
+	writeRow := func(cells []string) string {
+		escaped := make([]string, len(headers))
+		for i := range headers {
+			if i < len(cells) {
+				escaped[i] = escape.Replace(cells[i])
+			}
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(writeRow(headers))
+	separators := make([]string, len(headers))
+	for i := range separators {
+		separators[i] = "---"
+	}
+	b.WriteString("| " + strings.Join(separators, " | ") + " |\n")
+	for _, row := range rows {
+		b.WriteString(writeRow(row))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortRows sorts rows in place by the named column. Column names match
+// case-insensitively and ignore spaces, dashes and underscores, so "total-lag"
+// selects "Total Lag". Numeric columns sort numerically.
+func sortRows(headers []string, rows [][]string, sortBy string) error {
+	descending := strings.HasPrefix(sortBy, "-")
+	column := -1
+	want := normalizeColumnName(strings.TrimPrefix(sortBy, "-"))
+	for i, header := range headers {
+		if normalizeColumnName(header) == want {
+			column = i
+			break
+		}
+	}
+	if column == -1 {
+		return fmt.Errorf("cannot sort by '%s': no such column (available: %s)", sortBy, strings.Join(headers, ", "))
+	}
+
+	cell := func(row []string) string {
+		if column < len(row) {
+			return row[column]
+		}
+		return ""
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := cell(rows[i]), cell(rows[j])
+		if descending {
+			a, b = b, a
+		}
+		aNum, aErr := strconv.ParseFloat(a, 64)
+		bNum, bErr := strconv.ParseFloat(b, 64)
+		if aErr == nil && bErr == nil {
+			return aNum < bNum
+		}
+		return a < b
+	})
+	return nil
+}
+
+func normalizeColumnName(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name))
+}
+
+// normalize converts arbitrary data into plain JSON values (maps, slices and
+// scalars) so it can be inspected generically
+func normalize(data interface{}) (interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// flattenData turns structured data into headers and rows for the tabular text
+// formats. Lists of objects become one row per element, a single object becomes
+// Key/Value rows, and nested values are rendered as compact JSON.
+func flattenData(data interface{}) ([]string, [][]string, error) {
+	normalized, err := normalize(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch value := normalized.(type) {
+	case []interface{}:
+		var headers []string
+		seen := make(map[string]bool)
+		for _, item := range value {
+			if object, ok := item.(map[string]interface{}); ok {
+				for key := range object {
+					if !seen[key] {
+						seen[key] = true
+						headers = append(headers, key)
+					}
+				}
+			}
+		}
+		if len(headers) == 0 {
+			rows := make([][]string, len(value))
+			for i, item := range value {
+				rows[i] = []string{cellString(item)}
+			}
+			return []string{"Value"}, rows, nil
+		}
+		sort.Strings(headers)
+
+		rows := make([][]string, len(value))
+		for i, item := range value {
+			row := make([]string, len(headers))
+			if object, ok := item.(map[string]interface{}); ok {
+				for j, header := range headers {
+					if cellValue, exists := object[header]; exists {
+						row[j] = cellString(cellValue)
+					}
+				}
+			}
+			rows[i] = row
+		}
+		return headers, rows, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		rows := make([][]string, len(keys))
+		for i, key := range keys {
+			rows[i] = []string{key, cellString(value[key])}
+		}
+		return []string{"Key", "Value"}, rows, nil
+	default:
+		return []string{"Value"}, [][]string{{cellString(value)}}, nil
+	}
+}
+
+func cellString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(raw)
+	}
+}
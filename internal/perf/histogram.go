@@ -0,0 +1,161 @@
+package perf
+
+import (
+        "math"
+        "math/bits"
+        "sync"
+        "time"
+)
+
+// Histogram records latencies in log-linear microsecond buckets. Values below
+// 64µs are exact and larger values keep about 3% precision, so memory stays
+// fixed no matter how many samples are recorded.
+type Histogram struct {
+        mu     sync.Mutex
+        counts []int64
+        count  int64
+        sum    int64
+        min    int64
+        max    int64
+}
+
+const (
+        linearBuckets = 64
+        subBuckets    = 32
+)
+
+func NewHistogram() *Histogram {
+        return &Histogram{
+                counts: make([]int64, linearBuckets+64*subBuckets),
+                min:    math.MaxInt64,
+        }
+}
+
+func bucketIndex(v int64) int {
+        if v < linearBuckets {
+                return int(v)
+        }
+        shift := bits.Len64(uint64(v)) - 6
+        return linearBuckets + (shift-1)*subBuckets + int(v>>uint(shift)) - subBuckets
+}
+
+// bucketValue returns the midpoint of a bucket
+func bucketValue(index int) int64 {
+        if index < linearBuckets {
+                return int64(index)
+        }
+        i := index - linearBuckets
+        shift := uint(i/subBuckets + 1)
+        base := int64(i%subBuckets+subBuckets) << shift
+        return base + (int64(1)<<shift)/2
+}
+
+// Record adds one latency sample
+func (h *Histogram) Record(d time.Duration) {
+        v := d.Microseconds()
+        if v < 0 {
+                v = 0
+        }
+        h.mu.Lock()
+        h.counts[bucketIndex(v)]++
+        h.count++
+        h.sum += v
+        if v < h.min {
+                h.min = v
+        }
+        if v > h.max {
+                h.max = v
+        }
+        h.mu.Unlock()
+}
+
+// Merge adds all samples from other into h
+func (h *Histogram) Merge(other *Histogram) {
+        other.mu.Lock()
+        defer other.mu.Unlock()
+        h.mu.Lock()
+        defer h.mu.Unlock()
+        for i, c := range other.counts {
+                h.counts[i] += c
+        }
+        h.count += other.count
+        h.sum += other.sum
+        if other.min < h.min {
+                h.min = other.min
+        }
+        if other.max > h.max {
+                h.max = other.max
+        }
+}
+
+func (h *Histogram) Count() int64 {
+        h.mu.Lock()
+        defer h.mu.Unlock()
+        return h.count
+}
+
+// Percentile returns the latency at or below which p percent of samples fall
+func (h *Histogram) Percentile(p float64) time.Duration {
+        h.mu.Lock()
+        defer h.mu.Unlock()
+        return h.percentile(p)
+}
+
+func (h *Histogram) percentile(p float64) time.Duration {
+        if h.count == 0 {
+                return 0
+        }
+        target := int64(math.Ceil(p / 100 * float64(h.count)))
+        if target < 1 {
+                target = 1
+        }
+        var seen int64
+        for i, c := range h.counts {
+                seen += c
+                if seen >= target {
+                        v := bucketValue(i)
+                        // Bucket midpoints can overshoot the real extremes
+                        if v > h.max {
+                                v = h.max
+                        }
+                        if v < h.min {
+                                v = h.min
+                        }
+                        return time.Duration(v) * time.Microsecond
+                }
+        }
+        return time.Duration(h.max) * time.Microsecond
+}
+
+// LatencySummary is a histogram snapshot in milliseconds, ready for output
+type LatencySummary struct {
+        Samples int64   `json:"samples" yaml:"samples"`
+        Min     float64 `json:"min_ms" yaml:"min_ms"`
+        Mean    float64 `json:"mean_ms" yaml:"mean_ms"`
+        P50     float64 `json:"p50_ms" yaml:"p50_ms"`
+        P95     float64 `json:"p95_ms" yaml:"p95_ms"`
+        P99     float64 `json:"p99_ms" yaml:"p99_ms"`
+        P999    float64 `json:"p99_9_ms" yaml:"p99_9_ms"`
+        Max     float64 `json:"max_ms" yaml:"max_ms"`
+}
+
+func (h *Histogram) Summary() LatencySummary {
+        h.mu.Lock()
+        defer h.mu.Unlock()
+        if h.count == 0 {
+                return LatencySummary{}
+        }
+        ms := func(d time.Duration) float64 {
+                return math.Round(float64(d)/float64(time.Millisecond)*1000) / 1000
+        }
+        return LatencySummary{
+                Samples: h.count,
+                Min:     ms(time.Duration(h.min) * time.Microsecond),
+                Mean:    ms(time.Duration(h.sum/h.count) * time.Microsecond),
+                P50:     ms(h.percentile(50)),
+                P95:     ms(h.percentile(95)),
+                P99:     ms(h.percentile(99)),
+                P999:    ms(h.percentile(99.9)),
+                Max:     ms(time.Duration(h.max) * time.Microsecond),
+        }
+}
@@ -0,0 +1,138 @@
+package perf
+
+import (
+        "fmt"
+        "math"
+        "math/rand"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// SizeDistribution picks message sizes in bytes
+type SizeDistribution struct {
+        kind string
+        a, b float64
+        spec string
+}
+
+// ParseSizeDistribution accepts "1024" or "fixed:1024", "uniform:100-2000" and
+// "normal:1000,200" (mean and standard deviation)
+func ParseSizeDistribution(spec string) (SizeDistribution, error) {
+        spec = strings.ToLower(strings.TrimSpace(spec))
+        kind, args, found := strings.Cut(spec, ":")
+        if !found {
+                kind, args = "fixed", spec
+        }
+
+        invalid := fmt.Errorf("invalid size distribution '%s' (use 1024, fixed:1024, uniform:100-2000 or normal:1000,200)", spec)
+        d := SizeDistribution{kind: kind, spec: spec}
+        switch kind {
+        case "fixed":
+                n, err := strconv.Atoi(args)
+                if err != nil || n < 0 {
+                        return d, invalid
+                }
+                d.a = float64(n)
+        case "uniform":
+                lo, hi, ok := strings.Cut(args, "-")
+                min, err1 := strconv.Atoi(lo)
+                max, err2 := strconv.Atoi(hi)
+                if !ok || err1 != nil || err2 != nil || min < 0 || max < min {
+                        return d, invalid
+                }
+                d.a, d.b = float64(min), float64(max)
+        case "normal":
+                m, s, ok := strings.Cut(args, ",")
+                mean, err1 := strconv.ParseFloat(m, 64)
+                stddev, err2 := strconv.ParseFloat(s, 64)
+                if !ok || err1 != nil || err2 != nil || mean < 0 || stddev < 0 {
+                        return d, invalid
+                }
+                d.a, d.b = mean, stddev
+        default:
+                return d, invalid
+        }
+        return d, nil
+}
+
+func (d SizeDistribution) String() string {
+        return d.spec
+}
+
+// Next returns a message size
+func (d SizeDistribution) Next(r *rand.Rand) int {
+        switch d.kind {
+        case "uniform":
+                return int(d.a) + r.Intn(int(d.b-d.a)+1)
+        case "normal":
+                return int(math.Max(0, math.Round(r.NormFloat64()*d.b+d.a)))
+        default:
+                return int(d.a)
+        }
+}
+
+// Max returns the largest size the distribution is expected to produce
+func (d SizeDistribution) Max() int {
+        switch d.kind {
+        case "uniform":
+                return int(d.b)
+        case "normal":
+                return int(d.a + 6*d.b)
+        default:
+                return int(d.a)
+        }
+}
+
+// Payloads hands out random printable payloads sliced from one shared buffer,
+// which keeps generation cheap at high rates
+type Payloads struct {
+        buf []byte
+}
+
+func NewPayloads(maxSize int, r *rand.Rand) *Payloads {
+        const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+        buf := make([]byte, 2*maxSize+1024)
+        for i := range buf {
+                buf[i] = alphabet[r.Intn(len(alphabet))]
+        }
+        return &Payloads{buf: buf}
+}
+
+// Get returns a payload of the given size. Callers must not modify it.
+func (p *Payloads) Get(size int, r *rand.Rand) []byte {
+        if size > len(p.buf) {
+                size = len(p.buf)
+        }
+        offset := r.Intn(len(p.buf) - size + 1)
+        return p.buf[offset : offset+size]
+}
+
+// Pacer spaces events evenly to hit a target rate. After a stall it restarts the
+// schedule from now, so the rate is never exceeded by a catch-up burst.
+type Pacer struct {
+        interval time.Duration
+        next     time.Time
+}
+
+// NewPacer returns a pacer for rate events per second; a rate of 0 means unlimited
+func NewPacer(rate float64) *Pacer {
+        if rate <= 0 {
+                return &Pacer{}
+        }
+        return &Pacer{interval: time.Duration(float64(time.Second) / rate), next: time.Now()}
+}
+
+// Wait blocks until the next event is due
+func (p *Pacer) Wait() {
+        if p.interval == 0 {
+                return
+        }
+        now := time.Now()
+        if delay := p.next.Sub(now); delay > 0 {
+                time.Sleep(delay)
+        } else {
+                p.next = now
+        }
+        p.next = p.next.Add(p.interval)
+}
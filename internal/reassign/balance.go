@@ -0,0 +1,298 @@
+package reassign
+
+import (
+        "fmt"
+        "sort"
+)
+
+// Options steer Balance
+type Options struct {
+        Brokers []int32          // Brokers replicas may be placed on; replicas elsewhere are moved off
+        Racks   map[int32]string // Rack of each broker; replicas of a partition are kept on distinct racks where possible
+        Sizes   map[Key]int64    // Partition sizes in bytes; smaller partitions are moved first to limit data movement
+}
+
+// Balance proposes an assignment of the partitions over the brokers that
+// evens out replica counts and preferred leaders per broker while moving as
+// few replicas as possible. Replicas keep their position in the replica list
+// when they move, and preferred leaders are rebalanced by reordering replicas,
+// which moves no data.
+func Balance(current []Partition, opts Options) ([]Partition, error) {
+        if len(opts.Brokers) == 0 {
+                return nil, fmt.Errorf("no brokers to assign replicas to")
+        }
+        b := &balancer{
+                opts:      opts,
+                allowed:   make(map[int32]bool),
+                load:      make(map[int32]int),
+                topicLoad: make(map[string]map[int32]int),
+        }
+        for _, id := range opts.Brokers {
+                b.allowed[id] = true
+                b.load[id] = 0
+        }
+        b.brokers = make([]int32, 0, len(b.allowed))
+        for id := range b.allowed {
+                b.brokers = append(b.brokers, id)
+        }
+        sort.Slice(b.brokers, func(i, j int) bool { return b.brokers[i] < b.brokers[j] })
+
+        for _, p := range current {
+                if len(p.Replicas) > len(b.brokers) {
+                        return nil, fmt.Errorf("%s/%d has %d replicas but only %d brokers are available", p.Topic, p.Partition, len(p.Replicas), len(b.brokers))
+                }
+                b.partitions = append(b.partitions, Partition{Topic: p.Topic, Partition: p.Partition, Replicas: append([]int32(nil), p.Replicas...)})
+        }
+        sort.Slice(b.partitions, func(i, j int) bool {
+                if b.partitions[i].Topic != b.partitions[j].Topic {
+                        return b.partitions[i].Topic < b.partitions[j].Topic
+                }
+                return b.partitions[i].Partition < b.partitions[j].Partition
+        })
+        for _, p := range b.partitions {
+                for _, r := range p.Replicas {
+                        b.add(p.Topic, r, 1)
+                }
+        }
+
+        if err := b.evacuate(); err != nil {
+                return nil, err
+        }
+        b.balanceReplicas()
+        b.balanceLeaders()
+        return b.partitions, nil
+}
+
+// Changed returns the partitions of proposed whose replicas differ from current
+func Changed(current, proposed []Partition) []Partition {
+        before := make(map[Key][]int32)
+        for _, p := range current {
+                before[p.Key()] = p.Replicas
+        }
+        var changed []Partition
+        for _, p := range proposed {
+                if !SameReplicas(before[p.Key()], p.Replicas) {
+                        changed = append(changed, p)
+                }
+        }
+        return changed
+}
+
+// Movement counts the replicas proposed on brokers that do not host them now,
+// and their bytes when sizes are known
+func Movement(current, proposed []Partition, sizes map[Key]int64) (replicas int, bytes int64) {
+        before := make(map[Key][]int32)
+        for _, p := range current {
+                before[p.Key()] = p.Replicas
+        }
+        for _, p := range proposed {
+                for _, r := range p.Replicas {
+                        if !contains(before[p.Key()], r) {
+                                replicas++
+                                bytes += sizes[p.Key()]
+                        }
+                }
+        }
+        return replicas, bytes
+}
+
+type balancer struct {
+        opts       Options
+        brokers    []int32
+        allowed    map[int32]bool
+        partitions []Partition
+        load       map[int32]int
+        topicLoad  map[string]map[int32]int
+}
+
+func (b *balancer) add(topic string, broker int32, delta int) {
+        b.load[broker] += delta
+        if b.topicLoad[topic] == nil {
+                b.topicLoad[topic] = make(map[int32]int)
+        }
+        b.topicLoad[topic][broker] += delta
+}
+
+func (b *balancer) move(p *Partition, index int, to int32) {
+        from := p.Replicas[index]
+        b.add(p.Topic, from, -1)
+        b.add(p.Topic, to, 1)
+        p.Replicas[index] = to
+}
+
+// rackOK reports whether moving a replica from one broker to another keeps
+// the partition at least as spread over racks as before
+func (b *balancer) rackOK(p *Partition, from, to int32) bool {
+        if len(b.opts.Racks) == 0 {
+                return true
+        }
+        target := b.opts.Racks[to]
+        if target == "" || target == b.opts.Racks[from] {
+                return true
+        }
+        for _, r := range p.Replicas {
+                if r != from && b.opts.Racks[r] == target {
+                        return false
+                }
+        }
+        return true
+}
+
+// byLoad returns the allowed brokers, least loaded first
+func (b *balancer) byLoad() []int32 {
+        brokers := append([]int32(nil), b.brokers...)
+        sort.SliceStable(brokers, func(i, j int) bool { return b.load[brokers[i]] < b.load[brokers[j]] })
+        return brokers
+}
+
+// evacuate moves replicas off brokers that are not allowed, to the least
+// loaded allowed broker, keeping racks distinct where possible
+func (b *balancer) evacuate() error {
+        for i := range b.partitions {
+                p := &b.partitions[i]
+                for j, r := range p.Replicas {
+                        if b.allowed[r] {
+                                continue
+                        }
+                        target := int32(-1)
+                        for _, strict := range []bool{true, false} {
+                                for _, candidate := range b.byLoad() {
+                                        if contains(p.Replicas, candidate) || (strict && !b.rackOK(p, r, candidate)) {
+                                                continue
+                                        }
+                                        target = candidate
+                                        break
+                                }
+                                if target >= 0 {
+                                        break
+                                }
+                        }
+                        if target < 0 {
+                                return fmt.Errorf("no broker available for the replica of %s/%d on broker %d", p.Topic, p.Partition, r)
+                        }
+                        b.move(p, j, target)
+                }
+        }
+        return nil
+}
+
+// balanceReplicas moves replicas from the most to the least loaded brokers
+// until replica counts differ by at most one, or no move is possible
+func (b *balancer) balanceReplicas() {
+        for {
+                brokers := b.byLoad()
+                moved := false
+                for hiIndex := len(brokers) - 1; hiIndex > 0 && !moved; hiIndex-- {
+                        hi := brokers[hiIndex]
+                        for _, lo := range brokers[:hiIndex] {
+                                if b.load[hi]-b.load[lo] < 2 {
+                                        break
+                                }
+                                if p, index := b.bestMove(hi, lo); p != nil {
+                                        b.move(p, index, lo)
+                                        moved = true
+                                        break
+                                }
+                        }
+                }
+                if !moved {
+                        return
+                }
+        }
+}
+
+// bestMove picks the replica on hi to move to lo: from the topic most
+// concentrated on hi relative to lo, then the smallest partition
+func (b *balancer) bestMove(hi, lo int32) (*Partition, int) {
+        var best *Partition
+        bestIndex, bestGain := -1, 0
+        var bestSize int64
+        for i := range b.partitions {
+                p := &b.partitions[i]
+                index := indexOf(p.Replicas, hi)
+                if index < 0 || contains(p.Replicas, lo) || !b.rackOK(p, hi, lo) {
+                        continue
+                }
+                gain := b.topicLoad[p.Topic][hi] - b.topicLoad[p.Topic][lo]
+                size := b.opts.Sizes[p.Key()]
+                if best == nil || gain > bestGain || (gain == bestGain && size < bestSize) {
+                        best, bestIndex, bestGain, bestSize = p, index, gain, size
+                }
+        }
+        return best, bestIndex
+}
+
+// balanceLeaders evens out preferred leaders by moving a less loaded replica
+// of the partition to the front of the replica list
+func (b *balancer) balanceLeaders() {
+        leaders := make(map[int32]int)
+        for _, id := range b.brokers {
+                leaders[id] = 0
+        }
+        for _, p := range b.partitions {
+                leaders[p.Replicas[0]]++
+        }
+
+        for range b.partitions {
+                hi, lo := b.brokers[0], b.brokers[0]
+                for _, id := range b.brokers {
+                        if leaders[id] > leaders[hi] {
+                                hi = id
+                        }
+                        if leaders[id] < leaders[lo] {
+                                lo = id
+                        }
+                }
+                if leaders[hi]-leaders[lo] < 2 {
+                        return
+                }
+
+                var best *Partition
+                bestIndex := -1
+                for i := range b.partitions {
+                        p := &b.partitions[i]
+                        if p.Replicas[0] != hi {
+                                continue
+                        }
+                        for j := 1; j < len(p.Replicas); j++ {
+                                r := p.Replicas[j]
+                                if leaders[r] <= leaders[hi]-2 && (best == nil || leaders[r] < leaders[best.Replicas[bestIndex]]) {
+                                        best, bestIndex = p, j
+                                }
+                        }
+                }
+                if best == nil {
+                        return
+                }
+                newLeader := best.Replicas[bestIndex]
+                best.Replicas[0], best.Replicas[bestIndex] = newLeader, hi
+                leaders[hi]--
+                leaders[newLeader]++
+        }
+}
+
+func contains(replicas []int32, broker int32) bool {
+        return indexOf(replicas, broker) >= 0
+}
+
+func indexOf(replicas []int32, broker int32) int {
+        for i, r := range replicas {
+                if r == broker {
+                        return i
+                }
+        }
+        return -1
+}
+
+// SameReplicas reports whether two replica lists are equal, including their order
+func SameReplicas(a, b []int32) bool {
+        if len(a) != len(b) {
+                return false
+        }
+        for i := range a {
+                if a[i] != b[i] {
+                        return false
+                }
+        }
+        return true
+}
@@ -0,0 +1,155 @@
+package reassign
+
+import (
+        "strings"
+        "testing"
+)
+
+// assignment builds partitions of topic t from replica lists
+func assignment(replicas ...[]int32) []Partition {
+        partitions := make([]Partition, len(replicas))
+        for i, r := range replicas {
+                partitions[i] = Partition{Topic: "t", Partition: int32(i), Replicas: r}
+        }
+        return partitions
+}
+
+// counts returns the replicas and preferred leaders per broker
+func counts(partitions []Partition) (replicas, leaders map[int32]int) {
+        replicas, leaders = make(map[int32]int), make(map[int32]int)
+        for _, p := range partitions {
+                for _, r := range p.Replicas {
+                        replicas[r]++
+                }
+                leaders[p.Replicas[0]]++
+        }
+        return replicas, leaders
+}
+
+// spread is the difference between the most and least loaded of the brokers
+func spread(load map[int32]int, brokers []int32) int {
+        lo, hi := load[brokers[0]], load[brokers[0]]
+        for _, b := range brokers {
+                lo, hi = min(lo, load[b]), max(hi, load[b])
+        }
+        return hi - lo
+}
+
+func TestBalance(t *testing.T) {
+        tests := []struct {
+                name    string
+                current []Partition
+                opts    Options
+                moves   int // Replicas expected to move
+        }{
+                {
+                        name:    "balanced stays put",
+                        current: assignment([]int32{1, 2}, []int32{2, 3}, []int32{3, 1}),
+                        opts:    Options{Brokers: []int32{1, 2, 3}},
+                        moves:   0,
+                },
+                {
+                        name:    "new broker takes one replica",
+                        current: assignment([]int32{1}, []int32{1}, []int32{1}, []int32{2}),
+                        opts:    Options{Brokers: []int32{1, 2, 3}},
+                        moves:   1,
+                },
+                {
+                        name:    "removed broker is drained",
+                        current: assignment([]int32{1, 4}, []int32{2, 4}, []int32{3, 4}, []int32{4, 1}),
+                        opts:    Options{Brokers: []int32{1, 2, 3}},
+                        moves:   4,
+                },
+                {
+                        name:    "leaders are balanced without moving data",
+                        current: assignment([]int32{1, 2, 3}, []int32{1, 2, 3}, []int32{1, 2, 3}),
+                        opts:    Options{Brokers: []int32{1, 2, 3}},
+                        moves:   0,
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        proposed, err := Balance(tt.current, tt.opts)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if moves, _ := Movement(tt.current, proposed, nil); moves != tt.moves {
+                                t.Errorf("moved %d replicas, want %d: %v", moves, tt.moves, proposed)
+                        }
+                        replicas, leaders := counts(proposed)
+                        if s := spread(replicas, tt.opts.Brokers); s > 1 {
+                                t.Errorf("replica counts %v differ by %d", replicas, s)
+                        }
+                        if s := spread(leaders, tt.opts.Brokers); s > 1 {
+                                t.Errorf("leader counts %v differ by %d", leaders, s)
+                        }
+                        allowed := make(map[int32]bool)
+                        for _, b := range tt.opts.Brokers {
+                                allowed[b] = true
+                        }
+                        for _, p := range proposed {
+                                seen := make(map[int32]bool)
+                                for _, r := range p.Replicas {
+                                        if !allowed[r] || seen[r] {
+                                                t.Errorf("%s/%d has replicas %v", p.Topic, p.Partition, p.Replicas)
+                                        }
+                                        seen[r] = true
+                                }
+                        }
+                })
+        }
+}
+
+func TestBalanceKeepsRacksDistinct(t *testing.T) {
+        racks := map[int32]string{1: "a", 2: "a", 3: "b", 4: "b"}
+        // Broker 3 is drained; 2 is the least loaded broker but shares a rack with 1
+        current := assignment([]int32{1, 3}, []int32{4, 1}, []int32{1, 4})
+        proposed, err := Balance(current, Options{Brokers: []int32{1, 2, 4}, Racks: racks})
+        if err != nil {
+                t.Fatal(err)
+        }
+        for _, p := range proposed {
+                if racks[p.Replicas[0]] == racks[p.Replicas[1]] {
+                        t.Errorf("%s/%d has replicas %v on one rack", p.Topic, p.Partition, p.Replicas)
+                }
+        }
+        if SameReplicas(proposed[0].Replicas, []int32{1, 2}) {
+                t.Errorf("replica of broker 3 moved to the rack of the other replica")
+        }
+}
+
+func TestBalanceMovesSmallestPartitions(t *testing.T) {
+        current := []Partition{
+                {Topic: "big", Partition: 0, Replicas: []int32{1}},
+                {Topic: "small", Partition: 0, Replicas: []int32{1}},
+        }
+        sizes := map[Key]int64{{Topic: "big", Partition: 0}: 1 << 30, {Topic: "small", Partition: 0}: 1 << 10}
+        proposed, err := Balance(current, Options{Brokers: []int32{1, 2}, Sizes: sizes})
+        if err != nil {
+                t.Fatal(err)
+        }
+        moves, bytes := Movement(current, proposed, sizes)
+        if moves != 1 || bytes != 1<<10 {
+                t.Errorf("moved %d replicas and %d bytes, want only the small partition: %v", moves, bytes, proposed)
+        }
+}
+
+func TestBalanceErrors(t *testing.T) {
+        if _, err := Balance(assignment([]int32{1}), Options{}); err == nil {
+                t.Error("no error without brokers")
+        }
+        _, err := Balance(assignment([]int32{1, 2, 3}), Options{Brokers: []int32{1, 2}})
+        if err == nil || !strings.Contains(err.Error(), "only 2 brokers") {
+                t.Errorf("err = %v, want too few brokers for the replication factor", err)
+        }
+}
+
+func TestBalanceLeavesInputAlone(t *testing.T) {
+        current := assignment([]int32{1}, []int32{1})
+        if _, err := Balance(current, Options{Brokers: []int32{1, 2}}); err != nil {
+                t.Fatal(err)
+        }
+        if !SameReplicas(current[0].Replicas, []int32{1}) || !SameReplicas(current[1].Replicas, []int32{1}) {
+                t.Errorf("current assignment was modified: %v", current)
+        }
+}
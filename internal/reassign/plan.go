@@ -0,0 +1,108 @@
+package reassign
+
+import (
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "sort"
+)
+
+// Plan is a partition reassignment in the JSON format of kafka-reassign-partitions.sh
+type Plan struct {
+        Version    int         `json:"version"`
+        Partitions []Partition `json:"partitions"`
+}
+
+// Partition is the replica list of one partition; the first replica is the preferred leader
+type Partition struct {
+        Topic     string   `json:"topic"`
+        Partition int32    `json:"partition"`
+        Replicas  []int32  `json:"replicas"`
+        LogDirs   []string `json:"log_dirs,omitempty"`
+}
+
+// Key identifies a partition
+type Key struct {
+        Topic     string
+        Partition int32
+}
+
+func (p Partition) Key() Key {
+        return Key{Topic: p.Topic, Partition: p.Partition}
+}
+
+// NewPlan builds a plan from partitions, sorted by topic and partition
+func NewPlan(partitions []Partition) *Plan {
+        plan := &Plan{Version: 1, Partitions: append([]Partition(nil), partitions...)}
+        plan.Sort()
+        return plan
+}
+
+func (p *Plan) Sort() {
+        sort.Slice(p.Partitions, func(i, j int) bool {
+                if p.Partitions[i].Topic != p.Partitions[j].Topic {
+                        return p.Partitions[i].Topic < p.Partitions[j].Topic
+                }
+                return p.Partitions[i].Partition < p.Partitions[j].Partition
+        })
+}
+
+// Write writes the plan as indented JSON
+func (p *Plan) Write(w io.Writer) error {
+        data, err := json.MarshalIndent(p, "", "  ")
+        if err != nil {
+                return err
+        }
+        _, err = w.Write(append(data, '\n'))
+        return err
+}
+
+// WriteFile writes the plan to a file
+func (p *Plan) WriteFile(path string) error {
+        file, err := os.Create(path)
+        if err != nil {
+                return fmt.Errorf("failed to create %s: %w", path, err)
+        }
+        err = p.Write(file)
+        if closeErr := file.Close(); err == nil {
+                err = closeErr
+        }
+        return err
+}
+
+// LoadPlan reads and validates a plan file
+func LoadPlan(path string) (*Plan, error) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+                return nil, fmt.Errorf("failed to read reassignment file: %w", err)
+        }
+        plan := &Plan{}
+        if err := json.Unmarshal(data, plan); err != nil {
+                return nil, fmt.Errorf("failed to parse reassignment file %s: %w", path, err)
+        }
+        if plan.Version != 1 {
+                return nil, fmt.Errorf("reassignment file %s: unsupported version %d", path, plan.Version)
+        }
+        seen := make(map[Key]bool)
+        for _, p := range plan.Partitions {
+                if p.Topic == "" || len(p.Replicas) == 0 {
+                        return nil, fmt.Errorf("reassignment file %s: every partition needs a topic and replicas", path)
+                }
+                if seen[p.Key()] {
+                        return nil, fmt.Errorf("reassignment file %s: %s/%d is listed twice", path, p.Topic, p.Partition)
+                }
+                seen[p.Key()] = true
+                brokers := make(map[int32]bool)
+                for _, r := range p.Replicas {
+                        if brokers[r] {
+                                return nil, fmt.Errorf("reassignment file %s: %s/%d lists broker %d twice", path, p.Topic, p.Partition, r)
+                        }
+                        brokers[r] = true
+                }
+                if len(p.LogDirs) > 0 && len(p.LogDirs) != len(p.Replicas) {
+                        return nil, fmt.Errorf("reassignment file %s: %s/%d needs one log dir per replica", path, p.Topic, p.Partition)
+                }
+        }
+        return plan, nil
+}
@@ -0,0 +1,94 @@
+package reassign
+
+import (
+        "fmt"
+        "sort"
+        "strings"
+)
+
+// Throttles returns, per topic, the values of the leader and follower
+// throttled replicas configs for executing the plan. Leader throttles cover
+// the current replicas, which serve the copies, and follower throttles the
+// replicas being added. Partitions whose replica set does not change are left out.
+func Throttles(current map[Key][]int32, plan *Plan) (leaders, followers map[string]string) {
+        leaderPairs := make(map[string][]string)
+        followerPairs := make(map[string][]string)
+        for _, p := range plan.Partitions {
+                before := current[p.Key()]
+                var added []int32
+                for _, r := range p.Replicas {
+                        if !contains(before, r) {
+                                added = append(added, r)
+                        }
+                }
+                if len(added) == 0 {
+                        continue
+                }
+                for _, r := range before {
+                        leaderPairs[p.Topic] = append(leaderPairs[p.Topic], fmt.Sprintf("%d:%d", p.Partition, r))
+                }
+                for _, r := range added {
+                        followerPairs[p.Topic] = append(followerPairs[p.Topic], fmt.Sprintf("%d:%d", p.Partition, r))
+                }
+        }
+
+        join := func(pairs map[string][]string) map[string]string {
+                values := make(map[string]string)
+                for topic, list := range pairs {
+                        sort.Strings(list)
+                        values[topic] = strings.Join(list, ",")
+                }
+                return values
+        }
+        return join(leaderPairs), join(followerPairs)
+}
+
+// PlanReplicas returns, per topic, every partition:broker pair of the plan's
+// partitions on the brokers. It covers whatever Throttles listed for the plan,
+// whatever the assignment was when it ran, so it can be subtracted afterwards.
+func PlanReplicas(plan *Plan, brokers []int32) map[string]string {
+        pairs := make(map[string][]string)
+        seen := make(map[Key]bool)
+        for _, p := range plan.Partitions {
+                if seen[p.Key()] {
+                        continue
+                }
+                seen[p.Key()] = true
+                for _, broker := range brokers {
+                        pairs[p.Topic] = append(pairs[p.Topic], fmt.Sprintf("%d:%d", p.Partition, broker))
+                }
+        }
+        values := make(map[string]string)
+        for topic, list := range pairs {
+                sort.Strings(list)
+                values[topic] = strings.Join(list, ",")
+        }
+        return values
+}
+
+// Brokers returns the brokers that host, or will host, a replica of a
+// partition in the plan that gains replicas, in ascending order
+func Brokers(current map[Key][]int32, plan *Plan) []int32 {
+        seen := make(map[int32]bool)
+        for _, p := range plan.Partitions {
+                before := current[p.Key()]
+                moving := false
+                for _, r := range p.Replicas {
+                        if !contains(before, r) {
+                                moving = true
+                        }
+                }
+                if !moving {
+                        continue
+                }
+                for _, r := range append(append([]int32(nil), before...), p.Replicas...) {
+                        seen[r] = true
+                }
+        }
+        brokers := make([]int32, 0, len(seen))
+        for r := range seen {
+                brokers = append(brokers, r)
+        }
+        sort.Slice(brokers, func(i, j int) bool { return brokers[i] < brokers[j] })
+        return brokers
+}
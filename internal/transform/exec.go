@@ -0,0 +1,235 @@
+package transform
+
+import (
+        "bufio"
+        "bytes"
+        "encoding/base64"
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "os/exec"
+        "runtime"
+        "time"
+        "unicode/utf8"
+)
+
+// execStep pipes each record through a long-running external command, one
+// JSON object per line in each direction. It is the escape hatch for logic the
+// built-in steps cannot express.
+//
+// The command reads lines like
+//
+//      {"topic":"orders","partition":0,"key":"k1","value":"{\"id\":1}","headers":[{"key":"h","value":"v"}],"timestamp":1700000000000}
+//
+// and must answer every line with exactly one line, flushed straight away:
+// the record (fields left out keep their values), or null to drop it. Key,
+// value and header values are strings; those that are not valid UTF-8 are
+// base64 encoded and listed in "base64", e.g. "base64":["value"]. Replies may
+// use "base64" the same way.
+type execStep struct {
+        source  string
+        command string
+        cmd     *exec.Cmd
+        stdin   io.WriteCloser
+        stdout  *bufio.Reader
+}
+
+type execHeader struct {
+        Key   string  `json:"key"`
+        Value *string `json:"value"`
+}
+
+type execRecord struct {
+        Topic     *string         `json:"topic,omitempty"`
+        Partition *int32          `json:"partition,omitempty"`
+        Key       json.RawMessage `json:"key,omitempty"`
+        Value     json.RawMessage `json:"value,omitempty"`
+        Headers   []execHeader    `json:"headers,omitempty"`
+        Timestamp *int64          `json:"timestamp,omitempty"`
+        Base64    []string        `json:"base64,omitempty"`
+}
+
+func newExecStep(source, command string) (*execStep, error) {
+        var cmd *exec.Cmd
+        if runtime.GOOS == "windows" {
+                cmd = exec.Command("cmd", "/C", command)
+        } else {
+                cmd = exec.Command("sh", "-c", command)
+        }
+        cmd.Stderr = os.Stderr
+
+        stdin, err := cmd.StdinPipe()
+        if err != nil {
+                return nil, err
+        }
+        stdout, err := cmd.StdoutPipe()
+        if err != nil {
+                return nil, err
+        }
+        if err := cmd.Start(); err != nil {
+                return nil, fmt.Errorf("failed to start '%s': %w", command, err)
+        }
+
+        step := &execStep{
+                source:  source,
+                command: command,
+                cmd:     cmd,
+                stdin:   stdin,
+                stdout:  bufio.NewReaderSize(stdout, 64*1024),
+        }
+        return step, nil
+}
+
+func (s *execStep) String() string {
+        return s.source
+}
+
+func (s *execStep) Apply(r *Record) (bool, error) {
+        if err := r.flush(); err != nil {
+                return false, err
+        }
+
+        line, err := json.Marshal(encodeExecRecord(r))
+        if err != nil {
+                return false, err
+        }
+        if _, err := s.stdin.Write(append(line, '\n')); err != nil {
+                return false, fmt.Errorf("failed to write to '%s': %w", s.command, err)
+        }
+
+        reply, err := s.stdout.ReadBytes('\n')
+        if err != nil {
+                if err == io.EOF {
+                        return false, fmt.Errorf("'%s' exited without answering", s.command)
+                }
+                return false, fmt.Errorf("failed to read from '%s': %w", s.command, err)
+        }
+        reply = bytes.TrimSpace(reply)
+        if bytes.Equal(reply, []byte("null")) {
+                return false, nil
+        }
+
+        var result execRecord
+        if err := json.Unmarshal(reply, &result); err != nil {
+                return false, fmt.Errorf("'%s' answered with invalid JSON: %w", s.command, err)
+        }
+        if err := decodeExecRecord(&result, r); err != nil {
+                return false, fmt.Errorf("'%s' answered with %w", s.command, err)
+        }
+        return true, nil
+}
+
+// Close ends the command's input and waits briefly for it to exit
+func (s *execStep) Close() error {
+        s.stdin.Close()
+        exited := make(chan error, 1)
+        go func() { exited <- s.cmd.Wait() }()
+        select {
+        case err := <-exited:
+                return err
+        case <-time.After(5 * time.Second):
+                s.cmd.Process.Kill()
+                return fmt.Errorf("'%s' did not exit after its input was closed", s.command)
+        }
+}
+
+func encodeExecRecord(r *Record) execRecord {
+        record := execRecord{Topic: &r.Topic}
+        if r.Partition >= 0 {
+                partition := r.Partition
+                record.Partition = &partition
+        }
+        if !r.Timestamp.IsZero() {
+                timestamp := r.Timestamp.UnixMilli()
+                record.Timestamp = &timestamp
+        }
+
+        encode := func(field string, data []byte) *string {
+                if data == nil {
+                        return nil
+                }
+                text := string(data)
+                if !utf8.Valid(data) {
+                        text = base64.StdEncoding.EncodeToString(data)
+                        if !contains(record.Base64, field) {
+                                record.Base64 = append(record.Base64, field)
+                        }
+                }
+                return &text
+        }
+        record.Key, _ = json.Marshal(encode("key", r.Key))
+        record.Value, _ = json.Marshal(encode("value", r.Value))
+        for _, header := range r.Headers {
+                record.Headers = append(record.Headers, execHeader{Key: header.Key, Value: encode("headers", header.Value)})
+        }
+        return record
+}
+
+func decodeExecRecord(reply *execRecord, r *Record) error {
+        decode := func(field string, text *string) ([]byte, error) {
+                if text == nil {
+                        return nil, nil
+                }
+                if contains(reply.Base64, field) {
+                        data, err := base64.StdEncoding.DecodeString(*text)
+                        if err != nil {
+                                return nil, fmt.Errorf("invalid base64 in %s: %w", field, err)
+                        }
+                        return data, nil
+                }
+                return []byte(*text), nil
+        }
+        decodeRaw := func(field string, raw json.RawMessage) ([]byte, error) {
+                var text *string
+                if err := json.Unmarshal(raw, &text); err != nil {
+                        return nil, fmt.Errorf("%s that is not a string or null", field)
+                }
+                return decode(field, text)
+        }
+
+        if reply.Topic != nil {
+                r.Topic = *reply.Topic
+        }
+        if reply.Partition != nil {
+                r.Partition = *reply.Partition
+        }
+        if reply.Timestamp != nil {
+                r.Timestamp = time.UnixMilli(*reply.Timestamp)
+        }
+        if reply.Key != nil {
+                key, err := decodeRaw("key", reply.Key)
+                if err != nil {
+                        return err
+                }
+                r.Key = key
+        }
+        if reply.Value != nil {
+                value, err := decodeRaw("value", reply.Value)
+                if err != nil {
+                        return err
+                }
+                r.setValue(value)
+        }
+        if reply.Headers != nil {
+                headers := make([]Header, 0, len(reply.Headers))
+                for _, header := range reply.Headers {
+                        value, err := decode("headers", header.Value)
+                        if err != nil {
+                                return err
+                        }
+                        headers = append(headers, Header{Key: header.Key, Value: value})
+                }
+                r.Headers = headers
+        }
+        return nil
+}
+
+func contains(list []string, s string) bool {
+        for _, item := range list {
+                if item == s {
+                        return true
+                }
+        }
+        return false
+}
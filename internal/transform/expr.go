@@ -0,0 +1,243 @@
+package transform
+
+import (
+        "fmt"
+        "regexp"
+        "strconv"
+        "strings"
+)
+
+// operand is a part of a record an expression refers to: key, value, topic,
+// partition, header.<name> or a value field path such as .customer.id
+type operand struct {
+        source string
+        kind   string
+        header string
+        path   Path
+}
+
+func parseOperand(source string) (operand, error) {
+        source = strings.TrimSpace(source)
+        op := operand{source: source, kind: source}
+        switch {
+        case source == "key" || source == "value" || source == "topic" || source == "partition":
+        case strings.HasPrefix(source, "header.") || strings.HasPrefix(source, "headers."):
+                op.kind = "header"
+                _, op.header, _ = strings.Cut(source, ".")
+                if op.header == "" {
+                        return op, fmt.Errorf("missing header name in '%s'", source)
+                }
+        case strings.HasPrefix(source, ".") || strings.HasPrefix(source, "$"):
+                path, err := ParsePath(source)
+                if err != nil {
+                        return op, err
+                }
+                op.kind, op.path = "field", path
+        default:
+                return op, fmt.Errorf("unknown operand '%s' (use key, value, topic, partition, header.<name> or a field path like .id)", source)
+        }
+        return op, nil
+}
+
+// resolve returns the operand's text and whether it is present in the record
+func (o operand) resolve(r *Record) (string, bool, error) {
+        switch o.kind {
+        case "key":
+                return string(r.Key), r.Key != nil, nil
+        case "value":
+                if err := r.flush(); err != nil {
+                        return "", false, err
+                }
+                return string(r.Value), r.Value != nil, nil
+        case "topic":
+                return r.Topic, true, nil
+        case "partition":
+                return strconv.Itoa(int(r.Partition)), true, nil
+        case "header":
+                value, ok := r.Header(o.header)
+                return string(value), ok, nil
+        }
+
+        if r.Value == nil && !r.decoded {
+                return "", false, nil // A tombstone has no fields
+        }
+        document, err := r.Document()
+        if err != nil {
+                return "", false, err
+        }
+        values := o.path.Get(document)
+        if len(values) == 0 || values[0] == nil {
+                return "", false, nil
+        }
+        return valueText(values[0]), true, nil
+}
+
+// Condition decides whether a step applies to a record. Supported forms:
+// "<operand>" (present), "!<operand>" (absent), "tombstone", and
+// "<operand> <op> <literal>" with ==, !=, =~, !~ (regular expressions) or
+// the numeric comparisons >, >=, <, <=.
+type Condition struct {
+        source  string
+        operand operand
+        op      string
+        literal string
+        number  float64
+        pattern *regexp.Regexp
+        negate  bool
+}
+
+var conditionOperators = []string{"==", "!=", "=~", "!~", ">=", "<=", ">", "<"}
+
+// ParseCondition parses a condition expression
+func ParseCondition(source string) (*Condition, error) {
+        source = strings.TrimSpace(source)
+        c := &Condition{source: source}
+        if source == "" {
+                return nil, fmt.Errorf("empty condition")
+        }
+        if source == "tombstone" {
+                source = "!value"
+        }
+
+        // The leftmost operator wins; at the same position the two-character one does
+        index, op := -1, ""
+        for _, candidate := range conditionOperators {
+                if i := strings.Index(source, candidate); i >= 0 && (index < 0 || i < index) {
+                        index, op = i, candidate
+                }
+        }
+
+        if index < 0 {
+                if strings.HasPrefix(source, "!") {
+                        c.negate = true
+                        source = source[1:]
+                }
+                operand, err := parseOperand(source)
+                if err != nil {
+                        return nil, err
+                }
+                c.operand = operand
+                return c, nil
+        }
+
+        operand, err := parseOperand(source[:index])
+        if err != nil {
+                return nil, err
+        }
+        c.operand, c.op = operand, op
+        c.literal = unquote(strings.TrimSpace(source[index+len(op):]))
+
+        switch op {
+        case "=~", "!~":
+                pattern, err := regexp.Compile(c.literal)
+                if err != nil {
+                        return nil, fmt.Errorf("invalid regular expression in '%s': %w", c.source, err)
+                }
+                c.pattern = pattern
+        case ">", ">=", "<", "<=":
+                number, err := strconv.ParseFloat(c.literal, 64)
+                if err != nil {
+                        return nil, fmt.Errorf("'%s' compares with '%s', which is not a number", c.source, c.literal)
+                }
+                c.number = number
+        }
+        return c, nil
+}
+
+func (c *Condition) String() string {
+        return c.source
+}
+
+// Match evaluates the condition against a record
+func (c *Condition) Match(r *Record) (bool, error) {
+        text, present, err := c.operand.resolve(r)
+        if err != nil {
+                return false, err
+        }
+
+        switch c.op {
+        case "":
+                return present != c.negate, nil
+        case "==":
+                return present && text == c.literal, nil
+        case "!=":
+                return !present || text != c.literal, nil
+        case "=~":
+                return present && c.pattern.MatchString(text), nil
+        case "!~":
+                return !present || !c.pattern.MatchString(text), nil
+        }
+
+        if !present {
+                return false, nil
+        }
+        number, err := strconv.ParseFloat(text, 64)
+        if err != nil {
+                return false, fmt.Errorf("%s is '%s', which is not a number", c.operand.source, text)
+        }
+        switch c.op {
+        case ">":
+                return number > c.number, nil
+        case ">=":
+                return number >= c.number, nil
+        case "<":
+                return number < c.number, nil
+        }
+        return number <= c.number, nil
+}
+
+// Template is text with {operand} placeholders, e.g. "orders-{.region}" or "{topic}.masked"
+type Template struct {
+        source   string
+        literals []string // One more than operands: text before, between and after the placeholders
+        operands []operand
+}
+
+// ParseTemplate parses a template
+func ParseTemplate(source string) (*Template, error) {
+        t := &Template{source: source}
+        rest := source
+        for {
+                start := strings.IndexByte(rest, '{')
+                if start < 0 {
+                        t.literals = append(t.literals, rest)
+                        return t, nil
+                }
+                end := strings.IndexByte(rest[start:], '}')
+                if end < 0 {
+                        return nil, fmt.Errorf("unterminated '{' in '%s'", source)
+                }
+                operand, err := parseOperand(rest[start+1 : start+end])
+                if err != nil {
+                        return nil, err
+                }
+                t.literals = append(t.literals, rest[:start])
+                t.operands = append(t.operands, operand)
+                rest = rest[start+end+1:]
+        }
+}
+
+// Render fills in the placeholders; a missing operand is an error
+func (t *Template) Render(r *Record) (string, error) {
+        var b strings.Builder
+        for i, operand := range t.operands {
+                b.WriteString(t.literals[i])
+                text, present, err := operand.resolve(r)
+                if err != nil {
+                        return "", err
+                }
+                if !present {
+                        return "", fmt.Errorf("%s is not set, needed for '%s'", operand.source, t.source)
+                }
+                b.WriteString(text)
+        }
+        b.WriteString(t.literals[len(t.literals)-1])
+        return b.String(), nil
+}
+
+func unquote(s string) string {
+        if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+                return s[1 : len(s)-1]
+        }
+        return s
+}
@@ -0,0 +1,279 @@
+package transform
+
+import (
+        "bytes"
+        "encoding/json"
+        "fmt"
+        "io"
+        "strconv"
+        "strings"
+)
+
+// object is a decoded JSON object that keeps its key order, so transformed
+// values only differ from the originals where a step changed them
+type object struct {
+        keys   []string
+        values map[string]interface{}
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+        var buf bytes.Buffer
+        buf.WriteByte('{')
+        for i, key := range o.keys {
+                if i > 0 {
+                        buf.WriteByte(',')
+                }
+                name, err := json.Marshal(key)
+                if err != nil {
+                        return nil, err
+                }
+                buf.Write(name)
+                buf.WriteByte(':')
+                value, err := marshalJSON(o.values[key])
+                if err != nil {
+                        return nil, err
+                }
+                buf.Write(value)
+        }
+        buf.WriteByte('}')
+        return buf.Bytes(), nil
+}
+
+// marshalJSON encodes without HTML escaping, so values round-trip byte for byte
+func marshalJSON(v interface{}) ([]byte, error) {
+        var buf bytes.Buffer
+        encoder := json.NewEncoder(&buf)
+        encoder.SetEscapeHTML(false)
+        if err := encoder.Encode(v); err != nil {
+                return nil, err
+        }
+        return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// decodeJSON decodes a document keeping object key order and exact numbers
+func decodeJSON(data []byte) (interface{}, error) {
+        decoder := json.NewDecoder(bytes.NewReader(data))
+        decoder.UseNumber()
+        value, err := decodeValue(decoder)
+        if err != nil {
+                return nil, err
+        }
+        if _, err := decoder.Token(); err != io.EOF {
+                return nil, fmt.Errorf("unexpected data after JSON value")
+        }
+        return value, nil
+}
+
+func decodeValue(decoder *json.Decoder) (interface{}, error) {
+        token, err := decoder.Token()
+        if err != nil {
+                return nil, err
+        }
+        delim, ok := token.(json.Delim)
+        if !ok {
+                return token, nil
+        }
+
+        switch delim {
+        case '{':
+                o := &object{values: make(map[string]interface{})}
+                for decoder.More() {
+                        keyToken, err := decoder.Token()
+                        if err != nil {
+                                return nil, err
+                        }
+                        key := keyToken.(string)
+                        value, err := decodeValue(decoder)
+                        if err != nil {
+                                return nil, err
+                        }
+                        if _, exists := o.values[key]; !exists {
+                                o.keys = append(o.keys, key)
+                        }
+                        o.values[key] = value
+                }
+                if _, err := decoder.Token(); err != nil {
+                        return nil, err
+                }
+                return o, nil
+        case '[':
+                list := []interface{}{}
+                for decoder.More() {
+                        value, err := decodeValue(decoder)
+                        if err != nil {
+                                return nil, err
+                        }
+                        list = append(list, value)
+                }
+                if _, err := decoder.Token(); err != nil {
+                        return nil, err
+                }
+                return list, nil
+        }
+        return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+// pathSegment is one step of a path: an object key, an array index or a wildcard
+type pathSegment struct {
+        key      string
+        index    int
+        isIndex  bool
+        wildcard bool
+}
+
+// Path is a parsed JSONPath subset: $.a.b, .a.b, a.b, a[0], a[*].b, a.*.b and ['a b']
+type Path struct {
+        source   string
+        segments []pathSegment
+}
+
+func (p Path) String() string {
+        return p.source
+}
+
+// ParsePath parses a field path
+func ParsePath(source string) (Path, error) {
+        path := Path{source: source}
+        s := strings.TrimSpace(source)
+        s = strings.TrimPrefix(s, "$")
+        if s == "" || s == "." {
+                return path, fmt.Errorf("path '%s' does not select a field", source)
+        }
+
+        for len(s) > 0 {
+                switch {
+                case s[0] == '.':
+                        s = s[1:]
+                        if s == "" {
+                                return path, fmt.Errorf("path '%s' ends with '.'", source)
+                        }
+                case s[0] == '[':
+                        end := strings.IndexByte(s, ']')
+                        if end < 0 {
+                                return path, fmt.Errorf("unterminated '[' in path '%s'", source)
+                        }
+                        inner := strings.TrimSpace(s[1:end])
+                        s = s[end+1:]
+                        switch {
+                        case inner == "*":
+                                path.segments = append(path.segments, pathSegment{wildcard: true})
+                        case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+                                path.segments = append(path.segments, pathSegment{key: inner[1 : len(inner)-1]})
+                        default:
+                                index, err := strconv.Atoi(inner)
+                                if err != nil {
+                                        return path, fmt.Errorf("invalid index '[%s]' in path '%s'", inner, source)
+                                }
+                                path.segments = append(path.segments, pathSegment{index: index, isIndex: true})
+                        }
+                        continue
+                }
+
+                end := strings.IndexAny(s, ".[")
+                if end < 0 {
+                        end = len(s)
+                }
+                name := s[:end]
+                s = s[end:]
+                switch {
+                case name == "*":
+                        path.segments = append(path.segments, pathSegment{wildcard: true})
+                case name == "":
+                        return path, fmt.Errorf("empty field name in path '%s'", source)
+                default:
+                        if index, err := strconv.Atoi(name); err == nil {
+                                // a.0.b is accepted like a[0].b, matching --key-field
+                                path.segments = append(path.segments, pathSegment{key: name, index: index, isIndex: true})
+                        } else {
+                                path.segments = append(path.segments, pathSegment{key: name})
+                        }
+                }
+        }
+        return path, nil
+}
+
+// Get returns every value the path selects
+func (p Path) Get(document interface{}) []interface{} {
+        var found []interface{}
+        p.walk(document, 0, func(parent interface{}, key string, index int) {
+                switch node := parent.(type) {
+                case *object:
+                        found = append(found, node.values[key])
+                case []interface{}:
+                        found = append(found, node[index])
+                }
+        })
+        return found
+}
+
+// Update replaces every selected value with fn's result and returns how many were changed
+func (p Path) Update(document interface{}, fn func(interface{}) interface{}) int {
+        count := 0
+        p.walk(document, 0, func(parent interface{}, key string, index int) {
+                switch node := parent.(type) {
+                case *object:
+                        node.values[key] = fn(node.values[key])
+                case []interface{}:
+                        node[index] = fn(node[index])
+                }
+                count++
+        })
+        return count
+}
+
+// walk calls visit with the container and position of each selected value
+func (p Path) walk(current interface{}, depth int, visit func(parent interface{}, key string, index int)) {
+        segment := p.segments[depth]
+        last := depth == len(p.segments)-1
+
+        step := func(parent interface{}, key string, index int, child interface{}) {
+                if last {
+                        visit(parent, key, index)
+                } else {
+                        p.walk(child, depth+1, visit)
+                }
+        }
+
+        switch node := current.(type) {
+        case *object:
+                if segment.wildcard {
+                        for _, key := range node.keys {
+                                step(node, key, 0, node.values[key])
+                        }
+                        return
+                }
+                if segment.isIndex && segment.key == "" {
+                        return
+                }
+                if child, ok := node.values[segment.key]; ok {
+                        step(node, segment.key, 0, child)
+                }
+        case []interface{}:
+                if segment.wildcard {
+                        for i, child := range node {
+                                step(node, "", i, child)
+                        }
+                        return
+                }
+                if segment.isIndex && segment.index >= 0 && segment.index < len(node) {
+                        step(node, "", segment.index, node[segment.index])
+                }
+        }
+}
+
+// valueText renders a JSON value as text: strings as-is, null as "", others as JSON
+func valueText(v interface{}) string {
+        switch value := v.(type) {
+        case string:
+                return value
+        case nil:
+                return ""
+        case json.Number:
+                return value.String()
+        }
+        data, err := marshalJSON(v)
+        if err != nil {
+                return fmt.Sprint(v)
+        }
+        return string(data)
+}
@@ -0,0 +1,82 @@
+package transform
+
+import (
+        "fmt"
+)
+
+// Pipeline applies a list of steps to each record, in order
+type Pipeline struct {
+        steps []Step
+}
+
+// NewPipeline parses step specifications. External commands are started
+// straight away; Close stops them. In strict mode, mask and hash steps fail
+// records whose value has no field their path selects.
+func NewPipeline(specs []string, strict bool) (*Pipeline, error) {
+        p := &Pipeline{}
+        for _, spec := range specs {
+                step, err := Parse(spec)
+                if err != nil {
+                        p.Close()
+                        return nil, err
+                }
+                if update := fieldUpdateOf(step); update != nil {
+                        update.strict = strict
+                }
+                p.steps = append(p.steps, step)
+        }
+        return p, nil
+}
+
+// Apply runs the record through every step. It returns false when a step
+// dropped the record; the error names the step that failed.
+func (p *Pipeline) Apply(r *Record) (bool, error) {
+        for _, step := range p.steps {
+                keep, err := step.Apply(r)
+                if err != nil {
+                        return false, fmt.Errorf("%s: %w", step, err)
+                }
+                if !keep {
+                        return false, nil
+                }
+        }
+        if err := r.flush(); err != nil {
+                return false, err
+        }
+        return true, nil
+}
+
+// Unmatched returns, per mask or hash step that matched nothing at least once,
+// the number of records whose value had no field the step's path selects
+func (p *Pipeline) Unmatched() map[string]int64 {
+        unmatched := make(map[string]int64)
+        for _, step := range p.steps {
+                if update := fieldUpdateOf(step); update != nil && update.unmatched > 0 {
+                        unmatched[step.String()] = update.unmatched
+                }
+        }
+        return unmatched
+}
+
+func fieldUpdateOf(step Step) *fieldUpdate {
+        switch s := step.(type) {
+        case *maskStep:
+                return &s.fieldUpdate
+        case *hashStep:
+                return &s.fieldUpdate
+        }
+        return nil
+}
+
+// Close stops external commands
+func (p *Pipeline) Close() error {
+        var firstErr error
+        for _, step := range p.steps {
+                if exec, ok := step.(*execStep); ok {
+                        if err := exec.Close(); err != nil && firstErr == nil {
+                                firstErr = err
+                        }
+                }
+        }
+        return firstErr
+}
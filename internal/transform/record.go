@@ -0,0 +1,94 @@
+package transform
+
+import (
+        "fmt"
+        "time"
+)
+
+// Header is a record header
+type Header struct {
+        Key   string
+        Value []byte
+}
+
+// Record is the message a pipeline works on. Steps read and modify it in place.
+type Record struct {
+        Topic     string
+        Partition int32 // -1 lets the producer choose
+        Key       []byte
+        Value     []byte
+        Headers   []Header
+        Timestamp time.Time
+
+        document interface{} // Value decoded as JSON, once a step needed it
+        decoded  bool
+        dirty    bool // document was changed and Value must be re-encoded
+}
+
+// Document returns the value decoded as JSON. Changes to it are written back
+// to Value once the pipeline finishes or a step needs the raw value.
+func (r *Record) Document() (interface{}, error) {
+        if r.decoded {
+                return r.document, nil
+        }
+        if r.Value == nil {
+                return nil, fmt.Errorf("record has no value (tombstone)")
+        }
+        document, err := decodeJSON(r.Value)
+        if err != nil {
+                return nil, fmt.Errorf("value is not valid JSON: %w", err)
+        }
+        r.document, r.decoded = document, true
+        return document, nil
+}
+
+// markChanged notes that the decoded document was modified
+func (r *Record) markChanged() {
+        r.dirty = true
+}
+
+// flush re-encodes a modified document into Value
+func (r *Record) flush() error {
+        if !r.dirty {
+                return nil
+        }
+        value, err := marshalJSON(r.document)
+        if err != nil {
+                return fmt.Errorf("failed to encode transformed value: %w", err)
+        }
+        r.Value, r.dirty = value, false
+        return nil
+}
+
+// setValue replaces the raw value, dropping any decoded document
+func (r *Record) setValue(value []byte) {
+        r.Value = value
+        r.document, r.decoded, r.dirty = nil, false, false
+}
+
+// Header returns the value of the first header with the given key
+func (r *Record) Header(key string) ([]byte, bool) {
+        for _, header := range r.Headers {
+                if header.Key == key {
+                        return header.Value, true
+                }
+        }
+        return nil, false
+}
+
+// SetHeader replaces every header with the key by a single one
+func (r *Record) SetHeader(key string, value []byte) {
+        r.RemoveHeader(key)
+        r.Headers = append(r.Headers, Header{Key: key, Value: value})
+}
+
+// RemoveHeader removes every header with the key
+func (r *Record) RemoveHeader(key string) {
+        kept := r.Headers[:0]
+        for _, header := range r.Headers {
+                if header.Key != key {
+                        kept = append(kept, header)
+                }
+        }
+        r.Headers = kept
+}
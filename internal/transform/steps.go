@@ -0,0 +1,395 @@
+package transform
+
+import (
+        "crypto/hmac"
+        "crypto/sha256"
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "os"
+        "strconv"
+        "strings"
+)
+
+// Step changes a record in place. It returns false to drop the record.
+type Step interface {
+        Apply(r *Record) (bool, error)
+        String() string
+}
+
+// Steps lists the supported step specifications, for help text
+var Steps = []string{
+        "mask:<path>[:<keep-last>]        Replace a value field with asterisks, optionally keeping the last characters",
+        "hash:<path>[:env:<var>|file:<f>] Replace a value field with its HMAC-SHA256 hex digest, keyed from $KAFY_HASH_KEY by default",
+        "set-header:<name>=<template>     Add or replace a header, e.g. set-header:x-source={topic}",
+        "remove-header:<name>             Remove a header",
+        "rename-header:<old>=<new>        Rename a header",
+        "key-from:<operand>               Set the key from a value field (or header.<name>)",
+        "route:[<condition>->]<template>  Send the record to another topic, e.g. route:.region == eu->orders-eu",
+        "drop:<condition>                 Drop records matching a condition, e.g. drop:.type == heartbeat",
+        "exec:<command>                   Pipe records through an external command as NDJSON (the only scripting hook)",
+}
+
+// Parse parses one step specification
+func Parse(spec string) (Step, error) {
+        name, arg, found := strings.Cut(spec, ":")
+        name = strings.ToLower(strings.TrimSpace(name))
+        if !found || strings.TrimSpace(arg) == "" {
+                return nil, fmt.Errorf("invalid transform '%s' (expected <step>:<argument>)", spec)
+        }
+
+        var step Step
+        var err error
+        switch name {
+        case "mask":
+                step, err = newMaskStep(spec, arg)
+        case "hash":
+                step, err = newHashStep(spec, arg)
+        case "set-header":
+                step, err = newSetHeaderStep(spec, arg)
+        case "remove-header":
+                step = &removeHeaderStep{source: spec, name: strings.TrimSpace(arg)}
+        case "rename-header":
+                step, err = newRenameHeaderStep(spec, arg)
+        case "key-from":
+                step, err = newKeyFromStep(spec, arg)
+        case "route":
+                step, err = newRouteStep(spec, arg)
+        case "drop":
+                step, err = newDropStep(spec, arg)
+        case "exec":
+                step, err = newExecStep(spec, strings.TrimSpace(arg))
+        default:
+                return nil, fmt.Errorf("unknown transform step '%s' (supported: mask, hash, set-header, remove-header, rename-header, key-from, route, drop, exec)", name)
+        }
+        if err != nil {
+                return nil, fmt.Errorf("invalid transform '%s': %w", spec, err)
+        }
+        return step, nil
+}
+
+// errNoMatch fails records in strict mode when a mask or hash path selects no
+// field, so a mistyped path does not leave data unmasked
+var errNoMatch = errors.New("path matched no field")
+
+// fieldUpdate rewrites the value fields a path selects. Records whose value has
+// no such field are counted; tombstones have no fields to rewrite and pass through.
+type fieldUpdate struct {
+        path      Path
+        strict    bool
+        unmatched int64
+}
+
+// update applies fn to every value selected by the path; null values are left alone
+func (u *fieldUpdate) update(r *Record, fn func(text string) string) error {
+        if r.Value == nil && !r.decoded {
+                return nil
+        }
+        document, err := r.Document()
+        if err != nil {
+                return err
+        }
+        matched := u.path.Update(document, func(v interface{}) interface{} {
+                if v == nil {
+                        return nil
+                }
+                return fn(valueText(v))
+        })
+        if matched > 0 {
+                r.markChanged()
+                return nil
+        }
+        u.unmatched++
+        if u.strict {
+                return errNoMatch
+        }
+        return nil
+}
+
+type maskStep struct {
+        fieldUpdate
+        source   string
+        keepLast int
+}
+
+func newMaskStep(source, arg string) (*maskStep, error) {
+        step := &maskStep{source: source}
+        pathSpec := arg
+        if i := strings.LastIndex(arg, ":"); i >= 0 {
+                keep, err := strconv.Atoi(strings.TrimSpace(arg[i+1:]))
+                if err != nil || keep < 0 {
+                        return nil, fmt.Errorf("invalid number of characters to keep '%s'", arg[i+1:])
+                }
+                pathSpec, step.keepLast = arg[:i], keep
+        }
+        path, err := ParsePath(pathSpec)
+        if err != nil {
+                return nil, err
+        }
+        step.path = path
+        return step, nil
+}
+
+func (s *maskStep) String() string {
+        return s.source
+}
+
+func (s *maskStep) Apply(r *Record) (bool, error) {
+        return true, s.update(r, func(text string) string {
+                runes := []rune(text)
+                keep := s.keepLast
+                if keep > len(runes) {
+                        keep = len(runes)
+                }
+                return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+        })
+}
+
+// DefaultHashKeyEnv names the environment variable hash steps read their key
+// from when the step does not name a source
+const DefaultHashKeyEnv = "KAFY_HASH_KEY"
+
+type hashStep struct {
+        fieldUpdate
+        source string
+        key    []byte
+}
+
+// newHashStep reads the HMAC key from env:<name> or file:<path>. Keys are never
+// part of the step itself, which would show them in ps and shell history.
+func newHashStep(source, arg string) (*hashStep, error) {
+        pathSpec, keySource, _ := strings.Cut(arg, ":")
+        path, err := ParsePath(pathSpec)
+        if err != nil {
+                return nil, err
+        }
+        key, err := readHashKey(strings.TrimSpace(keySource))
+        if err != nil {
+                return nil, err
+        }
+        return &hashStep{fieldUpdate: fieldUpdate{path: path}, source: source, key: key}, nil
+}
+
+func readHashKey(keySource string) ([]byte, error) {
+        if keySource == "" {
+                keySource = "env:" + DefaultHashKeyEnv
+        }
+        kind, name, _ := strings.Cut(keySource, ":")
+        var key string
+        switch kind {
+        case "env":
+                key = os.Getenv(name)
+                if key == "" {
+                        return nil, fmt.Errorf("hash key variable %s is not set", name)
+                }
+        case "file":
+                raw, err := os.ReadFile(name)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to read hash key: %w", err)
+                }
+                key = strings.TrimRight(string(raw), "\r\n")
+                if key == "" {
+                        return nil, fmt.Errorf("hash key file %s is empty", name)
+                }
+        default:
+                return nil, fmt.Errorf("invalid hash key source '%s' (use env:<variable> or file:<path>)", keySource)
+        }
+        return []byte(key), nil
+}
+
+func (s *hashStep) String() string {
+        return s.source
+}
+
+func (s *hashStep) Apply(r *Record) (bool, error) {
+        return true, s.update(r, func(text string) string {
+                mac := hmac.New(sha256.New, s.key)
+                mac.Write([]byte(text))
+                return hex.EncodeToString(mac.Sum(nil))
+        })
+}
+
+type setHeaderStep struct {
+        source string
+        name   string
+        value  *Template
+}
+
+func newSetHeaderStep(source, arg string) (*setHeaderStep, error) {
+        name, value, found := strings.Cut(arg, "=")
+        name = strings.TrimSpace(name)
+        if !found || name == "" {
+                return nil, fmt.Errorf("expected <name>=<value>")
+        }
+        template, err := ParseTemplate(value)
+        if err != nil {
+                return nil, err
+        }
+        return &setHeaderStep{source: source, name: name, value: template}, nil
+}
+
+func (s *setHeaderStep) String() string {
+        return s.source
+}
+
+func (s *setHeaderStep) Apply(r *Record) (bool, error) {
+        value, err := s.value.Render(r)
+        if err != nil {
+                return false, err
+        }
+        r.SetHeader(s.name, []byte(value))
+        return true, nil
+}
+
+type removeHeaderStep struct {
+        source string
+        name   string
+}
+
+func (s *removeHeaderStep) String() string {
+        return s.source
+}
+
+func (s *removeHeaderStep) Apply(r *Record) (bool, error) {
+        r.RemoveHeader(s.name)
+        return true, nil
+}
+
+type renameHeaderStep struct {
+        source string
+        from   string
+        to     string
+}
+
+func newRenameHeaderStep(source, arg string) (*renameHeaderStep, error) {
+        from, to, found := strings.Cut(arg, "=")
+        from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+        if !found || from == "" || to == "" {
+                return nil, fmt.Errorf("expected <old>=<new>")
+        }
+        return &renameHeaderStep{source: source, from: from, to: to}, nil
+}
+
+func (s *renameHeaderStep) String() string {
+        return s.source
+}
+
+func (s *renameHeaderStep) Apply(r *Record) (bool, error) {
+        for i := range r.Headers {
+                if r.Headers[i].Key == s.from {
+                        r.Headers[i].Key = s.to
+                }
+        }
+        return true, nil
+}
+
+type keyFromStep struct {
+        source  string
+        operand operand
+}
+
+func newKeyFromStep(source, arg string) (*keyFromStep, error) {
+        arg = strings.TrimSpace(arg)
+        if !strings.HasPrefix(arg, "$") && !strings.HasPrefix(arg, ".") && !strings.HasPrefix(arg, "header.") && !strings.HasPrefix(arg, "headers.") {
+                // key-from:customer.id reads the field, like --key-field
+                arg = "." + arg
+        }
+        operand, err := parseOperand(arg)
+        if err != nil {
+                return nil, err
+        }
+        return &keyFromStep{source: source, operand: operand}, nil
+}
+
+func (s *keyFromStep) String() string {
+        return s.source
+}
+
+func (s *keyFromStep) Apply(r *Record) (bool, error) {
+        key, present, err := s.operand.resolve(r)
+        if err != nil {
+                return false, err
+        }
+        if !present {
+                return false, fmt.Errorf("%s is not set", s.operand.source)
+        }
+        r.Key = []byte(key)
+        return true, nil
+}
+
+type routeStep struct {
+        source    string
+        condition *Condition // nil routes every record
+        topic     *Template
+}
+
+func newRouteStep(source, arg string) (*routeStep, error) {
+        step := &routeStep{source: source}
+        target := arg
+        if i := strings.LastIndex(arg, "->"); i >= 0 {
+                condition, err := ParseCondition(arg[:i])
+                if err != nil {
+                        return nil, err
+                }
+                step.condition, target = condition, arg[i+2:]
+        }
+        target = strings.TrimSpace(target)
+        if target == "" {
+                return nil, fmt.Errorf("missing destination topic")
+        }
+        topic, err := ParseTemplate(target)
+        if err != nil {
+                return nil, err
+        }
+        step.topic = topic
+        return step, nil
+}
+
+func (s *routeStep) String() string {
+        return s.source
+}
+
+func (s *routeStep) Apply(r *Record) (bool, error) {
+        if s.condition != nil {
+                match, err := s.condition.Match(r)
+                if err != nil || !match {
+                        return true, err
+                }
+        }
+        topic, err := s.topic.Render(r)
+        if err != nil {
+                return false, err
+        }
+        if topic == "" {
+                return false, fmt.Errorf("route produced an empty topic name")
+        }
+        r.Topic = topic
+        // The partition belonged to the old topic
+        r.Partition = -1
+        return true, nil
+}
+
+type dropStep struct {
+        source    string
+        condition *Condition
+}
+
+func newDropStep(source, arg string) (*dropStep, error) {
+        condition, err := ParseCondition(arg)
+        if err != nil {
+                return nil, err
+        }
+        return &dropStep{source: source, condition: condition}, nil
+}
+
+func (s *dropStep) String() string {
+        return s.source
+}
+
+func (s *dropStep) Apply(r *Record) (bool, error) {
+        match, err := s.condition.Match(r)
+        if err != nil {
+                return false, err
+        }
+        return !match, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+        "errors"
         "os"
 
         "kafy/cmd"
@@ -10,6 +11,10 @@ func main() {
         if err := cmd.Execute(); err != nil {
                 // Error is already printed by cobra with usage/help
                 // Just exit with error code
+                var exitErr *cmd.ExitError
+                if errors.As(err, &exitErr) {
+                        os.Exit(exitErr.Code)
+                }
                 os.Exit(1)
         }
 }
\ No newline at end of file